@@ -6,23 +6,28 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"log"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/azure/azure-dev/cli/azd/pkg/azureutil"
 	"github.com/azure/azure-dev/cli/azd/pkg/commands"
 	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/ext"
 	"github.com/azure/azure-dev/cli/azd/pkg/output"
 	"github.com/azure/azure-dev/cli/azd/pkg/project"
 	"github.com/azure/azure-dev/cli/azd/pkg/spin"
 	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 )
 
 func deployCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
+	action := &deployAction{rootOptions: rootOptions}
 	cmd := commands.Build(
-		&deployAction{rootOptions: rootOptions},
+		action,
 		rootOptions,
 		"deploy",
 		"Deploy the application's code to Azure.",
@@ -30,15 +35,33 @@ func deployCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
 
 When no `+withBackticks("--service")+` value is specified, all services in the *azure.yaml* file (found in the root of your project) are deployed.
 
+Services whose source has not changed since they were last deployed to the environment are skipped. Files excluded by *.gitignore* or *.azdignore* files are not considered. Use `+withBackticks("--force")+` to deploy every service regardless.
+
 Examples:
 
 	$ azd deploy
 	$ azd deploy –-service api
 	$ azd deploy –-service web
+	$ azd deploy --force
+	$ azd deploy --from-package .azure/packages/20220101120000
 	
 After the deployment is complete, the endpoint is printed. To start the service, select the endpoint or paste it in a browser.`,
 	)
 
+	cmd.AddCommand(output.AddOutputParam(
+		deployHistoryCmd(rootOptions),
+		[]output.Format{output.JsonFormat, output.TableFormat},
+		output.TableFormat,
+	))
+	cmd.AddCommand(output.AddOutputParam(
+		deployRollbackCmd(rootOptions),
+		[]output.Format{output.JsonFormat, output.NoneFormat},
+		output.NoneFormat,
+	))
+
+	// --from-package is not part of SetupFlags, since the action is also used by `up`, which always deploys from source.
+	cmd.Flags().StringVar(&action.fromPackage, "from-package", "", "Deploys the artifacts created by "+withBackticks("azd package")+" at the given path instead of packaging the services.")
+
 	return output.AddOutputParam(
 		cmd,
 		[]output.Format{output.JsonFormat, output.NoneFormat},
@@ -47,6 +70,9 @@ After the deployment is complete, the endpoint is printed. To start the service,
 
 type deployAction struct {
 	serviceName string
+	parallelism int
+	force       bool
+	fromPackage string
 	rootOptions *commands.GlobalCommandOptions
 }
 
@@ -55,11 +81,17 @@ type DeploymentResult struct {
 	Services  []project.ServiceDeploymentResult `json:"services"`
 }
 
+// defaultDeployParallelism is the number of services deployed at the same time by default, which is bounded to limit the
+// load on the machine building the services and on the hosts they are deployed to.
+const defaultDeployParallelism = 4
+
 func (d *deployAction) SetupFlags(
 	persis *pflag.FlagSet,
 	local *pflag.FlagSet,
 ) {
 	local.StringVar(&d.serviceName, "service", "", "Deploys a specific service (when the string is unspecified, all services that are listed in the "+environment.ProjectFileName+" file are deployed).")
+	local.BoolVar(&d.force, "force", false, "Deploys all services, including services which have not changed since they were last deployed.")
+	local.IntVar(&d.parallelism, "parallelism", defaultDeployParallelism, "The maximum number of services to deploy at the same time. Services are always deployed after the services they depend on.")
 }
 
 func (d *deployAction) Run(ctx context.Context, cmd *cobra.Command, args []string, azdCtx *environment.AzdContext) error {
@@ -83,6 +115,10 @@ func (d *deployAction) Run(ctx context.Context, cmd *cobra.Command, args []strin
 		return fmt.Errorf("loading environment: %w", err)
 	}
 
+	if err := ensureEnvironmentValues(ctx, azdCtx, &env, askOne); err != nil {
+		return fmt.Errorf("validating environment: %w", err)
+	}
+
 	projConfig, err := project.LoadProjectConfig(azdCtx.ProjectPath(), &env)
 	if err != nil {
 		return fmt.Errorf("loading project: %w", err)
@@ -92,18 +128,33 @@ func (d *deployAction) Run(ctx context.Context, cmd *cobra.Command, args []strin
 		return fmt.Errorf("service name '%s' doesn't exist", d.serviceName)
 	}
 
+	var manifest *project.PackageManifest
+	var packageDir string
+	if d.fromPackage != "" {
+		manifest, packageDir, err = project.LoadPackageManifest(d.fromPackage)
+		if err != nil {
+			return err
+		}
+	}
+
 	proj, err := projConfig.GetProject(ctx, &env)
 	if err != nil {
 		return fmt.Errorf("creating project: %w", err)
 	}
+	proj.History = newDeploymentHistory(azdCtx, env)
 
 	// Collect all the tools we will need to do the deployment and validate that
 	// the are installed. When a single project is being deployed, we need just
 	// the tools for that project, otherwise we need the tools from all project.
+	// Services deployed from a package are not built, so only the tools of the target are needed.
 	var allTools []tools.ExternalTool
 	for _, svc := range proj.Services {
 		if d.serviceName == "" || d.serviceName == svc.Config.Name {
-			allTools = append(allTools, svc.RequiredExternalTools()...)
+			if manifest != nil {
+				allTools = append(allTools, svc.Target.RequiredExternalTools()...)
+			} else {
+				allTools = append(allTools, svc.RequiredExternalTools()...)
+			}
 		}
 	}
 
@@ -116,10 +167,9 @@ func (d *deployAction) Run(ctx context.Context, cmd *cobra.Command, args []strin
 		return err
 	}
 	interactive := formatter.Kind() == output.NoneFormat
+	hooksOut := hooksOutput(formatter)
 
-	var svcDeploymentResult project.ServiceDeploymentResult
-	var deploymentResults []project.ServiceDeploymentResult
-
+	var servicesToDeploy []string
 	for _, svc := range proj.Services {
 		// Skip this service if both cases are true:
 		// 1. The user specified a service name
@@ -128,8 +178,79 @@ func (d *deployAction) Run(ctx context.Context, cmd *cobra.Command, args []strin
 			continue
 		}
 
-		deployAndReportProgress := func(showProgress func(string)) error {
-			result, progress := svc.Deploy(ctx, azdCtx)
+		if manifest != nil {
+			// Services azd package skips are not deployed from the package either.
+			if d.serviceName == "" && !svc.Config.SupportsPackage() {
+				fmt.Fprintf(cmd.ErrOrStderr(),
+					"WARNING: service %s is not deployed, since it is hosted by %s, which does not support deploying "+
+						"from a package.\n", svc.Config.Name, svc.Config.Host)
+				continue
+			}
+
+			if _, has := manifest.Services[svc.Config.Name]; !has {
+				return fmt.Errorf("the package at %s does not contain service '%s'", packageDir, svc.Config.Name)
+			}
+		}
+
+		servicesToDeploy = append(servicesToDeploy, svc.Config.Name)
+	}
+
+	projectHooks := ext.NewHooksRunner(projConfig.Hooks, projConfig.Path, &env, ext.HooksRunnerArgs{Output: hooksOut})
+	if err := projectHooks.Run(ctx, ext.PreDeployHook); err != nil {
+		return err
+	}
+
+	// When services are deployed one at a time, progress is reported with a spinner. When several services may be
+	// deployed at the same time, each progress message is printed on its own line, prefixed with the service name.
+	concurrent := d.parallelism > 1 && len(servicesToDeploy) > 1
+
+	var outputMu sync.Mutex
+	deploymentResults := map[string]project.ServiceDeploymentResult{}
+
+	deployService := func(ctx context.Context, name string) error {
+		svc := proj.GetService(name)
+
+		// Services deployed from a package are always deployed, since the package is not built from the source.
+		if manifest == nil && !d.force {
+			unchanged, err := svc.UnchangedDeployment()
+			if err != nil {
+				return fmt.Errorf("checking service %s for changes: %w", name, err)
+			}
+
+			if unchanged != nil {
+				svcDeploymentResult := project.ServiceDeploymentResult{
+					TargetResourceId: unchanged.TargetResourceId,
+					Kind:             svc.Config.GetServiceTargetKind(),
+					Endpoints:        unchanged.Endpoints,
+					Image:            unchanged.Package.Image,
+					Skipped:          true,
+				}
+
+				outputMu.Lock()
+				defer outputMu.Unlock()
+
+				deploymentResults[name] = svcDeploymentResult
+				if interactive {
+					reportServiceSkippedInteractive(svc, unchanged, &svcDeploymentResult)
+				}
+
+				return nil
+			}
+		}
+
+		serviceHooks := ext.NewHooksRunner(svc.Config.Hooks, svc.Config.Path(), &env, ext.HooksRunnerArgs{Output: hooksOut})
+		if err := serviceHooks.Run(ctx, ext.PreDeployHook); err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+
+		deployAndReportProgress := func(showProgress func(string)) (*project.ServiceDeploymentResult, error) {
+			var result <-chan *project.ServiceDeploymentChannelResponse
+			var progress <-chan string
+			if manifest != nil {
+				result, progress = svc.DeployPackage(ctx, azdCtx, packageDir, manifest.Services[name])
+			} else {
+				result, progress = svc.Deploy(ctx, azdCtx)
+			}
 
 			// Report any progress
 			go func() {
@@ -140,43 +261,112 @@ func (d *deployAction) Run(ctx context.Context, cmd *cobra.Command, args []strin
 
 			response := <-result
 			if response.Error != nil {
-				return fmt.Errorf("deploying service: %w", response.Error)
+				// The result may be reported along with the error, such as when the service is not healthy.
+				return response.Result, fmt.Errorf("deploying service %s: %w", name, response.Error)
 			}
 
-			svcDeploymentResult = *response.Result
-			deploymentResults = append(deploymentResults, svcDeploymentResult)
-
-			return nil
+			return response.Result, nil
 		}
 
-		if interactive {
-			deployMsg := fmt.Sprintf("Deploying service %s", svc.Config.Name)
+		var svcDeploymentResult *project.ServiceDeploymentResult
+		var err error
+
+		switch {
+		case interactive && concurrent:
+			printServiceProgress := func(message string) {
+				outputMu.Lock()
+				defer outputMu.Unlock()
+				fmt.Printf("%s %s\n", withServicePrefix(name), message)
+			}
+
+			printServiceProgress("Deploying service")
+			svcDeploymentResult, err = deployAndReportProgress(printServiceProgress)
+
+			if err == nil {
+				outputMu.Lock()
+				reportServiceDeploymentResultInteractive(svc, svcDeploymentResult)
+				outputMu.Unlock()
+			}
+		case interactive:
+			deployMsg := fmt.Sprintf("Deploying service %s", name)
 			fmt.Println(deployMsg)
 			spinner := spin.NewSpinner(deployMsg)
 			spinner.Start()
-			err = deployAndReportProgress(spinner.Title)
+			svcDeploymentResult, err = deployAndReportProgress(spinner.Title)
 			spinner.Stop()
 
 			if err == nil {
-				reportServiceDeploymentResultInteractive(svc, &svcDeploymentResult)
+				reportServiceDeploymentResultInteractive(svc, svcDeploymentResult)
 			}
-		} else {
-			err = deployAndReportProgress(nil)
+		default:
+			svcDeploymentResult, err = deployAndReportProgress(func(string) {})
 		}
 		if err != nil {
+			if svcDeploymentResult != nil {
+				outputMu.Lock()
+				deploymentResults[name] = *svcDeploymentResult
+				outputMu.Unlock()
+			}
+
 			return err
 		}
+
+		outputMu.Lock()
+		deploymentResults[name] = *svcDeploymentResult
+		outputMu.Unlock()
+
+		if err := serviceHooks.Run(ctx, ext.PostDeployHook); err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+
+		return nil
 	}
 
-	if formatter.Kind() == output.JsonFormat {
+	writeDeploymentResult := func() error {
+		if formatter.Kind() != output.JsonFormat {
+			return nil
+		}
+
+		// Report results in project order regardless of the order in which the deployments completed.
+		orderedResults := make([]project.ServiceDeploymentResult, 0, len(deploymentResults))
+		for _, name := range servicesToDeploy {
+			if result, has := deploymentResults[name]; has {
+				orderedResults = append(orderedResults, result)
+			}
+		}
+
 		aggregateDeploymentResult := DeploymentResult{
 			Timestamp: time.Now(),
-			Services:  deploymentResults,
+			Services:  orderedResults,
 		}
 
 		if fmtErr := formatter.Format(aggregateDeploymentResult, cmd.OutOrStdout(), nil); fmtErr != nil {
 			return fmt.Errorf("deployment result could not be displayed: %w", fmtErr)
 		}
+
+		return nil
+	}
+
+	if err := proj.Graph.Walk(ctx, servicesToDeploy, d.parallelism, deployService); err != nil {
+		// The results of the services which were deployed are still reported, including the health of the services
+		// which are not healthy.
+		if len(deploymentResults) == 0 {
+			return err
+		}
+
+		if writeErr := writeDeploymentResult(); writeErr != nil {
+			log.Printf("failed writing deployment result: %v", writeErr)
+		}
+
+		return err
+	}
+
+	if err := projectHooks.Run(ctx, ext.PostDeployHook); err != nil {
+		return err
+	}
+
+	if err := writeDeploymentResult(); err != nil {
+		return err
 	}
 
 	resourceGroups, err := azureutil.GetResourceGroupsForDeployment(ctx, azCli, env.GetSubscriptionId(), env.GetEnvName())
@@ -198,6 +388,34 @@ func (d *deployAction) Run(ctx context.Context, cmd *cobra.Command, args []strin
 	return nil
 }
 
+// withServicePrefix formats the name of a service as a colored prefix for progress messages. Each service is assigned a
+// color based on its name so its messages can be told apart when several services are deployed at the same time.
+func withServicePrefix(name string) string {
+	colors := []color.Attribute{color.FgCyan, color.FgMagenta, color.FgYellow, color.FgGreen, color.FgBlue}
+
+	hash := 0
+	for _, c := range name {
+		hash += int(c)
+	}
+
+	return color.New(colors[hash%len(colors)]).Sprintf("(%s)", name)
+}
+
+func reportServiceSkippedInteractive(svc *project.Service, unchanged *project.DeploymentRecord, sdr *project.ServiceDeploymentResult) {
+	var builder strings.Builder
+
+	builder.WriteString(fmt.Sprintf(
+		"Skipped service %s, it has not changed since deployment %s. Use %s to deploy it anyway.\n",
+		svc.Config.Name, unchanged.Id, withHighLightFormat("--force")))
+
+	for _, endpoint := range sdr.Endpoints {
+		builder.WriteString(fmt.Sprintf(" - Endpoint: %s\n", withLinkFormat(endpoint)))
+	}
+
+	printWithStyling(builder.String())
+	fmt.Println()
+}
+
 func reportServiceDeploymentResultInteractive(svc *project.Service, sdr *project.ServiceDeploymentResult) {
 	var builder strings.Builder
 
@@ -207,6 +425,10 @@ func reportServiceDeploymentResultInteractive(svc *project.Service, sdr *project
 		builder.WriteString(fmt.Sprintf(" - Endpoint: %s\n", withLinkFormat(endpoint)))
 	}
 
+	if sdr.Health != nil {
+		builder.WriteString(fmt.Sprintf(" - Healthy: %s responded with status %d\n", withLinkFormat("%s", sdr.Health.Url), sdr.Health.Status))
+	}
+
 	printWithStyling(builder.String())
 	fmt.Println()
 }
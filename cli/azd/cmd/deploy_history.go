@@ -0,0 +1,261 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/commands"
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/output"
+	"github.com/azure/azure-dev/cli/azd/pkg/project"
+	"github.com/azure/azure-dev/cli/azd/pkg/spin"
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// newDeploymentHistory returns the deployment history of an environment, which is kept in the environment directory.
+func newDeploymentHistory(azdCtx *environment.AzdContext, env environment.Environment) *project.DeploymentHistory {
+	return project.NewDeploymentHistory(filepath.Join(azdCtx.EnvironmentDirectory(), env.GetEnvName()))
+}
+
+func deployHistoryCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
+	action := &deployHistoryAction{rootOptions: rootOptions}
+	return commands.Build(
+		action,
+		rootOptions,
+		"history",
+		"List the deployments of the application's services.",
+		`List the deployments of the application's services to the environment, from oldest to newest.
+
+The id of a deployment can be passed to `+withBackticks("azd deploy rollback --to <id>")+` to redeploy its artifact.`,
+	)
+}
+
+type deployHistoryAction struct {
+	serviceName string
+	rootOptions *commands.GlobalCommandOptions
+}
+
+func (h *deployHistoryAction) SetupFlags(persis, local *pflag.FlagSet) {
+	local.StringVar(&h.serviceName, "service", "", "Lists the deployments of a specific service.")
+}
+
+func (h *deployHistoryAction) Run(ctx context.Context, cmd *cobra.Command, args []string, azdCtx *environment.AzdContext) error {
+	if err := ensureProject(azdCtx.ProjectPath()); err != nil {
+		return err
+	}
+
+	env, err := loadEnvironmentIfExists(h.rootOptions.EnvironmentName, azdCtx)
+	if err != nil {
+		return fmt.Errorf("loading environment: %w", err)
+	}
+
+	if env.GetEnvName() == "" {
+		return errors.New("no environment exists, create one with `azd env new` or `azd init`")
+	}
+
+	records, err := newDeploymentHistory(azdCtx, env).List()
+	if err != nil {
+		return err
+	}
+
+	if h.serviceName != "" {
+		filtered := []project.DeploymentRecord{}
+		for _, record := range records {
+			if record.Service == h.serviceName {
+				filtered = append(filtered, record)
+			}
+		}
+		records = filtered
+	}
+
+	formatter, err := output.GetFormatter(cmd)
+	if err != nil {
+		return err
+	}
+
+	if formatter.Kind() == output.TableFormat {
+		columns := []output.Column{
+			{
+				Heading:       "ID",
+				ValueTemplate: "{{.Id}}",
+			},
+			{
+				Heading:       "SERVICE",
+				ValueTemplate: "{{.Service}}",
+			},
+			{
+				Heading:       "TIMESTAMP",
+				ValueTemplate: `{{.Timestamp.Format "2006-01-02 15:04:05"}}`,
+			},
+			{
+				Heading:       "ARTIFACT",
+				ValueTemplate: `{{if .Package.Image}}{{.Package.Image}}{{else if .ArtifactHash}}sha256:{{slice .ArtifactHash 0 12}}{{else}}-{{end}}`,
+			},
+			{
+				Heading:       "COMMIT",
+				ValueTemplate: `{{if .GitCommit}}{{slice .GitCommit 0 7}}{{else}}-{{end}}`,
+			},
+			{
+				Heading:       "HEALTH",
+				ValueTemplate: `{{if not .Health}}-{{else if .Health.Healthy}}healthy{{else}}unhealthy{{end}}`,
+			},
+		}
+
+		return formatter.Format(records, cmd.OutOrStdout(), output.TableFormatterOptions{
+			Columns: columns,
+		})
+	}
+
+	return formatter.Format(records, cmd.OutOrStdout(), nil)
+}
+
+func deployRollbackCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
+	return commands.Build(
+		&deployRollbackAction{rootOptions: rootOptions},
+		rootOptions,
+		"rollback",
+		"Redeploy the artifact of a previous deployment of a service.",
+		`Redeploy the artifact of a previous deployment of a service.
+
+When no `+withBackticks("--to")+` value is specified, the deployment before the most recent one is redeployed. Use `+withBackticks("azd deploy history")+` to list the deployments.
+
+Examples:
+
+	$ azd deploy rollback --service api
+	$ azd deploy rollback --service api --to 20220101120000-api`,
+	)
+}
+
+type deployRollbackAction struct {
+	serviceName  string
+	deploymentId string
+	rootOptions  *commands.GlobalCommandOptions
+}
+
+func (r *deployRollbackAction) SetupFlags(persis, local *pflag.FlagSet) {
+	local.StringVar(&r.serviceName, "service", "", "The service to roll back.")
+	local.StringVar(&r.deploymentId, "to", "", "The id of the deployment to roll back to. Defaults to the deployment before the most recent one.")
+}
+
+func (r *deployRollbackAction) Run(ctx context.Context, cmd *cobra.Command, args []string, azdCtx *environment.AzdContext) error {
+	azCli := commands.GetAzCliFromContext(ctx)
+	askOne := makeAskOne(r.rootOptions.NoPrompt)
+
+	if r.serviceName == "" {
+		return errors.New("the service to roll back must be specified with --service")
+	}
+
+	if err := ensureProject(azdCtx.ProjectPath()); err != nil {
+		return err
+	}
+
+	if err := tools.EnsureInstalled(ctx, azCli); err != nil {
+		return err
+	}
+
+	if err := ensureLoggedIn(ctx); err != nil {
+		return fmt.Errorf("failed to ensure login: %w", err)
+	}
+
+	env, err := loadOrInitEnvironment(ctx, &r.rootOptions.EnvironmentName, azdCtx, askOne)
+	if err != nil {
+		return fmt.Errorf("loading environment: %w", err)
+	}
+
+	projConfig, err := project.LoadProjectConfig(azdCtx.ProjectPath(), &env)
+	if err != nil {
+		return fmt.Errorf("loading project: %w", err)
+	}
+
+	if !projConfig.HasService(r.serviceName) {
+		return fmt.Errorf("service name '%s' doesn't exist", r.serviceName)
+	}
+
+	history := newDeploymentHistory(azdCtx, env)
+
+	var record *project.DeploymentRecord
+	if r.deploymentId != "" {
+		record, err = history.Get(r.deploymentId)
+	} else {
+		record, err = history.Previous(r.serviceName)
+	}
+	if err != nil {
+		return err
+	}
+
+	if record.Service != r.serviceName {
+		return fmt.Errorf("deployment '%s' is a deployment of service '%s', not '%s'", record.Id, record.Service, r.serviceName)
+	}
+
+	if !record.CanRollback() {
+		return fmt.Errorf("the artifact of deployment '%s' is no longer available, so it cannot be redeployed", record.Id)
+	}
+
+	proj, err := projConfig.GetProject(ctx, &env)
+	if err != nil {
+		return fmt.Errorf("creating project: %w", err)
+	}
+	proj.History = history
+
+	svc := proj.GetService(r.serviceName)
+	if err := tools.EnsureInstalled(ctx, tools.Unique(svc.Target.RequiredExternalTools())...); err != nil {
+		return err
+	}
+
+	formatter, err := output.GetFormatter(cmd)
+	if err != nil {
+		return err
+	}
+	interactive := formatter.Kind() == output.NoneFormat
+
+	deployAndReportProgress := func(showProgress func(string)) (*project.ServiceDeploymentResult, error) {
+		result, progress := svc.DeployPackage(ctx, azdCtx, history.Dir(), record.Package)
+
+		// Report any progress
+		go func() {
+			for message := range progress {
+				showProgress(fmt.Sprintf("- %s...", message))
+			}
+		}()
+
+		response := <-result
+		if response.Error != nil {
+			return nil, fmt.Errorf("rolling back service %s: %w", r.serviceName, response.Error)
+		}
+
+		return response.Result, nil
+	}
+
+	var svcDeploymentResult *project.ServiceDeploymentResult
+	if interactive {
+		rollbackMsg := fmt.Sprintf("Rolling back service %s to deployment %s", r.serviceName, record.Id)
+		fmt.Println(rollbackMsg)
+		spinner := spin.NewSpinner(rollbackMsg)
+		spinner.Start()
+		svcDeploymentResult, err = deployAndReportProgress(spinner.Title)
+		spinner.Stop()
+	} else {
+		svcDeploymentResult, err = deployAndReportProgress(func(string) {})
+	}
+	if err != nil {
+		return err
+	}
+
+	if formatter.Kind() == output.JsonFormat {
+		if fmtErr := formatter.Format(svcDeploymentResult, cmd.OutOrStdout(), nil); fmtErr != nil {
+			return fmt.Errorf("deployment result could not be displayed: %w", fmtErr)
+		}
+
+		return nil
+	}
+
+	reportServiceDeploymentResultInteractive(svc, svcDeploymentResult)
+	return nil
+}
@@ -7,12 +7,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"path/filepath"
+	"sort"
+
+	"github.com/AlecAivazis/survey/v2"
 
 	"github.com/azure/azure-dev/cli/azd/pkg/commands"
 	"github.com/azure/azure-dev/cli/azd/pkg/environment"
-	"github.com/azure/azure-dev/cli/azd/pkg/iac/bicep"
 	"github.com/azure/azure-dev/cli/azd/pkg/output"
+	"github.com/azure/azure-dev/cli/azd/pkg/project"
+	"github.com/azure/azure-dev/cli/azd/pkg/state"
 	"github.com/azure/azure-dev/cli/azd/pkg/tools"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -24,7 +27,7 @@ func envCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
 		Short: "Manage environments.",
 		Long: `Manage environments.
 
-With this command group, you can create a new environment or get, set, and list your application environments. An application can have multiple environments (for example, dev, test, prod), each with a different configuration (that is, connectivity information) for accessing Azure resources. 
+With this command group, you can create a new environment or get, set, list, describe, copy, compare, export, import, rename and delete your application environments. An application can have multiple environments (for example, dev, test, prod), each with a different configuration (that is, connectivity information) for accessing Azure resources. 
 
 You can find all environment configurations under the *.azure\<environment-name>* folder. The environment name is stored as the AZURE_ENV_NAME environment variable in the *.azure\<environment-name>\folder\.env* file.`,
 	}
@@ -48,55 +51,174 @@ You can find all environment configurations under the *.azure\<environment-name>
 		[]output.Format{output.JsonFormat, output.EnvVarsFormat},
 		output.EnvVarsFormat,
 	))
+	root.AddCommand(envDeleteCmd(rootOptions))
+	root.AddCommand(envCopyCmd(rootOptions))
+	root.AddCommand(envRenameCmd(rootOptions))
+	root.AddCommand(output.AddOutputParam(
+		envDiffCmd(rootOptions),
+		[]output.Format{output.JsonFormat, output.TableFormat},
+		output.TableFormat,
+	))
+	root.AddCommand(output.AddOutputParam(
+		envExportCmd(rootOptions),
+		[]output.Format{output.JsonFormat, output.EnvVarsFormat},
+		output.EnvVarsFormat,
+	))
+	root.AddCommand(envImportCmd(rootOptions))
+	root.AddCommand(envPullCmd(rootOptions))
+	root.AddCommand(envPushCmd(rootOptions))
+	root.AddCommand(output.AddOutputParam(
+		envDescribeCmd(rootOptions),
+		[]output.Format{output.JsonFormat, output.TableFormat},
+		output.TableFormat,
+	))
 
 	return root
 }
 
 func envSetCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
-	actionFn := func(ctx context.Context, _ *cobra.Command, args []string, azdCtx *environment.AzdContext) error {
-		askOne := makeAskOne(rootOptions.NoPrompt)
-		azCli := commands.GetAzCliFromContext(ctx)
+	cmd := commands.Build(
+		&envSetAction{rootOptions: rootOptions},
+		rootOptions,
+		"set <key> [<value>]",
+		"Set a value in the environment.",
+		`Set a value in the environment.
 
-		if err := ensureProject(azdCtx.ProjectPath()); err != nil {
+With --secret, the value is stored as a secret of a Key Vault, and the environment only stores a reference to it, such as
+akvs://<vault>/<secret>. Secrets are resolved when they are used, by hooks, builds and the parameters of Bicep
+templates, and are never written to disk. Bicep templates get the secrets from Key Vault when they are deployed, which
+requires the vault to be enabled for template deployment. The value is prompted for when it is not given.
+
+Examples:
+
+	$ azd env set MY_SETTING value
+	$ azd env set --secret DB_PASSWORD`,
+	)
+	cmd.Args = cobra.RangeArgs(1, 2)
+	return cmd
+}
+
+type envSetAction struct {
+	secret      bool
+	vaultName   string
+	rootOptions *commands.GlobalCommandOptions
+}
+
+func (e *envSetAction) SetupFlags(
+	persis *pflag.FlagSet,
+	local *pflag.FlagSet,
+) {
+	local.BoolVar(&e.secret, "secret", false, "Stores the value in Key Vault, and a reference to it in the environment.")
+	local.StringVar(&e.vaultName, "vault", "", "The name of the Key Vault to store the secret in (when unspecified, the vault named by "+environment.KeyVaultNameEnvVarName+" is used).")
+}
+
+func (e *envSetAction) Run(ctx context.Context, _ *cobra.Command, args []string, azdCtx *environment.AzdContext) error {
+	askOne := makeAskOne(e.rootOptions.NoPrompt)
+	azCli := commands.GetAzCliFromContext(ctx)
+
+	if !e.secret && len(args) != 2 {
+		return errors.New("a value is required, unless --secret is set")
+	}
+
+	if e.vaultName != "" && !e.secret {
+		return errors.New("--vault can only be used with --secret")
+	}
+
+	if err := ensureProject(azdCtx.ProjectPath()); err != nil {
+		return err
+	}
+
+	if err := tools.EnsureInstalled(ctx, azCli); err != nil {
+		return err
+	}
+
+	env, err := loadOrInitEnvironment(ctx, &e.rootOptions.EnvironmentName, azdCtx, askOne)
+	if err != nil {
+		return fmt.Errorf("loading environment: %w", err)
+	}
+
+	name := args[0]
+
+	if !e.secret {
+		if err := validateEnvironmentValue(azdCtx, &env, name, args[1]); err != nil {
 			return err
 		}
 
-		if err := tools.EnsureInstalled(ctx, azCli); err != nil {
-			return err
+		env.SetUserValue(name, args[1])
+	} else {
+		vaultName := e.vaultName
+		if vaultName == "" {
+			vaultName = env.Values[environment.KeyVaultNameEnvVarName]
 		}
 
-		env, err := loadOrInitEnvironment(ctx, &rootOptions.EnvironmentName, azdCtx, askOne)
-		if err != nil {
-			return fmt.Errorf("loading environment: %w", err)
+		if vaultName == "" {
+			return fmt.Errorf("there is no Key Vault to store the secret in, use --vault or set %s",
+				environment.KeyVaultNameEnvVarName)
 		}
 
-		env.Values[args[0]] = args[1]
+		var value string
+		if len(args) == 2 {
+			value = args[1]
+		} else if err := askOne(&survey.Password{
+			Message: fmt.Sprintf("Please enter the value of secret %s:", name),
+		}, &value); err != nil {
+			return fmt.Errorf("prompting for secret value: %w", err)
+		}
 
-		if err := env.Save(); err != nil {
-			return fmt.Errorf("saving environment: %w", err)
+		reference, err := setSecretValue(ctx, &env, vaultName, name, value)
+		if err != nil {
+			return err
 		}
 
-		return nil
+		fmt.Printf("Stored the value of %s as secret %s of Key Vault %s.\n", name, reference.SecretName, reference.VaultName)
 	}
 
-	cmd := commands.Build(
-		commands.ActionFunc(actionFn),
-		rootOptions,
-		"set <key> <value>",
-		"Set a value in the environment.",
-		"",
-	)
-	cmd.Args = cobra.ExactArgs(2)
-	return cmd
+	if err := env.Save(); err != nil {
+		return fmt.Errorf("saving environment: %w", err)
+	}
+
+	return nil
+}
+
+// setSecretValue stores value as a secret of a Key Vault, and sets the value name of env to a reference to the secret.
+func setSecretValue(
+	ctx context.Context, env *environment.Environment, vaultName string, name string, value string,
+) (environment.KeyVaultSecretReference, error) {
+	if err := ensureLoggedIn(ctx); err != nil {
+		return environment.KeyVaultSecretReference{}, fmt.Errorf("failed to ensure login: %w", err)
+	}
+
+	reference := environment.KeyVaultSecretReference{
+		VaultName:  vaultName,
+		SecretName: environment.SecretName(env.GetEnvName(), name),
+	}
+
+	if err := commands.GetAzCliFromContext(ctx).SetKeyVaultSecret(ctx, reference.VaultName, reference.SecretName, value); err != nil {
+		return environment.KeyVaultSecretReference{}, err
+	}
+
+	env.SetUserValue(name, reference.String())
+	return reference, nil
 }
 
 func envSelectCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
 	action := commands.ActionFunc(
-		func(_ context.Context, _ *cobra.Command, args []string, azdCtx *environment.AzdContext) error {
+		func(ctx context.Context, _ *cobra.Command, args []string, azdCtx *environment.AzdContext) error {
 			if err := ensureProject(azdCtx.ProjectPath()); err != nil {
 				return err
 			}
 
+			store, err := newRemoteEnvironmentStore(ctx, azdCtx)
+			if err != nil {
+				return err
+			}
+
+			if store != nil {
+				if _, err := state.Pull(ctx, store, azdCtx, args[0], false); err != nil {
+					return fmt.Errorf("pulling environment: %w", err)
+				}
+			}
+
 			if err := azdCtx.SetDefaultEnvironmentName(args[0]); err != nil {
 				return fmt.Errorf("setting default environment: %w", err)
 			}
@@ -109,24 +231,16 @@ func envSelectCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
 		rootOptions,
 		"select <environment>",
 		"Set the default environment.",
-		"",
+		"Set the default environment. When the project has a remote state, the environment is pulled from it first.",
 	)
 	cmd.Args = cobra.ExactArgs(1)
 	return cmd
 }
 
 func envListCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
-	cmd := &cobra.Command{
-		Use:     "list",
-		Short:   "List environments.",
-		Aliases: []string{"ls"},
-		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx, err := environment.NewAzdContext()
-			if err != nil {
-				return fmt.Errorf("failed to get the current directory: %w", err)
-			}
-
-			if err := ensureProject(ctx.ProjectPath()); err != nil {
+	action := commands.ActionFunc(
+		func(ctx context.Context, cmd *cobra.Command, _ []string, azdCtx *environment.AzdContext) error {
+			if err := ensureProject(azdCtx.ProjectPath()); err != nil {
 				return err
 			}
 
@@ -135,11 +249,25 @@ func envListCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
 				return err
 			}
 
-			envs, err := ctx.ListEnvironments()
+			envs, err := azdCtx.ListEnvironments()
 			if err != nil {
 				return fmt.Errorf("listing environments: %w", err)
 			}
 
+			store, err := newRemoteEnvironmentStore(ctx, azdCtx)
+			if err != nil {
+				return err
+			}
+
+			if store != nil {
+				remoteNames, err := store.List(ctx)
+				if err != nil {
+					return fmt.Errorf("listing remote environments: %w", err)
+				}
+
+				envs = withRemoteEnvironments(envs, remoteNames)
+			}
+
 			if formatter.Kind() == output.TableFormat {
 				columns := []output.Column{
 					{
@@ -152,6 +280,13 @@ func envListCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
 					},
 				}
 
+				if store != nil {
+					columns = append(columns, output.Column{
+						Heading:       "REMOTE",
+						ValueTemplate: "{{.IsRemote}}",
+					})
+				}
+
 				err = formatter.Format(envs, cmd.OutOrStdout(), output.TableFormatterOptions{
 					Columns: columns,
 				})
@@ -164,11 +299,70 @@ func envListCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
 
 			return nil
 		},
-	}
-	cmd.Flags().BoolP("help", "h", false, fmt.Sprintf("Gets help for %s.", cmd.Name()))
+	)
+	cmd := commands.Build(
+		action,
+		rootOptions,
+		"list",
+		"List environments.",
+		"List environments. When the project has a remote state, the environments which were not pulled yet are listed too.",
+	)
+	cmd.Aliases = []string{"ls"}
 	return cmd
 }
 
+// withRemoteEnvironments marks the local environments which are in the remote state, and adds the remote ones which
+// were not pulled yet.
+func withRemoteEnvironments(envs []environment.EnvironmentView, remoteNames []string) []environment.EnvironmentView {
+	isRemote := map[string]bool{}
+	for _, name := range remoteNames {
+		isRemote[name] = true
+	}
+
+	for i := range envs {
+		if isRemote[envs[i].Name] {
+			envs[i].IsRemote = true
+			delete(isRemote, envs[i].Name)
+		}
+	}
+
+	for name := range isRemote {
+		envs = append(envs, environment.EnvironmentView{Name: name, IsRemote: true})
+	}
+
+	sort.Slice(envs, func(i, j int) bool {
+		return envs[i].Name < envs[j].Name
+	})
+	return envs
+}
+
+// newRemoteEnvironmentStore returns the remote store of environments configured in azure.yaml, or nil when the
+// project only stores environments locally.
+func newRemoteEnvironmentStore(ctx context.Context, azdCtx *environment.AzdContext) (state.RemoteStore, error) {
+	env := environment.Empty("")
+	prj, err := project.LoadProjectConfig(azdCtx.ProjectPath(), &env)
+	if err != nil {
+		return nil, fmt.Errorf("loading project: %w", err)
+	}
+
+	if prj.State.Remote == nil {
+		return nil, nil
+	}
+
+	azCli := commands.GetAzCliFromContext(ctx)
+	if prj.State.Remote.Backend == state.AzureBlobStorage {
+		if err := tools.EnsureInstalled(ctx, azCli); err != nil {
+			return nil, err
+		}
+
+		if err := ensureLoggedIn(ctx); err != nil {
+			return nil, fmt.Errorf("failed to ensure login: %w", err)
+		}
+	}
+
+	return state.NewRemoteStore(*prj.State.Remote, prj.Name, azdCtx.ProjectDirectory(), azCli)
+}
+
 func envNewCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
 	cmd := commands.Build(
 		&envNewAction{rootOptions: rootOptions},
@@ -221,14 +415,13 @@ func (en *envNewAction) Run(ctx context.Context, _ *cobra.Command, args []string
 func envRefreshCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
 	actionFn := func(ctx context.Context, cmd *cobra.Command, args []string, azdCtx *environment.AzdContext) error {
 		azCli := commands.GetAzCliFromContext(ctx)
-		bicepCli := tools.NewBicepCli(azCli)
 		askOne := makeAskOne(rootOptions.NoPrompt)
 
 		if err := ensureProject(azdCtx.ProjectPath()); err != nil {
 			return err
 		}
 
-		if err := tools.EnsureInstalled(ctx, azCli, bicepCli); err != nil {
+		if err := tools.EnsureInstalled(ctx, azCli); err != nil {
 			return err
 		}
 
@@ -236,25 +429,49 @@ func envRefreshCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
 			return fmt.Errorf("failed to ensure login: %w", err)
 		}
 
+		store, err := newRemoteEnvironmentStore(ctx, azdCtx)
+		if err != nil {
+			return err
+		}
+
+		// The environment is pulled before it is loaded, so that environments which were not pulled yet are not
+		// created anew.
+		if store != nil {
+			envName := rootOptions.EnvironmentName
+			if envName == "" {
+				if envName, err = azdCtx.GetDefaultEnvironmentName(); err != nil {
+					return err
+				}
+			}
+
+			if envName != "" {
+				if _, err := state.Pull(ctx, store, azdCtx, envName, false); err != nil {
+					return fmt.Errorf("pulling environment: %w", err)
+				}
+			}
+		}
+
 		env, err := loadOrInitEnvironment(ctx, &rootOptions.EnvironmentName, azdCtx, askOne)
 		if err != nil {
 			return fmt.Errorf("loading environment: %w", err)
 		}
 
-		template, err := bicep.Compile(ctx, bicepCli, filepath.Join(azdCtx.InfrastructureDirectory(), "main.bicep"))
+		prj, err := project.LoadProjectConfig(azdCtx.ProjectPath(), &env)
+		if err != nil {
+			return fmt.Errorf("loading project: %w", err)
+		}
+
+		provider, err := newInfraProvider(ctx, azdCtx, &env, prj, askOne)
 		if err != nil {
 			return err
 		}
 
-		res, err := azCli.GetSubscriptionDeployment(ctx, env.GetSubscriptionId(), env.GetEnvName())
-		if errors.Is(err, tools.ErrDeploymentNotFound) {
-			return fmt.Errorf("no deployment for environment '%s' found. Have you run `infra create`?", rootOptions.EnvironmentName)
-		} else if err != nil {
-			return fmt.Errorf("fetching latest deployment: %w", err)
+		if err := tools.EnsureInstalled(ctx, provider.RequiredExternalTools()...); err != nil {
+			return err
 		}
 
-		template.CanonicalizeDeploymentOutputs(&res.Properties.Outputs)
-		if err = saveEnvironmentValues(res, env); err != nil {
+		res, err := provider.Outputs(ctx)
+		if err != nil {
 			return err
 		}
 
@@ -262,11 +479,25 @@ func envRefreshCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
 		if err != nil {
 			return err
 		}
+
+		changes, err := saveEnvironmentValues(prj.Infra.ModuleName(), res.Outputs, &env, hooksOutput(formatter))
+		if err != nil {
+			return err
+		}
+
+		if store != nil {
+			if err := state.Push(ctx, store, azdCtx, rootOptions.EnvironmentName, false); err != nil {
+				return fmt.Errorf("pushing environment: %w", err)
+			}
+		}
+
 		if formatter.Kind() == output.JsonFormat {
-			err = formatter.Format(res, cmd.OutOrStdout(), nil)
+			err = formatter.Format(res.Details, cmd.OutOrStdout(), nil)
 			if err != nil {
 				return fmt.Errorf("writing deployment result in JSON format: %w", err)
 			}
+		} else {
+			printOutputChanges(cmd.OutOrStdout(), changes)
 		}
 
 		return nil
@@ -277,7 +508,14 @@ func envRefreshCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
 		rootOptions,
 		"refresh",
 		"Refresh environment settings by using information from a previous infrastructure provision.",
-		"",
+		`Refresh environment settings by using information from a previous infrastructure provision.
+
+The outputs which were added, changed or removed since the environment was last refreshed or provisioned are reported.
+Outputs do not replace values of the same name set with azd env set, which are reported instead.
+
+When the project has a remote state, the environment is pulled from it first, and pushed to it once refreshed. Changes
+made both locally and remotely are reported as conflicts rather than overwritten, which are resolved with
+azd env pull --force or azd env push --force.`,
 	)
 }
 
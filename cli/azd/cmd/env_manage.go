@@ -0,0 +1,521 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/azure/azure-dev/cli/azd/pkg/commands"
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/infra/provisioning"
+	"github.com/azure/azure-dev/cli/azd/pkg/osutil"
+	"github.com/azure/azure-dev/cli/azd/pkg/output"
+	"github.com/azure/azure-dev/cli/azd/pkg/project"
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func envDeleteCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
+	cmd := commands.Build(
+		&envDeleteAction{rootOptions: rootOptions},
+		rootOptions,
+		"delete <environment>",
+		"Delete an environment.",
+		`Delete an environment.
+
+The Azure resources of the environment are kept, unless --down is set, in which case they are deleted first, as with
+azd down. The remote state of the environment, if the project has one, is kept.`,
+	)
+	cmd.Args = cobra.ExactArgs(1)
+	return cmd
+}
+
+type envDeleteAction struct {
+	down        bool
+	force       bool
+	purge       bool
+	rootOptions *commands.GlobalCommandOptions
+}
+
+func (a *envDeleteAction) SetupFlags(
+	persis *pflag.FlagSet,
+	local *pflag.FlagSet,
+) {
+	local.BoolVar(&a.down, "down", false, "Deletes the Azure resources of the environment first.")
+	local.BoolVar(&a.force, "force", false, "Does not require confirmation before it deletes the environment, or its resources with --down.")
+	local.BoolVar(&a.purge, "purge", false, "Permanently deletes resources that are soft-deleted by default (for example, key vaults), with --down.")
+}
+
+func (a *envDeleteAction) Run(ctx context.Context, _ *cobra.Command, args []string, azdCtx *environment.AzdContext) error {
+	if err := ensureProject(azdCtx.ProjectPath()); err != nil {
+		return err
+	}
+
+	if a.purge && !a.down {
+		return errors.New("--purge can only be used with --down")
+	}
+
+	name := args[0]
+	if !environment.IsValidEnvironmentName(name) {
+		return fmt.Errorf("environment name '%s' is invalid (it should contain only alphanumeric characters and hyphens)", name)
+	}
+
+	if _, err := azdCtx.GetEnvironment(name); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("environment '%s' does not exist", name)
+	}
+
+	if !a.force {
+		var ok bool
+		if err := makeAskOne(a.rootOptions.NoPrompt)(&survey.Confirm{
+			Message: fmt.Sprintf("This will delete environment '%s', are you sure you want to continue?", name),
+			Default: false,
+		}, &ok); err != nil {
+			return fmt.Errorf("prompting for confirmation: %w", err)
+		}
+
+		if !ok {
+			return nil
+		}
+	}
+
+	if a.down {
+		options := *a.rootOptions
+		options.EnvironmentName = name
+
+		destroyed, err := (&infraDeleteAction{
+			forceDelete: a.force,
+			purgeDelete: a.purge,
+			rootOptions: &options,
+		}).destroy(ctx, azdCtx)
+		if err != nil {
+			return err
+		}
+
+		// The user chose to keep the resources, which the environment is needed for.
+		if !destroyed {
+			return nil
+		}
+	}
+
+	return azdCtx.DeleteEnvironment(name)
+}
+
+func envCopyCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
+	action := commands.ActionFunc(
+		func(ctx context.Context, _ *cobra.Command, args []string, azdCtx *environment.AzdContext) error {
+			if err := ensureProject(azdCtx.ProjectPath()); err != nil {
+				return err
+			}
+
+			source, destination := args[0], args[1]
+
+			sourceEnv, err := azdCtx.GetEnvironment(source)
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("environment '%s' does not exist", source)
+			} else if err != nil {
+				return fmt.Errorf("loading environment '%s': %w", source, err)
+			}
+
+			if !environment.IsValidEnvironmentName(destination) {
+				return fmt.Errorf("environment name '%s' is invalid (it should contain only alphanumeric characters and hyphens)", destination)
+			}
+
+			if _, err := azdCtx.GetEnvironment(destination); err == nil {
+				return fmt.Errorf("environment '%s' already exists", destination)
+			}
+
+			// Environments provisioned before azd recorded which of their values are outputs have none recorded, so they
+			// are read from the latest deployment of the environment instead.
+			outputNames := sourceEnv.OutputNames()
+			if len(outputNames) == 0 {
+				outputNames, err = deploymentOutputNames(ctx, azdCtx, &sourceEnv, makeAskOne(rootOptions.NoPrompt))
+				if err != nil {
+					return err
+				}
+			}
+
+			if err := azdCtx.NewEnvironment(destination); err != nil {
+				return fmt.Errorf("creating environment '%s': %w", destination, err)
+			}
+
+			env := environment.Empty(azdCtx.GetEnvironmentFilePath(destination))
+			for name, value := range sourceEnv.Values {
+				if outputNames[name] {
+					continue
+				}
+
+				env.Values[name] = value
+				if metadata, has := sourceEnv.Metadata[name]; has {
+					env.Metadata[name] = metadata
+				}
+			}
+			env.SetEnvName(destination)
+
+			if err := env.Save(); err != nil {
+				return fmt.Errorf("saving environment: %w", err)
+			}
+
+			return nil
+		},
+	)
+	cmd := commands.Build(
+		action,
+		rootOptions,
+		"copy <source> <destination>",
+		"Copy an environment to a new environment.",
+		`Copy an environment to a new environment.
+
+The values of the source environment are copied, except the outputs of its deployments, which the new
+environment gets once it is provisioned. Values referencing Key Vault secrets are copied as is, so both environments
+reference the same secrets.`,
+	)
+	cmd.Args = cobra.ExactArgs(2)
+	return cmd
+}
+
+// deploymentOutputNames returns the names of the outputs of the latest deployment of env, which are none when the
+// environment was never provisioned.
+func deploymentOutputNames(
+	ctx context.Context, azdCtx *environment.AzdContext, env *environment.Environment, askOne Asker,
+) (map[string]bool, error) {
+	names := map[string]bool{}
+
+	// Environments are only provisioned once they have a subscription.
+	if env.GetSubscriptionId() == "" {
+		return names, nil
+	}
+
+	azCli := commands.GetAzCliFromContext(ctx)
+	if err := tools.EnsureInstalled(ctx, azCli); err != nil {
+		return nil, err
+	}
+
+	if err := ensureLoggedIn(ctx); err != nil {
+		return nil, fmt.Errorf("failed to ensure login: %w", err)
+	}
+
+	prj, err := project.LoadProjectConfig(azdCtx.ProjectPath(), env)
+	if err != nil {
+		return nil, fmt.Errorf("loading project: %w", err)
+	}
+
+	provider, err := newInfraProvider(ctx, azdCtx, env, prj, askOne)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tools.EnsureInstalled(ctx, provider.RequiredExternalTools()...); err != nil {
+		return nil, err
+	}
+
+	deployment, err := provider.Outputs(ctx)
+	var notFoundErr *provisioning.DeploymentNotFoundError
+	if errors.As(err, &notFoundErr) {
+		return names, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("getting outputs of environment '%s': %w", env.GetEnvName(), err)
+	}
+
+	for _, name := range deployment.OutputNames() {
+		names[name] = true
+	}
+
+	return names, nil
+}
+
+func envRenameCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
+	action := commands.ActionFunc(
+		func(_ context.Context, _ *cobra.Command, args []string, azdCtx *environment.AzdContext) error {
+			if err := ensureProject(azdCtx.ProjectPath()); err != nil {
+				return err
+			}
+
+			if err := azdCtx.RenameEnvironment(args[0], args[1]); err != nil {
+				return fmt.Errorf("renaming environment: %w", err)
+			}
+
+			return nil
+		},
+	)
+	cmd := commands.Build(
+		action,
+		rootOptions,
+		"rename <environment> <new-name>",
+		"Rename an environment.",
+		`Rename an environment.
+
+Only the local name of the environment changes. Its AZURE_ENV_NAME value is kept, so that it still deploys to the
+same Azure resources, which are named after it.`,
+	)
+	cmd.Args = cobra.ExactArgs(2)
+	return cmd
+}
+
+// envValueDifference is a value which differs between two environments, where a nil value is not set.
+type envValueDifference struct {
+	Name  string  `json:"name"`
+	Left  *string `json:"left"`
+	Right *string `json:"right"`
+}
+
+func envDiffCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
+	action := commands.ActionFunc(
+		func(_ context.Context, cmd *cobra.Command, args []string, azdCtx *environment.AzdContext) error {
+			if err := ensureProject(azdCtx.ProjectPath()); err != nil {
+				return err
+			}
+
+			formatter, err := output.GetFormatter(cmd)
+			if err != nil {
+				return err
+			}
+
+			var envs [2]environment.Environment
+			for i, name := range args {
+				env, err := azdCtx.GetEnvironment(name)
+				if errors.Is(err, os.ErrNotExist) {
+					return fmt.Errorf("environment '%s' does not exist", name)
+				} else if err != nil {
+					return fmt.Errorf("loading environment '%s': %w", name, err)
+				}
+
+				envs[i] = env
+			}
+
+			differences := diffEnvironmentValues(envs[0].Values, envs[1].Values)
+
+			if formatter.Kind() != output.TableFormat {
+				return formatter.Format(differences, cmd.OutOrStdout(), nil)
+			}
+
+			if len(differences) == 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "Environments '%s' and '%s' have the same values.\n", args[0], args[1])
+				return nil
+			}
+
+			rows := make([][]string, 0, len(differences))
+			for _, difference := range differences {
+				rows = append(rows, []string{difference.Name, formatEnvValue(difference.Left), formatEnvValue(difference.Right)})
+			}
+
+			return formatter.Format(rows, cmd.OutOrStdout(), output.TableFormatterOptions{
+				Columns: []output.Column{
+					{Heading: "NAME", ValueTemplate: "{{index . 0}}"},
+					{Heading: args[0], ValueTemplate: "{{index . 1}}"},
+					{Heading: args[1], ValueTemplate: "{{index . 2}}"},
+				},
+			})
+		},
+	)
+	cmd := commands.Build(
+		action,
+		rootOptions,
+		"diff <environment> <other-environment>",
+		"Compare the values of two environments.",
+		`Compare the values of two environments.
+
+Lists the values which are only set in one of the environments, or which differ. Values referencing Key Vault secrets
+are compared as references.`,
+	)
+	cmd.Args = cobra.ExactArgs(2)
+	return cmd
+}
+
+// diffEnvironmentValues returns the values which differ between left and right, ordered by name.
+func diffEnvironmentValues(left map[string]string, right map[string]string) []envValueDifference {
+	differences := []envValueDifference{}
+
+	for name, leftValue := range left {
+		leftValue := leftValue
+		if rightValue, has := right[name]; !has {
+			differences = append(differences, envValueDifference{Name: name, Left: &leftValue})
+		} else if rightValue != leftValue {
+			rightValue := rightValue
+			differences = append(differences, envValueDifference{Name: name, Left: &leftValue, Right: &rightValue})
+		}
+	}
+
+	for name, rightValue := range right {
+		rightValue := rightValue
+		if _, has := left[name]; !has {
+			differences = append(differences, envValueDifference{Name: name, Right: &rightValue})
+		}
+	}
+
+	sort.Slice(differences, func(i, j int) bool {
+		return differences[i].Name < differences[j].Name
+	})
+	return differences
+}
+
+func formatEnvValue(value *string) string {
+	if value == nil {
+		return "(not set)"
+	}
+
+	return *value
+}
+
+func envExportCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
+	action := commands.ActionFunc(
+		func(_ context.Context, cmd *cobra.Command, args []string, azdCtx *environment.AzdContext) error {
+			if err := ensureProject(azdCtx.ProjectPath()); err != nil {
+				return err
+			}
+
+			formatter, err := output.GetFormatter(cmd)
+			if err != nil {
+				return err
+			}
+
+			name, err := environmentNameOrDefault(rootOptions.EnvironmentName, azdCtx)
+			if err != nil {
+				return err
+			}
+
+			env, err := azdCtx.GetEnvironment(name)
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("environment '%s' does not exist", name)
+			} else if err != nil {
+				return fmt.Errorf("loading environment '%s': %w", name, err)
+			}
+
+			var writer io.Writer = cmd.OutOrStdout()
+			if len(args) == 1 {
+				file, err := os.OpenFile(args[0], os.O_CREATE|os.O_WRONLY|os.O_TRUNC, osutil.PermissionFile)
+				if err != nil {
+					return fmt.Errorf("creating %s: %w", args[0], err)
+				}
+				defer file.Close()
+
+				writer = file
+			}
+
+			return formatter.Format(env.Values, writer, nil)
+		},
+	)
+	cmd := commands.Build(
+		action,
+		rootOptions,
+		"export [<file>]",
+		"Export the values of an environment.",
+		`Export the values of an environment, to a file or to the standard output, in the format of .env files or in
+JSON. Values referencing Key Vault secrets are exported as references. Exported values are imported with
+azd env import.`,
+	)
+	cmd.Args = cobra.MaximumNArgs(1)
+	return cmd
+}
+
+func envImportCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
+	action := commands.ActionFunc(
+		func(_ context.Context, _ *cobra.Command, args []string, azdCtx *environment.AzdContext) error {
+			if err := ensureProject(azdCtx.ProjectPath()); err != nil {
+				return err
+			}
+
+			contents, err := ioutil.ReadFile(args[0])
+			if err != nil {
+				return fmt.Errorf("reading %s: %w", args[0], err)
+			}
+
+			values, err := parseEnvironmentValues(contents)
+			if err != nil {
+				return fmt.Errorf("parsing %s: %w", args[0], err)
+			}
+
+			name, err := environmentNameOrDefault(rootOptions.EnvironmentName, azdCtx)
+			if err != nil {
+				return err
+			}
+
+			if name == "" {
+				return errors.New("no environment is selected, use --environment to name the environment to import into")
+			}
+
+			env, err := azdCtx.GetEnvironment(name)
+			if errors.Is(err, os.ErrNotExist) {
+				if !environment.IsValidEnvironmentName(name) {
+					return fmt.Errorf("environment name '%s' is invalid (it should contain only alphanumeric characters and hyphens)", name)
+				}
+
+				if err := azdCtx.NewEnvironment(name); err != nil {
+					return fmt.Errorf("creating environment '%s': %w", name, err)
+				}
+
+				env = environment.Empty(azdCtx.GetEnvironmentFilePath(name))
+			} else if err != nil {
+				return fmt.Errorf("loading environment '%s': %w", name, err)
+			}
+
+			// The environment keeps its name, rather than the one of the environment the values were exported from.
+			delete(values, environment.EnvNameEnvVarName)
+			for key, value := range values {
+				env.SetImportedValue(key, value)
+			}
+			env.SetEnvName(name)
+
+			if err := env.Save(); err != nil {
+				return fmt.Errorf("saving environment: %w", err)
+			}
+
+			fmt.Printf("Imported %d values into environment %s.\n", len(values), name)
+			return nil
+		},
+	)
+	cmd := commands.Build(
+		action,
+		rootOptions,
+		"import <file>",
+		"Import values into an environment.",
+		`Import values into an environment, from a file in the format of .env files or in JSON, such as a file written by
+azd env export. The environment is created when it does not exist, and imported values replace its values with the
+same name, except AZURE_ENV_NAME.
+
+Exported files do not record where values came from, so imported values are replaced by the outputs of deployments of
+the same name, as values set by editing the .env file of an environment are.`,
+	)
+	cmd.Args = cobra.ExactArgs(1)
+	return cmd
+}
+
+// parseEnvironmentValues parses the values of an environment, from a JSON object of strings, or from a .env file.
+func parseEnvironmentValues(contents []byte) (map[string]string, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(contents), []byte("{")) {
+		var values map[string]string
+		if err := json.Unmarshal(contents, &values); err != nil {
+			return nil, fmt.Errorf("values in JSON must be an object of strings: %w", err)
+		}
+
+		return values, nil
+	}
+
+	return godotenv.Unmarshal(string(contents))
+}
+
+// environmentNameOrDefault returns name, or the name of the default environment when name is empty, which is empty
+// when there is no default environment.
+func environmentNameOrDefault(name string, azdCtx *environment.AzdContext) (string, error) {
+	if name != "" {
+		return name, nil
+	}
+
+	name, err := azdCtx.GetDefaultEnvironmentName()
+	if err != nil {
+		return "", fmt.Errorf("getting default environment: %w", err)
+	}
+
+	return name, nil
+}
@@ -0,0 +1,63 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/commands"
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffEnvironmentValues(t *testing.T) {
+	left := map[string]string{"A": "1", "B": "2", "C": "3"}
+	right := map[string]string{"B": "2", "C": "4", "D": "5"}
+
+	one, three, four, five := "1", "3", "4", "5"
+	require.Equal(t, []envValueDifference{
+		{Name: "A", Left: &one},
+		{Name: "C", Left: &three, Right: &four},
+		{Name: "D", Right: &five},
+	}, diffEnvironmentValues(left, right))
+
+	require.Empty(t, diffEnvironmentValues(left, left))
+}
+
+func TestParseEnvironmentValues(t *testing.T) {
+	values, err := parseEnvironmentValues([]byte("AZURE_LOCATION=\"westus\"\nSECRET=\"akvs://vault/dev-SECRET\"\n"))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"AZURE_LOCATION": "westus", "SECRET": "akvs://vault/dev-SECRET"}, values)
+
+	values, err = parseEnvironmentValues([]byte(`  {"AZURE_LOCATION": "westus"}`))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"AZURE_LOCATION": "westus"}, values)
+
+	_, err = parseEnvironmentValues([]byte(`{"COUNT": 1}`))
+	require.Error(t, err)
+}
+
+// Names such as `..` do not name environments, even when the directory they resolve to has a .env file.
+func TestEnvDeleteRejectsParentDirectory(t *testing.T) {
+	projectDir := t.TempDir()
+	azdCtx := &environment.AzdContext{}
+	azdCtx.SetProjectDirectory(projectDir)
+
+	require.NoError(t, os.WriteFile(azdCtx.ProjectPath(), []byte("name: test\n"), 0600))
+	require.NoError(t, os.MkdirAll(azdCtx.EnvironmentDirectory(), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, ".env"), []byte("A=1\n"), 0600))
+
+	action := &envDeleteAction{rootOptions: &commands.GlobalCommandOptions{NoPrompt: true}, force: true}
+	err := action.Run(context.Background(), nil, []string{".."}, azdCtx)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is invalid")
+
+	_, err = os.Stat(azdCtx.ProjectPath())
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(projectDir, ".env"))
+	require.NoError(t, err)
+}
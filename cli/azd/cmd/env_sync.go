@@ -0,0 +1,105 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/commands"
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/state"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func envPullCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
+	return commands.Build(
+		&envSyncAction{rootOptions: rootOptions, pull: true},
+		rootOptions,
+		"pull",
+		"Pull an environment from the remote state of the project.",
+		`Pull an environment from the remote state of the project, creating it locally when it only exists remotely.
+
+When the environment has local changes which were not pushed, and was also changed remotely, the changes conflict and
+the environment is not pulled. Pull with --force to keep the remote changes, which replace the local changes.`,
+	)
+}
+
+func envPushCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
+	return commands.Build(
+		&envSyncAction{rootOptions: rootOptions},
+		rootOptions,
+		"push",
+		"Push an environment to the remote state of the project.",
+		`Push an environment to the remote state of the project.
+
+When the environment was changed remotely since it was last pulled or pushed, the changes conflict and the environment
+is not pushed. Push with --force to keep the local changes, which replace the remote changes.`,
+	)
+}
+
+// envSyncAction pulls or pushes an environment.
+type envSyncAction struct {
+	rootOptions *commands.GlobalCommandOptions
+	pull        bool
+	force       bool
+}
+
+func (a *envSyncAction) SetupFlags(
+	persis *pflag.FlagSet,
+	local *pflag.FlagSet,
+) {
+	if a.pull {
+		local.BoolVar(&a.force, "force", false, "Replaces local changes which conflict with remote changes.")
+	} else {
+		local.BoolVar(&a.force, "force", false, "Replaces remote changes which conflict with local changes.")
+	}
+}
+
+func (a *envSyncAction) Run(ctx context.Context, _ *cobra.Command, _ []string, azdCtx *environment.AzdContext) error {
+	if err := ensureProject(azdCtx.ProjectPath()); err != nil {
+		return err
+	}
+
+	name, err := environmentNameOrDefault(a.rootOptions.EnvironmentName, azdCtx)
+	if err != nil {
+		return err
+	}
+
+	if name == "" {
+		return errors.New("no environment is selected, use --environment to name the environment")
+	}
+
+	store, err := newRemoteEnvironmentStore(ctx, azdCtx)
+	if err != nil {
+		return err
+	}
+
+	if store == nil {
+		return errors.New("the project does not have a remote state, configure one in the state section of azure.yaml")
+	}
+
+	if !a.pull {
+		if err := state.Push(ctx, store, azdCtx, name, a.force); err != nil {
+			return fmt.Errorf("pushing environment: %w", err)
+		}
+
+		fmt.Printf("Pushed environment %s.\n", name)
+		return nil
+	}
+
+	found, err := state.Pull(ctx, store, azdCtx, name, a.force)
+	if err != nil {
+		return fmt.Errorf("pulling environment: %w", err)
+	}
+
+	if !found {
+		return fmt.Errorf("environment '%s' is not in the remote state", name)
+	}
+
+	fmt.Printf("Pulled environment %s.\n", name)
+	return nil
+}
@@ -0,0 +1,313 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/azure/azure-dev/cli/azd/pkg/commands"
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/output"
+	"github.com/azure/azure-dev/cli/azd/pkg/project"
+	"github.com/spf13/cobra"
+)
+
+// environmentVariables returns the variables the project declares in the `env` section of azure.yaml, which are none
+// when there is no project yet.
+func environmentVariables(azdCtx *environment.AzdContext, env *environment.Environment) ([]environment.VariableConfig, error) {
+	prj, err := project.LoadProjectConfig(azdCtx.ProjectPath(), env)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("loading project: %w", err)
+	}
+
+	return prj.Env, nil
+}
+
+// validateEnvironmentValue ensures value is valid for the variable name, when the project declares it.
+func validateEnvironmentValue(azdCtx *environment.AzdContext, env *environment.Environment, name string, value string) error {
+	variables, err := environmentVariables(azdCtx, env)
+	if err != nil {
+		return err
+	}
+
+	for _, variable := range variables {
+		if variable.Name == name {
+			if err := variable.Validate(value); err != nil {
+				return fmt.Errorf("invalid value for environment variable '%s': %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ensureEnvironmentValues ensures env has a valid value for each variable the project declares, before the environment
+// is provisioned or deployed. Defaults are set for values which are not, and required values without a default are
+// prompted for, as are invalid values. Other commands do not require the values, so they can be used to set them.
+// Secrets are only saved to the Key Vault of the environment, and are otherwise only kept in memory for this command.
+func ensureEnvironmentValues(ctx context.Context, azdCtx *environment.AzdContext, env *environment.Environment, askOne Asker) error {
+	variables, err := environmentVariables(azdCtx, env)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for _, variable := range variables {
+		value := env.Values[variable.Name]
+		if value != "" {
+			err := variable.Validate(value)
+			if err == nil {
+				continue
+			}
+
+			fmt.Printf("The value of environment variable '%s' is invalid: %s.\n", variable.Name, err)
+		} else if variable.Default != "" {
+			env.SetSystemValue(variable.Name, variable.Default)
+			changed = true
+			continue
+		} else if !variable.Required {
+			continue
+		}
+
+		value, err := promptEnvironmentValue(variable, askOne)
+		if err != nil {
+			return err
+		}
+
+		vaultName := env.Values[environment.KeyVaultNameEnvVarName]
+		switch {
+		case variable.Secret && vaultName != "":
+			if _, err := setSecretValue(ctx, env, vaultName, variable.Name, value); err != nil {
+				return err
+			}
+		case variable.Secret:
+			fmt.Printf(
+				"The value of secret environment variable '%s' is not saved, since the environment has no Key Vault to "+
+					"save it to. Set %s to save it.\n", variable.Name, environment.KeyVaultNameEnvVarName)
+			env.SetTransientValue(variable.Name, value)
+		default:
+			env.SetUserValue(variable.Name, value)
+		}
+
+		changed = true
+	}
+
+	if changed {
+		if err := env.Save(); err != nil {
+			return fmt.Errorf("saving environment: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// promptEnvironmentValue prompts for the value of variable with the prompt matching it, until the value is valid.
+func promptEnvironmentValue(variable environment.VariableConfig, askOne Asker) (string, error) {
+	message := fmt.Sprintf("Please enter a value for the '%s' environment variable:", variable.Name)
+
+	for {
+		var value string
+		var err error
+
+		switch {
+		case len(variable.Allowed) > 0:
+			prompt := &survey.Select{
+				Message: fmt.Sprintf("Please select a value for the '%s' environment variable:", variable.Name),
+				Options: variable.Allowed,
+				Help:    variable.Description,
+			}
+			if variable.Default != "" {
+				prompt.Default = variable.Default
+			}
+
+			err = askOne(prompt, &value)
+		case variable.VariableType() == environment.BoolVariable:
+			defaultValue, _ := strconv.ParseBool(variable.Default)
+
+			var confirmed bool
+			err = askOne(&survey.Confirm{
+				Message: fmt.Sprintf("Should the '%s' environment variable be set to true?", variable.Name),
+				Help:    variable.Description,
+				Default: defaultValue,
+			}, &confirmed)
+			value = strconv.FormatBool(confirmed)
+		case variable.Secret:
+			err = askOne(&survey.Password{
+				Message: message,
+				Help:    variable.Description,
+			}, &value)
+		default:
+			err = askOne(&survey.Input{
+				Message: message,
+				Help:    variable.Description,
+				Default: variable.Default,
+			}, &value)
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("prompting for value of %s: %w", variable.Name, err)
+		}
+
+		if value == "" {
+			fmt.Println("error: a value is required.")
+			continue
+		}
+
+		if err := variable.Validate(value); err != nil {
+			fmt.Printf("error: %s.\n", err)
+			continue
+		}
+
+		return value, nil
+	}
+}
+
+// Sources of the values of environments, as described by azd env describe. The sources of values without metadata,
+// which were set before azd recorded it or by editing the .env file, are either default or user.
+const (
+	envValueSourceUser    = "user"
+	envValueSourceOutput  = "output"
+	envValueSourceSystem  = "system"
+	envValueSourceDefault = "default"
+)
+
+// envVariableDescription describes the value of a declared variable in an environment.
+type envVariableDescription struct {
+	Name        string                   `json:"name"`
+	Type        environment.VariableType `json:"type"`
+	Required    bool                     `json:"required"`
+	Secret      bool                     `json:"secret"`
+	Description string                   `json:"description,omitempty"`
+	// The value, which is omitted for secrets
+	Value string `json:"value,omitempty"`
+	// One of user, output, system or default, which is empty when the value is not set
+	Source string `json:"source,omitempty"`
+	Valid  bool   `json:"valid"`
+	Error  string `json:"error,omitempty"`
+}
+
+func envDescribeCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
+	action := commands.ActionFunc(
+		func(_ context.Context, cmd *cobra.Command, _ []string, azdCtx *environment.AzdContext) error {
+			if err := ensureProject(azdCtx.ProjectPath()); err != nil {
+				return err
+			}
+
+			formatter, err := output.GetFormatter(cmd)
+			if err != nil {
+				return err
+			}
+
+			name, err := environmentNameOrDefault(rootOptions.EnvironmentName, azdCtx)
+			if err != nil {
+				return err
+			}
+
+			env, err := azdCtx.GetEnvironment(name)
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("environment '%s' does not exist", name)
+			} else if err != nil {
+				return fmt.Errorf("loading environment '%s': %w", name, err)
+			}
+
+			variables, err := environmentVariables(azdCtx, &env)
+			if err != nil {
+				return err
+			}
+
+			descriptions := describeEnvironmentVariables(variables, &env)
+
+			if formatter.Kind() != output.TableFormat {
+				return formatter.Format(descriptions, cmd.OutOrStdout(), nil)
+			}
+
+			if len(descriptions) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "The project does not declare environment variables in azure.yaml.")
+				return nil
+			}
+
+			return formatter.Format(descriptions, cmd.OutOrStdout(), output.TableFormatterOptions{
+				Columns: []output.Column{
+					{Heading: "NAME", ValueTemplate: "{{.Name}}"},
+					{Heading: "TYPE", ValueTemplate: "{{.Type}}"},
+					{Heading: "REQUIRED", ValueTemplate: "{{.Required}}"},
+					{Heading: "VALUE", ValueTemplate: "{{if .Secret}}(secret){{else}}{{.Value}}{{end}}"},
+					{Heading: "SOURCE", ValueTemplate: "{{.Source}}"},
+					{Heading: "VALID", ValueTemplate: "{{if .Valid}}true{{else}}false: {{.Error}}{{end}}"},
+				},
+			})
+		},
+	)
+
+	return commands.Build(
+		action,
+		rootOptions,
+		"describe",
+		"Describe the values of an environment.",
+		`Describe the values of an environment, for each variable declared in the env section of azure.yaml.
+
+Lists whether each value is set by a user, by the outputs of a deployment of the environment or by azd, such as the
+default of its variable, and whether it is valid. Values whose source was not recorded, such as values edited in the
+.env file, are listed as set by the default of their variable when they are the same, and by a user otherwise. The values
+of secrets are not displayed.`,
+	)
+}
+
+// describeEnvironmentVariables describes the values of variables in env.
+func describeEnvironmentVariables(variables []environment.VariableConfig, env *environment.Environment) []envVariableDescription {
+	descriptions := []envVariableDescription{}
+
+	for _, variable := range variables {
+		description := envVariableDescription{
+			Name:        variable.Name,
+			Type:        variable.VariableType(),
+			Required:    variable.Required,
+			Secret:      variable.Secret,
+			Description: variable.Description,
+			Valid:       true,
+		}
+
+		value, has := env.Lookup(variable.Name)
+		metadata, hasMetadata := env.LookupMetadata(variable.Name)
+		switch {
+		case !has || value == "":
+			if variable.Required {
+				description.Valid = false
+				description.Error = "required value is not set"
+			}
+		case hasMetadata && metadata.Source == environment.UserSource:
+			description.Source = envValueSourceUser
+		case hasMetadata && metadata.Source == environment.OutputSource:
+			description.Source = envValueSourceOutput
+		case hasMetadata && metadata.Source == environment.SystemSource:
+			description.Source = envValueSourceSystem
+		case value == variable.Default:
+			description.Source = envValueSourceDefault
+		default:
+			description.Source = envValueSourceUser
+		}
+
+		if has && value != "" {
+			if !variable.Secret {
+				description.Value = value
+			}
+
+			if err := variable.Validate(value); err != nil {
+				description.Valid = false
+				description.Error = err.Error()
+			}
+		}
+
+		descriptions = append(descriptions, description)
+	}
+
+	return descriptions
+}
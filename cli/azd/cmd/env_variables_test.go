@@ -0,0 +1,57 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribeEnvironmentVariables(t *testing.T) {
+	variables := []environment.VariableConfig{
+		{Name: "SKU", Allowed: []string{"S1", "P1"}, Default: "S1"},
+		{Name: "REPLICAS", Type: environment.NumberVariable, Required: true},
+		{Name: "DB_PASSWORD", Secret: true, Required: true},
+		{Name: "API_URL"},
+		{Name: "DEBUG", Type: environment.BoolVariable},
+		{Name: "REGION", Default: "westus"},
+		{Name: "TIER", Default: "basic"},
+	}
+
+	env := environment.Environment{Values: map[string]string{
+		"SKU":         "S1",
+		"REPLICAS":    "two",
+		"DB_PASSWORD": "akvs://my-vault/dev-DB-PASSWORD",
+	}}
+	env.ApplyOutputs("main", map[string]string{"API_URL": "https://example.com"})
+	env.SetUserValue("REGION", "westus")
+	env.SetSystemValue("TIER", "basic")
+
+	descriptions := describeEnvironmentVariables(variables, &env)
+
+	require.Len(t, descriptions, 7)
+
+	require.Equal(t, envValueSourceDefault, descriptions[0].Source)
+	require.True(t, descriptions[0].Valid)
+
+	require.Equal(t, envValueSourceUser, descriptions[1].Source)
+	require.False(t, descriptions[1].Valid)
+	require.Contains(t, descriptions[1].Error, "not a number")
+
+	require.Equal(t, envValueSourceUser, descriptions[2].Source)
+	require.Empty(t, descriptions[2].Value)
+	require.True(t, descriptions[2].Valid)
+
+	require.Equal(t, envValueSourceOutput, descriptions[3].Source)
+	require.Equal(t, "https://example.com", descriptions[3].Value)
+
+	require.Empty(t, descriptions[4].Source)
+	require.True(t, descriptions[4].Valid)
+
+	// The recorded source is used, even when the value is the default of the variable.
+	require.Equal(t, envValueSourceUser, descriptions[5].Source)
+	require.Equal(t, envValueSourceSystem, descriptions[6].Source)
+}
@@ -3,24 +3,19 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
 	"net/url"
-	"os"
 	"time"
 
-	"github.com/AlecAivazis/survey/v2"
 	"github.com/azure/azure-dev/cli/azd/pkg/azure"
 	"github.com/azure/azure-dev/cli/azd/pkg/commands"
 	"github.com/azure/azure-dev/cli/azd/pkg/environment"
-	"github.com/azure/azure-dev/cli/azd/pkg/iac/bicep"
+	"github.com/azure/azure-dev/cli/azd/pkg/ext"
 	"github.com/azure/azure-dev/cli/azd/pkg/infra"
 	"github.com/azure/azure-dev/cli/azd/pkg/infra/provisioning"
-	"github.com/azure/azure-dev/cli/azd/pkg/osutil"
 	"github.com/azure/azure-dev/cli/azd/pkg/output"
 	"github.com/azure/azure-dev/cli/azd/pkg/project"
 	"github.com/azure/azure-dev/cli/azd/pkg/spin"
 	"github.com/azure/azure-dev/cli/azd/pkg/tools"
-	"github.com/drone/envsubst"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"go.uber.org/multierr"
@@ -28,38 +23,46 @@ import (
 
 type infraCreateAction struct {
 	noProgress  bool
+	preview     bool
 	rootOptions *commands.GlobalCommandOptions
 }
 
 func infraCreateCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
+	action := &infraCreateAction{
+		rootOptions: rootOptions,
+	}
 	cmd := commands.Build(
-		&infraCreateAction{
-			rootOptions: rootOptions,
-		},
+		action,
 		rootOptions,
 		"create",
 		"Create Azure resources for an application.",
 		"",
 	)
 
+	action.setupPreviewFlag(cmd.Flags())
 	cmd.Aliases = []string{"provision"}
 	return cmd
 }
 
+// setupPreviewFlag adds the --preview flag. This is not part of SetupFlags, which is also used when the action is
+// composed into `up`, where a preview would stop before deploying.
+func (ica *infraCreateAction) setupPreviewFlag(flags *pflag.FlagSet) {
+	flags.BoolVar(&ica.preview, "preview", false, "Preview the changes to Azure resources without applying them.")
+}
+
 func (ica *infraCreateAction) SetupFlags(persis, local *pflag.FlagSet) {
 	local.BoolVar(&ica.noProgress, "no-progress", false, "Suppresses progress information.")
 }
 
 func (ica *infraCreateAction) Run(ctx context.Context, cmd *cobra.Command, args []string, azdCtx *environment.AzdContext) error {
 	azCli := commands.GetAzCliFromContext(ctx)
-	bicepCli := tools.NewBicepCli(azCli)
 	askOne := makeAskOne(ica.rootOptions.NoPrompt)
 
 	if err := ensureProject(azdCtx.ProjectPath()); err != nil {
 		return err
 	}
 
-	if err := tools.EnsureInstalled(ctx, azCli, bicepCli); err != nil {
+	if err := tools.EnsureInstalled(ctx, azCli); err != nil {
 		return err
 	}
 
@@ -72,130 +75,60 @@ func (ica *infraCreateAction) Run(ctx context.Context, cmd *cobra.Command, args
 		return fmt.Errorf("loading environment: %w", err)
 	}
 
-	_, err = project.LoadProjectConfig(azdCtx.ProjectPath(), &environment.Environment{})
+	if err := ensureEnvironmentValues(ctx, azdCtx, &env, askOne); err != nil {
+		return fmt.Errorf("validating environment: %w", err)
+	}
+
+	prj, err := project.LoadProjectConfig(azdCtx.ProjectPath(), &env)
 	if err != nil {
 		return fmt.Errorf("loading project: %w", err)
 	}
 
-	const rootModule = "main"
-
-	// Copy the parameter template file to the environment working directory and do substitutions.
-	parametersPath := azdCtx.BicepParametersTemplateFilePath(rootModule)
-	parametersBytes, err := ioutil.ReadFile(parametersPath)
+	formatter, err := output.GetFormatter(cmd)
 	if err != nil {
-		return fmt.Errorf("reading parameter file template: %w", err)
+		return err
 	}
-	replaced, err := envsubst.Eval(string(parametersBytes), func(name string) string {
-		if val, has := env.Values[name]; has {
-			return val
+	interactive := formatter.Kind() == output.NoneFormat
+
+	// Hooks are not run for a preview, since nothing is provisioned.
+	hooks := ext.NewHooksRunner(prj.Hooks, prj.Path, &env, ext.HooksRunnerArgs{Output: hooksOutput(formatter)})
+	if !ica.preview {
+		if err := hooks.Run(ctx, ext.PreProvisionHook); err != nil {
+			return err
 		}
-		return os.Getenv(name)
-	})
-	if err != nil {
-		return fmt.Errorf("substituting parameter file: %w", err)
-	}
-	err = ioutil.WriteFile(azdCtx.BicepParametersFilePath(ica.rootOptions.EnvironmentName, rootModule), []byte(replaced), osutil.PermissionFile)
-	if err != nil {
-		return fmt.Errorf("writing parameter file: %w", err)
 	}
 
-	// Fetch the parameters from the template and ensure we have a value for each one, otherwise
-	// prompt.
-	bicepPath := azdCtx.BicepModulePath(rootModule)
-	template, err := bicep.Compile(ctx, bicepCli, bicepPath)
+	provider, err := newInfraProvider(ctx, azdCtx, &env, prj, askOne)
 	if err != nil {
 		return err
 	}
 
-	// When creating a deployment, we need an azure location which is used to store the deployment metadata. This can be
-	// any azure location and the choice doesn't impact what location individual resources in the deployment use. By default
-	// we'll just use whatever value is being passed to the `location` parameter for bicep, and if that's not defined,
-	// we'll prompt the user as to what location they want to use.
-	//
-	// TODO: The UX here could be improved. One problem is the concept of "the location used to store deployment metadata,
-	// but not the resources" is sort of confusing and hard to clearly articulate.
-	var location string
-
-	if len(template.Parameters) > 0 {
-		configuredParameters, err := azdCtx.BicepParameters(ica.rootOptions.EnvironmentName, rootModule)
-		if err != nil {
-			return fmt.Errorf("reading existing parameters: %w", err)
-		}
-
-		updatedParameters := false
-		for parameter, value := range template.Parameters {
-			// If this parameter has a default, then there is no need for us to configure it
-			if _, hasDefault := value["defaultValue"]; hasDefault {
-				continue
-			}
-			if _, has := configuredParameters[parameter]; !has {
-
-				var val string
-				if err := askOne(&survey.Input{
-					Message: fmt.Sprintf("Please enter a value for the '%s' deployment parameter:", parameter),
-				}, &val); err != nil {
-					return fmt.Errorf("prompting for deployment parameter: %w", err)
-				}
-
-				configuredParameters[parameter] = val
-
-				saveParameter := true
-				if err := askOne(&survey.Confirm{
-					Message: "Save the value in the environment for future use",
-				}, &saveParameter); err != nil {
-					return fmt.Errorf("prompting to save deployment parameter: %w", err)
-				}
-
-				if saveParameter {
-					env.Values[parameter] = val
-				}
-
-				updatedParameters = true
-			}
-
-			if parameter == "location" {
-				location = configuredParameters[parameter].(string)
-			}
-		}
-
-		if updatedParameters {
-			if err := azdCtx.WriteBicepParameters(ica.rootOptions.EnvironmentName, rootModule, configuredParameters); err != nil {
-				return fmt.Errorf("saving deployment parameters: %w", err)
-			}
-
-			if err := env.Save(); err != nil {
-				return fmt.Errorf("writing env file: %w", err)
-			}
-		}
+	if err := tools.EnsureInstalled(ctx, provider.RequiredExternalTools()...); err != nil {
+		return err
 	}
 
-	for location == "" {
-		// TODO: We will want to store this information somewhere (so we don't have to prompt the
-		// user on every deployment if they don't have a `location` parameter in their bicep file.
-		// When we store it, we should store it /per environment/ not as a property of the entire
-		// project.
-		selected, err := promptLocation(ctx, "Please select an Azure location to use to store deployment metadata:", askOne)
-		if err != nil {
-			return fmt.Errorf("prompting for deployment metadata region: %w", err)
-		}
-
-		location = selected
+	if err := provider.Init(ctx); err != nil {
+		return fmt.Errorf("initializing %s provider: %w", provider.Name(), err)
 	}
 
-	formatter, err := output.GetFormatter(cmd)
+	plan, err := provider.Plan(ctx)
 	if err != nil {
-		return err
+		return fmt.Errorf("planning deployment: %w", err)
 	}
-	interactive := formatter.Kind() == output.NoneFormat
 
-	// Do the creating. The call to `DeployToSubscription` blocks until the deployment completes,
+	if ica.preview {
+		return ica.showPreview(ctx, cmd, provider, plan, formatter)
+	}
+
+	// Only bicep deployments are tracked as an ARM subscription deployment, which is used to report detailed progress.
+	_, isBicep := provider.(*provisioning.BicepProvider)
+
+	// Do the creating. The call to `Apply` blocks until the deployment completes,
 	// which can take a bit, so we typically do some progress indication.
 	// For interactive use (default case, using table formatter), we use a spinner.
 	// With JSON formatter we emit progress information, unless --no-progress option was set.
-	deploymentTarget := bicep.NewSubscriptionDeploymentTarget(azCli, location, env.GetSubscriptionId(), env.GetEnvName())
-
 	type deployFuncResult struct {
-		Result tools.AzCliDeployment
+		Result *provisioning.Deployment
 		Err    error
 	}
 	var res deployFuncResult
@@ -203,7 +136,7 @@ func (ica *infraCreateAction) Run(ctx context.Context, cmd *cobra.Command, args
 	deployAndReportProgress := func(spinner *spin.Spinner) error {
 		deployResChan := make(chan deployFuncResult)
 		go func() {
-			res, err := bicep.Deploy(ctx, deploymentTarget, bicepPath, azdCtx.BicepParametersFilePath(ica.rootOptions.EnvironmentName, "main"))
+			res, err := provider.Apply(ctx, plan)
 			deployResChan <- deployFuncResult{Result: res, Err: err}
 			close(deployResChan)
 		}()
@@ -216,7 +149,7 @@ func (ica *infraCreateAction) Run(ctx context.Context, cmd *cobra.Command, args
 				res = deployRes
 				return deployRes.Err
 			case <-time.After(10 * time.Second):
-				if ica.noProgress {
+				if ica.noProgress || !isBicep {
 					continue
 				}
 				if interactive {
@@ -229,13 +162,17 @@ func (ica *infraCreateAction) Run(ctx context.Context, cmd *cobra.Command, args
 	}
 
 	if interactive {
-		deploymentSlug := azure.SubscriptionDeploymentRID(env.GetSubscriptionId(), env.GetEnvName())
-		deploymentURL := withLinkFormat(
-			"https://portal.azure.com/#blade/HubsExtension/DeploymentDetailsBlade/overview/id/%s\n\n",
-			url.PathEscape(deploymentSlug))
-		printWithStyling(
-			"Provisioning Azure resources can take some time.\n\nYou can view detailed progress in the Azure Portal:\n%s",
-			deploymentURL)
+		if isBicep {
+			deploymentSlug := azure.SubscriptionDeploymentRID(env.GetSubscriptionId(), env.GetEnvName())
+			deploymentURL := withLinkFormat(
+				"https://portal.azure.com/#blade/HubsExtension/DeploymentDetailsBlade/overview/id/%s\n\n",
+				url.PathEscape(deploymentSlug))
+			printWithStyling(
+				"Provisioning Azure resources can take some time.\n\nYou can view detailed progress in the Azure Portal:\n%s",
+				deploymentURL)
+		} else {
+			printWithStyling("Provisioning Azure resources can take some time.\n\n")
+		}
 
 		spinner := spin.NewSpinner("Creating Azure resources")
 		spinner.Start()
@@ -250,7 +187,7 @@ func (ica *infraCreateAction) Run(ctx context.Context, cmd *cobra.Command, args
 	}
 
 	if err != nil {
-		if formatter.Kind() == output.JsonFormat {
+		if formatter.Kind() == output.JsonFormat && isBicep {
 			deploy, deployErr := azCli.GetSubscriptionDeployment(ctx, env.GetSubscriptionId(), env.GetEnvName())
 			if deployErr != nil {
 				return fmt.Errorf("deployment failed and the deployment result is unavailable: %w", multierr.Combine(err, deployErr))
@@ -264,13 +201,16 @@ func (ica *infraCreateAction) Run(ctx context.Context, cmd *cobra.Command, args
 		return fmt.Errorf("deployment failed: %w", err)
 	}
 
-	template.CanonicalizeDeploymentOutputs(&res.Result.Properties.Outputs)
-	if err = saveEnvironmentValues(res.Result, env); err != nil {
+	if _, err = saveEnvironmentValues(prj.Infra.ModuleName(), res.Result.Outputs, &env, hooksOutput(formatter)); err != nil {
+		return err
+	}
+
+	if err := hooks.Run(ctx, ext.PostProvisionHook); err != nil {
 		return err
 	}
 
 	if formatter.Kind() == output.JsonFormat {
-		if err = formatter.Format(res.Result, cmd.OutOrStdout(), nil); err != nil {
+		if err = formatter.Format(res.Result.Details, cmd.OutOrStdout(), nil); err != nil {
 			return fmt.Errorf("deployment result could not be displayed: %w", err)
 		}
 	}
@@ -278,6 +218,38 @@ func (ica *infraCreateAction) Run(ctx context.Context, cmd *cobra.Command, args
 	return nil
 }
 
+// showPreview displays the changes applying the plan would make, without applying it.
+func (ica *infraCreateAction) showPreview(
+	ctx context.Context, cmd *cobra.Command, provider provisioning.Provider, plan *provisioning.DeploymentPlan, formatter output.Formatter,
+) error {
+	var preview *provisioning.DeploymentPreview
+	previewFn := func() error {
+		res, err := provider.Preview(ctx, plan)
+		preview = res
+		return err
+	}
+
+	var err error
+	if formatter.Kind() == output.NoneFormat {
+		err = spin.NewSpinner("Previewing Azure resource changes").Run(previewFn)
+	} else {
+		err = previewFn()
+	}
+	if err != nil {
+		return fmt.Errorf("previewing deployment: %w", err)
+	}
+
+	if formatter.Kind() == output.JsonFormat {
+		if err := formatter.Format(preview, cmd.OutOrStdout(), nil); err != nil {
+			return fmt.Errorf("deployment preview could not be displayed: %w", err)
+		}
+
+		return nil
+	}
+
+	return provisioning.RenderPreview(cmd.OutOrStdout(), preview)
+}
+
 type progressReport struct {
 	Timestamp  time.Time                      `json:"timestamp"`
 	Operations []tools.AzCliResourceOperation `json:"operations"`
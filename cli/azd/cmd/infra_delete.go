@@ -3,14 +3,12 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"log"
 
-	"github.com/AlecAivazis/survey/v2"
-	"github.com/azure/azure-dev/cli/azd/pkg/azureutil"
 	"github.com/azure/azure-dev/cli/azd/pkg/commands"
 	"github.com/azure/azure-dev/cli/azd/pkg/environment"
-	"github.com/azure/azure-dev/cli/azd/pkg/iac/bicep"
-	"github.com/azure/azure-dev/cli/azd/pkg/infra"
-	"github.com/azure/azure-dev/cli/azd/pkg/spin"
+	"github.com/azure/azure-dev/cli/azd/pkg/infra/provisioning"
+	"github.com/azure/azure-dev/cli/azd/pkg/project"
 	"github.com/azure/azure-dev/cli/azd/pkg/tools"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -43,151 +41,88 @@ func (a *infraDeleteAction) SetupFlags(
 }
 
 func (a *infraDeleteAction) Run(ctx context.Context, _ *cobra.Command, args []string, azdCtx *environment.AzdContext) error {
+	_, err := a.destroy(ctx, azdCtx)
+	return err
+}
+
+// destroy deletes the Azure resources of the environment, and removes the outputs of its deployment from it. false is
+// returned when the user chose not to continue.
+func (a *infraDeleteAction) destroy(ctx context.Context, azdCtx *environment.AzdContext) (bool, error) {
 	azCli := commands.GetAzCliFromContext(ctx)
-	bicepCli := tools.NewBicepCli(azCli)
 	askOne := makeAskOne(a.rootOptions.NoPrompt)
 
 	if err := ensureProject(azdCtx.ProjectPath()); err != nil {
-		return err
+		return false, err
 	}
 
-	if err := tools.EnsureInstalled(ctx, azCli, bicepCli); err != nil {
-		return err
+	if err := tools.EnsureInstalled(ctx, azCli); err != nil {
+		return false, err
 	}
 
 	if err := ensureLoggedIn(ctx); err != nil {
-		return fmt.Errorf("failed to ensure login: %w", err)
+		return false, fmt.Errorf("failed to ensure login: %w", err)
 	}
 
 	env, err := loadOrInitEnvironment(ctx, &a.rootOptions.EnvironmentName, azdCtx, askOne)
 	if err != nil {
-		return fmt.Errorf("loading environment: %w", err)
+		return false, fmt.Errorf("loading environment: %w", err)
 	}
 
-	const rootModule = "main"
-
-	bicepPath := azdCtx.BicepModulePath(rootModule)
-
-	// When we destroy the infrastructure, we want to remove any outputs from the deployment
-	// that are in the environment. This allows templates to use outputs as "state" across deployment
-	// that persists in the environment but is removed when the infrastructure is destroyed. This is
-	// often exploited by container apps and not removing these outputs makes an `up`, `down`, `up` flow
-	// fail.
-	template, err := bicep.Compile(ctx, bicepCli, bicepPath)
+	prj, err := project.LoadProjectConfig(azdCtx.ProjectPath(), &env)
 	if err != nil {
-		return fmt.Errorf("compiling template: %w", err)
+		return false, fmt.Errorf("loading project: %w", err)
 	}
 
-	resourceGroups, err := azureutil.GetResourceGroupsForDeployment(ctx, azCli, env.GetSubscriptionId(), env.GetEnvName())
+	provider, err := newInfraProvider(ctx, azdCtx, &env, prj, askOne)
 	if err != nil {
-		return fmt.Errorf("discovering resource groups from deployment: %w", err)
+		return false, err
 	}
 
-	var allResources []tools.AzCliResource
-
-	for _, resourceGroup := range resourceGroups {
-		resources, err := azCli.ListResourceGroupResources(ctx, env.GetSubscriptionId(), resourceGroup)
-		if err != nil {
-			return fmt.Errorf("listing resource group %s: %w", resourceGroup, err)
-		}
-
-		allResources = append(allResources, resources...)
-	}
-
-	if len(allResources) > 0 && !a.forceDelete {
-		var ok bool
-		err := askOne(&survey.Confirm{
-			Message: fmt.Sprintf("This will delete %d resources, are you sure you want to continue?", len(allResources)),
-			Default: false,
-		}, &ok)
-		if err != nil {
-			return fmt.Errorf("prompting for confirmation: %w", err)
-		}
-		if !ok {
-			return nil
-		}
+	if err := tools.EnsureInstalled(ctx, provider.RequiredExternalTools()...); err != nil {
+		return false, err
 	}
 
-	// Azure KeyVaults have a "soft delete" functionality (now enabled by default) where a vault may be marked
-	// such that when it is deleted it can be recovered for a period of time. During that time, the name may
-	// not be reused.
-	//
-	// This means that running `az dev provision`, then `az dev infra delete` and finally `az dev provision`
-	// again would lead to a deployment error since the vault name is in use.
-	//
-	// Since that's behavior we'd like to support, we run a purge operation for each KeyVault after
-	// it has been deleted.
-	//
-	// See https://docs.microsoft.com/azure/key-vault/general/key-vault-recovery?tabs=azure-portal#what-are-soft-delete-and-purge-protection
-	// for more information on this feature.
-	var keyVaultsToPurge []string
-
-	for _, resource := range allResources {
-		if resource.Type == string(infra.AzureResourceTypeKeyVault) {
-			vault, err := azCli.GetKeyVault(ctx, env.GetSubscriptionId(), resource.Name)
-			if err != nil {
-				return fmt.Errorf("listing keyvault %s properties: %w", resource.Name, err)
-			}
-			if vault.Properties.EnableSoftDelete && !vault.Properties.EnablePurgeProtection {
-				keyVaultsToPurge = append(keyVaultsToPurge, resource.Name)
+	// Environments provisioned before azd recorded which of their values are outputs have none recorded, so they are
+	// recorded from the latest deployment before it is destroyed.
+	if len(env.OutputNames()) == 0 {
+		if deployment, err := provider.Outputs(ctx); err != nil {
+			log.Printf("failed reading outputs before destroying: %v", err)
+		} else {
+			outputs := make(map[string]string, len(deployment.Outputs))
+			for name, o := range deployment.Outputs {
+				outputs[name] = fmt.Sprintf("%v", o.Value)
 			}
-		}
-	}
 
-	purgeDelete := a.purgeDelete
-
-	if len(keyVaultsToPurge) > 0 && !purgeDelete {
-		fmt.Printf(""+
-			"This operation will delete %d Key Vaults. These Key Vaults have soft delete enabled allowing them to be recovered for a period \n"+
-			"of time after deletion. During this period, their names may not be reused.\n",
-			len(keyVaultsToPurge))
-		err := askOne(&survey.Confirm{
-			Message: "Would you like to *permanently* delete these Key Vaults instead, allowing their names to be reused?",
-			Default: false,
-		}, &purgeDelete)
-		if err != nil {
-			return fmt.Errorf("prompting for purge confirmation: %w", err)
+			env.ApplyOutputs(prj.Infra.ModuleName(), outputs)
 		}
 	}
 
-	// Do the deleting. The calls to `DeleteResourceGroup` and `DeleteSubscriptionDeployment` block
-	// until everything has been deleted which can take a bit, so indicate we are working with a spinner.
-	deleteFn := func() error {
-		for _, resourceGroup := range resourceGroups {
-			if err := azCli.DeleteResourceGroup(ctx, env.GetSubscriptionId(), resourceGroup); err != nil {
-				return fmt.Errorf("deleting resource group %s: %w", resourceGroup, err)
-			}
-		}
-
-		if purgeDelete {
-			for _, vaultName := range keyVaultsToPurge {
-				err := azCli.PurgeKeyVault(ctx, env.GetSubscriptionId(), vaultName)
-				if err != nil {
-					return fmt.Errorf("purging key vault %s: %w", vaultName, err)
-				}
-			}
-		}
-
-		if err := azCli.DeleteSubscriptionDeployment(ctx, env.GetSubscriptionId(), env.GetEnvName()); err != nil {
-			return fmt.Errorf("deleting subscription deployment: %w", err)
-		}
-		return nil
+	res, err := provider.Destroy(ctx, provisioning.DestroyOptions{Force: a.forceDelete, Purge: a.purgeDelete})
+	if err != nil {
+		return false, err
 	}
 
-	spinner := spin.NewSpinner("Deleting Azure resources")
-	if err := spinner.Run(deleteFn); err != nil {
-		return fmt.Errorf("destroying: %w", err)
+	// The user chose not to continue
+	if res == nil {
+		return false, nil
 	}
 
-	// Remove any outputs from the template from the environment since destroying the infrastructure
-	// invalidated them all.
-	for outputName := range template.Outputs {
-		delete(env.Values, outputName)
+	// When we destroy the infrastructure, we want to remove any outputs from the deployment
+	// that are in the environment. This allows templates to use outputs as "state" across deployment
+	// that persists in the environment but is removed when the infrastructure is destroyed. This is
+	// often exploited by container apps and not removing these outputs makes an `up`, `down`, `up` flow
+	// fail.
+	env.RemoveOutputs()
+
+	// Resource names are deterministic, so services must be deployed again to the resources which are provisioned
+	// next, rather than skipped as unchanged.
+	if err := newDeploymentHistory(azdCtx, env).Invalidate(); err != nil {
+		return false, fmt.Errorf("invalidating deployment history: %w", err)
 	}
 
 	if err := env.Save(); err != nil {
-		return fmt.Errorf("saving environment: %w", err)
+		return false, fmt.Errorf("saving environment: %w", err)
 	}
 
-	return nil
+	return true, nil
 }
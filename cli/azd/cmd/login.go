@@ -10,6 +10,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/azure/azure-dev/cli/azd/pkg/auth"
 	"github.com/azure/azure-dev/cli/azd/pkg/commands"
 	"github.com/azure/azure-dev/cli/azd/pkg/environment"
 	"github.com/azure/azure-dev/cli/azd/pkg/output"
@@ -26,7 +27,22 @@ func loginCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
 		rootOptions,
 		"login",
 		"Log in to Azure.",
-		"",
+		`Log in to Azure.
+
+By default, azd uses the account the Azure CLI is logged in to, and `+withBackticks("azd login")+` runs `+withBackticks("az login")+`.
+
+To log in to a service principal instead, pass `+withBackticks("--client-id")+` and `+withBackticks("--tenant-id")+` along with one of:
+
+	`+withBackticks("--client-certificate")+` with the path of a PEM file containing the certificate and its private key
+	`+withBackticks("--federated-token-file")+` with the path of a file containing a token issued by a trusted identity provider
+	the secret of the service principal, set in the AZURE_CLIENT_SECRET environment variable
+
+Pass `+withBackticks("--managed-identity")+` to log in to the managed identity of the Azure resource azd runs on.
+
+The login is saved in ~/.azd and used by later commands, until `+withBackticks("azd login")+` is run without these flags. Secrets are never saved.
+A service principal configured with the AZURE_CLIENT_ID, AZURE_TENANT_ID and AZURE_CLIENT_SECRET, AZURE_CLIENT_CERTIFICATE_PATH or AZURE_FEDERATED_TOKEN_FILE environment variables is used instead of the saved login, and each command reports it is used.
+
+When azd uses a saved login or a service principal configured in the environment, it calls Azure directly instead of running the Azure CLI. Set the AZURE_DEV_AZURE_CLIENT environment variable to 'cli' to always use the Azure CLI, or to 'rest' to always call Azure directly.`,
 	)
 
 	return output.AddOutputParam(
@@ -37,9 +53,14 @@ func loginCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
 }
 
 type loginAction struct {
-	rootOptions     *commands.GlobalCommandOptions
-	onlyCheckStatus bool
-	useDeviceCode   bool
+	rootOptions        *commands.GlobalCommandOptions
+	onlyCheckStatus    bool
+	useDeviceCode      bool
+	clientId           string
+	tenantId           string
+	clientCertificate  string
+	federatedTokenFile string
+	managedIdentity    bool
 }
 
 var _ commands.Action = &loginAction{}
@@ -50,14 +71,16 @@ func (la *loginAction) Run(ctx context.Context, cmd *cobra.Command, args []strin
 		return err
 	}
 
-	azCli := commands.GetAzCliFromContext(ctx)
-	if err := tools.EnsureInstalled(ctx, azCli); err != nil {
-		return err
+	if !la.onlyCheckStatus {
+		if err := la.login(ctx); err != nil {
+			return err
+		}
 	}
 
-	if !la.onlyCheckStatus {
-		if err := runLogin(ctx, la.useDeviceCode); err != nil {
-			return fmt.Errorf("logging in: %w", err)
+	azCli := commands.GetAzCliFromContext(ctx)
+	if la.rootOptions.Credential == nil {
+		if err := tools.EnsureInstalled(ctx, azCli); err != nil {
+			return err
 		}
 	}
 
@@ -84,9 +107,119 @@ func (la *loginAction) Run(ctx context.Context, cmd *cobra.Command, args []strin
 	return formatter.Format(res, cmd.OutOrStdout(), nil)
 }
 
+// login saves the identity selected with the flags, or logs in to the Azure CLI when no identity is selected.
+func (la *loginAction) login(ctx context.Context) error {
+	authManager, err := auth.NewDefaultManager()
+	if err != nil {
+		return err
+	}
+
+	config, err := la.loginConfig()
+	if err != nil {
+		return err
+	}
+
+	if config == nil {
+		if err := authManager.RemoveLoginConfig(); err != nil {
+			return err
+		}
+
+		// A service principal configured in the environment is still used.
+		credential, err := authManager.CurrentCredential()
+		if err != nil {
+			return fmt.Errorf("loading credential: %w", err)
+		}
+
+		la.rootOptions.Credential = credential
+		if credential != nil {
+			return nil
+		}
+
+		if _, has := os.LookupEnv("AZURE_DEV_AZURE_CLIENT"); !has {
+			la.rootOptions.AzCliKind = tools.AzCliKindCli
+		}
+
+		azCli := commands.GetAzCliFromContext(ctx)
+		if err := tools.EnsureInstalled(ctx, azCli); err != nil {
+			return err
+		}
+
+		if err := runLogin(ctx, la.useDeviceCode); err != nil {
+			return fmt.Errorf("logging in: %w", err)
+		}
+
+		return nil
+	}
+
+	credential, err := authManager.NewCredential(*config)
+	if err != nil {
+		return err
+	}
+
+	// The credential is checked before it is saved, so a misconfigured login does not break later commands.
+	if _, err := credential.GetToken(ctx, tools.ResourceManagerScope); err != nil {
+		return fmt.Errorf("logging in: %w", err)
+	}
+
+	if err := authManager.SaveLoginConfig(*config); err != nil {
+		return fmt.Errorf("saving login: %w", err)
+	}
+
+	la.rootOptions.Credential = credential
+	if _, has := os.LookupEnv("AZURE_DEV_AZURE_CLIENT"); !has {
+		la.rootOptions.AzCliKind = tools.AzCliKindRest
+	}
+
+	return nil
+}
+
+// loginConfig returns the identity selected with the flags, or nil when none is selected.
+func (la *loginAction) loginConfig() (*auth.LoginConfig, error) {
+	config := &auth.LoginConfig{
+		TenantId:               la.tenantId,
+		ClientId:               la.clientId,
+		ClientCertificatePath:  la.clientCertificate,
+		FederatedTokenFilePath: la.federatedTokenFile,
+	}
+
+	switch {
+	case la.managedIdentity:
+		if la.tenantId != "" || la.clientCertificate != "" || la.federatedTokenFile != "" {
+			return nil, errors.New("--managed-identity can only be combined with --client-id")
+		}
+		config.Kind = auth.ManagedIdentityLogin
+	case la.clientCertificate != "" && la.federatedTokenFile != "":
+		return nil, errors.New("only one of --client-certificate and --federated-token-file can be set")
+	case la.clientCertificate != "":
+		config.Kind = auth.ClientCertificateLogin
+	case la.federatedTokenFile != "":
+		config.Kind = auth.FederatedTokenLogin
+	case la.clientId != "" || la.tenantId != "":
+		if os.Getenv(auth.ClientSecretEnvVarName) == "" {
+			return nil, fmt.Errorf(
+				"logging in to a service principal requires --client-certificate, --federated-token-file or its secret set in %s",
+				auth.ClientSecretEnvVarName)
+		}
+		config.Kind = auth.ClientSecretLogin
+	default:
+		return nil, nil
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
 func (la *loginAction) SetupFlags(persistent *pflag.FlagSet, local *pflag.FlagSet) {
 	local.BoolVar(&la.onlyCheckStatus, "check-status", false, "Checks the log-in status instead of logging in.")
 	local.BoolVar(&la.useDeviceCode, "use-device-code", false, "When true, log in by using a device code instead of a browser.")
+	local.StringVar(&la.clientId, "client-id", "", "The client id of the service principal, or of the user assigned managed identity, to log in to.")
+	local.StringVar(&la.tenantId, "tenant-id", "", "The tenant id of the service principal to log in to.")
+	local.StringVar(&la.clientCertificate, "client-certificate", "", "The path of a PEM file containing the certificate of the service principal and its private key.")
+	local.StringVar(&la.federatedTokenFile, "federated-token-file", "", "The path of a file containing a token issued by an identity provider trusted by the service principal.")
+	local.BoolVar(&la.managedIdentity, "managed-identity", false, "Logs in to the managed identity of the Azure resource azd runs on.")
 }
 
 // ensureLoggedIn checks to see if the user is currently logged in. If not, the equivalent of `az login` is run.
@@ -97,6 +230,10 @@ func ensureLoggedIn(ctx context.Context) error {
 		if err := runLogin(ctx, false); err != nil {
 			return fmt.Errorf("logging in: %w", err)
 		}
+	} else if errors.Is(err, tools.ErrNoCredential) {
+		// Azure is called without the Azure CLI, so the interactive login of the Azure CLI would not be used.
+		return fmt.Errorf("%s is '%s', which calls Azure without the Azure CLI: %w",
+			"AZURE_DEV_AZURE_CLIENT", tools.AzCliKindRest, err)
 	} else if err != nil {
 		return fmt.Errorf("fetching access token: %w", err)
 	}
@@ -4,14 +4,22 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/azure/azure-dev/cli/azd/pkg/azureutil"
 	"github.com/azure/azure-dev/cli/azd/pkg/commands"
 	"github.com/azure/azure-dev/cli/azd/pkg/environment"
 	"github.com/azure/azure-dev/cli/azd/pkg/infra"
+	"github.com/azure/azure-dev/cli/azd/pkg/project"
 	"github.com/azure/azure-dev/cli/azd/pkg/tools"
 	"github.com/pbnj/go-open"
 	"github.com/spf13/cobra"
@@ -33,9 +41,14 @@ Examples:
 	$ azd monitor --overview
 	$ azd monitor -–live
 	$ azd monitor --logs
+	$ azd monitor --tail
+	$ azd monitor --tail --service api
+	$ azd monitor query --errors --since 1h
 		
 For more information, go to https://aka.ms/azure-dev/monitor.`,
 	)
+	cmd.AddCommand(monitorQueryCmd(rootOptions))
+
 	return cmd
 }
 
@@ -43,6 +56,8 @@ type monitorAction struct {
 	monitorLive     bool
 	monitorLogs     bool
 	monitorOverview bool
+	monitorTail     bool
+	serviceName     string
 	rootOptions     *commands.GlobalCommandOptions
 }
 
@@ -50,9 +65,11 @@ func (m *monitorAction) SetupFlags(
 	persis *pflag.FlagSet,
 	local *pflag.FlagSet,
 ) {
-	persis.BoolVar(&m.monitorLive, "live", false, "Open a browser to Application Insights Live Metrics. Live Metrics is currently not supported for Python applications.")
-	persis.BoolVar(&m.monitorLogs, "logs", false, "Open a browser to Application Insights Logs.")
-	persis.BoolVar(&m.monitorOverview, "overview", false, "Open a browser to Application Insights Overview Dashboard.")
+	local.BoolVar(&m.monitorLive, "live", false, "Open a browser to Application Insights Live Metrics. Live Metrics is currently not supported for Python applications.")
+	local.BoolVar(&m.monitorLogs, "logs", false, "Open a browser to Application Insights Logs.")
+	local.BoolVar(&m.monitorOverview, "overview", false, "Open a browser to Application Insights Overview Dashboard.")
+	local.BoolVar(&m.monitorTail, "tail", false, "Stream the logs of the services to the terminal, until interrupted with Ctrl-C.")
+	local.StringVar(&m.serviceName, "service", "", "Streams the logs of a specific service with --tail (when unspecified, the logs of all services are streamed).")
 }
 
 func (m *monitorAction) Run(ctx context.Context, _ *cobra.Command, args []string, azdCtx *environment.AzdContext) error {
@@ -71,7 +88,15 @@ func (m *monitorAction) Run(ctx context.Context, _ *cobra.Command, args []string
 		return fmt.Errorf("failed to ensure login: %w", err)
 	}
 
-	if !m.monitorLive && !m.monitorLogs && !m.monitorOverview {
+	if m.monitorTail && (m.monitorLive || m.monitorLogs || m.monitorOverview) {
+		return errors.New("--tail cannot be combined with --live, --logs or --overview")
+	}
+
+	if m.serviceName != "" && !m.monitorTail {
+		return errors.New("--service can only be used with --tail")
+	}
+
+	if !m.monitorTail && !m.monitorLive && !m.monitorLogs && !m.monitorOverview {
 		m.monitorLive = true
 	}
 
@@ -80,34 +105,22 @@ func (m *monitorAction) Run(ctx context.Context, _ *cobra.Command, args []string
 		return fmt.Errorf("loading environment: %w", err)
 	}
 
+	if m.monitorTail {
+		return m.tail(ctx, azCli, azdCtx, &env)
+	}
+
 	tenantId, err := azCli.GetSubscriptionTenant(ctx, env.GetSubscriptionId())
 	if err != nil {
 		return fmt.Errorf("getting tenant id for subscription: %w", err)
 	}
 
-	resourceGroups, err := azureutil.GetResourceGroupsForDeployment(ctx, azCli, env.GetSubscriptionId(), env.GetEnvName())
+	monitoringResources, err := findMonitoringResources(ctx, azCli, &env)
 	if err != nil {
-		return fmt.Errorf("discovering resource groups from deployment: %w", err)
+		return err
 	}
 
-	var insightsResources []tools.AzCliResource
-	var portalResources []tools.AzCliResource
-
-	for _, resourceGroup := range resourceGroups {
-		resources, err := azCli.ListResourceGroupResources(ctx, env.GetSubscriptionId(), resourceGroup)
-		if err != nil {
-			return fmt.Errorf("listing resources: %w", err)
-		}
-
-		for _, resource := range resources {
-			switch resource.Type {
-			case string(infra.AzureResourceTypePortalDashboard):
-				portalResources = append(portalResources, resource)
-			case string(infra.AzureResourceTypeAppInsightComponent):
-				insightsResources = append(insightsResources, resource)
-			}
-		}
-	}
+	insightsResources := monitoringResources[infra.AzureResourceTypeAppInsightComponent]
+	portalResources := monitoringResources[infra.AzureResourceTypePortalDashboard]
 
 	if len(insightsResources) == 0 && (m.monitorLive || m.monitorLogs) {
 		return fmt.Errorf("application does not contain an Application Insights resource")
@@ -143,3 +156,177 @@ func (m *monitorAction) Run(ctx context.Context, _ *cobra.Command, args []string
 
 	return nil
 }
+
+// findMonitoringResources finds the Application Insights components, the dashboards and the Log Analytics workspaces
+// of the resource groups of the deployment of env, by type.
+func findMonitoringResources(ctx context.Context, azCli tools.AzCli, env *environment.Environment) (map[infra.AzureResourceType][]tools.AzCliResource, error) {
+	resourceGroups, err := azureutil.GetResourceGroupsForDeployment(ctx, azCli, env.GetSubscriptionId(), env.GetEnvName())
+	if err != nil {
+		return nil, fmt.Errorf("discovering resource groups from deployment: %w", err)
+	}
+
+	monitoringResources := map[infra.AzureResourceType][]tools.AzCliResource{}
+
+	for _, resourceGroup := range resourceGroups {
+		resources, err := azCli.ListResourceGroupResources(ctx, env.GetSubscriptionId(), resourceGroup)
+		if err != nil {
+			return nil, fmt.Errorf("listing resources: %w", err)
+		}
+
+		for _, resource := range resources {
+			switch resourceType := infra.AzureResourceType(resource.Type); resourceType {
+			case infra.AzureResourceTypePortalDashboard,
+				infra.AzureResourceTypeAppInsightComponent,
+				infra.AzureResourceTypeLogAnalyticsWorkspace:
+				monitoringResources[resourceType] = append(monitoringResources[resourceType], resource)
+			}
+		}
+	}
+
+	return monitoringResources, nil
+}
+
+// tail streams the logs of the services of the project, or of the service selected with --service, until the command
+// is interrupted. The logs of each service are prefixed with its name.
+func (m *monitorAction) tail(ctx context.Context, azCli tools.AzCli, azdCtx *environment.AzdContext, env *environment.Environment) error {
+	prj, err := project.LoadProjectConfig(azdCtx.ProjectPath(), env)
+	if err != nil {
+		return fmt.Errorf("loading project: %w", err)
+	}
+
+	if m.serviceName != "" && !prj.HasService(m.serviceName) {
+		return fmt.Errorf("service name '%s' doesn't exist", m.serviceName)
+	}
+
+	resourceGroupName := prj.ResourceGroupName
+	if resourceGroupName == "" {
+		resourceGroupName, err = azureutil.FindResourceGroupForEnvironment(ctx, env)
+		if err != nil {
+			return err
+		}
+	}
+
+	serviceNames := make([]string, 0, len(prj.Services))
+	for name := range prj.Services {
+		if m.serviceName == "" || m.serviceName == name {
+			serviceNames = append(serviceNames, name)
+		}
+	}
+	sort.Strings(serviceNames)
+
+	type serviceStream struct {
+		name   string
+		stream func(ctx context.Context, writer io.Writer) error
+	}
+
+	var streams []serviceStream
+	for _, name := range serviceNames {
+		svc := prj.Services[name]
+
+		resourceName := svc.ResourceName
+		if strings.TrimSpace(resourceName) == "" {
+			resourceName, err = project.GetServiceResourceName(ctx, resourceGroupName, svc.Name, env)
+			if err != nil {
+				return fmt.Errorf("getting resource name: %w", err)
+			}
+		}
+
+		var streamLogs func(context.Context, string, string, string, io.Writer) error
+		switch project.ServiceTargetKind(svc.Host) {
+		case project.AppServiceTarget, project.AzureFunctionTarget:
+			streamLogs = azCli.StreamAppServiceLogs
+		case project.ContainerAppTarget:
+			streamLogs = azCli.StreamContainerAppLogs
+		default:
+			if m.serviceName != "" {
+				return fmt.Errorf("the logs of service %s cannot be streamed, since it is hosted by '%s'", name, svc.Host)
+			}
+
+			fmt.Printf("Skipping service %s, the logs of services hosted by '%s' cannot be streamed.\n", name, svc.Host)
+			continue
+		}
+
+		streams = append(streams, serviceStream{
+			name: name,
+			stream: func(ctx context.Context, writer io.Writer) error {
+				return streamLogs(ctx, env.GetSubscriptionId(), resourceGroupName, resourceName, writer)
+			},
+		})
+	}
+
+	if len(streams) == 0 {
+		return errors.New("the project does not have services whose logs can be streamed")
+	}
+
+	streamedNames := make([]string, 0, len(streams))
+	for _, stream := range streams {
+		streamedNames = append(streamedNames, stream.name)
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	printWithStyling("Streaming the logs of %s. Press %s to stop.\n",
+		withHighLightFormat(strings.Join(streamedNames, ", ")), withHighLightFormat("Ctrl-C"))
+
+	var wg sync.WaitGroup
+	var outputMu sync.Mutex
+	errs := make(chan error, len(streams))
+
+	for _, stream := range streams {
+		stream := stream
+		writer := &serviceLogWriter{prefix: withServicePrefix(stream.name), out: os.Stdout, mu: &outputMu}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if err := stream.stream(ctx, writer); err != nil {
+				errs <- fmt.Errorf("streaming logs of service %s: %w", stream.name, err)
+				// The other streams are stopped as well, rather than following them without the failed one.
+				cancel()
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	return <-errs
+}
+
+// serviceLogWriter prefixes each line written to it with the prefix of a service, so the logs of several services can
+// be told apart. Partial lines are buffered until they are complete. mu is shared by the writers of each service,
+// since they write to the same output.
+type serviceLogWriter struct {
+	prefix string
+	out    io.Writer
+	mu     *sync.Mutex
+	buf    []byte
+}
+
+func (w *serviceLogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		line := strings.TrimSuffix(string(w.buf[:i]), "\r")
+		w.buf = w.buf[i+1:]
+
+		if _, err := fmt.Fprintf(w.out, "%s %s\n", w.prefix, line); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,247 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/commands"
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/infra"
+	"github.com/azure/azure-dev/cli/azd/pkg/output"
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func monitorQueryCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
+	cmd := commands.Build(
+		&monitorQueryAction{
+			rootOptions: rootOptions,
+		},
+		rootOptions,
+		"query [<kql>]",
+		"Query the logs of a deployed application.",
+		`Query the logs of a deployed application.
+
+Runs a KQL query over the logs of the Application Insights resources of the application, or of its Log Analytics
+workspaces when it does not have any, and prints the results. Instead of a query, use one of the flags for common
+queries, such as --errors.
+
+Examples:
+
+	$ azd monitor query --errors
+	$ azd monitor query --failed-requests --since 24h
+	$ azd monitor query "requests | summarize count() by resultCode" --output json`,
+	)
+	cmd.Args = cobra.MaximumNArgs(1)
+
+	return output.AddOutputParam(cmd, []output.Format{output.TableFormat, output.JsonFormat}, output.TableFormat)
+}
+
+// monitorCannedQuery is a query run with a flag of azd monitor query. Its tables and columns are named differently
+// for Application Insights components and for Log Analytics workspaces.
+type monitorCannedQuery struct {
+	insights  string
+	workspace string
+}
+
+var monitorCannedQueries = map[string]monitorCannedQuery{
+	"errors": {
+		insights: "exceptions | order by timestamp desc | " +
+			"project timestamp, cloud_RoleName, type, outerMessage | take 100",
+		workspace: "AppExceptions | order by TimeGenerated desc | " +
+			"project TimeGenerated, AppRoleName, ExceptionType, OuterMessage | take 100",
+	},
+	"failed-requests": {
+		insights: "requests | where success == false | order by timestamp desc | " +
+			"project timestamp, cloud_RoleName, name, resultCode, duration | take 100",
+		workspace: "AppRequests | where Success == false | order by TimeGenerated desc | " +
+			"project TimeGenerated, AppRoleName, Name, ResultCode, DurationMs | take 100",
+	},
+	"traces": {
+		insights: "traces | order by timestamp desc | " +
+			"project timestamp, cloud_RoleName, severityLevel, message | take 100",
+		workspace: "AppTraces | order by TimeGenerated desc | " +
+			"project TimeGenerated, AppRoleName, SeverityLevel, Message | take 100",
+	},
+}
+
+type monitorQueryAction struct {
+	errors         bool
+	failedRequests bool
+	traces         bool
+	since          time.Duration
+	rootOptions    *commands.GlobalCommandOptions
+}
+
+func (m *monitorQueryAction) SetupFlags(
+	persis *pflag.FlagSet,
+	local *pflag.FlagSet,
+) {
+	local.BoolVar(&m.errors, "errors", false, "Query the most recent exceptions.")
+	local.BoolVar(&m.failedRequests, "failed-requests", false, "Query the most recent failed requests.")
+	local.BoolVar(&m.traces, "traces", false, "Query the most recent traces.")
+	local.DurationVar(&m.since, "since", time.Hour, "Query the logs of this period, such as 30m or 24h.")
+}
+
+func (m *monitorQueryAction) Run(ctx context.Context, cmd *cobra.Command, args []string, azdCtx *environment.AzdContext) error {
+	azCli := commands.GetAzCliFromContext(ctx)
+	askOne := makeAskOne(m.rootOptions.NoPrompt)
+
+	formatter, err := output.GetFormatter(cmd)
+	if err != nil {
+		return err
+	}
+
+	var cannedNames []string
+	for name, set := range map[string]bool{"errors": m.errors, "failed-requests": m.failedRequests, "traces": m.traces} {
+		if set {
+			cannedNames = append(cannedNames, name)
+		}
+	}
+
+	switch {
+	case len(args) == 0 && len(cannedNames) == 0:
+		return errors.New("specify a KQL query, or one of --errors, --failed-requests or --traces")
+	case len(args)+len(cannedNames) > 1:
+		return errors.New("only one of a KQL query, --errors, --failed-requests or --traces can be specified")
+	}
+
+	if m.since <= 0 {
+		return errors.New("--since must be a positive duration")
+	}
+
+	if err := ensureProject(azdCtx.ProjectPath()); err != nil {
+		return err
+	}
+
+	if err := tools.EnsureInstalled(ctx, azCli); err != nil {
+		return err
+	}
+
+	if err := ensureLoggedIn(ctx); err != nil {
+		return fmt.Errorf("failed to ensure login: %w", err)
+	}
+
+	env, err := loadOrInitEnvironment(ctx, &m.rootOptions.EnvironmentName, azdCtx, askOne)
+	if err != nil {
+		return fmt.Errorf("loading environment: %w", err)
+	}
+
+	monitoringResources, err := findMonitoringResources(ctx, azCli, &env)
+	if err != nil {
+		return err
+	}
+
+	// Application Insights components are preferred, since workspace-based components store their logs in a
+	// workspace which may be shared with other applications.
+	resources := monitoringResources[infra.AzureResourceTypeAppInsightComponent]
+	isWorkspace := false
+	if len(resources) == 0 {
+		resources = monitoringResources[infra.AzureResourceTypeLogAnalyticsWorkspace]
+		isWorkspace = true
+	}
+
+	if len(resources) == 0 {
+		return errors.New("application does not contain an Application Insights or a Log Analytics resource")
+	}
+
+	var query string
+	if len(args) == 1 {
+		query = args[0]
+	} else if isWorkspace {
+		query = monitorCannedQueries[cannedNames[0]].workspace
+	} else {
+		query = monitorCannedQueries[cannedNames[0]].insights
+	}
+
+	var results []tools.AzCliLogsQueryResult
+	for _, resource := range resources {
+		result, err := azCli.QueryLogs(ctx, resource.Id, query, fmt.Sprintf("PT%dS", int(m.since.Seconds())))
+		if err != nil {
+			return fmt.Errorf("querying logs of %s: %w", resource.Name, err)
+		}
+
+		results = append(results, result)
+	}
+
+	return writeLogsQueryResults(results, formatter, cmd.OutOrStdout())
+}
+
+// writeLogsQueryResults writes the rows of the primary tables of the results, which are merged since the same query
+// is run over each resource. Rows are written as objects keyed by column name in JSON, and as the columns of the first
+// table in a table.
+func writeLogsQueryResults(results []tools.AzCliLogsQueryResult, formatter output.Formatter, writer io.Writer) error {
+	var columns []string
+	rows := []map[string]interface{}{}
+
+	for _, result := range results {
+		if len(result.Tables) == 0 {
+			continue
+		}
+
+		table := result.Tables[0]
+		if columns == nil {
+			for _, column := range table.Columns {
+				columns = append(columns, column.Name)
+			}
+		}
+
+		for _, values := range table.Rows {
+			row := map[string]interface{}{}
+			for i, value := range values {
+				if i < len(table.Columns) {
+					row[table.Columns[i].Name] = value
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	if formatter.Kind() != output.TableFormat {
+		return formatter.Format(rows, writer, nil)
+	}
+
+	if len(rows) == 0 {
+		fmt.Fprintln(writer, "No results.")
+		return nil
+	}
+
+	tableColumns := make([]output.Column, 0, len(columns))
+	for i, column := range columns {
+		tableColumns = append(tableColumns, output.Column{
+			Heading:       column,
+			ValueTemplate: fmt.Sprintf("{{index . %d}}", i),
+		})
+	}
+
+	tableRows := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		tableRow := make([]string, 0, len(columns))
+		for _, column := range columns {
+			tableRow = append(tableRow, formatLogsQueryValue(row[column]))
+		}
+		tableRows = append(tableRows, tableRow)
+	}
+
+	return formatter.Format(tableRows, writer, output.TableFormatterOptions{Columns: tableColumns})
+}
+
+// formatLogsQueryValue formats a value of a row for a table, where numbers are not written in exponent notation.
+func formatLogsQueryValue(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/output"
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteLogsQueryResults(t *testing.T) {
+	table := func(rows ...[]interface{}) tools.AzCliLogsQueryResult {
+		return tools.AzCliLogsQueryResult{
+			Tables: []tools.AzCliLogsQueryTable{
+				{
+					Name: "PrimaryResult",
+					Columns: []tools.AzCliLogsQueryColumn{
+						{Name: "cloud_RoleName", Type: "string"},
+						{Name: "duration", Type: "real"},
+					},
+					Rows: rows,
+				},
+			},
+		}
+	}
+
+	// The rows of the results of each resource are merged.
+	results := []tools.AzCliLogsQueryResult{
+		table([]interface{}{"api", 1234567.5}),
+		table([]interface{}{"web", nil}),
+	}
+
+	t.Run("Table", func(t *testing.T) {
+		var out strings.Builder
+		require.NoError(t, writeLogsQueryResults(results, &output.TableFormatter{}, &out))
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		require.Len(t, lines, 3)
+		require.Equal(t, []string{"cloud_RoleName", "duration"}, strings.Fields(lines[0]))
+		require.Equal(t, []string{"api", "1234567.5"}, strings.Fields(lines[1]))
+		require.Equal(t, []string{"web"}, strings.Fields(lines[2]))
+	})
+
+	t.Run("Json", func(t *testing.T) {
+		var out strings.Builder
+		require.NoError(t, writeLogsQueryResults(results, &output.JsonFormatter{}, &out))
+		require.JSONEq(t, `[
+			{"cloud_RoleName": "api", "duration": 1234567.5},
+			{"cloud_RoleName": "web", "duration": null}
+		]`, out.String())
+	})
+
+	t.Run("NoResults", func(t *testing.T) {
+		var out strings.Builder
+		require.NoError(t, writeLogsQueryResults([]tools.AzCliLogsQueryResult{table()}, &output.TableFormatter{}, &out))
+		require.Equal(t, "No results.\n", out.String())
+	})
+}
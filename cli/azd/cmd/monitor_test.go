@@ -0,0 +1,33 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceLogWriter(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+
+	api := &serviceLogWriter{prefix: "(api)", out: &out, mu: &mu}
+	web := &serviceLogWriter{prefix: "(web)", out: &out, mu: &mu}
+
+	_, err := api.Write([]byte("starting\r\nlisten"))
+	require.NoError(t, err)
+
+	// Partial lines are only written once complete, so lines of other services are not split.
+	_, err = web.Write([]byte("ready\n"))
+	require.NoError(t, err)
+
+	n, err := api.Write([]byte("ing on 8080\n"))
+	require.NoError(t, err)
+	require.Equal(t, len("ing on 8080\n"), n)
+
+	require.Equal(t, "(api) starting\n(web) ready\n(api) listening on 8080\n", out.String())
+}
@@ -0,0 +1,216 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/commands"
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/osutil"
+	"github.com/azure/azure-dev/cli/azd/pkg/output"
+	"github.com/azure/azure-dev/cli/azd/pkg/project"
+	"github.com/azure/azure-dev/cli/azd/pkg/spin"
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func packageCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
+	cmd := commands.Build(
+		&packageAction{rootOptions: rootOptions},
+		rootOptions,
+		"package",
+		"Package the application's code to be deployed to Azure.",
+		`Package the application's code to be deployed to Azure.
+
+Each service is built and its deployable artifact is written to a versioned directory, along with a manifest describing the artifacts. Services hosted by container apps are packaged as a container image, which is tagged with the version. Services hosted by static web apps are skipped, since they cannot be deployed from a package. The same artifacts can be deployed to any environment with `+withBackticks("azd deploy --from-package <path>")+`.
+
+Examples:
+
+	$ azd package
+	$ azd package --service api --version 1.0.0
+	$ azd package --output-path ./dist`,
+	)
+
+	return output.AddOutputParam(
+		cmd,
+		[]output.Format{output.JsonFormat, output.NoneFormat},
+		output.NoneFormat)
+}
+
+type packageAction struct {
+	serviceName string
+	outputPath  string
+	version     string
+	rootOptions *commands.GlobalCommandOptions
+}
+
+type PackageResult struct {
+	// The directory containing the manifest and artifacts
+	Path     string                   `json:"path"`
+	Manifest *project.PackageManifest `json:"manifest"`
+}
+
+func (p *packageAction) SetupFlags(persis, local *pflag.FlagSet) {
+	local.StringVar(&p.serviceName, "service", "", "Packages a specific service (when the string is unspecified, all services that are listed in the "+environment.ProjectFileName+" file are packaged).")
+	local.StringVar(&p.outputPath, "output-path", "", "The directory the packages are written to. Defaults to .azure/packages in the project directory.")
+	local.StringVar(&p.version, "version", "", "The version of the packages. Defaults to the current UTC time, for example 20220101120000.")
+}
+
+func (p *packageAction) Run(ctx context.Context, cmd *cobra.Command, args []string, azdCtx *environment.AzdContext) error {
+	if err := ensureProject(azdCtx.ProjectPath()); err != nil {
+		return err
+	}
+
+	// Packaging does not require an environment, but when one exists its values are made available to the build.
+	env, err := loadEnvironmentIfExists(p.rootOptions.EnvironmentName, azdCtx)
+	if err != nil {
+		return fmt.Errorf("loading environment: %w", err)
+	}
+
+	projConfig, err := project.LoadProjectConfig(azdCtx.ProjectPath(), &env)
+	if err != nil {
+		return fmt.Errorf("loading project: %w", err)
+	}
+
+	if p.serviceName != "" && !projConfig.HasService(p.serviceName) {
+		return fmt.Errorf("service name '%s' doesn't exist", p.serviceName)
+	}
+
+	graph, err := project.NewServiceGraph(projConfig.Services)
+	if err != nil {
+		return fmt.Errorf("validating service dependencies: %w", err)
+	}
+
+	var servicesToPackage []*project.ServiceConfig
+	var allTools []tools.ExternalTool
+	for _, name := range graph.Order() {
+		if p.serviceName != "" && name != p.serviceName {
+			continue
+		}
+
+		// Services whose host cannot deploy from a package are only packaged when named, which reports the error.
+		svc := projConfig.Services[name]
+		if p.serviceName == "" && !svc.SupportsPackage() {
+			fmt.Fprintf(cmd.ErrOrStderr(),
+				"WARNING: service %s is not packaged, since it is hosted by %s, which does not support deploying from "+
+					"a package.\n", name, svc.Host)
+			continue
+		}
+
+		frameworkService, err := svc.GetFrameworkService(ctx, &env)
+		if err != nil {
+			return fmt.Errorf("getting framework services: %w", err)
+		}
+
+		allTools = append(allTools, (*frameworkService).RequiredExternalTools()...)
+		servicesToPackage = append(servicesToPackage, svc)
+	}
+
+	if err := tools.EnsureInstalled(ctx, tools.Unique(allTools)...); err != nil {
+		return err
+	}
+
+	formatter, err := output.GetFormatter(cmd)
+	if err != nil {
+		return err
+	}
+	interactive := formatter.Kind() == output.NoneFormat
+
+	now := time.Now()
+	version := p.version
+	if version == "" {
+		version = project.NewPackageVersion(now)
+	}
+
+	outputPath := p.outputPath
+	if outputPath == "" {
+		outputPath = filepath.Join(azdCtx.EnvironmentDirectory(), "packages")
+	}
+
+	packageDir := filepath.Join(outputPath, version)
+	if err := os.MkdirAll(packageDir, osutil.PermissionDirectory); err != nil {
+		return fmt.Errorf("creating package directory: %w", err)
+	}
+
+	// Packaging a single service updates the manifest of an existing version, so services can be packaged separately.
+	manifest := project.NewPackageManifest(version, now)
+	if _, err := os.Stat(filepath.Join(packageDir, project.PackageManifestFileName)); err == nil {
+		manifest, _, err = project.LoadPackageManifest(packageDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, svc := range servicesToPackage {
+		packageAndReportProgress := func(showProgress func(string)) (project.ServicePackage, error) {
+			progress := make(chan string)
+			done := make(chan struct{})
+
+			// Report any progress
+			go func() {
+				defer close(done)
+				for message := range progress {
+					showProgress(fmt.Sprintf("- %s...", message))
+				}
+			}()
+
+			servicePackage, err := svc.Package(ctx, &env, packageDir, version, progress)
+			close(progress)
+			<-done
+
+			return servicePackage, err
+		}
+
+		var servicePackage project.ServicePackage
+		if interactive {
+			packageMsg := fmt.Sprintf("Packaging service %s", svc.Name)
+			fmt.Println(packageMsg)
+			spinner := spin.NewSpinner(packageMsg)
+			spinner.Start()
+			servicePackage, err = packageAndReportProgress(spinner.Title)
+			spinner.Stop()
+		} else {
+			servicePackage, err = packageAndReportProgress(func(string) {})
+		}
+		if err != nil {
+			return err
+		}
+
+		manifest.Services[svc.Name] = servicePackage
+
+		if interactive {
+			artifact := servicePackage.Image
+			if servicePackage.Kind == project.ZipPackage {
+				artifact = filepath.Join(packageDir, servicePackage.Path)
+			}
+
+			printWithStyling("Packaged service %s\n - Artifact: %s\n\n", svc.Name, withHighLightFormat("%s", artifact))
+		}
+	}
+
+	if err := manifest.Save(packageDir); err != nil {
+		return err
+	}
+
+	if formatter.Kind() == output.JsonFormat {
+		if fmtErr := formatter.Format(PackageResult{Path: packageDir, Manifest: manifest}, cmd.OutOrStdout(), nil); fmtErr != nil {
+			return fmt.Errorf("package result could not be displayed: %w", fmtErr)
+		}
+
+		return nil
+	}
+
+	printWithStyling(
+		"Packages written to %s\nDeploy them with %s\n",
+		withHighLightFormat("%s", packageDir),
+		withHighLightFormat("azd deploy --from-package %s", packageDir))
+
+	return nil
+}
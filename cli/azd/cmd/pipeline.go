@@ -5,16 +5,20 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"io/fs"
-	"path/filepath"
+	"io"
+	"os"
 	"time"
 
 	"github.com/AlecAivazis/survey/v2"
+	"github.com/azure/azure-dev/cli/azd/pkg/azdo"
 	"github.com/azure/azure-dev/cli/azd/pkg/commands"
 	"github.com/azure/azure-dev/cli/azd/pkg/environment"
-	"github.com/azure/azure-dev/cli/azd/pkg/github"
+	"github.com/azure/azure-dev/cli/azd/pkg/gitlab"
+	"github.com/azure/azure-dev/cli/azd/pkg/output"
+	"github.com/azure/azure-dev/cli/azd/pkg/project"
 	"github.com/azure/azure-dev/cli/azd/pkg/tools"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -23,10 +27,10 @@ import (
 func pipelineCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "pipeline",
-		Short: "Manage GitHub Actions pipelines.",
-		Long: `Manage GitHub Actions pipelines.
+		Short: "Manage GitHub Actions, Azure Pipelines and GitLab CI/CD pipelines.",
+		Long: `Manage GitHub Actions, Azure Pipelines and GitLab CI/CD pipelines.
 
-The Azure Developer CLI template includes a GitHub Actions pipeline configuration file (in the *.github/workflows* folder) that deploys your application whenever code is pushed to the main branch.
+The Azure Developer CLI template includes a GitHub Actions pipeline configuration file (in the *.github/workflows* folder) that deploys your application whenever code is pushed to the main branch. Templates may also include an Azure Pipelines configuration file (*.azdo/pipelines/azure-dev.yml*). When the project does not have a configuration file for the pipeline, ` + withBackticks("azd pipeline config") + ` creates one.
 
 For more information, go to https://aka.ms/azure-dev/pipeline.`,
 	}
@@ -40,21 +44,121 @@ func pipelineConfigCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Comman
 		&pipelineConfigAction{rootOptions: rootOptions},
 		rootOptions,
 		"config",
-		"Create and configure your deployment pipeline by using GitHub Actions.",
-		`Create and configure your deployment pipeline by using GitHub Actions.
+		"Create and configure your deployment pipeline by using GitHub Actions, Azure Pipelines or GitLab CI/CD.",
+		`Create and configure your deployment pipeline by using GitHub Actions, Azure Pipelines or GitLab CI/CD.
+
+The pipeline provider is selected with `+withBackticks("--provider")+`. When it is not set, Azure Pipelines is used for repositories hosted by Azure DevOps, GitLab CI/CD for projects hosted by GitLab.com or by the instance set with `+withBackticks("--gitlab-url")+`, and GitHub Actions otherwise.
+
+With Azure Pipelines, azd creates or updates:
+
+	- the `+withBackticks(azdoServiceConnectionName)+` service connection, which authenticates to Azure with the service principal
+	- the `+withBackticks(azdoVariableGroupName)+` variable group, with the name, location and subscription of the environment, and the values of the project
+	- a pipeline running `+withBackticks(azdoPipelineYamlPath)+`
+
+A personal access token for Azure DevOps is read from `+azdo.PersonalAccessTokenEnvVarName+`, or prompted for.
+
+With GitLab CI/CD, azd creates or updates the CI/CD variables of the project, which are masked when they are secret, and only exposed to protected branches and tags with `+withBackticks("--gitlab-protected")+`. A personal access token for GitLab, with the api scope, is read from `+gitlab.TokenEnvVarName+`, or prompted for.
+
+By default, the environment is configured for the whole repository. With `+withBackticks("--github-environment <name>")+`, which may be repeated, each azd environment is deployed from the GitHub environment of the same name, with a service principal of its own, and its secrets and variables are scoped to that GitHub environment. The required reviewers of each GitHub environment are read from the `+withBackticks("pipeline.environments.<name>.reviewers")+` list of azure.yaml.
+
+Besides the name, location and subscription of the environment, azd stores the environment values listed in the `+withBackticks("pipeline.variables")+` and `+withBackticks("pipeline.secrets")+` lists of azure.yaml, and the values referenced by the parameters of the infrastructure, such as `+withBackticks("${MY_VALUE}")+` in main.parameters.json. They are stored as variables, unless listed as secrets, and must be mapped to the environment of the steps running azd in the pipeline definition, which generated definitions do.
+
+The pipeline definition is generated when the project does not have one, with the steps logging in to Azure, setting up the toolchains of the services, and running `+withBackticks("azd provision")+` and `+withBackticks("azd deploy")+`. Generated definitions are kept up to date, unless they are edited. Definitions which were edited, or not generated by azd, are only replaced with `+withBackticks("--force")+`, which is also required to generate a definition next to other definitions of the provider, such as the workflows in .github/workflows.
+
+By default, the pipeline authenticates to Azure with a client secret of the service principal, which is reset and stored by the provider. With `+withBackticks("--auth-type federated")+`, the service principal trusts the tokens GitHub Actions issues for the repository instead, and only its client, tenant and subscription IDs are stored, as variables.
+
+With `+withBackticks("--dry-run")+`, the changes azd would make to Azure, the provider, the pipeline definition and git are listed, as a table or as JSON with `+withBackticks("--output json")+`, without making them. The remote must be configured for a dry run. A dry run does not log in to Azure or to the provider, and lists the logins the changes require instead.
 
 For more information, go to https://aka.ms/azure-dev/pipeline.`,
 	)
-	return cmd
+	return output.AddOutputParam(
+		cmd,
+		[]output.Format{output.TableFormat, output.JsonFormat},
+		output.TableFormat)
 }
 
 type pipelineConfigAction struct {
 	pipelineServicePrincipalName string
 	pipelineRemoteName           string
 	pipelineRoleName             string
+	pipelineProvider             string
+	pipelineAuthType             string
+	gitHubEnvironments           []string
+	gitHubPullRequests           bool
+	gitLabUrl                    string
+	gitLabProtected              bool
+	force                        bool
+	dryRun                       bool
 	rootOptions                  *commands.GlobalCommandOptions
 }
 
+// The values of --provider
+const (
+	gitHubProviderName = "github"
+	azdoProviderName   = "azdo"
+	gitLabProviderName = "gitlab"
+)
+
+// The values of --auth-type
+const (
+	clientCredentialsAuthType = "client-credentials"
+	federatedAuthType         = "federated"
+)
+
+// pipelineProvider configures a CI/CD service to deploy the project whenever code is pushed to its repository.
+type pipelineProvider interface {
+	// Name is the display name of the CI/CD service, such as "GitHub Actions".
+	Name() string
+	// RequiredTools returns the tools the provider runs, besides git and the Azure CLI.
+	RequiredTools() []tools.ExternalTool
+	// PreConfigureCheck ensures the project and the user are ready to configure the service, for example by logging
+	// in. It runs before the remote is resolved and the service principal is created.
+	PreConfigureCheck(ctx context.Context) error
+	// DefinitionPath returns the path of the pipeline definition azd generates, relative to the root of the repository.
+	DefinitionPath() string
+	// Definition generates the pipeline definition provisioning and deploying the project.
+	Definition(def pipelineDefinition) string
+	// RepositoryName returns the name of the repository a remote refers to, or an error when the remote is not
+	// hosted by the service.
+	RepositoryName(remoteUrl string) (string, error)
+	// ConfigureRemote prompts the user for the repository to use, when the remote does not exist, and returns its URL.
+	ConfigureRemote(ctx context.Context) (string, error)
+	// FederatedCredentials returns the federated identity credentials which allow the pipeline to authenticate as the
+	// service principal, when it deploys the environment from the branch.
+	FederatedCredentials(repo pipelineRepository, branch string, env *environment.Environment) ([]tools.FederatedIdentityCredential, error)
+	// ConfigureDeployment configures the service to deploy the environment with the credentials of the service
+	// principal, and with the values of the environment the project needs.
+	ConfigureDeployment(
+		ctx context.Context,
+		repo pipelineRepository,
+		credentials pipelineCredentials,
+		env *environment.Environment,
+		values []project.PipelineValue) error
+	// PrePush runs before the changes are pushed, and returns true when the push should be canceled.
+	PrePush(ctx context.Context, repo pipelineRepository, branch string) (bool, error)
+	// PostPush runs after the changes are pushed, or not when pushed is false.
+	PostPush(ctx context.Context, repo pipelineRepository, branch string, pushed bool) error
+}
+
+// pipelineRepository is the repository the pipeline runs on.
+type pipelineRepository struct {
+	remoteName string
+	remoteUrl  string
+	// name is the name of the repository displayed to the user, such as `owner/repo` on GitHub.
+	name string
+}
+
+// pipelineCredentials are the credentials of the service principal the pipeline authenticates to Azure with.
+type pipelineCredentials struct {
+	authType       string
+	clientId       string
+	tenantId       string
+	subscriptionId string
+	// The credentials in the `AZURE_CREDENTIALS` format, including the client secret, which is only set for the
+	// client-credentials authentication type
+	azureCredentials json.RawMessage
+}
+
 func (p *pipelineConfigAction) SetupFlags(
 	persis *pflag.FlagSet,
 	local *pflag.FlagSet,
@@ -62,143 +166,89 @@ func (p *pipelineConfigAction) SetupFlags(
 	local.StringVar(&p.pipelineServicePrincipalName, "principal-name", "", "The name of the service principal to use to grant access to Azure resources as part of the pipeline.")
 	local.StringVar(&p.pipelineRemoteName, "remote-name", "origin", "The name of the git remote to configure the pipeline to run on.")
 	local.StringVar(&p.pipelineRoleName, "principal-role", "Contributor", "The role to assign to the service principal.")
+	local.StringVar(&p.pipelineProvider, "provider", "", "The pipeline provider to use, either github, azdo or gitlab. Detected from the git remote by default.")
+	local.StringVar(&p.pipelineAuthType, "auth-type", clientCredentialsAuthType, "The authentication type the pipeline uses to access Azure, either client-credentials or federated.")
+	local.StringSliceVar(&p.gitHubEnvironments, "github-environment", nil, "The azd environment to deploy from a GitHub environment of the same name. May be repeated.")
+	local.BoolVar(&p.gitHubPullRequests, "github-pull-requests", false, "Also allow the workflow runs of pull requests to authenticate to Azure with federated credentials, which lets anyone who can open a pull request deploy their changes.")
+	local.StringVar(&p.gitLabUrl, "gitlab-url", gitlab.DefaultBaseUrl, "The URL of the GitLab instance hosting the project, for self-managed instances.")
+	local.BoolVar(&p.gitLabProtected, "gitlab-protected", false, "Only expose the GitLab CI/CD variables to protected branches and tags.")
+	local.BoolVar(&p.dryRun, "dry-run", false, "List the changes the command would make, without making them.")
+	local.BoolVar(&p.force, "force", false, "Replace the pipeline definition with a generated one, even when it was not generated by azd or was edited, or create it next to other pipeline definitions.")
 }
 
-func (p *pipelineConfigAction) Run(ctx context.Context, _ *cobra.Command, args []string, azdCtx *environment.AzdContext) error {
+func (p *pipelineConfigAction) Run(ctx context.Context, cmd *cobra.Command, args []string, azdCtx *environment.AzdContext) error {
 	askOne := makeAskOne(p.rootOptions.NoPrompt)
 	azCli := commands.GetAzCliFromContext(ctx)
+	plan := &pipelinePlan{dryRun: p.dryRun}
+
+	formatter, err := output.GetFormatter(cmd)
+	if err != nil {
+		return err
+	}
 
 	if err := ensureProject(azdCtx.ProjectPath()); err != nil {
 		return err
 	}
 
-	env, err := loadOrInitEnvironment(ctx, &p.rootOptions.EnvironmentName, azdCtx, askOne)
+	if p.pipelineAuthType != clientCredentialsAuthType && p.pipelineAuthType != federatedAuthType {
+		return fmt.Errorf("unsupported authentication type '%s', it must be '%s' or '%s'", p.pipelineAuthType, clientCredentialsAuthType, federatedAuthType)
+	}
+
+	environments, err := p.loadEnvironments(ctx, azdCtx, askOne)
 	if err != nil {
-		return fmt.Errorf("loading environment: %w", err)
+		return err
 	}
 
+	prj, err := project.LoadProjectConfig(azdCtx.ProjectPath(), environments[0])
 	if err != nil {
-		return fmt.Errorf("loading environment: %w", err)
+		return fmt.Errorf("loading project: %w", err)
+	}
+
+	// The values are resolved before anything is configured, since an environment may lack a listed value.
+	values := make([][]project.PipelineValue, len(environments))
+	for i, env := range environments {
+		if values[i], err = prj.PipelineValues(env); err != nil {
+			return err
+		}
 	}
 
 	gitCli := tools.NewGitCli()
-	ghCli := tools.NewGitHubCli()
 
-	if err := tools.EnsureInstalled(ctx, azCli, gitCli, ghCli); err != nil {
+	provider, err := p.selectProvider(ctx, gitCli, azdCtx, prj, plan, askOne)
+	if err != nil {
 		return err
 	}
 
-	if err := ensureLoggedIn(ctx); err != nil {
-		return fmt.Errorf("failed to ensure login: %w", err)
-	}
-
-	if err := ensureGitHubLogin(ctx, ghCli, tools.GitHubHostName, askOne); err != nil {
-		return fmt.Errorf("failed to ensure login to GitHub: %w", err)
-	}
-
-	// This flag is used later to skip checking GitHub Actions.
-	// For new repositories, there's no need to check
-	newGitHubRepoCreated := false
-
-	getSlugOrInit := func() (string, error) {
-		for {
-			repoSlug, err := github.EnsureRemote(ctx, azdCtx.ProjectDirectory(), p.pipelineRemoteName, gitCli)
-			switch {
-			case errors.Is(err, tools.ErrNotRepository):
-				// Offer the user a chance to init a new repository if one does not exist.
-				initRepo := false
-				if askErr := askOne(&survey.Confirm{
-					Message: "Initialize a new git repository?",
-					Default: true,
-				}, &initRepo); askErr != nil {
-					return "", fmt.Errorf("prompting for git init: %w", err)
-				}
-
-				if !initRepo {
-					return "", err
-				}
-
-				if err := gitCli.InitRepo(ctx, azdCtx.ProjectDirectory()); err != nil {
-					return "", fmt.Errorf("initializing repository: %w", err)
-				}
-
-				// Recovered from this error, try again
-				continue
-			case errors.Is(err, tools.ErrNoSuchRemote):
-				// Offer the user a chance to create the remote if one does not exist.
-				addRemote := false
-				if err := askOne(&survey.Confirm{
-					Message: fmt.Sprintf("A remote named \"%s\" was not found. Would you like to configure one?", p.pipelineRemoteName),
-					Default: true,
-				}, &addRemote); err != nil {
-					return "", fmt.Errorf("prompting for remote init: %w", err)
-				}
-
-				if !addRemote {
-					return "", errors.New("confirmation declined")
-				}
-
-				// There are a few ways to configure the remote so offer a choice to the user.
-				var idx int
-
-				if err := askOne(&survey.Select{
-					Message: "How would you like to configure your remote?",
-					Options: []string{
-						"Select an existing GitHub project",
-						"Create a new private GitHub repository",
-						"Enter a remote URL directly",
-					},
-					Default: "Create a new private GitHub repository",
-				}, &idx); err != nil {
-					return "", fmt.Errorf("prompting for remote configuration type: %w", err)
-				}
-
-				var remoteUrl string
-
-				switch idx {
-				// Select from an existing GitHub project
-				case 0:
-					url, err := getRemoteUrlFromExisting(ctx, ghCli, askOne)
-					if err != nil {
-						return "", fmt.Errorf("getting remote from existing repository: %w", err)
-					}
-					remoteUrl = url
-				// Create a new project
-				case 1:
-					url, err := getRemoteUrlFromNewRepository(ctx, ghCli, azdCtx, askOne)
-					if err != nil {
-						return "", fmt.Errorf("getting remote from new repository: %w", err)
-					}
-					newGitHubRepoCreated = true
-					remoteUrl = url
-				// Enter a URL directly.
-				case 2:
-					url, err := p.getRemoteUrlFromPrompt(askOne)
-					if err != nil {
-						return "", fmt.Errorf("getting remote from prompt: %w", err)
-					}
-					remoteUrl = url
-				default:
-					panic(fmt.Sprintf("unexpected selection index %d", idx))
-				}
-
-				if err := gitCli.AddRemote(ctx, azdCtx.ProjectDirectory(), p.pipelineRemoteName, remoteUrl); err != nil {
-					return "", fmt.Errorf("initializing repository: %w", err)
-				}
-
-				// Recovered from this error, try again
-				continue
-			case err != nil:
-				return "", err
-			default:
-				return repoSlug, nil
-			}
+	if err := tools.EnsureInstalled(ctx, append([]tools.ExternalTool{azCli, gitCli}, provider.RequiredTools()...)...); err != nil {
+		return err
+	}
+
+	err = plan.require(pipelineChange{Target: azureChangeTarget, Action: "require", Resource: "login"}, func() error {
+		if err := ensureLoggedIn(ctx); err != nil {
+			return fmt.Errorf("failed to ensure login: %w", err)
 		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = plan.require(pipelineChange{Target: provider.Name(), Action: "require", Resource: "credentials"}, func() error {
+		return provider.PreConfigureCheck(ctx)
+	})
+	if err != nil {
+		return err
+	}
+
+	def := newPipelineDefinition(prj, values, p.gitHubEnvironments)
+	if err := scaffoldPipelineDefinition(azdCtx.ProjectDirectory(), provider, def, p.force, plan); err != nil {
+		return err
 	}
 
-	repoSlug, err := getSlugOrInit()
+	repo, err := p.ensureRemote(ctx, gitCli, provider, azdCtx, askOne)
 	if err != nil {
-		return fmt.Errorf("ensuring github remote: %w", err)
+		return fmt.Errorf("ensuring git remote: %w", err)
 	}
 
 	currentBranch, err := gitCli.GetCurrentBranch(ctx, azdCtx.ProjectDirectory())
@@ -212,54 +262,45 @@ func (p *pipelineConfigAction) Run(ctx context.Context, _ *cobra.Command, args [
 		p.pipelineServicePrincipalName = fmt.Sprintf("az-dev-%s", time.Now().UTC().Format("01-02-2006-15-04-05"))
 	}
 
-	fmt.Printf("Creating or updating service principal %s.\n", p.pipelineServicePrincipalName)
-
-	credentials, err := azCli.CreateOrUpdateServicePrincipal(ctx, env.GetSubscriptionId(), p.pipelineServicePrincipalName, p.pipelineRoleName)
-	if err != nil {
-		return fmt.Errorf("failed to create or update service principal: %w", err)
-	}
-
-	fmt.Printf("Configuring repository %s to use credentials for %s.\n", repoSlug, p.pipelineServicePrincipalName)
-
-	fmt.Printf("Setting AZURE_CREDENTIALS GitHub repo secret.\n")
-
-	if err := ghCli.SetSecret(ctx, repoSlug, "AZURE_CREDENTIALS", string(credentials)); err != nil {
-		return fmt.Errorf("failed setting AZURE_CREDENTIALS secret: %w", err)
-	}
+	for i, env := range environments {
+		principalName := p.pipelineServicePrincipalName
+		if len(p.gitHubEnvironments) > 0 {
+			// Each environment has a principal of its own, since resetting the client secret of a shared principal
+			// would invalidate the secrets of the other environments.
+			principalName = fmt.Sprintf("%s-%s", principalName, env.GetEnvName())
+		}
 
-	fmt.Printf("Configuring repository environment.\n")
+		credentials, err := p.createOrUpdateServicePrincipal(ctx, azCli, provider, plan, repo, currentBranch, env, principalName)
+		if err != nil {
+			return err
+		}
 
-	for _, envName := range []string{environment.EnvNameEnvVarName, environment.LocationEnvVarName, environment.SubscriptionIdEnvVarName} {
-		fmt.Printf("Setting %s GitHub repo secret.\n", envName)
+		if !p.dryRun {
+			fmt.Printf("Configuring repository %s to use credentials for %s.\n", repo.name, principalName)
+		}
 
-		if err := ghCli.SetSecret(ctx, repoSlug, envName, env.Values[envName]); err != nil {
-			return fmt.Errorf("failed setting %s secret: %w", envName, err)
+		if err := provider.ConfigureDeployment(ctx, repo, credentials, env, values[i]); err != nil {
+			return err
 		}
 	}
 
-	fmt.Println()
-	fmt.Printf(`GitHub Action secrets are now configured. See your .github/workflows folder for details on which actions will be enabled.
-You can view the GitHub Actions here: https://github.com/%s/actions
-`, repoSlug)
+	if p.dryRun {
+		return p.writeDryRunPlan(ctx, formatter, cmd.OutOrStdout(), plan, provider, repo, currentBranch)
+	}
 
 	var doPush bool
 
 	if err := askOne(&survey.Confirm{
-		Message: "Would you like to commit and push your local changes to start a new GitHub Actions run?",
+		Message: fmt.Sprintf("Would you like to commit and push your local changes to start a new %s run?", provider.Name()),
 		Default: true,
 	}, &doPush); err != nil {
 		return fmt.Errorf("prompting to push: %w", err)
 	}
 
-	// Check if GitHub actions are disabled *Only* when user requested to push changes AND this is NOT a just-created repo
-	//
-	// A repo that is just created would return zero GitHub Actions and might be confused by azd
-	// as a repo where Actions are disabled. Sadly, there's not GitHub API to fetch exact information
-	// to distinguish between disabled-after-fork v/s repo-disabled-actions v/s similar scenarios).
-	if doPush && !newGitHubRepoCreated {
-		cancelPushing, err := notifyWhenGitHubActionsAreDisabled(ctx, gitCli, ghCli, azdCtx, repoSlug, p.pipelineRemoteName, currentBranch, askOne)
+	if doPush {
+		cancelPushing, err := provider.PrePush(ctx, repo, currentBranch)
 		if err != nil {
-			return fmt.Errorf("ensure github actions: %w", err)
+			return err
 		}
 		// Abort doing push on user request
 		doPush = !cancelPushing
@@ -270,7 +311,7 @@ You can view the GitHub Actions here: https://github.com/%s/actions
 			return fmt.Errorf("adding files: %w", err)
 		}
 
-		if err := gitCli.Commit(ctx, azdCtx.ProjectDirectory(), "Configure GitHub Actions"); err != nil {
+		if err := gitCli.Commit(ctx, azdCtx.ProjectDirectory(), fmt.Sprintf("Configure %s", provider.Name())); err != nil {
 			return fmt.Errorf("commit changes: %w", err)
 		}
 
@@ -279,252 +320,366 @@ You can view the GitHub Actions here: https://github.com/%s/actions
 		if err := gitCli.PushUpstream(ctx, azdCtx.ProjectDirectory(), p.pipelineRemoteName, currentBranch); err != nil {
 			return fmt.Errorf("pushing changes: %w", err)
 		}
-	} else {
-		fmt.Printf("To fully enable GitHub Actions you need to push this repo to GitHub using 'git push --set-upstream %s %s'.\n", p.pipelineRemoteName, currentBranch)
 	}
 
-	return nil
+	return provider.PostPush(ctx, repo, currentBranch, doPush)
 }
 
-// getRemoteUrlFromPrompt interactively prompts the user for a URL for a GitHub repository. It validates
-// that the URL is well formed and is in the correct format for a GitHub repository.
-func (p *pipelineConfigAction) getRemoteUrlFromPrompt(askOne Asker) (string, error) {
-	remoteUrl := ""
-
-	for remoteUrl == "" {
-		if err := askOne(&survey.Input{
-			Message: fmt.Sprintf("Please enter the url to use for remote %s:", p.pipelineRemoteName),
-		}, &remoteUrl); err != nil {
-			return "", fmt.Errorf("prompting for remote url: %w", err)
-		}
-
-		if _, err := github.GetSlugForRemote(remoteUrl); errors.Is(err, github.ErrRemoteHostIsNotGitHub) {
-			fmt.Printf("error: \"%s\" is not a valid GitHub URL.\n", remoteUrl)
+// writeDryRunPlan completes the plan of a dry run with the changes made once the changes are pushed, and writes it.
+func (p *pipelineConfigAction) writeDryRunPlan(
+	ctx context.Context,
+	formatter output.Formatter,
+	writer io.Writer,
+	plan *pipelinePlan,
+	provider pipelineProvider,
+	repo pipelineRepository,
+	branch string) error {
+	details := ""
+	if !p.rootOptions.NoPrompt {
+		details = "after confirmation"
+	}
+
+	plan.changes = append(plan.changes,
+		pipelineChange{Target: gitChangeTarget, Action: "commit", Resource: "local changes", Details: details},
+		pipelineChange{Target: gitChangeTarget, Action: "push", Resource: fmt.Sprintf("branch %s to %s", branch, repo.remoteName), Details: details},
+	)
 
-			// So we retry from the loop.
-			remoteUrl = ""
-		}
+	// Providers only record the changes they make after the push.
+	if err := provider.PostPush(ctx, repo, branch, true); err != nil {
+		return err
 	}
 
-	return remoteUrl, nil
+	return plan.write(formatter, writer)
 }
 
-func getRemoteUrlFromNewRepository(ctx context.Context, ghCli tools.GitHubCli, azdCtx *environment.AzdContext, askOne Asker) (string, error) {
-	var repoName string
+// loadEnvironments loads the environments named with --github-environment, or the current environment when the flag is
+// not set.
+func (p *pipelineConfigAction) loadEnvironments(ctx context.Context, azdCtx *environment.AzdContext, askOne Asker) ([]*environment.Environment, error) {
+	if len(p.gitHubEnvironments) == 0 {
+		env, err := loadOrInitEnvironment(ctx, &p.rootOptions.EnvironmentName, azdCtx, askOne)
+		if err != nil {
+			return nil, fmt.Errorf("loading environment: %w", err)
+		}
 
-	currentPathName := azdCtx.ProjectDirectory()
-	currentFolderName := filepath.Base(currentPathName)
+		return []*environment.Environment{&env}, nil
+	}
 
-	for {
-		if err := askOne(&survey.Input{
-			Message: "Enter the name for your new repository OR Hit enter to use this name:",
-			Default: currentFolderName,
-		}, &repoName); err != nil {
-			return "", fmt.Errorf("asking for new repository name: %w", err)
+	environments := make([]*environment.Environment, len(p.gitHubEnvironments))
+	for i, name := range p.gitHubEnvironments {
+		env, err := azdCtx.GetEnvironment(name)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("environment '%s' does not exist, create it with `azd env new %s`", name, name)
+		} else if err != nil {
+			return nil, fmt.Errorf("loading environment '%s': %w", name, err)
 		}
 
-		err := ghCli.CreatePrivateRepository(ctx, repoName)
-		if errors.Is(err, tools.ErrRepositoryNameInUse) {
-			fmt.Printf("error: the repository name '%s' is already in use\n", repoName)
-			continue // try again
+		environments[i] = &env
+	}
+
+	return environments, nil
+}
+
+// createOrUpdateServicePrincipal creates or updates the service principal the pipeline authenticates as, with a client
+// secret or with the federated credentials of the provider, depending on --auth-type.
+func (p *pipelineConfigAction) createOrUpdateServicePrincipal(
+	ctx context.Context,
+	azCli tools.AzCli,
+	provider pipelineProvider,
+	plan *pipelinePlan,
+	repo pipelineRepository,
+	branch string,
+	env *environment.Environment,
+	principalName string) (pipelineCredentials, error) {
+	subscriptionId := env.GetSubscriptionId()
+
+	principalChange := pipelineChange{Target: azureADChangeTarget, Action: "create or update", Resource: "service principal " + principalName}
+	roleChange := pipelineChange{
+		Target:   azureChangeTarget,
+		Action:   "assign",
+		Resource: "role " + p.pipelineRoleName,
+		Details:  fmt.Sprintf("to %s on subscription %s", principalName, subscriptionId),
+	}
+
+	// Whether the principal exists is only looked up for a dry run, since it is created or updated the same way.
+	var existingClientId string
+	if plan.dryRun {
+		clientId, err := azCli.GetApplicationClientId(ctx, principalName)
+		if errors.Is(err, tools.ErrApplicationNotFound) {
+			principalChange.Action = "create"
 		} else if err != nil {
-			return "", fmt.Errorf("creating repository: %w", err)
+			return pipelineCredentials{}, fmt.Errorf("looking up service principal: %w", err)
 		} else {
-			break
+			principalChange.Action = "update"
+			existingClientId = clientId
 		}
 	}
 
-	repo, err := ghCli.ViewRepository(ctx, repoName)
-	if err != nil {
-		return "", fmt.Errorf("fetching repository info: %w", err)
-	}
+	if p.pipelineAuthType == federatedAuthType {
+		federatedCredentials, err := provider.FederatedCredentials(repo, branch, env)
+		if err != nil {
+			return pipelineCredentials{}, err
+		}
 
-	return selectRemoteUrl(ctx, ghCli, repo)
+		credentials := pipelineCredentials{authType: federatedAuthType, subscriptionId: subscriptionId}
 
-}
+		err = plan.apply(principalChange, func() error {
+			fmt.Printf("Creating or updating service principal %s with federated credentials.\n", principalName)
 
-func getRemoteUrlFromExisting(ctx context.Context, ghCli tools.GitHubCli, askOne Asker) (string, error) {
-	repos, err := ghCli.ListRepositories(ctx)
-	if err != nil {
-		return "", fmt.Errorf("listing existing repositories: %w", err)
-	}
+			principal, err := azCli.CreateOrUpdateFederatedServicePrincipal(ctx, subscriptionId, principalName, p.pipelineRoleName, federatedCredentials)
+			if err != nil {
+				return fmt.Errorf("failed to create or update service principal: %w", err)
+			}
 
-	options := make([]string, len(repos))
-	for idx, repo := range repos {
-		options[idx] = repo.NameWithOwner
-	}
+			credentials.clientId = principal.ClientId
+			credentials.tenantId = principal.TenantId
+			return nil
+		})
+		if err != nil {
+			return pipelineCredentials{}, err
+		}
+
+		plan.changes = append(plan.changes, roleChange)
+		for _, credential := range federatedCredentials {
+			plan.changes = append(plan.changes, pipelineChange{
+				Target:   azureADChangeTarget,
+				Action:   "create or update",
+				Resource: "federated credential " + credential.Name,
+				Details:  "for " + credential.Subject,
+			})
+		}
+
+		if plan.dryRun {
+			return p.dryRunCredentials(ctx, azCli, credentials, existingClientId)
+		}
 
-	var repoIdx int
-	if err := askOne(&survey.Select{
-		Message: "Please choose an existing GitHub repository",
-		Options: options,
-	}, &repoIdx); err != nil {
-		return "", fmt.Errorf("prompting for repository: %w", err)
+		return credentials, nil
 	}
 
-	return selectRemoteUrl(ctx, ghCli, repos[repoIdx])
-}
+	principalChange.Details = "with a new client secret"
+
+	var credentials pipelineCredentials
+	err := plan.apply(principalChange, func() error {
+		fmt.Printf("Creating or updating service principal %s.\n", principalName)
+
+		rawCredentials, err := azCli.CreateOrUpdateServicePrincipal(ctx, subscriptionId, principalName, p.pipelineRoleName)
+		if err != nil {
+			return fmt.Errorf("failed to create or update service principal: %w", err)
+		}
 
-func selectRemoteUrl(ctx context.Context, ghCli tools.GitHubCli, repo tools.GhCliRepository) (string, error) {
-	protocolType, err := ghCli.GetGitProtocolType(ctx)
+		var azureCredentials tools.AzureCredentials
+		if err := json.Unmarshal(rawCredentials, &azureCredentials); err != nil {
+			return fmt.Errorf("parsing service principal credentials: %w", err)
+		}
+
+		credentials = pipelineCredentials{
+			authType:         clientCredentialsAuthType,
+			clientId:         azureCredentials.ClientId,
+			tenantId:         azureCredentials.TenantId,
+			subscriptionId:   azureCredentials.SubscriptionId,
+			azureCredentials: rawCredentials,
+		}
+		return nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("detecting default protocol: %w", err)
+		return pipelineCredentials{}, err
 	}
 
-	switch protocolType {
-	case tools.GitHttpsProtocolType:
-		return repo.HttpsUrl, nil
-	case tools.GitSshProtocolType:
-		return repo.SshUrl, nil
-	default:
-		panic(fmt.Sprintf("unexpected protocol type: %s", protocolType))
+	plan.changes = append(plan.changes, roleChange)
+
+	if plan.dryRun {
+		return p.dryRunCredentials(ctx, azCli, pipelineCredentials{authType: clientCredentialsAuthType, subscriptionId: subscriptionId}, existingClientId)
 	}
+
+	return credentials, nil
 }
 
-// ensureGitHubLogin ensures the user is logged into the GitHub CLI. If not, it prompt the user
-// if they would like to log in and if so runs `gh auth login` interactively.
-func ensureGitHubLogin(ctx context.Context, ghCli tools.GitHubCli, hostname string, askOne Asker) error {
-	loggedIn, err := ghCli.CheckAuth(ctx, hostname)
+// dryRunCredentials completes the credentials of a service principal which is not created or updated by a dry run.
+// The client ID is empty when the principal does not exist, and there is no client secret.
+func (p *pipelineConfigAction) dryRunCredentials(
+	ctx context.Context,
+	azCli tools.AzCli,
+	credentials pipelineCredentials,
+	clientId string) (pipelineCredentials, error) {
+	tenantId, err := azCli.GetSubscriptionTenant(ctx, credentials.subscriptionId)
 	if err != nil {
-		return err
+		return pipelineCredentials{}, fmt.Errorf("getting tenant of subscription: %w", err)
 	}
 
-	if loggedIn {
-		return nil
-	}
+	credentials.clientId = clientId
+	credentials.tenantId = tenantId
 
-	for {
-		var accept bool
-		if err := askOne(&survey.Confirm{
-			Message: "This command requires you to be logged into GitHub. Log in using the GitHub CLI?",
-			Default: true,
-		}, &accept); err != nil {
-			return fmt.Errorf("prompting to log in to github: %w", err)
+	if credentials.authType == clientCredentialsAuthType {
+		credentials.azureCredentials, err = json.Marshal(tools.AzureCredentials{
+			ClientId:       clientId,
+			SubscriptionId: credentials.subscriptionId,
+			TenantId:       tenantId,
+		})
+		if err != nil {
+			return pipelineCredentials{}, fmt.Errorf("marshalling credentials: %w", err)
 		}
+	}
 
-		if !accept {
-			return errors.New("interactive GitHub login declined; use `gh auth login` to log into GitHub")
-		}
+	return credentials, nil
+}
 
-		if err := ghCli.Login(ctx, hostname); err == nil {
-			return nil
+// selectProvider returns the provider selected with --provider or, when the flag is not set, the provider hosting the
+// repository of the remote, which defaults to GitHub.
+func (p *pipelineConfigAction) selectProvider(
+	ctx context.Context,
+	gitCli tools.GitCli,
+	azdCtx *environment.AzdContext,
+	prj *project.ProjectConfig,
+	plan *pipelinePlan,
+	askOne Asker) (pipelineProvider, error) {
+	name := p.pipelineProvider
+	if name == "" {
+		name = gitHubProviderName
+
+		// The remote may not be configured yet, in which case GitHub is used.
+		if remoteUrl, err := gitCli.GetRemoteUrl(ctx, azdCtx.ProjectDirectory(), p.pipelineRemoteName); err == nil {
+			if azdo.IsAzDoRemote(remoteUrl) {
+				name = azdoProviderName
+			} else if gitlab.IsGitLabRemote(remoteUrl, p.gitLabUrl) {
+				name = gitLabProviderName
+			}
 		}
-
-		fmt.Println("There was an issue logging into GitHub.")
 	}
-}
 
-type gitHubActionsEnablingChoice int
-
-const (
-	manualChoice gitHubActionsEnablingChoice = iota
-	cancelChoice
-)
-
-func (selection gitHubActionsEnablingChoice) String() string {
-	switch selection {
-	case manualChoice:
-		return "I have manually enabled GitHub Actions. Continue with pushing my changes."
-	case cancelChoice:
-		return "Exit without pushing my changes. I don't need to run GitHub actions right now."
+	if name != gitHubProviderName && len(p.gitHubEnvironments) > 0 {
+		return nil, fmt.Errorf("--github-environment is only supported by the '%s' provider", gitHubProviderName)
+	}
+
+	if name != gitHubProviderName && p.gitHubPullRequests {
+		return nil, fmt.Errorf("--github-pull-requests is only supported by the '%s' provider", gitHubProviderName)
+	}
+
+	switch name {
+	case gitHubProviderName:
+		return &gitHubProvider{
+			ghCli:                 tools.NewGitHubCli(),
+			gitCli:                gitCli,
+			azdCtx:                azdCtx,
+			remoteName:            p.pipelineRemoteName,
+			askOne:                askOne,
+			useGitHubEnvironments: len(p.gitHubEnvironments) > 0,
+			trustPullRequests:     p.gitHubPullRequests,
+			pipelineConfig:        prj.Pipeline,
+			plan:                  plan,
+		}, nil
+	case azdoProviderName:
+		return &azdoProvider{
+			azdCtx:     azdCtx,
+			remoteName: p.pipelineRemoteName,
+			askOne:     askOne,
+			noPrompt:   p.rootOptions.NoPrompt,
+			plan:       plan,
+		}, nil
+	case gitLabProviderName:
+		return &gitLabProvider{
+			azdCtx:     azdCtx,
+			remoteName: p.pipelineRemoteName,
+			askOne:     askOne,
+			noPrompt:   p.rootOptions.NoPrompt,
+			baseUrl:    p.gitLabUrl,
+			protected:  p.gitLabProtected,
+			plan:       plan,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported pipeline provider '%s', it must be '%s', '%s' or '%s'",
+			name, gitHubProviderName, azdoProviderName, gitLabProviderName)
 	}
-	panic("Tried to convert invalid input gitHubActionsEnablingChoice to string")
 }
 
-// notifyWhenGitHubActionsAreDisabled checks if gh-actions are disabled on the repo
-// This can happen when a template is first forked and user calls `pipeline config`
-// GitHub disables actions by default when a repo is forked.
-//
-// A user can also disable Actions from /settings/actions, which is different from
-// what GitHub does after a template is forked. However, for both cases, calling API
-// /repos/<repoSlug>/actions/workflows would return the same.
-//
-// Returns true, nil if user decides to cancel pushing changes.
-func notifyWhenGitHubActionsAreDisabled(
+// ensureRemote ensures the project is a git repository with a remote hosted by the provider, offering to initialize
+// the repository and to configure the remote when they do not exist.
+func (p *pipelineConfigAction) ensureRemote(
 	ctx context.Context,
 	gitCli tools.GitCli,
-	ghCli tools.GitHubCli,
+	provider pipelineProvider,
 	azdCtx *environment.AzdContext,
-	repoSlug string,
-	origin string,
-	branch string,
-	askOne Asker) (bool, error) {
+	askOne Asker) (pipelineRepository, error) {
+	for {
+		remoteUrl, err := gitCli.GetRemoteUrl(ctx, azdCtx.ProjectDirectory(), p.pipelineRemoteName)
+		switch {
+		case p.dryRun && (errors.Is(err, tools.ErrNotRepository) || errors.Is(err, tools.ErrNoSuchRemote)):
+			return pipelineRepository{}, fmt.Errorf("the remote %s must be configured for a dry run: %w", p.pipelineRemoteName, err)
+		case errors.Is(err, tools.ErrNotRepository):
+			// Offer the user a chance to init a new repository if one does not exist.
+			initRepo := false
+			if askErr := askOne(&survey.Confirm{
+				Message: "Initialize a new git repository?",
+				Default: true,
+			}, &initRepo); askErr != nil {
+				return pipelineRepository{}, fmt.Errorf("prompting for git init: %w", err)
+			}
 
-	ghActionsInUpstreamRepo, err := ghCli.GitHubActionsExists(ctx, repoSlug)
-	if err != nil {
-		return false, err
-	}
-
-	if ghActionsInUpstreamRepo {
-		// upstream is already listing GitHub actions.
-		// There's no need to check if there are local workflows
-		return false, nil
-	}
-
-	// Upstream has no GitHub actions listed.
-	// See if there's at least one workflow file within .github/workflows
-	ghLocalWorkflowFiles := false
-	defaultGitHubWorkflowPathLocation := filepath.Join(
-		azdCtx.ProjectDirectory(),
-		".github",
-		"workflows")
-	err = filepath.WalkDir(defaultGitHubWorkflowPathLocation,
-		func(folderName string, file fs.DirEntry, e error) error {
-			if e != nil {
-				return e
+			if !initRepo {
+				return pipelineRepository{}, err
 			}
-			fileName := file.Name()
-			fileExtension := filepath.Ext(fileName)
-			if fileExtension == ".yml" || fileExtension == ".yaml" {
-				// ** workflow file found.
-				// Now check if this file is already tracked by git.
-				// If the file is not tracked, it means this is a new file (never pushed to mainstream)
-				// A git untracked file should not be considered as GitHub workflow until it is pushed.
-				newFile, err := gitCli.IsUntrackedFile(ctx, azdCtx.ProjectDirectory(), folderName)
-				if err != nil {
-					return fmt.Errorf("checking workflow file %w", err)
-				}
-				if !newFile {
-					ghLocalWorkflowFiles = true
-				}
+
+			if err := gitCli.InitRepo(ctx, azdCtx.ProjectDirectory()); err != nil {
+				return pipelineRepository{}, fmt.Errorf("initializing repository: %w", err)
 			}
 
-			return nil
-		})
+			// Recovered from this error, try again
+			continue
+		case errors.Is(err, tools.ErrNoSuchRemote):
+			// Offer the user a chance to create the remote if one does not exist.
+			addRemote := false
+			if err := askOne(&survey.Confirm{
+				Message: fmt.Sprintf("A remote named \"%s\" was not found. Would you like to configure one?", p.pipelineRemoteName),
+				Default: true,
+			}, &addRemote); err != nil {
+				return pipelineRepository{}, fmt.Errorf("prompting for remote init: %w", err)
+			}
 
-	if err != nil {
-		return false, fmt.Errorf("Getting GitHub local workflow files %w", err)
-	}
-
-	if ghLocalWorkflowFiles {
-		printWithStyling("\n%s\n"+
-			" - If you forked and cloned a template, please enable actions here: %s.\n"+
-			" - Otherwise, check the GitHub Actions permissions here: %s.\n",
-			withHighLightFormat("GitHub actions are currently disabled for your repository."),
-			withHighLightFormat("https://github.com/%s/actions", repoSlug),
-			withHighLightFormat("https://github.com/%s/settings/actions", repoSlug))
-
-		var rawSelection int
-		if err := askOne(&survey.Select{
-			Message: "What would you like to do now?",
-			Options: []string{
-				manualChoice.String(),
-				cancelChoice.String(),
-			},
-			Default: manualChoice,
-		}, &rawSelection); err != nil {
-			return false, fmt.Errorf("prompting to enable github actions: %w", err)
+			if !addRemote {
+				return pipelineRepository{}, errors.New("confirmation declined")
+			}
+
+			remoteUrl, err := provider.ConfigureRemote(ctx)
+			if err != nil {
+				return pipelineRepository{}, err
+			}
+
+			if err := gitCli.AddRemote(ctx, azdCtx.ProjectDirectory(), p.pipelineRemoteName, remoteUrl); err != nil {
+				return pipelineRepository{}, fmt.Errorf("initializing repository: %w", err)
+			}
+
+			// Recovered from this error, try again
+			continue
+		case err != nil:
+			return pipelineRepository{}, fmt.Errorf("failed to get remote url: %w", err)
 		}
-		choice := gitHubActionsEnablingChoice(rawSelection)
 
-		if choice == manualChoice {
-			return false, nil
+		name, err := provider.RepositoryName(remoteUrl)
+		if err != nil {
+			return pipelineRepository{}, fmt.Errorf("remote `%s` is not supported by %s: %w", p.pipelineRemoteName, provider.Name(), err)
 		}
 
-		if choice == cancelChoice {
-			return true, nil
+		return pipelineRepository{remoteName: p.pipelineRemoteName, remoteUrl: remoteUrl, name: name}, nil
+	}
+}
+
+// readPersonalAccessToken reads the personal access token for a service from an environment variable, or prompts the
+// user for it when the variable is not set.
+func readPersonalAccessToken(service string, envVarName string, noPrompt bool, askOne Asker) (string, error) {
+	token := os.Getenv(envVarName)
+	if token != "" {
+		return token, nil
+	}
+
+	if noPrompt {
+		return "", fmt.Errorf("a personal access token for %s is required, set it in %s", service, envVarName)
+	}
+
+	for token == "" {
+		if err := askOne(&survey.Password{
+			Message: fmt.Sprintf("Please enter a personal access token for %s:", service),
+		}, &token); err != nil {
+			return "", fmt.Errorf("prompting for personal access token: %w", err)
 		}
 	}
 
-	return false, nil
+	return token, nil
 }
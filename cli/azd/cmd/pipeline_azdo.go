@@ -0,0 +1,352 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/azure/azure-dev/cli/azd/pkg/azdo"
+	"github.com/azure/azure-dev/cli/azd/pkg/commands"
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/project"
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+)
+
+const (
+	// The pipeline definition, relative to the root of the repository
+	azdoPipelineYamlPath      = ".azdo/pipelines/azure-dev.yml"
+	azdoPipelineName          = "azure-dev"
+	azdoServiceConnectionName = "azconnection"
+	azdoVariableGroupName     = "azure-dev"
+)
+
+// azdoProvider configures Azure Pipelines, by creating the service connection and the variable group which the
+// pipeline defined in .azdo/pipelines/azure-dev.yml uses to deploy, and the pipeline itself.
+type azdoProvider struct {
+	azdCtx     *environment.AzdContext
+	remoteName string
+	askOne     Asker
+	noPrompt   bool
+	plan       *pipelinePlan
+
+	personalAccessToken string
+	// Set when the deployment is configured
+	client  *azdo.Client
+	project azdo.Project
+	repo    azdo.Repository
+}
+
+func (p *azdoProvider) Name() string {
+	return "Azure Pipelines"
+}
+
+func (p *azdoProvider) RequiredTools() []tools.ExternalTool {
+	return nil
+}
+
+func (p *azdoProvider) PreConfigureCheck(ctx context.Context) error {
+	token, err := readPersonalAccessToken("Azure DevOps", azdo.PersonalAccessTokenEnvVarName, p.noPrompt, p.askOne)
+	if err != nil {
+		return err
+	}
+
+	p.personalAccessToken = token
+	return nil
+}
+
+func (p *azdoProvider) DefinitionPath() string {
+	return azdoPipelineYamlPath
+}
+
+// Definition generates a pipeline authenticating with the service connection, and provisioning and deploying the
+// project with the values of the variable group on pushes to the main branch.
+func (p *azdoProvider) Definition(def pipelineDefinition) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, `trigger:
+  - main
+  - master
+
+pool:
+  vmImage: ubuntu-latest
+
+variables:
+  - group: %s
+
+steps:
+  - bash: curl -fsSL https://aka.ms/install-azd.sh | bash
+    displayName: Install azd
+`, azdoVariableGroupName)
+
+	if def.dotnet {
+		b.WriteString(`
+  - task: UseDotNet@2
+    displayName: Set up .NET
+    inputs:
+      version: 6.0.x
+`)
+	}
+
+	if def.node {
+		b.WriteString(`
+  - task: NodeTool@0
+    displayName: Set up Node.js
+    inputs:
+      versionSpec: 18.x
+`)
+	}
+
+	if def.python {
+		b.WriteString(`
+  - task: UsePythonVersion@0
+    displayName: Set up Python
+    inputs:
+      versionSpec: "3.10"
+`)
+	}
+
+	for _, step := range []string{"Provision", "Deploy"} {
+		fmt.Fprintf(&b, `
+  - task: AzureCLI@2
+    displayName: Azure Dev %s
+    inputs:
+      azureSubscription: %s
+      scriptType: bash
+      scriptLocation: inlineScript
+      inlineScript: |
+        azd %s --no-prompt
+    env:
+      AZURE_SUBSCRIPTION_ID: $(AZURE_SUBSCRIPTION_ID)
+      AZURE_ENV_NAME: $(AZURE_ENV_NAME)
+      AZURE_LOCATION: $(AZURE_LOCATION)
+`, step, azdoServiceConnectionName, strings.ToLower(step))
+
+		// Unlike the other variables of the group, secrets are only exposed to the steps mapping them.
+		for _, value := range def.values {
+			if value.Secret {
+				fmt.Fprintf(&b, "      %s: $(%s)\n", value.Name, value.Name)
+			}
+		}
+	}
+
+	return b.String()
+}
+
+func (p *azdoProvider) RepositoryName(remoteUrl string) (string, error) {
+	repo, err := azdo.GetRepositoryForRemote(remoteUrl)
+	if err != nil {
+		return "", err
+	}
+
+	return repo.Slug(), nil
+}
+
+// ConfigureRemote interactively prompts the user for a URL for an Azure DevOps repository, which is validated.
+// Repositories are not created, since they belong to a project which is usually managed by an administrator.
+func (p *azdoProvider) ConfigureRemote(ctx context.Context) (string, error) {
+	remoteUrl := ""
+
+	for remoteUrl == "" {
+		if err := p.askOne(&survey.Input{
+			Message: fmt.Sprintf("Please enter the url of the Azure DevOps repository to use for remote %s:", p.remoteName),
+		}, &remoteUrl); err != nil {
+			return "", fmt.Errorf("prompting for remote url: %w", err)
+		}
+
+		if !azdo.IsAzDoRemote(remoteUrl) {
+			fmt.Printf("error: \"%s\" is not a valid Azure DevOps URL.\n", remoteUrl)
+
+			// So we retry from the loop.
+			remoteUrl = ""
+		}
+	}
+
+	return remoteUrl, nil
+}
+
+// FederatedCredentials is not supported, since service connections authenticate with a client secret.
+func (p *azdoProvider) FederatedCredentials(repo pipelineRepository, branch string, env *environment.Environment) ([]tools.FederatedIdentityCredential, error) {
+	return nil, fmt.Errorf("%s does not support the '%s' authentication type, use '%s'", p.Name(), federatedAuthType, clientCredentialsAuthType)
+}
+
+func (p *azdoProvider) ConfigureDeployment(
+	ctx context.Context,
+	repo pipelineRepository,
+	credentials pipelineCredentials,
+	env *environment.Environment,
+	values []project.PipelineValue) error {
+	var err error
+
+	p.repo, err = azdo.GetRepositoryForRemote(repo.remoteUrl)
+	if err != nil {
+		return fmt.Errorf("parsing remote url: %w", err)
+	}
+
+	p.client = azdo.NewClient(p.repo.OrganizationUrl, p.personalAccessToken)
+
+	// A dry run has no personal access token, since the preconditions are not checked, so the project is not looked up.
+	if !p.plan.dryRun {
+		p.project, err = p.client.GetProject(ctx, p.repo.Project)
+		if err != nil {
+			return err
+		}
+	}
+
+	var azureCredentials tools.AzureCredentials
+	if err := json.Unmarshal(credentials.azureCredentials, &azureCredentials); err != nil {
+		return fmt.Errorf("parsing service principal credentials: %w", err)
+	}
+
+	subscriptionName, err := getSubscriptionName(ctx, credentials.subscriptionId)
+	if err != nil {
+		return err
+	}
+
+	err = p.plan.apply(pipelineChange{
+		Target:   "Azure DevOps",
+		Action:   "create or update",
+		Resource: "service connection " + azdoServiceConnectionName,
+		Details:  "authorized for all pipelines",
+	}, func() error {
+		fmt.Printf("Creating or updating service connection %s.\n", azdoServiceConnectionName)
+
+		endpoint, err := p.client.CreateOrUpdateServiceConnection(ctx, p.project, azdoServiceConnectionName, azdo.ServicePrincipal{
+			TenantId:         credentials.tenantId,
+			ClientId:         credentials.clientId,
+			ClientSecret:     azureCredentials.ClientSecret,
+			SubscriptionId:   credentials.subscriptionId,
+			SubscriptionName: subscriptionName,
+		})
+		if err != nil {
+			return fmt.Errorf("failed creating service connection: %w", err)
+		}
+
+		return p.client.AuthorizeForAllPipelines(ctx, p.project, azdo.EndpointResourceType, endpoint.Id)
+	})
+	if err != nil {
+		return err
+	}
+
+	variables := map[string]azdo.Variable{}
+	for _, envName := range []string{environment.EnvNameEnvVarName, environment.LocationEnvVarName, environment.SubscriptionIdEnvVarName} {
+		variables[envName] = azdo.Variable{Value: env.Values[envName]}
+	}
+
+	var secretNames []string
+	for _, value := range values {
+		variables[value.Name] = azdo.Variable{Value: value.Value, IsSecret: value.Secret}
+		if value.Secret {
+			secretNames = append(secretNames, value.Name)
+		}
+	}
+
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	err = p.plan.apply(pipelineChange{
+		Target:   "Azure DevOps",
+		Action:   "create or update",
+		Resource: "variable group " + azdoVariableGroupName,
+		Details:  "with " + strings.Join(names, ", "),
+	}, func() error {
+		fmt.Printf("Creating or updating variable group %s.\n", azdoVariableGroupName)
+
+		group, err := p.client.CreateOrUpdateVariableGroup(ctx, p.project, azdoVariableGroupName, variables)
+		if err != nil {
+			return fmt.Errorf("failed creating variable group: %w", err)
+		}
+
+		return p.client.AuthorizeForAllPipelines(ctx, p.project, azdo.VariableGroupResourceType, strconv.Itoa(group.Id))
+	})
+	if err != nil {
+		return err
+	}
+
+	if p.plan.dryRun {
+		return nil
+	}
+
+	fmt.Println()
+	fmt.Printf("The %s service connection and the %s variable group are now configured. See %s for details on the pipeline.\n",
+		azdoServiceConnectionName, azdoVariableGroupName, azdoPipelineYamlPath)
+
+	if len(secretNames) > 0 {
+		// Unlike the other variables of the group, secrets are only available to the steps which map them.
+		printWithStyling("Map the secrets %s in the %s of the steps running azd, such as %s.\n",
+			withHighLightFormat(strings.Join(secretNames, ", ")),
+			withBackticks("env"),
+			withBackticks(fmt.Sprintf("%s: $(%s)", secretNames[0], secretNames[0])))
+	}
+
+	return nil
+}
+
+func (p *azdoProvider) PrePush(ctx context.Context, repo pipelineRepository, branch string) (bool, error) {
+	return false, nil
+}
+
+// PostPush creates the pipeline, which requires its definition to be pushed to the repository. A pipeline which
+// already exists is run by the push itself, and a new one is run once created.
+func (p *azdoProvider) PostPush(ctx context.Context, repo pipelineRepository, branch string, pushed bool) error {
+	if !pushed {
+		fmt.Printf("To create the Azure Pipelines pipeline you need to push this repo to Azure DevOps using "+
+			"'git push --set-upstream %s %s', and run 'azd pipeline config' again.\n", repo.remoteName, branch)
+		return nil
+	}
+
+	return p.plan.apply(pipelineChange{
+		Target:   "Azure DevOps",
+		Action:   "create",
+		Resource: "pipeline " + azdoPipelineName,
+		Details:  "unless it exists, and run it on " + branch,
+	}, func() error {
+		gitRepo, err := p.client.GetRepository(ctx, p.project, p.repo.Name)
+		if err != nil {
+			return err
+		}
+
+		pipeline, created, err := p.client.GetOrCreatePipeline(ctx, p.project, azdoPipelineName, gitRepo, azdoPipelineYamlPath)
+		if err != nil {
+			return err
+		}
+
+		if created {
+			fmt.Printf("Created pipeline %s.\n", azdoPipelineName)
+
+			if err := p.client.RunPipeline(ctx, p.project, pipeline, "refs/heads/"+branch); err != nil {
+				return err
+			}
+		}
+
+		fmt.Println()
+		printWithStyling("You can view the pipeline runs here: %s\n", withLinkFormat("%s", pipeline.WebUrl()))
+
+		return nil
+	})
+}
+
+// getSubscriptionName returns the display name of a subscription of the logged in account.
+func getSubscriptionName(ctx context.Context, subscriptionId string) (string, error) {
+	accounts, err := commands.GetAzCliFromContext(ctx).ListAccounts(ctx)
+	if err != nil {
+		return "", fmt.Errorf("listing subscriptions: %w", err)
+	}
+
+	for _, account := range accounts {
+		if account.Id == subscriptionId {
+			return account.Name, nil
+		}
+	}
+
+	return "", fmt.Errorf("subscription %s was not found", subscriptionId)
+}
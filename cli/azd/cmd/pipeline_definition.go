@@ -0,0 +1,183 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/osutil"
+	"github.com/azure/azure-dev/cli/azd/pkg/project"
+)
+
+// pipelineDefinition describes what the pipeline definitions azd generates must set up to provision and deploy the
+// project.
+type pipelineDefinition struct {
+	// The toolchains the services of the project are built with
+	dotnet bool
+	node   bool
+	python bool
+	// Set when services are built as container images
+	docker bool
+	// The values of the environments the pipeline maps, besides the values every definition maps
+	values []project.PipelineValue
+	// The GitHub environments the azd environments are deployed from, if any
+	gitHubEnvironments []string
+}
+
+// newPipelineDefinition describes the definition of the pipeline deploying the services of prj, with the values of
+// each of the environments it deploys.
+func newPipelineDefinition(prj *project.ProjectConfig, values [][]project.PipelineValue, gitHubEnvironments []string) pipelineDefinition {
+	def := pipelineDefinition{gitHubEnvironments: gitHubEnvironments}
+
+	for _, svc := range prj.Services {
+		switch svc.Language {
+		case "", "dotnet", "csharp", "fsharp":
+			def.dotnet = true
+		case "py", "python":
+			def.python = true
+		case "js", "ts":
+			def.node = true
+		}
+
+		if svc.Host == string(project.ContainerAppTarget) {
+			def.docker = true
+		}
+	}
+
+	// Environments may not all have the same values, and a value is secret when it is secret in any of them.
+	byName := map[string]project.PipelineValue{}
+	for _, envValues := range values {
+		for _, value := range envValues {
+			existing, has := byName[value.Name]
+			byName[value.Name] = project.PipelineValue{Name: value.Name, Secret: value.Secret || (has && existing.Secret)}
+		}
+	}
+
+	for _, value := range byName {
+		def.values = append(def.values, value)
+	}
+	sort.Slice(def.values, func(i, j int) bool { return def.values[i].Name < def.values[j].Name })
+
+	return def
+}
+
+// The first line of the definitions azd generates, which ends with the checksum of the rest of the file, so edited
+// files are detected.
+const pipelineDefinitionHeaderFormat = "# Generated by azd pipeline config, which updates this file until it is edited. checksum: %x\n"
+
+var pipelineDefinitionHeaderRegex = regexp.MustCompile(`^# Generated by azd pipeline config, which updates this file until it is edited\. checksum: ([0-9a-f]{64})\r?\n`)
+
+// withPipelineDefinitionHeader prepends the header of generated definitions to content.
+func withPipelineDefinitionHeader(content string) []byte {
+	return []byte(fmt.Sprintf(pipelineDefinitionHeaderFormat, sha256.Sum256([]byte(content))) + content)
+}
+
+// isUneditedPipelineDefinition returns true when contents is a definition generated by azd, which was not edited since.
+func isUneditedPipelineDefinition(contents []byte) bool {
+	captures := pipelineDefinitionHeaderRegex.FindSubmatch(contents)
+	if captures == nil {
+		return false
+	}
+
+	return string(captures[1]) == fmt.Sprintf("%x", sha256.Sum256(contents[len(captures[0]):]))
+}
+
+// otherPipelineDefinitions returns the paths, relative to the project, of the pipeline definitions in the directory of
+// the definition at relativePath, other than it, such as the workflows of a template in .github/workflows. Definitions
+// at the root of the project, such as .gitlab-ci.yml, are the only definition of their provider, so there are none.
+func otherPipelineDefinitions(projectDir string, relativePath string) ([]string, error) {
+	relativeDir := path.Dir(relativePath)
+	if relativeDir == "." {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(filepath.Join(projectDir, filepath.FromSlash(relativeDir)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading pipeline definitions: %w", err)
+	}
+
+	var others []string
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".yml" && ext != ".yaml") {
+			continue
+		}
+
+		if other := path.Join(relativeDir, entry.Name()); other != relativePath {
+			others = append(others, other)
+		}
+	}
+
+	return others, nil
+}
+
+// scaffoldPipelineDefinition writes the pipeline definition the provider generates for the project, when the project
+// does not have one, or has one azd generated and which was not edited. Other definitions, such as the ones of
+// templates, are only overwritten when force is set. Likewise, the definition is not created next to other definitions
+// of the provider, which may already provision and deploy the project, unless force is set.
+func scaffoldPipelineDefinition(projectDir string, provider pipelineProvider, def pipelineDefinition, force bool, plan *pipelinePlan) error {
+	relativePath := provider.DefinitionPath()
+	path := filepath.Join(projectDir, filepath.FromSlash(relativePath))
+	contents := withPipelineDefinitionHeader(provider.Definition(def))
+
+	var action, progress string
+	existing, err := ioutil.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		others, err := otherPipelineDefinitions(projectDir, relativePath)
+		if err != nil {
+			return err
+		}
+
+		if len(others) > 0 && !force {
+			if !plan.dryRun {
+				printWithStyling("%s is not created, since the project already has the pipeline definitions %s. "+
+					"Use %s to create it anyway.\n", relativePath, strings.Join(others, ", "), withBackticks("--force"))
+			}
+			return nil
+		}
+
+		action, progress = "create", "Creating"
+	case err != nil:
+		return fmt.Errorf("reading pipeline definition: %w", err)
+	case bytes.Equal(existing, contents):
+		return nil
+	case isUneditedPipelineDefinition(existing):
+		action, progress = "update", "Updating"
+	case force:
+		action, progress = "overwrite", "Overwriting"
+	default:
+		if !plan.dryRun {
+			printWithStyling("%s is kept as is, since it was not generated by azd or was edited. "+
+				"Use %s to replace it with a generated definition.\n", relativePath, withBackticks("--force"))
+		}
+		return nil
+	}
+
+	return plan.apply(pipelineChange{Target: fileChangeTarget, Action: action, Resource: relativePath}, func() error {
+		fmt.Printf("%s %s.\n", progress, relativePath)
+
+		if err := os.MkdirAll(filepath.Dir(path), osutil.PermissionDirectory); err != nil {
+			return fmt.Errorf("creating pipeline definition directory: %w", err)
+		}
+
+		if err := ioutil.WriteFile(path, contents, osutil.PermissionFile); err != nil {
+			return fmt.Errorf("writing pipeline definition: %w", err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,124 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/project"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPipelineDefinition(t *testing.T) {
+	prj := &project.ProjectConfig{
+		Services: map[string]*project.ServiceConfig{
+			"api": {Language: "py", Host: "containerapp"},
+			"web": {Language: "ts", Host: "staticwebapp"},
+		},
+	}
+
+	def := newPipelineDefinition(prj, [][]project.PipelineValue{
+		{{Name: "WEB_SKU", Value: "B1"}, {Name: "DB_PASSWORD", Value: "dev", Secret: true}},
+		{{Name: "WEB_SKU", Value: "P1", Secret: true}},
+	}, nil)
+
+	require.True(t, def.python)
+	require.True(t, def.node)
+	require.False(t, def.dotnet)
+	require.True(t, def.docker)
+	require.Equal(t, []project.PipelineValue{
+		{Name: "DB_PASSWORD", Secret: true},
+		{Name: "WEB_SKU", Secret: true},
+	}, def.values)
+}
+
+func TestScaffoldPipelineDefinition(t *testing.T) {
+	dir := t.TempDir()
+	provider := &azdoProvider{}
+	path := filepath.Join(dir, ".azdo", "pipelines", "azure-dev.yml")
+
+	def := pipelineDefinition{node: true}
+	require.NoError(t, scaffoldPipelineDefinition(dir, provider, def, false, &pipelinePlan{}))
+
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.True(t, isUneditedPipelineDefinition(contents))
+	require.Contains(t, string(contents), "NodeTool@0")
+
+	// Unedited definitions are updated.
+	def.values = []project.PipelineValue{{Name: "DB_PASSWORD", Secret: true}}
+	require.NoError(t, scaffoldPipelineDefinition(dir, provider, def, false, &pipelinePlan{}))
+
+	contents, err = os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(contents), "DB_PASSWORD: $(DB_PASSWORD)")
+
+	// Edited definitions are kept, unless forced.
+	edited := append(contents, []byte("# edited\n")...)
+	require.False(t, isUneditedPipelineDefinition(edited))
+	require.NoError(t, os.WriteFile(path, edited, 0600))
+
+	require.NoError(t, scaffoldPipelineDefinition(dir, provider, pipelineDefinition{}, false, &pipelinePlan{}))
+	contents, err = os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, edited, contents)
+
+	require.NoError(t, scaffoldPipelineDefinition(dir, provider, pipelineDefinition{}, true, &pipelinePlan{}))
+	contents, err = os.ReadFile(path)
+	require.NoError(t, err)
+	require.True(t, isUneditedPipelineDefinition(contents))
+	require.NotContains(t, string(contents), "DB_PASSWORD")
+}
+
+func TestScaffoldPipelineDefinitionWithOtherDefinitions(t *testing.T) {
+	dir := t.TempDir()
+	provider := &gitHubProvider{}
+	workflows := filepath.Join(dir, ".github", "workflows")
+	path := filepath.Join(workflows, "azure-dev.yml")
+
+	require.NoError(t, os.MkdirAll(workflows, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(workflows, "deploy.yaml"), []byte("on: push\n"), 0600))
+
+	others, err := otherPipelineDefinitions(dir, provider.DefinitionPath())
+	require.NoError(t, err)
+	require.Equal(t, []string{".github/workflows/deploy.yaml"}, others)
+
+	// No definition is created next to the existing ones, unless forced.
+	plan := &pipelinePlan{dryRun: true}
+	require.NoError(t, scaffoldPipelineDefinition(dir, provider, pipelineDefinition{}, false, plan))
+	require.Empty(t, plan.changes)
+
+	require.NoError(t, scaffoldPipelineDefinition(dir, provider, pipelineDefinition{}, false, &pipelinePlan{}))
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+
+	require.NoError(t, scaffoldPipelineDefinition(dir, provider, pipelineDefinition{}, true, &pipelinePlan{}))
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.True(t, isUneditedPipelineDefinition(contents))
+
+	// Definitions at the root of the project are the only definition of their provider.
+	others, err = otherPipelineDefinitions(dir, gitLabCIYamlPath)
+	require.NoError(t, err)
+	require.Empty(t, others)
+}
+
+func TestGitHubDefinitionWithEnvironments(t *testing.T) {
+	provider := &gitHubProvider{useGitHubEnvironments: true}
+
+	workflow := provider.Definition(pipelineDefinition{
+		dotnet:             true,
+		values:             []project.PipelineValue{{Name: "WEB_SKU"}, {Name: "DB_PASSWORD", Secret: true}},
+		gitHubEnvironments: []string{"dev", "prod"},
+	})
+
+	require.Contains(t, workflow, "environment: [dev, prod]")
+	require.Contains(t, workflow, "environment: ${{ matrix.environment }}")
+	require.Contains(t, workflow, "WEB_SKU: ${{ vars.WEB_SKU }}")
+	require.Contains(t, workflow, "DB_PASSWORD: ${{ secrets.DB_PASSWORD }}")
+	require.Contains(t, workflow, "actions/setup-dotnet")
+	require.NotContains(t, workflow, "actions/setup-node")
+}
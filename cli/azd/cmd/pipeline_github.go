@@ -0,0 +1,753 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/github"
+	"github.com/azure/azure-dev/cli/azd/pkg/project"
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+)
+
+// gitHubProvider configures GitHub Actions, by setting the secrets of the repository which the workflows in the
+// .github/workflows folder use to deploy.
+type gitHubProvider struct {
+	ghCli      tools.GitHubCli
+	gitCli     tools.GitCli
+	azdCtx     *environment.AzdContext
+	remoteName string
+	askOne     Asker
+
+	// When set, each azd environment is deployed from the GitHub environment of the same name, configured by
+	// pipelineConfig, and its secrets and variables are scoped to it.
+	useGitHubEnvironments bool
+	// When set, the workflow runs of pull requests are trusted by the federated credentials as well. Pull requests may
+	// come from anyone who can open one, so they are not trusted by default.
+	trustPullRequests bool
+	pipelineConfig    project.PipelineConfig
+
+	plan *pipelinePlan
+
+	// This flag is used to skip checking GitHub Actions.
+	// For new repositories, there's no need to check
+	newGitHubRepoCreated bool
+}
+
+func (p *gitHubProvider) Name() string {
+	return "GitHub Actions"
+}
+
+func (p *gitHubProvider) RequiredTools() []tools.ExternalTool {
+	return []tools.ExternalTool{p.ghCli}
+}
+
+func (p *gitHubProvider) PreConfigureCheck(ctx context.Context) error {
+	if err := ensureGitHubLogin(ctx, p.ghCli, tools.GitHubHostName, p.askOne); err != nil {
+		return fmt.Errorf("failed to ensure login to GitHub: %w", err)
+	}
+
+	return nil
+}
+
+func (p *gitHubProvider) RepositoryName(remoteUrl string) (string, error) {
+	return github.GetSlugForRemote(remoteUrl)
+}
+
+func (p *gitHubProvider) ConfigureRemote(ctx context.Context) (string, error) {
+	// There are a few ways to configure the remote so offer a choice to the user.
+	var idx int
+
+	if err := p.askOne(&survey.Select{
+		Message: "How would you like to configure your remote?",
+		Options: []string{
+			"Select an existing GitHub project",
+			"Create a new private GitHub repository",
+			"Enter a remote URL directly",
+		},
+		Default: "Create a new private GitHub repository",
+	}, &idx); err != nil {
+		return "", fmt.Errorf("prompting for remote configuration type: %w", err)
+	}
+
+	switch idx {
+	// Select from an existing GitHub project
+	case 0:
+		url, err := getRemoteUrlFromExisting(ctx, p.ghCli, p.askOne)
+		if err != nil {
+			return "", fmt.Errorf("getting remote from existing repository: %w", err)
+		}
+		return url, nil
+	// Create a new project
+	case 1:
+		url, err := getRemoteUrlFromNewRepository(ctx, p.ghCli, p.azdCtx, p.askOne)
+		if err != nil {
+			return "", fmt.Errorf("getting remote from new repository: %w", err)
+		}
+		p.newGitHubRepoCreated = true
+		return url, nil
+	// Enter a URL directly.
+	case 2:
+		url, err := getRemoteUrlFromPrompt(p.remoteName, p.askOne)
+		if err != nil {
+			return "", fmt.Errorf("getting remote from prompt: %w", err)
+		}
+		return url, nil
+	default:
+		panic(fmt.Sprintf("unexpected selection index %d", idx))
+	}
+}
+
+// The workflow azd generates, relative to the root of the repository
+const gitHubWorkflowPath = ".github/workflows/azure-dev.yml"
+
+func (p *gitHubProvider) DefinitionPath() string {
+	return gitHubWorkflowPath
+}
+
+// Definition generates a workflow logging in with the federated credentials or the client secret, whichever is
+// configured, and provisioning and deploying the project on pushes to the main branch.
+func (p *gitHubProvider) Definition(def pipelineDefinition) string {
+	var b strings.Builder
+
+	b.WriteString(`on:
+  workflow_dispatch:
+  push:
+    branches:
+      - main
+      - master
+
+# Required to log in with the federated credentials configured by ` + "`azd pipeline config --auth-type federated`" + `
+permissions:
+  id-token: write
+  contents: read
+
+jobs:
+  build:
+    runs-on: ubuntu-latest
+`)
+
+	if len(def.gitHubEnvironments) > 0 {
+		// Environments are deployed one after the other, in the order they are configured.
+		fmt.Fprintf(&b, `    strategy:
+      max-parallel: 1
+      matrix:
+        environment: [%s]
+    environment: ${{ matrix.environment }}
+`, strings.Join(def.gitHubEnvironments, ", "))
+	}
+
+	b.WriteString(`    env:
+      AZURE_CLIENT_ID: ${{ vars.AZURE_CLIENT_ID }}
+      AZURE_TENANT_ID: ${{ vars.AZURE_TENANT_ID }}
+      AZURE_ENV_NAME: ${{ vars.AZURE_ENV_NAME || secrets.AZURE_ENV_NAME }}
+      AZURE_LOCATION: ${{ vars.AZURE_LOCATION || secrets.AZURE_LOCATION }}
+      AZURE_SUBSCRIPTION_ID: ${{ vars.AZURE_SUBSCRIPTION_ID || secrets.AZURE_SUBSCRIPTION_ID }}
+`)
+
+	for _, value := range def.values {
+		source := "vars"
+		if value.Secret {
+			source = "secrets"
+		}
+		fmt.Fprintf(&b, "      %s: ${{ %s.%s }}\n", value.Name, source, value.Name)
+	}
+
+	b.WriteString(`    steps:
+      - name: Checkout
+        uses: actions/checkout@v3
+
+      - name: Install azd
+        run: curl -fsSL https://aka.ms/install-azd.sh | bash
+`)
+
+	if def.dotnet {
+		b.WriteString(`
+      - name: Set up .NET
+        uses: actions/setup-dotnet@v3
+        with:
+          dotnet-version: 6.0.x
+`)
+	}
+
+	if def.node {
+		b.WriteString(`
+      - name: Set up Node.js
+        uses: actions/setup-node@v3
+        with:
+          node-version: 18
+`)
+	}
+
+	if def.python {
+		b.WriteString(`
+      - name: Set up Python
+        uses: actions/setup-python@v4
+        with:
+          python-version: "3.10"
+`)
+	}
+
+	b.WriteString(`
+      - name: Log in with Azure (Federated Credentials)
+        if: ${{ env.AZURE_CLIENT_ID != '' }}
+        uses: azure/login@v1
+        with:
+          client-id: ${{ env.AZURE_CLIENT_ID }}
+          tenant-id: ${{ env.AZURE_TENANT_ID }}
+          subscription-id: ${{ env.AZURE_SUBSCRIPTION_ID }}
+
+      - name: Log in with Azure (Client Credentials)
+        if: ${{ env.AZURE_CLIENT_ID == '' }}
+        uses: azure/login@v1
+        with:
+          creds: ${{ secrets.AZURE_CREDENTIALS }}
+
+      - name: Azure Dev Provision
+        run: azd provision --no-prompt
+
+      - name: Azure Dev Deploy
+        run: azd deploy --no-prompt
+`)
+
+	return b.String()
+}
+
+const (
+	// The issuer of the tokens GitHub Actions requests with the `id-token: write` permission
+	gitHubActionsTokenIssuer = "https://token.actions.githubusercontent.com"
+	// The audience Azure AD expects in the tokens exchanged for an access token
+	azureADTokenExchangeAudience = "api://AzureADTokenExchange"
+)
+
+// Federated credential names may only contain letters, digits, dashes and underscores.
+var federatedCredentialNameInvalidCharsRegex = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// FederatedCredentials returns credentials for the workflow runs of pushes to the branch, and of pull requests when they
+// are trusted. With GitHub environments, the tokens of the jobs deploying to an environment have a subject of their own, which is the
+// only one trusted.
+func (p *gitHubProvider) FederatedCredentials(repo pipelineRepository, branch string, env *environment.Environment) ([]tools.FederatedIdentityCredential, error) {
+	credential := func(name string, subject string) tools.FederatedIdentityCredential {
+		return tools.FederatedIdentityCredential{
+			Name:        federatedCredentialNameInvalidCharsRegex.ReplaceAllString(name, "-"),
+			Issuer:      gitHubActionsTokenIssuer,
+			Subject:     subject,
+			Description: "Created by the Azure Developer CLI",
+			Audiences:   []string{azureADTokenExchangeAudience},
+		}
+	}
+
+	if p.useGitHubEnvironments {
+		return []tools.FederatedIdentityCredential{
+			credential(fmt.Sprintf("%s-environment-%s", repo.name, env.GetEnvName()),
+				fmt.Sprintf("repo:%s:environment:%s", repo.name, env.GetEnvName())),
+		}, nil
+	}
+
+	credentials := []tools.FederatedIdentityCredential{
+		credential(fmt.Sprintf("%s-branch-%s", repo.name, branch), fmt.Sprintf("repo:%s:ref:refs/heads/%s", repo.name, branch)),
+	}
+
+	if p.trustPullRequests {
+		credentials = append(credentials,
+			credential(fmt.Sprintf("%s-pull-request", repo.name), fmt.Sprintf("repo:%s:pull_request", repo.name)))
+	}
+
+	return credentials, nil
+}
+
+func (p *gitHubProvider) ConfigureDeployment(
+	ctx context.Context,
+	repo pipelineRepository,
+	credentials pipelineCredentials,
+	env *environment.Environment,
+	values []project.PipelineValue) error {
+	if p.useGitHubEnvironments {
+		var reviewers []string
+		if config, has := p.pipelineConfig.Environments[env.GetEnvName()]; has && config != nil {
+			reviewers = config.Reviewers
+		}
+
+		change := pipelineChange{Target: "GitHub", Action: "create or update", Resource: "environment " + env.GetEnvName()}
+		if len(reviewers) > 0 {
+			change.Details = "with reviewers " + strings.Join(reviewers, ", ")
+		}
+
+		err := p.plan.apply(change, func() error {
+			fmt.Printf("Creating or updating GitHub environment %s.\n", env.GetEnvName())
+
+			if err := p.ghCli.CreateOrUpdateEnvironment(ctx, repo.name, env.GetEnvName(), reviewers); err != nil {
+				return fmt.Errorf("failed creating GitHub environment %s: %w", env.GetEnvName(), err)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if credentials.authType == federatedAuthType {
+		return p.configureFederatedDeployment(ctx, repo, credentials, env, values)
+	}
+
+	if err := p.setSecret(ctx, repo, env, "AZURE_CREDENTIALS", string(credentials.azureCredentials)); err != nil {
+		return err
+	}
+
+	if !p.plan.dryRun {
+		fmt.Printf("Configuring repository environment.\n")
+	}
+
+	for _, envName := range []string{environment.EnvNameEnvVarName, environment.LocationEnvVarName, environment.SubscriptionIdEnvVarName} {
+		if err := p.setSecret(ctx, repo, env, envName, env.Values[envName]); err != nil {
+			return err
+		}
+	}
+
+	if err := p.setValues(ctx, repo, env, values); err != nil {
+		return err
+	}
+
+	if p.plan.dryRun {
+		return nil
+	}
+
+	fmt.Println()
+	fmt.Printf(`GitHub Action secrets are now configured. See your .github/workflows folder for details on which actions will be enabled.
+You can view the GitHub Actions here: https://github.com/%s/actions
+`, repo.name)
+	p.printEnvironmentUsage(env)
+	printValuesUsage(values)
+
+	return nil
+}
+
+// setSecret sets a secret of the repository, or of the GitHub environment of env when GitHub environments are used.
+func (p *gitHubProvider) setSecret(ctx context.Context, repo pipelineRepository, env *environment.Environment, name string, value string) error {
+	return p.plan.apply(p.valueChange("secret", name, env), func() error {
+		if p.useGitHubEnvironments {
+			fmt.Printf("Setting %s GitHub environment secret.\n", name)
+
+			if err := p.ghCli.SetEnvironmentSecret(ctx, repo.name, env.GetEnvName(), name, value); err != nil {
+				return fmt.Errorf("failed setting %s secret: %w", name, err)
+			}
+
+			return nil
+		}
+
+		fmt.Printf("Setting %s GitHub repo secret.\n", name)
+
+		if err := p.ghCli.SetSecret(ctx, repo.name, name, value); err != nil {
+			return fmt.Errorf("failed setting %s secret: %w", name, err)
+		}
+
+		return nil
+	})
+}
+
+// setVariable sets a variable of the repository, or of the GitHub environment of env when GitHub environments are
+// used.
+func (p *gitHubProvider) setVariable(ctx context.Context, repo pipelineRepository, env *environment.Environment, name string, value string) error {
+	return p.plan.apply(p.valueChange("variable", name, env), func() error {
+		if p.useGitHubEnvironments {
+			fmt.Printf("Setting %s GitHub environment variable.\n", name)
+
+			if err := p.ghCli.SetEnvironmentVariable(ctx, repo.name, env.GetEnvName(), name, value); err != nil {
+				return fmt.Errorf("failed setting %s variable: %w", name, err)
+			}
+
+			return nil
+		}
+
+		fmt.Printf("Setting %s GitHub repo variable.\n", name)
+
+		if err := p.ghCli.SetVariable(ctx, repo.name, name, value); err != nil {
+			return fmt.Errorf("failed setting %s variable: %w", name, err)
+		}
+
+		return nil
+	})
+}
+
+// valueChange describes setting a secret or a variable, of the repository or of the GitHub environment of env.
+func (p *gitHubProvider) valueChange(kind string, name string, env *environment.Environment) pipelineChange {
+	scope := "of the repository"
+	if p.useGitHubEnvironments {
+		scope = "of environment " + env.GetEnvName()
+	}
+
+	return pipelineChange{Target: "GitHub", Action: "set", Resource: kind + " " + name, Details: scope}
+}
+
+// setValues sets the values of the project as secrets or variables, depending on how they are configured.
+func (p *gitHubProvider) setValues(ctx context.Context, repo pipelineRepository, env *environment.Environment, values []project.PipelineValue) error {
+	for _, value := range values {
+		set := p.setVariable
+		if value.Secret {
+			set = p.setSecret
+		}
+
+		if err := set(ctx, repo, env, value.Name, value.Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// printValuesUsage explains how workflows use the values of the project, which, unlike the values azd always sets, the
+// workflow templates do not map.
+func printValuesUsage(values []project.PipelineValue) {
+	if len(values) == 0 {
+		return
+	}
+
+	names := make([]string, len(values))
+	for i, value := range values {
+		names[i] = value.Name
+	}
+
+	example := fmt.Sprintf("%s: ${{ vars.%s }}", values[0].Name, values[0].Name)
+	if values[0].Secret {
+		example = fmt.Sprintf("%s: ${{ secrets.%s }}", values[0].Name, values[0].Name)
+	}
+
+	printWithStyling("Map the values %s in the %s of the jobs running azd, such as %s.\n",
+		withHighLightFormat(strings.Join(names, ", ")),
+		withBackticks("env"),
+		withBackticks(example))
+}
+
+// printEnvironmentUsage explains how workflows deploy from the GitHub environment of env, when they are used.
+func (p *gitHubProvider) printEnvironmentUsage(env *environment.Environment) {
+	if !p.useGitHubEnvironments {
+		return
+	}
+
+	printWithStyling("The secrets and variables are only available to jobs deploying to the %s GitHub environment. "+
+		"Set %s on the jobs of your workflow which deploy it.\n",
+		withHighLightFormat(env.GetEnvName()),
+		withBackticks(fmt.Sprintf("environment: %s", env.GetEnvName())))
+}
+
+// configureFederatedDeployment sets the IDs the workflows log in with, and the environment, as variables of the
+// repository. No secret is stored, since the workflows authenticate with the federated credentials.
+func (p *gitHubProvider) configureFederatedDeployment(
+	ctx context.Context,
+	repo pipelineRepository,
+	credentials pipelineCredentials,
+	env *environment.Environment,
+	values []project.PipelineValue) error {
+	variables := []struct {
+		name  string
+		value string
+	}{
+		{name: "AZURE_CLIENT_ID", value: credentials.clientId},
+		{name: "AZURE_TENANT_ID", value: credentials.tenantId},
+		{name: environment.SubscriptionIdEnvVarName, value: credentials.subscriptionId},
+		{name: environment.EnvNameEnvVarName, value: env.Values[environment.EnvNameEnvVarName]},
+		{name: environment.LocationEnvVarName, value: env.Values[environment.LocationEnvVarName]},
+	}
+
+	for _, variable := range variables {
+		if err := p.setVariable(ctx, repo, env, variable.name, variable.value); err != nil {
+			return err
+		}
+	}
+
+	if err := p.setValues(ctx, repo, env, values); err != nil {
+		return err
+	}
+
+	if p.plan.dryRun {
+		return nil
+	}
+
+	fmt.Println()
+	fmt.Printf(`GitHub Action variables are now configured. See your .github/workflows folder for details on which actions will be enabled.
+You can view the GitHub Actions here: https://github.com/%s/actions
+`, repo.name)
+	p.printEnvironmentUsage(env)
+	printValuesUsage(values)
+
+	return nil
+}
+
+func (p *gitHubProvider) PrePush(ctx context.Context, repo pipelineRepository, branch string) (bool, error) {
+	// Check if GitHub actions are disabled *Only* when this is NOT a just-created repo
+	//
+	// A repo that is just created would return zero GitHub Actions and might be confused by azd
+	// as a repo where Actions are disabled. Sadly, there's not GitHub API to fetch exact information
+	// to distinguish between disabled-after-fork v/s repo-disabled-actions v/s similar scenarios).
+	if p.newGitHubRepoCreated {
+		return false, nil
+	}
+
+	cancelPushing, err := notifyWhenGitHubActionsAreDisabled(ctx, p.gitCli, p.ghCli, p.azdCtx, repo.name, repo.remoteName, branch, p.askOne)
+	if err != nil {
+		return false, fmt.Errorf("ensure github actions: %w", err)
+	}
+
+	return cancelPushing, nil
+}
+
+func (p *gitHubProvider) PostPush(ctx context.Context, repo pipelineRepository, branch string, pushed bool) error {
+	if !pushed {
+		fmt.Printf("To fully enable GitHub Actions you need to push this repo to GitHub using 'git push --set-upstream %s %s'.\n", repo.remoteName, branch)
+	}
+
+	return nil
+}
+
+// getRemoteUrlFromPrompt interactively prompts the user for a URL for a GitHub repository. It validates
+// that the URL is well formed and is in the correct format for a GitHub repository.
+func getRemoteUrlFromPrompt(remoteName string, askOne Asker) (string, error) {
+	remoteUrl := ""
+
+	for remoteUrl == "" {
+		if err := askOne(&survey.Input{
+			Message: fmt.Sprintf("Please enter the url to use for remote %s:", remoteName),
+		}, &remoteUrl); err != nil {
+			return "", fmt.Errorf("prompting for remote url: %w", err)
+		}
+
+		if _, err := github.GetSlugForRemote(remoteUrl); errors.Is(err, github.ErrRemoteHostIsNotGitHub) {
+			fmt.Printf("error: \"%s\" is not a valid GitHub URL.\n", remoteUrl)
+
+			// So we retry from the loop.
+			remoteUrl = ""
+		}
+	}
+
+	return remoteUrl, nil
+}
+
+func getRemoteUrlFromNewRepository(ctx context.Context, ghCli tools.GitHubCli, azdCtx *environment.AzdContext, askOne Asker) (string, error) {
+	var repoName string
+
+	currentPathName := azdCtx.ProjectDirectory()
+	currentFolderName := filepath.Base(currentPathName)
+
+	for {
+		if err := askOne(&survey.Input{
+			Message: "Enter the name for your new repository OR Hit enter to use this name:",
+			Default: currentFolderName,
+		}, &repoName); err != nil {
+			return "", fmt.Errorf("asking for new repository name: %w", err)
+		}
+
+		err := ghCli.CreatePrivateRepository(ctx, repoName)
+		if errors.Is(err, tools.ErrRepositoryNameInUse) {
+			fmt.Printf("error: the repository name '%s' is already in use\n", repoName)
+			continue // try again
+		} else if err != nil {
+			return "", fmt.Errorf("creating repository: %w", err)
+		} else {
+			break
+		}
+	}
+
+	repo, err := ghCli.ViewRepository(ctx, repoName)
+	if err != nil {
+		return "", fmt.Errorf("fetching repository info: %w", err)
+	}
+
+	return selectRemoteUrl(ctx, ghCli, repo)
+
+}
+
+func getRemoteUrlFromExisting(ctx context.Context, ghCli tools.GitHubCli, askOne Asker) (string, error) {
+	repos, err := ghCli.ListRepositories(ctx)
+	if err != nil {
+		return "", fmt.Errorf("listing existing repositories: %w", err)
+	}
+
+	options := make([]string, len(repos))
+	for idx, repo := range repos {
+		options[idx] = repo.NameWithOwner
+	}
+
+	var repoIdx int
+	if err := askOne(&survey.Select{
+		Message: "Please choose an existing GitHub repository",
+		Options: options,
+	}, &repoIdx); err != nil {
+		return "", fmt.Errorf("prompting for repository: %w", err)
+	}
+
+	return selectRemoteUrl(ctx, ghCli, repos[repoIdx])
+}
+
+func selectRemoteUrl(ctx context.Context, ghCli tools.GitHubCli, repo tools.GhCliRepository) (string, error) {
+	protocolType, err := ghCli.GetGitProtocolType(ctx)
+	if err != nil {
+		return "", fmt.Errorf("detecting default protocol: %w", err)
+	}
+
+	switch protocolType {
+	case tools.GitHttpsProtocolType:
+		return repo.HttpsUrl, nil
+	case tools.GitSshProtocolType:
+		return repo.SshUrl, nil
+	default:
+		panic(fmt.Sprintf("unexpected protocol type: %s", protocolType))
+	}
+}
+
+// ensureGitHubLogin ensures the user is logged into the GitHub CLI. If not, it prompt the user
+// if they would like to log in and if so runs `gh auth login` interactively.
+func ensureGitHubLogin(ctx context.Context, ghCli tools.GitHubCli, hostname string, askOne Asker) error {
+	loggedIn, err := ghCli.CheckAuth(ctx, hostname)
+	if err != nil {
+		return err
+	}
+
+	if loggedIn {
+		return nil
+	}
+
+	for {
+		var accept bool
+		if err := askOne(&survey.Confirm{
+			Message: "This command requires you to be logged into GitHub. Log in using the GitHub CLI?",
+			Default: true,
+		}, &accept); err != nil {
+			return fmt.Errorf("prompting to log in to github: %w", err)
+		}
+
+		if !accept {
+			return errors.New("interactive GitHub login declined; use `gh auth login` to log into GitHub")
+		}
+
+		if err := ghCli.Login(ctx, hostname); err == nil {
+			return nil
+		}
+
+		fmt.Println("There was an issue logging into GitHub.")
+	}
+}
+
+type gitHubActionsEnablingChoice int
+
+const (
+	manualChoice gitHubActionsEnablingChoice = iota
+	cancelChoice
+)
+
+func (selection gitHubActionsEnablingChoice) String() string {
+	switch selection {
+	case manualChoice:
+		return "I have manually enabled GitHub Actions. Continue with pushing my changes."
+	case cancelChoice:
+		return "Exit without pushing my changes. I don't need to run GitHub actions right now."
+	}
+	panic("Tried to convert invalid input gitHubActionsEnablingChoice to string")
+}
+
+// notifyWhenGitHubActionsAreDisabled checks if gh-actions are disabled on the repo
+// This can happen when a template is first forked and user calls `pipeline config`
+// GitHub disables actions by default when a repo is forked.
+//
+// A user can also disable Actions from /settings/actions, which is different from
+// what GitHub does after a template is forked. However, for both cases, calling API
+// /repos/<repoSlug>/actions/workflows would return the same.
+//
+// Returns true, nil if user decides to cancel pushing changes.
+func notifyWhenGitHubActionsAreDisabled(
+	ctx context.Context,
+	gitCli tools.GitCli,
+	ghCli tools.GitHubCli,
+	azdCtx *environment.AzdContext,
+	repoSlug string,
+	origin string,
+	branch string,
+	askOne Asker) (bool, error) {
+
+	ghActionsInUpstreamRepo, err := ghCli.GitHubActionsExists(ctx, repoSlug)
+	if err != nil {
+		return false, err
+	}
+
+	if ghActionsInUpstreamRepo {
+		// upstream is already listing GitHub actions.
+		// There's no need to check if there are local workflows
+		return false, nil
+	}
+
+	// Upstream has no GitHub actions listed.
+	// See if there's at least one workflow file within .github/workflows
+	ghLocalWorkflowFiles := false
+	defaultGitHubWorkflowPathLocation := filepath.Join(
+		azdCtx.ProjectDirectory(),
+		".github",
+		"workflows")
+	err = filepath.WalkDir(defaultGitHubWorkflowPathLocation,
+		func(folderName string, file fs.DirEntry, e error) error {
+			if e != nil {
+				return e
+			}
+			fileName := file.Name()
+			fileExtension := filepath.Ext(fileName)
+			if fileExtension == ".yml" || fileExtension == ".yaml" {
+				// ** workflow file found.
+				// Now check if this file is already tracked by git.
+				// If the file is not tracked, it means this is a new file (never pushed to mainstream)
+				// A git untracked file should not be considered as GitHub workflow until it is pushed.
+				newFile, err := gitCli.IsUntrackedFile(ctx, azdCtx.ProjectDirectory(), folderName)
+				if err != nil {
+					return fmt.Errorf("checking workflow file %w", err)
+				}
+				if !newFile {
+					ghLocalWorkflowFiles = true
+				}
+			}
+
+			return nil
+		})
+
+	if err != nil {
+		return false, fmt.Errorf("Getting GitHub local workflow files %w", err)
+	}
+
+	if ghLocalWorkflowFiles {
+		printWithStyling("\n%s\n"+
+			" - If you forked and cloned a template, please enable actions here: %s.\n"+
+			" - Otherwise, check the GitHub Actions permissions here: %s.\n",
+			withHighLightFormat("GitHub actions are currently disabled for your repository."),
+			withHighLightFormat("https://github.com/%s/actions", repoSlug),
+			withHighLightFormat("https://github.com/%s/settings/actions", repoSlug))
+
+		var rawSelection int
+		if err := askOne(&survey.Select{
+			Message: "What would you like to do now?",
+			Options: []string{
+				manualChoice.String(),
+				cancelChoice.String(),
+			},
+			Default: manualChoice,
+		}, &rawSelection); err != nil {
+			return false, fmt.Errorf("prompting to enable github actions: %w", err)
+		}
+		choice := gitHubActionsEnablingChoice(rawSelection)
+
+		if choice == manualChoice {
+			return false, nil
+		}
+
+		if choice == cancelChoice {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,50 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitHubFederatedCredentials(t *testing.T) {
+	env := environment.Empty("")
+	env.SetEnvName("dev")
+	provider := &gitHubProvider{}
+
+	credentials, err := provider.FederatedCredentials(pipelineRepository{name: "Contoso/todo.app"}, "feature/login", &env)
+	require.NoError(t, err)
+	require.Len(t, credentials, 1)
+
+	require.Equal(t, "Contoso-todo-app-branch-feature-login", credentials[0].Name)
+	require.Equal(t, "repo:Contoso/todo.app:ref:refs/heads/feature/login", credentials[0].Subject)
+
+	// Pull requests are only trusted when requested.
+	provider.trustPullRequests = true
+	credentials, err = provider.FederatedCredentials(pipelineRepository{name: "Contoso/todo.app"}, "feature/login", &env)
+	require.NoError(t, err)
+	require.Len(t, credentials, 2)
+
+	require.Equal(t, "Contoso-todo-app-pull-request", credentials[1].Name)
+	require.Equal(t, "repo:Contoso/todo.app:pull_request", credentials[1].Subject)
+
+	for _, credential := range credentials {
+		require.Equal(t, gitHubActionsTokenIssuer, credential.Issuer)
+		require.Equal(t, []string{azureADTokenExchangeAudience}, credential.Audiences)
+	}
+}
+
+func TestGitHubFederatedCredentialsWithEnvironments(t *testing.T) {
+	env := environment.Empty("")
+	env.SetEnvName("prod")
+	provider := &gitHubProvider{useGitHubEnvironments: true}
+
+	credentials, err := provider.FederatedCredentials(pipelineRepository{name: "Contoso/todo"}, "main", &env)
+	require.NoError(t, err)
+	require.Len(t, credentials, 1)
+	require.Equal(t, "Contoso-todo-environment-prod", credentials[0].Name)
+	require.Equal(t, "repo:Contoso/todo:environment:prod", credentials[0].Subject)
+}
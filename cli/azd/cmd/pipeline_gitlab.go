@@ -0,0 +1,270 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/gitlab"
+	"github.com/azure/azure-dev/cli/azd/pkg/project"
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+)
+
+// The pipeline definition, relative to the root of the repository
+const gitLabCIYamlPath = ".gitlab-ci.yml"
+
+// gitLabProvider configures GitLab CI/CD, by setting the CI/CD variables of the project which the pipeline defined
+// in .gitlab-ci.yml uses to deploy.
+type gitLabProvider struct {
+	azdCtx     *environment.AzdContext
+	remoteName string
+	askOne     Asker
+	noPrompt   bool
+	// The URL of the GitLab instance, which is GitLab.com when empty
+	baseUrl string
+	// When set, the variables are only exposed to pipelines running on protected branches and tags
+	protected bool
+	plan      *pipelinePlan
+
+	token string
+	// Set when the deployment is configured
+	client  *gitlab.Client
+	project gitlab.Project
+}
+
+func (p *gitLabProvider) Name() string {
+	return "GitLab CI/CD"
+}
+
+func (p *gitLabProvider) RequiredTools() []tools.ExternalTool {
+	return nil
+}
+
+func (p *gitLabProvider) PreConfigureCheck(ctx context.Context) error {
+	token, err := readPersonalAccessToken("GitLab", gitlab.TokenEnvVarName, p.noPrompt, p.askOne)
+	if err != nil {
+		return err
+	}
+
+	p.token = token
+	return nil
+}
+
+func (p *gitLabProvider) DefinitionPath() string {
+	return gitLabCIYamlPath
+}
+
+// Definition generates a pipeline logging in with the client secret of the service principal when it is set, and with
+// the ID token GitLab issues to the job otherwise, and provisioning and deploying the project on pushes to the default
+// branch. The azd image provides the toolchains of every language.
+func (p *gitLabProvider) Definition(def pipelineDefinition) string {
+	var b strings.Builder
+
+	b.WriteString(`image: mcr.microsoft.com/azure-dev-cli-apps:latest
+
+deploy:
+  id_tokens:
+    AZURE_FEDERATED_TOKEN:
+      aud: api://AzureADTokenExchange
+  rules:
+    - if: $CI_COMMIT_BRANCH == $CI_DEFAULT_BRANCH
+    - if: $CI_PIPELINE_SOURCE == "web"
+`)
+
+	if def.docker {
+		b.WriteString(`  services:
+    - docker:dind
+  variables:
+    DOCKER_HOST: tcp://docker:2375
+    DOCKER_TLS_CERTDIR: ""
+`)
+	}
+
+	b.WriteString(`  script:
+    - |
+      if [ -n "$AZURE_CLIENT_SECRET" ]; then
+        az login --service-principal -u "$AZURE_CLIENT_ID" -p "$AZURE_CLIENT_SECRET" --tenant "$AZURE_TENANT_ID"
+      else
+        az login --service-principal -u "$AZURE_CLIENT_ID" --federated-token "$AZURE_FEDERATED_TOKEN" --tenant "$AZURE_TENANT_ID"
+      fi
+    - az account set --subscription "$AZURE_SUBSCRIPTION_ID"
+    - azd provision --no-prompt
+    - azd deploy --no-prompt
+`)
+
+	return b.String()
+}
+
+func (p *gitLabProvider) RepositoryName(remoteUrl string) (string, error) {
+	project, err := gitlab.GetProjectForRemote(remoteUrl, p.baseUrl)
+	if err != nil {
+		return "", err
+	}
+
+	return project.Path, nil
+}
+
+// ConfigureRemote interactively prompts the user for a URL for a GitLab project, which is validated.
+func (p *gitLabProvider) ConfigureRemote(ctx context.Context) (string, error) {
+	remoteUrl := ""
+
+	for remoteUrl == "" {
+		if err := p.askOne(&survey.Input{
+			Message: fmt.Sprintf("Please enter the url of the GitLab project to use for remote %s:", p.remoteName),
+		}, &remoteUrl); err != nil {
+			return "", fmt.Errorf("prompting for remote url: %w", err)
+		}
+
+		if !gitlab.IsGitLabRemote(remoteUrl, p.baseUrl) {
+			fmt.Printf("error: \"%s\" is not a valid GitLab URL.\n", remoteUrl)
+
+			// So we retry from the loop.
+			remoteUrl = ""
+		}
+	}
+
+	return remoteUrl, nil
+}
+
+// FederatedCredentials returns a credential for the pipelines running on the branch, whose ID tokens are issued by
+// the GitLab instance.
+func (p *gitLabProvider) FederatedCredentials(repo pipelineRepository, branch string, env *environment.Environment) ([]tools.FederatedIdentityCredential, error) {
+	project, err := gitlab.GetProjectForRemote(repo.remoteUrl, p.baseUrl)
+	if err != nil {
+		return nil, fmt.Errorf("parsing remote url: %w", err)
+	}
+
+	return []tools.FederatedIdentityCredential{
+		{
+			Name:        federatedCredentialNameInvalidCharsRegex.ReplaceAllString(fmt.Sprintf("%s-branch-%s", project.Path, branch), "-"),
+			Issuer:      project.BaseUrl,
+			Subject:     fmt.Sprintf("project_path:%s:ref_type:branch:ref:%s", project.Path, branch),
+			Description: "Created by the Azure Developer CLI",
+			Audiences:   []string{azureADTokenExchangeAudience},
+		},
+	}, nil
+}
+
+func (p *gitLabProvider) ConfigureDeployment(
+	ctx context.Context,
+	repo pipelineRepository,
+	credentials pipelineCredentials,
+	env *environment.Environment,
+	values []project.PipelineValue) error {
+	var err error
+
+	p.project, err = gitlab.GetProjectForRemote(repo.remoteUrl, p.baseUrl)
+	if err != nil {
+		return fmt.Errorf("parsing remote url: %w", err)
+	}
+
+	p.client = gitlab.NewClient(p.project.BaseUrl, p.token)
+
+	variables := []project.PipelineValue{
+		{Name: "AZURE_CLIENT_ID", Value: credentials.clientId},
+		{Name: "AZURE_TENANT_ID", Value: credentials.tenantId},
+		{Name: environment.SubscriptionIdEnvVarName, Value: credentials.subscriptionId},
+		{Name: environment.EnvNameEnvVarName, Value: env.Values[environment.EnvNameEnvVarName]},
+		{Name: environment.LocationEnvVarName, Value: env.Values[environment.LocationEnvVarName]},
+	}
+
+	if credentials.authType == clientCredentialsAuthType {
+		var azureCredentials tools.AzureCredentials
+		if err := json.Unmarshal(credentials.azureCredentials, &azureCredentials); err != nil {
+			return fmt.Errorf("parsing service principal credentials: %w", err)
+		}
+
+		variables = append(variables, project.PipelineValue{Name: "AZURE_CLIENT_SECRET", Value: azureCredentials.ClientSecret, Secret: true})
+	}
+
+	// The values of the project are exposed to the jobs like the others, since GitLab exposes every variable.
+	variables = append(variables, values...)
+
+	for _, variable := range variables {
+		variable := variable
+		masked := variable.Secret && gitlab.CanMask(variable.Value)
+		// The client secret of a dry run is not known, but the secrets of Azure AD can always be masked.
+		if p.plan.dryRun && variable.Name == "AZURE_CLIENT_SECRET" {
+			masked = true
+		}
+
+		var details []string
+		if masked {
+			details = append(details, "masked")
+		} else if variable.Secret {
+			details = append(details, "not maskable")
+		}
+		if p.protected {
+			details = append(details, "protected")
+		}
+
+		err := p.plan.apply(pipelineChange{
+			Target:   "GitLab",
+			Action:   "set",
+			Resource: "variable " + variable.Name,
+			Details:  strings.Join(details, ", "),
+		}, func() error {
+			if variable.Secret && !masked {
+				printWithStyling("%s the value of %s cannot be masked in job logs by GitLab.\n",
+					withHighLightFormat("WARNING:"), variable.Name)
+			}
+
+			fmt.Printf("Setting %s GitLab CI/CD variable.\n", variable.Name)
+
+			if err := p.client.CreateOrUpdateVariable(ctx, p.project.Path, gitlab.Variable{
+				Key:       variable.Name,
+				Value:     variable.Value,
+				Masked:    masked,
+				Protected: p.protected,
+			}); err != nil {
+				return fmt.Errorf("failed setting %s variable: %w", variable.Name, err)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.plan.dryRun {
+		return nil
+	}
+
+	fmt.Println()
+	fmt.Printf("GitLab CI/CD variables are now configured. See %s for details on the pipeline.\n", gitLabCIYamlPath)
+
+	if p.protected {
+		fmt.Println("The variables are only exposed to pipelines running on protected branches and tags.")
+	}
+
+	return nil
+}
+
+func (p *gitLabProvider) PrePush(ctx context.Context, repo pipelineRepository, branch string) (bool, error) {
+	return false, nil
+}
+
+// PostPush points to the pipelines of the project, which GitLab runs on push.
+func (p *gitLabProvider) PostPush(ctx context.Context, repo pipelineRepository, branch string, pushed bool) error {
+	if p.plan.dryRun {
+		return nil
+	}
+
+	if !pushed {
+		fmt.Printf("To run the GitLab CI/CD pipeline you need to push this repo to GitLab using "+
+			"'git push --set-upstream %s %s'.\n", repo.remoteName, branch)
+		return nil
+	}
+
+	fmt.Println()
+	printWithStyling("You can view the pipelines here: %s\n", withLinkFormat("%s", p.project.WebUrl()+"/-/pipelines"))
+
+	return nil
+}
@@ -0,0 +1,27 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitLabFederatedCredentials(t *testing.T) {
+	env := environment.Empty("")
+	env.SetEnvName("dev")
+	provider := &gitLabProvider{baseUrl: "https://git.contoso.com/"}
+
+	repo := pipelineRepository{remoteUrl: "git@git.contoso.com:web/todo.app.git"}
+	credentials, err := provider.FederatedCredentials(repo, "main", &env)
+	require.NoError(t, err)
+	require.Len(t, credentials, 1)
+
+	require.Equal(t, "web-todo-app-branch-main", credentials[0].Name)
+	require.Equal(t, "https://git.contoso.com", credentials[0].Issuer)
+	require.Equal(t, "project_path:web/todo.app:ref_type:branch:ref:main", credentials[0].Subject)
+	require.Equal(t, []string{azureADTokenExchangeAudience}, credentials[0].Audiences)
+}
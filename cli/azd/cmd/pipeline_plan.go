@@ -0,0 +1,91 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"io"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/output"
+)
+
+// The targets of the changes azd pipeline config makes
+const (
+	azureADChangeTarget = "Azure AD"
+	azureChangeTarget   = "Azure"
+	gitChangeTarget     = "git"
+	fileChangeTarget    = "file"
+)
+
+// pipelineChange is a change azd pipeline config makes, such as creating a service principal or setting a secret.
+type pipelineChange struct {
+	// Target is the service or system changed, such as "Azure AD", "GitHub" or "git".
+	Target string `json:"target"`
+	// Action is what is done, such as "create", "update" or "set".
+	Action string `json:"action"`
+	// Resource is what is changed, such as "secret AZURE_CREDENTIALS".
+	Resource string `json:"resource"`
+	Details  string `json:"details,omitempty"`
+}
+
+// pipelinePlan records the changes azd pipeline config makes. With --dry-run, the changes are only recorded, so they
+// can be reviewed before they are made.
+type pipelinePlan struct {
+	dryRun  bool
+	changes []pipelineChange
+}
+
+// apply records change, and makes it by calling apply unless the plan is a dry run.
+func (p *pipelinePlan) apply(change pipelineChange, apply func() error) error {
+	p.changes = append(p.changes, change)
+
+	if p.dryRun {
+		return nil
+	}
+
+	return apply()
+}
+
+// require checks a precondition of the changes, such as being logged in, by calling check. A dry run does not check
+// preconditions, since checking them may prompt or log in, and records them with the changes instead.
+func (p *pipelinePlan) require(precondition pipelineChange, check func() error) error {
+	if p.dryRun {
+		p.changes = append(p.changes, precondition)
+		return nil
+	}
+
+	return check()
+}
+
+// write writes the changes of the plan with the formatter, as a table or as JSON.
+func (p *pipelinePlan) write(formatter output.Formatter, writer io.Writer) error {
+	changes := p.changes
+	if changes == nil {
+		changes = []pipelineChange{}
+	}
+
+	if formatter.Kind() == output.TableFormat {
+		return formatter.Format(changes, writer, output.TableFormatterOptions{
+			Columns: []output.Column{
+				{
+					Heading:       "TARGET",
+					ValueTemplate: "{{.Target}}",
+				},
+				{
+					Heading:       "ACTION",
+					ValueTemplate: "{{.Action}}",
+				},
+				{
+					Heading:       "RESOURCE",
+					ValueTemplate: "{{.Resource}}",
+				},
+				{
+					Heading:       "DETAILS",
+					ValueTemplate: "{{.Details}}",
+				},
+			},
+		})
+	}
+
+	return formatter.Format(changes, writer, nil)
+}
@@ -0,0 +1,103 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/output"
+	"github.com/azure/azure-dev/cli/azd/pkg/project"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelinePlanApply(t *testing.T) {
+	applied := 0
+	apply := func() error {
+		applied++
+		return nil
+	}
+
+	plan := &pipelinePlan{}
+	require.NoError(t, plan.apply(pipelineChange{Target: gitChangeTarget, Action: "push"}, apply))
+	require.Equal(t, 1, applied)
+
+	plan = &pipelinePlan{dryRun: true}
+	require.NoError(t, plan.apply(pipelineChange{Target: gitChangeTarget, Action: "push"}, apply))
+	require.Equal(t, 1, applied)
+	require.Equal(t, []pipelineChange{{Target: gitChangeTarget, Action: "push"}}, plan.changes)
+}
+
+func TestPipelinePlanRequire(t *testing.T) {
+	checked := 0
+	check := func() error {
+		checked++
+		return nil
+	}
+	precondition := pipelineChange{Target: azureChangeTarget, Action: "require", Resource: "login"}
+
+	plan := &pipelinePlan{}
+	require.NoError(t, plan.require(precondition, check))
+	require.Equal(t, 1, checked)
+	require.Empty(t, plan.changes)
+
+	plan = &pipelinePlan{dryRun: true}
+	require.NoError(t, plan.require(precondition, check))
+	require.Equal(t, 1, checked)
+	require.Equal(t, []pipelineChange{precondition}, plan.changes)
+}
+
+func TestPipelinePlanWriteJson(t *testing.T) {
+	formatter, err := output.NewFormatter(string(output.JsonFormat))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, (&pipelinePlan{}).write(formatter, &buf))
+	require.JSONEq(t, "[]", buf.String())
+}
+
+// The GitHub provider only records its changes in a dry run, and never calls the GitHub CLI, which is not set.
+func TestGitHubConfigureDeploymentDryRun(t *testing.T) {
+	env := environment.Empty("")
+	env.SetEnvName("prod")
+	env.Values[environment.LocationEnvVarName] = "westus2"
+
+	plan := &pipelinePlan{dryRun: true}
+	provider := &gitHubProvider{
+		useGitHubEnvironments: true,
+		pipelineConfig: project.PipelineConfig{
+			Environments: map[string]*project.PipelineEnvironmentConfig{"prod": {Reviewers: []string{"octocat"}}},
+		},
+		plan: plan,
+	}
+
+	credentials := pipelineCredentials{authType: federatedAuthType, clientId: "CLIENT_ID", tenantId: "TENANT_ID", subscriptionId: "SUBSCRIPTION_ID"}
+	err := provider.ConfigureDeployment(context.Background(), pipelineRepository{name: "contoso/todo"}, credentials, &env,
+		[]project.PipelineValue{{Name: "DB_PASSWORD", Value: "password", Secret: true}})
+	require.NoError(t, err)
+
+	resources := make([]string, len(plan.changes))
+	for i, change := range plan.changes {
+		resources[i] = change.Resource
+	}
+
+	require.Equal(t, []string{
+		"environment prod",
+		"variable AZURE_CLIENT_ID",
+		"variable AZURE_TENANT_ID",
+		"variable AZURE_SUBSCRIPTION_ID",
+		"variable AZURE_ENV_NAME",
+		"variable AZURE_LOCATION",
+		"secret DB_PASSWORD",
+	}, resources)
+	require.Equal(t, "with reviewers octocat", plan.changes[0].Details)
+	require.Equal(t, "of environment prod", plan.changes[1].Details)
+
+	raw, err := json.Marshal(plan.changes[0])
+	require.NoError(t, err)
+	require.JSONEq(t, `{"target":"GitHub","action":"create or update","resource":"environment prod","details":"with reviewers octocat"}`, string(raw))
+}
@@ -7,10 +7,11 @@ import (
 )
 
 func provisionCmd(rootOptions *commands.GlobalCommandOptions) *cobra.Command {
+	action := &infraCreateAction{
+		rootOptions: rootOptions,
+	}
 	cmd := commands.Build(
-		&infraCreateAction{
-			rootOptions: rootOptions,
-		},
+		action,
 		rootOptions,
 		"provision",
 		"Provision the Azure resources for an application.",
@@ -21,9 +22,13 @@ The command prompts you for the following:
 - Azure location: The Azure location where your resources will be deployed.
 - Azure subscription: The Azure subscription where your resources will be deployed.
 
-Depending on what Azure resources are created, running this command might take a while. To view progress, go to the Azure portal and search for the resource group that contains your environment name.`,
+Depending on what Azure resources are created, running this command might take a while. To view progress, go to the Azure portal and search for the resource group that contains your environment name.
+
+Use --preview to list the resources which would be created, modified or deleted, along with the changes to their properties, without provisioning them.`,
 	)
 
+	action.setupPreviewFlag(cmd.Flags())
+
 	return output.AddOutputParam(
 		cmd,
 		[]output.Format{output.JsonFormat, output.NoneFormat},
@@ -6,9 +6,11 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"github.com/azure/azure-dev/cli/azd/pkg/commands"
 	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/ext"
 	"github.com/azure/azure-dev/cli/azd/pkg/project"
 	"github.com/azure/azure-dev/cli/azd/pkg/spin"
 	"github.com/azure/azure-dev/cli/azd/pkg/tools"
@@ -77,6 +79,17 @@ func (r *restoreAction) Run(ctx context.Context, _ *cobra.Command, args []string
 		return err
 	}
 
+	// Restore does not require an environment, but when one exists its values are made available to hooks.
+	env, err := loadEnvironmentIfExists(r.rootOptions.EnvironmentName, azdCtx)
+	if err != nil {
+		return fmt.Errorf("loading environment: %w", err)
+	}
+
+	projectHooks := ext.NewHooksRunner(proj.Hooks, proj.Path, &env, ext.HooksRunnerArgs{Output: os.Stdout})
+	if err := projectHooks.Run(ctx, ext.PreRestoreHook); err != nil {
+		return err
+	}
+
 	for _, svc := range proj.Services {
 		if r.serviceName != "" && svc.Name != r.serviceName {
 			continue
@@ -88,11 +101,20 @@ func (r *restoreAction) Run(ctx context.Context, _ *cobra.Command, args []string
 			return fmt.Errorf("getting framework services: %w", err)
 		}
 
+		serviceHooks := ext.NewHooksRunner(svc.Hooks, svc.Path(), &env, ext.HooksRunnerArgs{Output: os.Stdout})
+		if err := serviceHooks.Run(ctx, ext.PreRestoreHook); err != nil {
+			return fmt.Errorf("service %s: %w", svc.Name, err)
+		}
+
 		spinner := spin.NewSpinner(installMsg)
 		if err = spinner.Run(func() error { return (*frameworkService).InstallDependencies(ctx) }); err != nil {
 			return err
 		}
 
+		if err := serviceHooks.Run(ctx, ext.PostRestoreHook); err != nil {
+			return fmt.Errorf("service %s: %w", svc.Name, err)
+		}
+
 		count++
 	}
 
@@ -100,5 +122,9 @@ func (r *restoreAction) Run(ctx context.Context, _ *cobra.Command, args []string
 		return fmt.Errorf("Dependencies were not restored (%s service was not found)", r.serviceName)
 	}
 
+	if err := projectHooks.Run(ctx, ext.PostRestoreHook); err != nil {
+		return err
+	}
+
 	return nil
 }
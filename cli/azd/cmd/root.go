@@ -5,10 +5,14 @@ package cmd
 
 import (
 	"fmt"
+	"log"
 	"os"
 
+	"github.com/azure/azure-dev/cli/azd/pkg/auth"
 	"github.com/azure/azure-dev/cli/azd/pkg/commands"
 	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/recording"
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
 	"github.com/spf13/cobra"
 )
 
@@ -53,6 +57,54 @@ For more information, visit the Azure Developer CLI Dev Hub: https://aka.ms/azur
 				opts.EnvironmentName = os.Getenv(environment.EnvNameEnvVarName)
 			}
 
+			if opts.AzCliKind != tools.AzCliKindCli && opts.AzCliKind != tools.AzCliKindRest {
+				return fmt.Errorf("AZURE_DEV_AZURE_CLIENT must be '%s' or '%s', but it was '%s'", tools.AzCliKindCli, tools.AzCliKindRest, opts.AzCliKind)
+			}
+
+			if opts.Recorder == nil {
+				cwd, err := os.Getwd()
+				if err != nil {
+					return err
+				}
+
+				recorder, err := recording.FromEnvironment(cwd)
+				if err != nil {
+					return fmt.Errorf("loading recording: %w", err)
+				}
+
+				opts.Recorder = recorder
+			}
+
+			if opts.Credential == nil {
+				authManager, err := auth.NewDefaultManager()
+				if err != nil {
+					return err
+				}
+
+				credential, err := authManager.CurrentCredential()
+				if err != nil {
+					return fmt.Errorf("loading credential: %w", err)
+				}
+
+				opts.Credential = credential
+			}
+
+			// Without the Azure CLI logged in to the identity of the credential, Azure must be called directly. A saved
+			// login was chosen with azd login, but a service principal configured in the environment may not be expected,
+			// so using it is reported.
+			if _, has := os.LookupEnv("AZURE_DEV_AZURE_CLIENT"); !has && opts.Credential != nil {
+				opts.AzCliKind = tools.AzCliKindRest
+
+				if config := auth.LoginConfigFromEnvironment(); config != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(),
+						"Using service principal %s configured with the %s and %s environment variables to call Azure, "+
+							"instead of the Azure CLI. Set AZURE_DEV_AZURE_CLIENT to '%s' to use the Azure CLI.\n",
+						config.ClientId, auth.ClientIdEnvVarName, auth.TenantIdEnvVarName, tools.AzCliKindCli)
+				} else {
+					log.Printf("using the saved login to call Azure, instead of the Azure CLI")
+				}
+			}
+
 			return nil
 		},
 		PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
@@ -80,6 +132,11 @@ For more information, visit the Azure Developer CLI Dev Hub: https://aka.ms/azur
 	// the equivalent of AZURE_CORE_COLLECT_TELEMETRY
 	opts.EnableTelemetry = os.Getenv("AZURE_DEV_COLLECT_TELEMETRY") != "no"
 
+	opts.AzCliKind = tools.AzCliKindCli
+	if kind, has := os.LookupEnv("AZURE_DEV_AZURE_CLIENT"); has {
+		opts.AzCliKind = kind
+	}
+
 	cmd.AddCommand(deployCmd(opts))
 	cmd.AddCommand(downCmd(opts))
 	cmd.AddCommand(envCmd(opts))
@@ -87,6 +144,7 @@ For more information, visit the Azure Developer CLI Dev Hub: https://aka.ms/azur
 	cmd.AddCommand(initCmd(opts))
 	cmd.AddCommand(loginCmd(opts))
 	cmd.AddCommand(monitorCmd(opts))
+	cmd.AddCommand(packageCmd(opts))
 	cmd.AddCommand(pipelineCmd(opts))
 	cmd.AddCommand(provisionCmd(opts))
 	cmd.AddCommand(restoreCmd(opts))
@@ -18,6 +18,9 @@ import (
 	"github.com/azure/azure-dev/cli/azd/pkg/azureutil"
 	"github.com/azure/azure-dev/cli/azd/pkg/commands"
 	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/infra/provisioning"
+	"github.com/azure/azure-dev/cli/azd/pkg/output"
+	"github.com/azure/azure-dev/cli/azd/pkg/project"
 	"github.com/azure/azure-dev/cli/azd/pkg/templates"
 	"github.com/azure/azure-dev/cli/azd/pkg/tools"
 	"github.com/fatih/color"
@@ -332,6 +335,8 @@ func askOneNoPrompt(p survey.Prompt, response interface{}) error {
 		}
 	case *survey.Confirm:
 		*(response.(*bool)) = v.Default
+	case *survey.Password:
+		return fmt.Errorf("no default response for prompt '%s'", v.Message)
 	default:
 		panic(fmt.Sprintf("don't know how to prompt for type %T", p))
 	}
@@ -550,18 +555,96 @@ func promptLocation(ctx context.Context, message string, askOne Asker) (string,
 	return locations[locationSelectionIndex].Name, nil
 }
 
-func saveEnvironmentValues(res tools.AzCliDeployment, env environment.Environment) error {
-	if len(res.Properties.Outputs) > 0 {
-		for name, o := range res.Properties.Outputs {
-			env.Values[name] = fmt.Sprintf("%v", o.Value)
+// newInfraProvider creates the infrastructure provider configured by the `infra` section of the project.
+func newInfraProvider(
+	ctx context.Context, azdCtx *environment.AzdContext, env *environment.Environment, prj *project.ProjectConfig, askOne Asker,
+) (provisioning.Provider, error) {
+	return provisioning.NewProvider(ctx, commands.GetAzCliFromContext(ctx), azdCtx, env, prj.Infra, provisioning.Prompters{
+		AskOne: askOne,
+		PromptLocation: func(ctx context.Context, message string) (string, error) {
+			return promptLocation(ctx, message, askOne)
+		},
+	})
+}
+
+// saveEnvironmentValues saves the outputs of the latest deployment of module to env. Outputs which are not saved, since
+// env has a different value of the same name which is not an output, are reported to w.
+func saveEnvironmentValues(
+	module string, outputs map[string]provisioning.OutputParameter, env *environment.Environment, w io.Writer,
+) (environment.OutputChanges, error) {
+	values := make(map[string]string, len(outputs))
+	for name, o := range outputs {
+		values[name] = fmt.Sprintf("%v", o.Value)
+	}
+
+	changes := env.ApplyOutputs(module, values)
+	if err := env.Save(); err != nil {
+		return changes, fmt.Errorf("writing environment: %w", err)
+	}
+
+	for _, name := range changes.Skipped {
+		fmt.Fprintf(w, "warning: output %s was not saved, since the environment has a value of the same name which "+
+			"was not set by a deployment. Remove %s from the environment to use the output instead.\n", name, name)
+	}
+
+	return changes, nil
+}
+
+// printOutputChanges reports how the outputs of a deployment changed the values of an environment to w.
+func printOutputChanges(w io.Writer, changes environment.OutputChanges) {
+	if len(changes.Added) == 0 && len(changes.Changed) == 0 && len(changes.Removed) == 0 {
+		fmt.Fprintln(w, "The outputs of the environment are up to date.")
+		return
+	}
+
+	for _, change := range []struct {
+		label string
+		names []string
+	}{
+		{"Added", changes.Added},
+		{"Changed", changes.Changed},
+		{"Removed", changes.Removed},
+	} {
+		if len(change.names) > 0 {
+			fmt.Fprintf(w, "%s outputs: %s\n", change.label, strings.Join(change.names, ", "))
 		}
+	}
+}
+
+// hooksOutput returns the writer that receives the output of lifecycle hooks. When a command emits structured
+// output, hook output is written to stderr so it does not corrupt the result written to stdout.
+func hooksOutput(formatter output.Formatter) io.Writer {
+	if formatter != nil && formatter.Kind() != output.NoneFormat {
+		return os.Stderr
+	}
+
+	return os.Stdout
+}
 
-		if err := env.Save(); err != nil {
-			return fmt.Errorf("writing environment: %w", err)
+// loadEnvironmentIfExists loads the environment named by environmentName, or the default environment when no name is
+// given, without prompting. An empty environment is returned when no such environment has been created yet.
+func loadEnvironmentIfExists(environmentName string, azdCtx *environment.AzdContext) (environment.Environment, error) {
+	if environmentName == "" {
+		defaultName, err := azdCtx.GetDefaultEnvironmentName()
+		if err != nil {
+			return environment.Environment{}, err
 		}
+
+		environmentName = defaultName
 	}
 
-	return nil
+	if environmentName == "" {
+		return environment.Empty(""), nil
+	}
+
+	env, err := azdCtx.GetEnvironment(environmentName)
+	if errors.Is(err, os.ErrNotExist) {
+		return environment.Empty(""), nil
+	} else if err != nil {
+		return environment.Environment{}, err
+	}
+
+	return env, nil
 }
 
 var (
@@ -0,0 +1,155 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+)
+
+// Cached tokens are only used while they are valid for at least this long, so they do not expire during a request.
+const tokenRefreshWindow = 5 * time.Minute
+
+type cachedToken struct {
+	AccessToken string    `json:"accessToken"`
+	ExpiresOn   time.Time `json:"expiresOn"`
+}
+
+// tokenCache is a file storing access tokens, so they are shared by the commands run by the user.
+type tokenCache struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (c *tokenCache) read() (map[string]cachedToken, error) {
+	tokens := map[string]cachedToken{}
+
+	bytes, err := ioutil.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return tokens, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading token cache: %w", err)
+	}
+
+	// A corrupt cache is not an error, the tokens are requested again.
+	if err := json.Unmarshal(bytes, &tokens); err != nil {
+		return map[string]cachedToken{}, nil
+	}
+
+	return tokens, nil
+}
+
+func (c *tokenCache) get(key string, now time.Time) (tools.AzCliAccessToken, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tokens, err := c.read()
+	if err != nil {
+		return tools.AzCliAccessToken{}, false, err
+	}
+
+	token, has := tokens[key]
+	if !has || token.ExpiresOn.Before(now.Add(tokenRefreshWindow)) {
+		return tools.AzCliAccessToken{}, false, nil
+	}
+
+	expiresOn := token.ExpiresOn
+	return tools.AzCliAccessToken{AccessToken: token.AccessToken, ExpiresOn: &expiresOn}, true, nil
+}
+
+func (c *tokenCache) set(key string, token tools.AzCliAccessToken, now time.Time) error {
+	if token.ExpiresOn == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tokens, err := c.read()
+	if err != nil {
+		return err
+	}
+
+	for k, v := range tokens {
+		if v.ExpiresOn.Before(now) {
+			delete(tokens, k)
+		}
+	}
+
+	tokens[key] = cachedToken{AccessToken: token.AccessToken, ExpiresOn: *token.ExpiresOn}
+
+	bytes, err := json.Marshal(tokens)
+	if err != nil {
+		return fmt.Errorf("marshalling token cache: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), authDirectoryPermission); err != nil {
+		return fmt.Errorf("creating auth directory: %w", err)
+	}
+
+	// The cache is written to a temporary file first, so a concurrent command never reads a partial file.
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), tokenCacheFileName)
+	if err != nil {
+		return fmt.Errorf("writing token cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(bytes); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing token cache: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing token cache: %w", err)
+	}
+
+	if err := os.Chmod(tmp.Name(), tokenCachePermission); err != nil {
+		return fmt.Errorf("writing token cache: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("writing token cache: %w", err)
+	}
+
+	return nil
+}
+
+// cachedCredential serves tokens from the token cache, requesting them from the wrapped credential when they are
+// missing or about to expire.
+type cachedCredential struct {
+	credential tools.TokenCredential
+	cache      *tokenCache
+	// Identifies the credential in the cache, the scope is appended to it
+	key string
+}
+
+func (cred *cachedCredential) GetToken(ctx context.Context, scope string) (tools.AzCliAccessToken, error) {
+	key := cred.key + "|" + scope
+
+	if token, has, err := cred.cache.get(key, time.Now()); err != nil {
+		return tools.AzCliAccessToken{}, err
+	} else if has {
+		return token, nil
+	}
+
+	token, err := cred.credential.GetToken(ctx, scope)
+	if err != nil {
+		return tools.AzCliAccessToken{}, err
+	}
+
+	if err := cred.cache.set(key, token, time.Now()); err != nil {
+		return tools.AzCliAccessToken{}, err
+	}
+
+	return token, nil
+}
@@ -0,0 +1,314 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/httpUtil"
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+)
+
+const (
+	defaultAuthorityHost = "https://login.microsoftonline.com"
+
+	// The endpoint of the instance metadata service, which provides tokens for managed identities of virtual machines
+	imdsTokenEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+	// Client assertions signed with a certificate are valid for this long
+	clientAssertionLifetime = 10 * time.Minute
+)
+
+// tokenResponse is the response of the token endpoint of Azure Active Directory and of managed identity endpoints.
+// Managed identity endpoints return numbers as strings.
+type tokenResponse struct {
+	AccessToken string          `json:"access_token"`
+	ExpiresIn   json.RawMessage `json:"expires_in"`
+	ExpiresOn   json.RawMessage `json:"expires_on"`
+}
+
+func (res *tokenResponse) toAccessToken(now time.Time) (tools.AzCliAccessToken, error) {
+	if res.AccessToken == "" {
+		return tools.AzCliAccessToken{}, errors.New("the response did not contain an access token")
+	}
+
+	var expiresOn time.Time
+	if seconds, err := parseNumber(res.ExpiresOn); err == nil {
+		expiresOn = time.Unix(seconds, 0)
+	} else if seconds, err := parseNumber(res.ExpiresIn); err == nil {
+		expiresOn = now.Add(time.Duration(seconds) * time.Second)
+	} else {
+		return tools.AzCliAccessToken{}, errors.New("the response did not contain the expiration of the access token")
+	}
+
+	return tools.AzCliAccessToken{AccessToken: res.AccessToken, ExpiresOn: &expiresOn}, nil
+}
+
+// parseNumber parses a JSON number, which may be quoted.
+func parseNumber(raw json.RawMessage) (int64, error) {
+	return strconv.ParseInt(strings.Trim(string(raw), `"`), 10, 64)
+}
+
+// sendTokenRequest sends a request for a token and parses the response.
+func sendTokenRequest(ctx context.Context, request *httpUtil.HttpRequestMessage) (tools.AzCliAccessToken, error) {
+	now := time.Now()
+
+	res, err := httpUtil.GetHttpUtilFromContext(ctx).Send(request)
+	if err != nil {
+		return tools.AzCliAccessToken{}, fmt.Errorf("requesting token: %w", err)
+	}
+
+	if res.Status != http.StatusOK {
+		var wire struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}
+		if err := json.Unmarshal(res.Body, &wire); err == nil && wire.Error != "" {
+			return tools.AzCliAccessToken{}, fmt.Errorf("requesting token: %s: %s", wire.Error, wire.ErrorDescription)
+		}
+
+		return tools.AzCliAccessToken{}, fmt.Errorf("requesting token failed with status %d: %s", res.Status, string(res.Body))
+	}
+
+	var token tokenResponse
+	if err := json.Unmarshal(res.Body, &token); err != nil {
+		return tools.AzCliAccessToken{}, fmt.Errorf("could not unmarshal token response: %w", err)
+	}
+
+	return token.toAccessToken(now)
+}
+
+// confidentialClient requests tokens for an application registered with Azure Active Directory, with the client
+// credentials flow. The credentials which embed it authenticate the application with additional form values.
+type confidentialClient struct {
+	authorityHost string
+	tenantId      string
+	clientId      string
+}
+
+func (c *confidentialClient) tokenEndpoint() string {
+	return fmt.Sprintf("%s/%s/oauth2/v2.0/token", strings.TrimSuffix(c.authorityHost, "/"), c.tenantId)
+}
+
+func (c *confidentialClient) requestToken(ctx context.Context, scope string, authentication url.Values) (tools.AzCliAccessToken, error) {
+	form := url.Values{
+		"grant_type": {"client_credentials"},
+		"client_id":  {c.clientId},
+		"scope":      {scope},
+	}
+	for k, v := range authentication {
+		form[k] = v
+	}
+
+	return sendTokenRequest(ctx, &httpUtil.HttpRequestMessage{
+		Url:     c.tokenEndpoint(),
+		Method:  http.MethodPost,
+		Headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+		Body:    form.Encode(),
+	})
+}
+
+// clientSecretCredential authenticates a service principal with the client secret set in AZURE_CLIENT_SECRET. The
+// secret is read when a token is requested, so it is never saved.
+type clientSecretCredential struct {
+	confidentialClient
+}
+
+func (cred *clientSecretCredential) GetToken(ctx context.Context, scope string) (tools.AzCliAccessToken, error) {
+	secret := os.Getenv(ClientSecretEnvVarName)
+	if secret == "" {
+		return tools.AzCliAccessToken{}, fmt.Errorf("the secret of the service principal must be set in %s", ClientSecretEnvVarName)
+	}
+
+	return cred.requestToken(ctx, scope, url.Values{"client_secret": {secret}})
+}
+
+// clientCertificateCredential authenticates a service principal with a client assertion signed with a certificate,
+// which is read from a PEM file containing the certificate and its RSA private key.
+type clientCertificateCredential struct {
+	confidentialClient
+	certificatePath string
+
+	certificate *x509.Certificate
+	key         *rsa.PrivateKey
+}
+
+func (cred *clientCertificateCredential) GetToken(ctx context.Context, scope string) (tools.AzCliAccessToken, error) {
+	if cred.certificate == nil {
+		certificate, key, err := readCertificate(cred.certificatePath)
+		if err != nil {
+			return tools.AzCliAccessToken{}, err
+		}
+
+		cred.certificate, cred.key = certificate, key
+	}
+
+	assertion, err := cred.newClientAssertion(time.Now())
+	if err != nil {
+		return tools.AzCliAccessToken{}, err
+	}
+
+	return cred.requestToken(ctx, scope, url.Values{
+		"client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+		"client_assertion":      {assertion},
+	})
+}
+
+// readCertificate reads a PEM file containing a certificate and its RSA private key.
+func readCertificate(certificatePath string) (*x509.Certificate, *rsa.PrivateKey, error) {
+	contents, err := os.ReadFile(certificatePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading certificate: %w", err)
+	}
+
+	var certificate *x509.Certificate
+	var key *rsa.PrivateKey
+
+	for block, rest := pem.Decode(contents); block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case "CERTIFICATE":
+			if certificate == nil {
+				if certificate, err = x509.ParseCertificate(block.Bytes); err != nil {
+					return nil, nil, fmt.Errorf("parsing certificate: %w", err)
+				}
+			}
+		case "RSA PRIVATE KEY":
+			if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+				return nil, nil, fmt.Errorf("parsing private key: %w", err)
+			}
+		case "PRIVATE KEY":
+			parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, nil, fmt.Errorf("parsing private key: %w", err)
+			}
+
+			rsaKey, ok := parsed.(*rsa.PrivateKey)
+			if !ok {
+				return nil, nil, errors.New("the private key of the certificate must be an RSA key")
+			}
+			key = rsaKey
+		}
+	}
+
+	if certificate == nil || key == nil {
+		return nil, nil, fmt.Errorf("%s must be a PEM file containing a certificate and its private key", certificatePath)
+	}
+
+	return certificate, key, nil
+}
+
+// newClientAssertion creates a JWT identifying the application, signed with its certificate.
+// See https://docs.microsoft.com/azure/active-directory/develop/active-directory-certificate-credentials
+func (cred *clientCertificateCredential) newClientAssertion(now time.Time) (string, error) {
+	// The x5t header is defined as the SHA-1 thumbprint of the certificate.
+	thumbprint := sha1.Sum(cred.certificate.Raw)
+
+	header, err := json.Marshal(map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+		"x5t": base64.RawURLEncoding.EncodeToString(thumbprint[:]),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", fmt.Errorf("generating assertion id: %w", err)
+	}
+
+	claims, err := json.Marshal(map[string]interface{}{
+		"aud": cred.tokenEndpoint(),
+		"iss": cred.clientId,
+		"sub": cred.clientId,
+		"jti": fmt.Sprintf("%x", jti),
+		"nbf": now.Unix(),
+		"exp": now.Add(clientAssertionLifetime).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, cred.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("signing client assertion: %w", err)
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// federatedTokenCredential authenticates a service principal with a token issued by a trusted identity provider, such
+// as the OIDC token of a GitHub Actions workflow or of a Kubernetes service account, which is read from a file.
+type federatedTokenCredential struct {
+	confidentialClient
+	tokenFilePath string
+}
+
+func (cred *federatedTokenCredential) GetToken(ctx context.Context, scope string) (tools.AzCliAccessToken, error) {
+	// The file is read for every request, since the identity provider refreshes the token it contains.
+	assertion, err := os.ReadFile(cred.tokenFilePath)
+	if err != nil {
+		return tools.AzCliAccessToken{}, fmt.Errorf("reading federated token: %w", err)
+	}
+
+	return cred.requestToken(ctx, scope, url.Values{
+		"client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+		"client_assertion":      {strings.TrimSpace(string(assertion))},
+	})
+}
+
+// managedIdentityCredential gets tokens for the managed identity of the Azure resource azd runs on. App Service and
+// Azure Functions provide an identity endpoint in the environment, other resources use the instance metadata service.
+type managedIdentityCredential struct {
+	// The client id of a user assigned identity, empty for the system assigned identity
+	clientId string
+}
+
+func (cred *managedIdentityCredential) GetToken(ctx context.Context, scope string) (tools.AzCliAccessToken, error) {
+	query := url.Values{
+		"resource": {strings.TrimSuffix(scope, "/.default")},
+	}
+	if cred.clientId != "" {
+		query.Set("client_id", cred.clientId)
+	}
+
+	request := &httpUtil.HttpRequestMessage{Method: http.MethodGet}
+
+	if endpoint, header := os.Getenv("IDENTITY_ENDPOINT"), os.Getenv("IDENTITY_HEADER"); endpoint != "" && header != "" {
+		query.Set("api-version", "2019-08-01")
+		request.Url = endpoint + "?" + query.Encode()
+		request.Headers = map[string]string{"X-IDENTITY-HEADER": header}
+	} else {
+		query.Set("api-version", "2018-02-01")
+		request.Url = imdsTokenEndpoint + "?" + query.Encode()
+		request.Headers = map[string]string{"Metadata": "true"}
+	}
+
+	token, err := sendTokenRequest(ctx, request)
+	if err != nil {
+		return tools.AzCliAccessToken{}, fmt.Errorf("getting token for managed identity: %w", err)
+	}
+
+	return token, nil
+}
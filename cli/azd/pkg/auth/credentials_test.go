@@ -0,0 +1,234 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/httpUtil"
+	"github.com/azure/azure-dev/cli/azd/test/helpers"
+	"github.com/stretchr/testify/require"
+)
+
+// withTokenEndpoint returns a context whose http client answers every request with a token expiring in an hour,
+// after passing the request to onRequest.
+func withTokenEndpoint(onRequest func(req *httpUtil.HttpRequestMessage)) context.Context {
+	client := &helpers.MockHttpUtil{
+		SendRequestFn: func(req *httpUtil.HttpRequestMessage) (*httpUtil.HttpResponseMessage, error) {
+			onRequest(req)
+			return &httpUtil.HttpResponseMessage{
+				Status: http.StatusOK,
+				Body:   []byte(`{"token_type":"Bearer","expires_in":3600,"access_token":"ABC123"}`),
+			}, nil
+		},
+	}
+
+	return context.WithValue(context.Background(), environment.HttpUtilContextKey, client)
+}
+
+func testClient() confidentialClient {
+	return confidentialClient{authorityHost: defaultAuthorityHost, tenantId: "TENANT_ID", clientId: "CLIENT_ID"}
+}
+
+func TestClientSecretCredential(t *testing.T) {
+	t.Setenv(ClientSecretEnvVarName, "SECRET")
+
+	var form url.Values
+	ctx := withTokenEndpoint(func(req *httpUtil.HttpRequestMessage) {
+		require.Equal(t, http.MethodPost, req.Method)
+		require.Equal(t, "https://login.microsoftonline.com/TENANT_ID/oauth2/v2.0/token", req.Url)
+
+		var err error
+		form, err = url.ParseQuery(req.Body)
+		require.NoError(t, err)
+	})
+
+	cred := &clientSecretCredential{confidentialClient: testClient()}
+	token, err := cred.GetToken(ctx, "https://management.azure.com//.default")
+	require.NoError(t, err)
+	require.Equal(t, "ABC123", token.AccessToken)
+	require.WithinDuration(t, time.Now().Add(time.Hour), *token.ExpiresOn, time.Minute)
+
+	require.Equal(t, "client_credentials", form.Get("grant_type"))
+	require.Equal(t, "CLIENT_ID", form.Get("client_id"))
+	require.Equal(t, "SECRET", form.Get("client_secret"))
+	require.Equal(t, "https://management.azure.com//.default", form.Get("scope"))
+}
+
+func TestClientSecretCredentialWithoutSecret(t *testing.T) {
+	t.Setenv(ClientSecretEnvVarName, "")
+
+	cred := &clientSecretCredential{confidentialClient: testClient()}
+	_, err := cred.GetToken(context.Background(), "scope")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), ClientSecretEnvVarName)
+}
+
+func TestClientCertificateCredential(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "azd"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	certificatePath := filepath.Join(t.TempDir(), "cert.pem")
+	contents := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	contents = append(contents, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	require.NoError(t, os.WriteFile(certificatePath, contents, 0600))
+
+	var form url.Values
+	ctx := withTokenEndpoint(func(req *httpUtil.HttpRequestMessage) {
+		form, err = url.ParseQuery(req.Body)
+		require.NoError(t, err)
+	})
+
+	cred := &clientCertificateCredential{confidentialClient: testClient(), certificatePath: certificatePath}
+	_, err = cred.GetToken(ctx, "scope")
+	require.NoError(t, err)
+
+	require.Equal(t, "urn:ietf:params:oauth:client-assertion-type:jwt-bearer", form.Get("client_assertion_type"))
+
+	parts := strings.Split(form.Get("client_assertion"), ".")
+	require.Len(t, parts, 3)
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	require.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature))
+
+	claimsJson, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+
+	var claims map[string]interface{}
+	require.NoError(t, json.Unmarshal(claimsJson, &claims))
+	require.Equal(t, "CLIENT_ID", claims["iss"])
+	require.Equal(t, "CLIENT_ID", claims["sub"])
+	require.Equal(t, "https://login.microsoftonline.com/TENANT_ID/oauth2/v2.0/token", claims["aud"])
+}
+
+func TestClientCertificateCredentialWithoutKey(t *testing.T) {
+	certificatePath := filepath.Join(t.TempDir(), "cert.pem")
+	require.NoError(t, os.WriteFile(certificatePath, []byte("not a certificate"), 0600))
+
+	cred := &clientCertificateCredential{confidentialClient: testClient(), certificatePath: certificatePath}
+	_, err := cred.GetToken(context.Background(), "scope")
+	require.Error(t, err)
+}
+
+func TestFederatedTokenCredential(t *testing.T) {
+	tokenFilePath := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFilePath, []byte("FEDERATED_TOKEN\n"), 0600))
+
+	var form url.Values
+	ctx := withTokenEndpoint(func(req *httpUtil.HttpRequestMessage) {
+		var err error
+		form, err = url.ParseQuery(req.Body)
+		require.NoError(t, err)
+	})
+
+	cred := &federatedTokenCredential{confidentialClient: testClient(), tokenFilePath: tokenFilePath}
+	_, err := cred.GetToken(ctx, "scope")
+	require.NoError(t, err)
+
+	require.Equal(t, "urn:ietf:params:oauth:client-assertion-type:jwt-bearer", form.Get("client_assertion_type"))
+	require.Equal(t, "FEDERATED_TOKEN", form.Get("client_assertion"))
+}
+
+func TestManagedIdentityCredential(t *testing.T) {
+	expiresOn := time.Now().Add(time.Hour).Truncate(time.Second)
+	response := fmt.Sprintf(`{"access_token":"ABC123","expires_in":"3600","expires_on":"%d"}`, expiresOn.Unix())
+
+	t.Run("InstanceMetadataService", func(t *testing.T) {
+		t.Setenv("IDENTITY_ENDPOINT", "")
+		t.Setenv("IDENTITY_HEADER", "")
+
+		var request *httpUtil.HttpRequestMessage
+		client := &helpers.MockHttpUtil{
+			SendRequestFn: func(req *httpUtil.HttpRequestMessage) (*httpUtil.HttpResponseMessage, error) {
+				request = req
+				return &httpUtil.HttpResponseMessage{Status: http.StatusOK, Body: []byte(response)}, nil
+			},
+		}
+		ctx := context.WithValue(context.Background(), environment.HttpUtilContextKey, client)
+
+		cred := &managedIdentityCredential{clientId: "CLIENT_ID"}
+		token, err := cred.GetToken(ctx, "https://management.azure.com//.default")
+		require.NoError(t, err)
+		require.Equal(t, "ABC123", token.AccessToken)
+		require.True(t, expiresOn.Equal(*token.ExpiresOn))
+
+		require.Equal(t, "true", request.Headers["Metadata"])
+		require.True(t, strings.HasPrefix(request.Url, imdsTokenEndpoint+"?"))
+
+		query, err := url.ParseQuery(strings.SplitN(request.Url, "?", 2)[1])
+		require.NoError(t, err)
+		require.Equal(t, "https://management.azure.com/", query.Get("resource"))
+		require.Equal(t, "CLIENT_ID", query.Get("client_id"))
+	})
+
+	t.Run("IdentityEndpoint", func(t *testing.T) {
+		t.Setenv("IDENTITY_ENDPOINT", "http://localhost:4141/token")
+		t.Setenv("IDENTITY_HEADER", "HEADER")
+
+		var request *httpUtil.HttpRequestMessage
+		client := &helpers.MockHttpUtil{
+			SendRequestFn: func(req *httpUtil.HttpRequestMessage) (*httpUtil.HttpResponseMessage, error) {
+				request = req
+				return &httpUtil.HttpResponseMessage{Status: http.StatusOK, Body: []byte(response)}, nil
+			},
+		}
+		ctx := context.WithValue(context.Background(), environment.HttpUtilContextKey, client)
+
+		cred := &managedIdentityCredential{}
+		_, err := cred.GetToken(ctx, "https://management.azure.com//.default")
+		require.NoError(t, err)
+
+		require.Equal(t, "HEADER", request.Headers["X-IDENTITY-HEADER"])
+		require.True(t, strings.HasPrefix(request.Url, "http://localhost:4141/token?"))
+		require.NotContains(t, request.Url, "client_id")
+	})
+}
+
+func TestTokenRequestError(t *testing.T) {
+	client := &helpers.MockHttpUtil{
+		SendRequestFn: func(req *httpUtil.HttpRequestMessage) (*httpUtil.HttpResponseMessage, error) {
+			return &httpUtil.HttpResponseMessage{
+				Status: http.StatusUnauthorized,
+				Body:   []byte(`{"error":"invalid_client","error_description":"Invalid client secret provided."}`),
+			}, nil
+		},
+	}
+	ctx := context.WithValue(context.Background(), environment.HttpUtilContextKey, client)
+	t.Setenv(ClientSecretEnvVarName, "SECRET")
+
+	cred := &clientSecretCredential{confidentialClient: testClient()}
+	_, err := cred.GetToken(ctx, "scope")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid_client: Invalid client secret provided.")
+}
@@ -0,0 +1,238 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+// Package auth provides the credentials azd authenticates to Azure with, when it does not use the account the Azure CLI
+// is logged in to.
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/osutil"
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+)
+
+// The environment variables which configure a service principal, which are the same as those of the Azure SDKs.
+const (
+	ClientIdEnvVarName              = "AZURE_CLIENT_ID"
+	TenantIdEnvVarName              = "AZURE_TENANT_ID"
+	ClientSecretEnvVarName          = "AZURE_CLIENT_SECRET"
+	ClientCertificatePathEnvVarName = "AZURE_CLIENT_CERTIFICATE_PATH"
+	FederatedTokenFileEnvVarName    = "AZURE_FEDERATED_TOKEN_FILE"
+	AuthorityHostEnvVarName         = "AZURE_AUTHORITY_HOST"
+)
+
+const (
+	// azdConfigDir is the name of the folder in the home directory of the user where azd writes user wide configuration
+	azdConfigDir = ".azd"
+	// The login configuration and the token cache are kept in this folder of the azd configuration folder
+	authDirectoryName   = "auth"
+	loginConfigFileName = "login.json"
+	tokenCacheFileName  = "tokens.json"
+
+	// The auth directory and the token cache are only accessible to the user, since access tokens are secrets
+	authDirectoryPermission os.FileMode = 0700
+	tokenCachePermission    os.FileMode = 0600
+)
+
+type LoginKind string
+
+const (
+	// ClientSecretLogin is a service principal authenticated with a secret, which is read from AZURE_CLIENT_SECRET
+	ClientSecretLogin LoginKind = "clientSecret"
+	// ClientCertificateLogin is a service principal authenticated with a certificate
+	ClientCertificateLogin LoginKind = "clientCertificate"
+	// FederatedTokenLogin is a service principal authenticated with a token issued by a trusted identity provider
+	FederatedTokenLogin LoginKind = "federatedToken"
+	// ManagedIdentityLogin is the managed identity of the Azure resource azd is running on
+	ManagedIdentityLogin LoginKind = "managedIdentity"
+)
+
+// LoginConfig describes the identity azd authenticates as. Secrets are never saved, so the secret of a service
+// principal is always read from AZURE_CLIENT_SECRET.
+type LoginConfig struct {
+	Kind     LoginKind `json:"kind"`
+	TenantId string    `json:"tenantId,omitempty"`
+	// The application id of the service principal, or of a user assigned managed identity
+	ClientId               string `json:"clientId,omitempty"`
+	ClientCertificatePath  string `json:"clientCertificatePath,omitempty"`
+	FederatedTokenFilePath string `json:"federatedTokenFilePath,omitempty"`
+}
+
+// Validate checks the configuration has the values its kind of login requires.
+func (c *LoginConfig) Validate() error {
+	switch c.Kind {
+	case ManagedIdentityLogin:
+		return nil
+	case ClientSecretLogin, ClientCertificateLogin, FederatedTokenLogin:
+		if c.TenantId == "" || c.ClientId == "" {
+			return errors.New("logging in as a service principal requires a client id and a tenant id")
+		}
+	default:
+		return fmt.Errorf("unsupported kind of login '%s'", c.Kind)
+	}
+
+	if c.Kind == ClientCertificateLogin && c.ClientCertificatePath == "" {
+		return errors.New("logging in with a certificate requires the path of the certificate")
+	}
+
+	if c.Kind == FederatedTokenLogin && c.FederatedTokenFilePath == "" {
+		return errors.New("logging in with a federated token requires the path of the token file")
+	}
+
+	return nil
+}
+
+// LoginConfigFromEnvironment returns the service principal configured with the environment variables used by the
+// Azure SDKs, or nil when none is configured.
+func LoginConfigFromEnvironment() *LoginConfig {
+	clientId, tenantId := os.Getenv(ClientIdEnvVarName), os.Getenv(TenantIdEnvVarName)
+	if clientId == "" || tenantId == "" {
+		return nil
+	}
+
+	config := &LoginConfig{TenantId: tenantId, ClientId: clientId}
+
+	switch {
+	case os.Getenv(ClientSecretEnvVarName) != "":
+		config.Kind = ClientSecretLogin
+	case os.Getenv(ClientCertificatePathEnvVarName) != "":
+		config.Kind = ClientCertificateLogin
+		config.ClientCertificatePath = os.Getenv(ClientCertificatePathEnvVarName)
+	case os.Getenv(FederatedTokenFileEnvVarName) != "":
+		config.Kind = FederatedTokenLogin
+		config.FederatedTokenFilePath = os.Getenv(FederatedTokenFileEnvVarName)
+	default:
+		return nil
+	}
+
+	return config
+}
+
+// Manager saves the login configuration and caches tokens in a directory, which is `~/.azd/auth` by default.
+type Manager struct {
+	dir   string
+	cache *tokenCache
+}
+
+// NewManager creates a Manager storing its files in dir.
+func NewManager(dir string) *Manager {
+	return &Manager{
+		dir:   dir,
+		cache: &tokenCache{path: filepath.Join(dir, tokenCacheFileName)},
+	}
+}
+
+// NewDefaultManager creates a Manager storing its files in the azd configuration directory of the current user.
+func NewDefaultManager() (*Manager, error) {
+	user, err := user.Current()
+	if err != nil {
+		return nil, fmt.Errorf("determining current user: %w", err)
+	}
+
+	return NewManager(filepath.Join(user.HomeDir, azdConfigDir, authDirectoryName)), nil
+}
+
+// LoadLoginConfig returns the saved login configuration, or nil when there is none.
+func (m *Manager) LoadLoginConfig() (*LoginConfig, error) {
+	bytes, err := ioutil.ReadFile(filepath.Join(m.dir, loginConfigFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading login configuration: %w", err)
+	}
+
+	var config LoginConfig
+	if err := json.Unmarshal(bytes, &config); err != nil {
+		return nil, fmt.Errorf("parsing login configuration: %w", err)
+	}
+
+	return &config, nil
+}
+
+// SaveLoginConfig saves the login configuration, which is used by later commands.
+func (m *Manager) SaveLoginConfig(config LoginConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	bytes, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling login configuration: %w", err)
+	}
+
+	if err := os.MkdirAll(m.dir, authDirectoryPermission); err != nil {
+		return fmt.Errorf("creating auth directory: %w", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(m.dir, loginConfigFileName), bytes, osutil.PermissionFile); err != nil {
+		return fmt.Errorf("writing login configuration: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveLoginConfig removes the saved login configuration, along with the cached tokens, so the account the Azure CLI
+// is logged in to is used again.
+func (m *Manager) RemoveLoginConfig() error {
+	for _, name := range []string{loginConfigFileName, tokenCacheFileName} {
+		if err := os.Remove(filepath.Join(m.dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing login configuration: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// CurrentCredential returns the credential configured with environment variables or, when there is none, with
+// `azd login`. nil is returned when neither is configured, in which case the Azure CLI should be used.
+func (m *Manager) CurrentCredential() (tools.TokenCredential, error) {
+	config := LoginConfigFromEnvironment()
+	if config == nil {
+		saved, err := m.LoadLoginConfig()
+		if err != nil || saved == nil {
+			return nil, err
+		}
+		config = saved
+	}
+
+	return m.NewCredential(*config)
+}
+
+// NewCredential creates the credential described by the configuration. Secrets and certificates are read when a token
+// is first requested. Tokens are cached, so they are reused by later commands.
+func (m *Manager) NewCredential(config LoginConfig) (tools.TokenCredential, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	client := confidentialClient{
+		authorityHost: osutil.GetenvOrDefault(AuthorityHostEnvVarName, defaultAuthorityHost),
+		tenantId:      config.TenantId,
+		clientId:      config.ClientId,
+	}
+
+	var credential tools.TokenCredential
+	switch config.Kind {
+	case ClientSecretLogin:
+		credential = &clientSecretCredential{confidentialClient: client}
+	case ClientCertificateLogin:
+		credential = &clientCertificateCredential{confidentialClient: client, certificatePath: config.ClientCertificatePath}
+	case FederatedTokenLogin:
+		credential = &federatedTokenCredential{confidentialClient: client, tokenFilePath: config.FederatedTokenFilePath}
+	case ManagedIdentityLogin:
+		credential = &managedIdentityCredential{clientId: config.ClientId}
+	}
+
+	return &cachedCredential{
+		credential: credential,
+		cache:      m.cache,
+		key:        fmt.Sprintf("%s|%s|%s", config.Kind, config.TenantId, config.ClientId),
+	}, nil
+}
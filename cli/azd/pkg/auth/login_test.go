@@ -0,0 +1,182 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+	"github.com/stretchr/testify/require"
+)
+
+func clearEnvironment(t *testing.T) {
+	for _, name := range []string{
+		ClientIdEnvVarName,
+		TenantIdEnvVarName,
+		ClientSecretEnvVarName,
+		ClientCertificatePathEnvVarName,
+		FederatedTokenFileEnvVarName,
+	} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestLoginConfigFromEnvironment(t *testing.T) {
+	t.Run("None", func(t *testing.T) {
+		clearEnvironment(t)
+		require.Nil(t, LoginConfigFromEnvironment())
+	})
+
+	t.Run("NoCredential", func(t *testing.T) {
+		clearEnvironment(t)
+		t.Setenv(ClientIdEnvVarName, "CLIENT_ID")
+		t.Setenv(TenantIdEnvVarName, "TENANT_ID")
+		require.Nil(t, LoginConfigFromEnvironment())
+	})
+
+	t.Run("ClientSecret", func(t *testing.T) {
+		clearEnvironment(t)
+		t.Setenv(ClientIdEnvVarName, "CLIENT_ID")
+		t.Setenv(TenantIdEnvVarName, "TENANT_ID")
+		t.Setenv(ClientSecretEnvVarName, "SECRET")
+
+		require.Equal(t, &LoginConfig{
+			Kind:     ClientSecretLogin,
+			TenantId: "TENANT_ID",
+			ClientId: "CLIENT_ID",
+		}, LoginConfigFromEnvironment())
+	})
+
+	t.Run("FederatedToken", func(t *testing.T) {
+		clearEnvironment(t)
+		t.Setenv(ClientIdEnvVarName, "CLIENT_ID")
+		t.Setenv(TenantIdEnvVarName, "TENANT_ID")
+		t.Setenv(FederatedTokenFileEnvVarName, "/var/run/token")
+
+		require.Equal(t, &LoginConfig{
+			Kind:                   FederatedTokenLogin,
+			TenantId:               "TENANT_ID",
+			ClientId:               "CLIENT_ID",
+			FederatedTokenFilePath: "/var/run/token",
+		}, LoginConfigFromEnvironment())
+	})
+}
+
+func TestLoginConfigValidate(t *testing.T) {
+	require.NoError(t, (&LoginConfig{Kind: ManagedIdentityLogin}).Validate())
+	require.NoError(t, (&LoginConfig{Kind: ClientSecretLogin, TenantId: "T", ClientId: "C"}).Validate())
+	require.Error(t, (&LoginConfig{Kind: ClientSecretLogin, ClientId: "C"}).Validate())
+	require.Error(t, (&LoginConfig{Kind: ClientCertificateLogin, TenantId: "T", ClientId: "C"}).Validate())
+	require.Error(t, (&LoginConfig{Kind: FederatedTokenLogin, TenantId: "T", ClientId: "C"}).Validate())
+	require.Error(t, (&LoginConfig{Kind: "password"}).Validate())
+}
+
+func TestManagerLoginConfig(t *testing.T) {
+	clearEnvironment(t)
+	manager := NewManager(filepath.Join(t.TempDir(), "auth"))
+
+	config, err := manager.LoadLoginConfig()
+	require.NoError(t, err)
+	require.Nil(t, config)
+
+	credential, err := manager.CurrentCredential()
+	require.NoError(t, err)
+	require.Nil(t, credential)
+
+	saved := LoginConfig{Kind: FederatedTokenLogin, TenantId: "T", ClientId: "C", FederatedTokenFilePath: "/token"}
+	require.NoError(t, manager.SaveLoginConfig(saved))
+
+	config, err = manager.LoadLoginConfig()
+	require.NoError(t, err)
+	require.Equal(t, saved, *config)
+
+	credential, err = manager.CurrentCredential()
+	require.NoError(t, err)
+	require.NotNil(t, credential)
+
+	require.NoError(t, manager.RemoveLoginConfig())
+
+	config, err = manager.LoadLoginConfig()
+	require.NoError(t, err)
+	require.Nil(t, config)
+}
+
+type countingCredential struct {
+	calls     int
+	expiresIn time.Duration
+}
+
+func (cred *countingCredential) GetToken(ctx context.Context, scope string) (tools.AzCliAccessToken, error) {
+	cred.calls++
+	expiresOn := time.Now().Add(cred.expiresIn)
+	return tools.AzCliAccessToken{AccessToken: scope, ExpiresOn: &expiresOn}, nil
+}
+
+func TestCachedCredential(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "auth")
+	cache := &tokenCache{path: filepath.Join(dir, tokenCacheFileName)}
+
+	t.Run("ReusesValidToken", func(t *testing.T) {
+		inner := &countingCredential{expiresIn: time.Hour}
+		cred := &cachedCredential{credential: inner, cache: cache, key: "valid"}
+
+		for i := 0; i < 2; i++ {
+			token, err := cred.GetToken(context.Background(), "scope")
+			require.NoError(t, err)
+			require.Equal(t, "scope", token.AccessToken)
+		}
+		require.Equal(t, 1, inner.calls)
+
+		// A new process reads the tokens from the file.
+		cred = &cachedCredential{credential: inner, cache: &tokenCache{path: cache.path}, key: "valid"}
+		_, err := cred.GetToken(context.Background(), "scope")
+		require.NoError(t, err)
+		require.Equal(t, 1, inner.calls)
+
+		_, err = cred.GetToken(context.Background(), "other")
+		require.NoError(t, err)
+		require.Equal(t, 2, inner.calls)
+	})
+
+	t.Run("RefreshesExpiringToken", func(t *testing.T) {
+		inner := &countingCredential{expiresIn: time.Minute}
+		cred := &cachedCredential{credential: inner, cache: cache, key: "expiring"}
+
+		for i := 0; i < 2; i++ {
+			_, err := cred.GetToken(context.Background(), "scope")
+			require.NoError(t, err)
+		}
+		require.Equal(t, 2, inner.calls)
+	})
+
+	// Windows does not support unix permissions.
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(cache.path)
+		require.NoError(t, err)
+		require.Equal(t, tokenCachePermission, info.Mode().Perm())
+	}
+}
+
+func TestCachedCredentialError(t *testing.T) {
+	cache := &tokenCache{path: filepath.Join(t.TempDir(), tokenCacheFileName)}
+	cred := &cachedCredential{credential: &failingCredential{}, cache: cache, key: "failing"}
+
+	_, err := cred.GetToken(context.Background(), "scope")
+	require.Error(t, err)
+
+	_, err = os.Stat(cache.path)
+	require.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+type failingCredential struct{}
+
+func (cred *failingCredential) GetToken(ctx context.Context, scope string) (tools.AzCliAccessToken, error) {
+	return tools.AzCliAccessToken{}, errors.New("failed")
+}
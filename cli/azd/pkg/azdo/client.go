@@ -0,0 +1,398 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+// Package azdo provides a client for the Azure DevOps REST APIs used to configure Azure Pipelines.
+package azdo
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/httpUtil"
+)
+
+const (
+	apiVersion = "7.0"
+	// The pipeline permissions API is only available as a preview
+	pipelinePermissionsApiVersion = "7.0-preview.1"
+
+	// PersonalAccessTokenEnvVarName is the environment variable the Azure DevOps extension of the Azure CLI reads the
+	// personal access token from, which azd uses as well.
+	PersonalAccessTokenEnvVarName = "AZURE_DEVOPS_EXT_PAT"
+)
+
+// The types of resources which can be authorized for all pipelines
+const (
+	EndpointResourceType      = "endpoint"
+	VariableGroupResourceType = "variablegroup"
+)
+
+var ErrNotFound = errors.New("not found")
+
+// AzDoError is returned when an Azure DevOps REST API fails.
+type AzDoError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *AzDoError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("azure devops request failed with status %d", e.StatusCode)
+	}
+
+	return fmt.Sprintf("azure devops request failed with status %d: %s", e.StatusCode, e.Message)
+}
+
+type Project struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type GitRepository struct {
+	Id            string `json:"id"`
+	Name          string `json:"name"`
+	DefaultBranch string `json:"defaultBranch"`
+	RemoteUrl     string `json:"remoteUrl"`
+	WebUrl        string `json:"webUrl"`
+}
+
+type ProjectReference struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// ServicePrincipal is the identity a service connection authenticates to Azure with.
+type ServicePrincipal struct {
+	TenantId       string
+	ClientId       string
+	ClientSecret   string
+	SubscriptionId string
+	// The display name of the subscription, which Azure DevOps requires
+	SubscriptionName string
+}
+
+type ServiceEndpoint struct {
+	Id                               string                            `json:"id,omitempty"`
+	Name                             string                            `json:"name"`
+	Type                             string                            `json:"type"`
+	Url                              string                            `json:"url"`
+	Description                      string                            `json:"description,omitempty"`
+	Owner                            string                            `json:"owner"`
+	IsShared                         bool                              `json:"isShared"`
+	Authorization                    ServiceEndpointAuthorization      `json:"authorization"`
+	Data                             map[string]string                 `json:"data"`
+	ServiceEndpointProjectReferences []ServiceEndpointProjectReference `json:"serviceEndpointProjectReferences"`
+}
+
+type ServiceEndpointAuthorization struct {
+	Scheme     string            `json:"scheme"`
+	Parameters map[string]string `json:"parameters"`
+}
+
+type ServiceEndpointProjectReference struct {
+	ProjectReference ProjectReference `json:"projectReference"`
+	Name             string           `json:"name"`
+	Description      string           `json:"description,omitempty"`
+}
+
+type Variable struct {
+	Value    string `json:"value"`
+	IsSecret bool   `json:"isSecret"`
+}
+
+type VariableGroup struct {
+	Id                             int                             `json:"id,omitempty"`
+	Name                           string                          `json:"name"`
+	Description                    string                          `json:"description,omitempty"`
+	Type                           string                          `json:"type"`
+	Variables                      map[string]Variable             `json:"variables"`
+	VariableGroupProjectReferences []VariableGroupProjectReference `json:"variableGroupProjectReferences"`
+}
+
+type VariableGroupProjectReference struct {
+	ProjectReference ProjectReference `json:"projectReference"`
+	Name             string           `json:"name"`
+	Description      string           `json:"description,omitempty"`
+}
+
+type Pipeline struct {
+	Id     int    `json:"id"`
+	Name   string `json:"name"`
+	Folder string `json:"folder"`
+	Links  struct {
+		Web struct {
+			Href string `json:"href"`
+		} `json:"web"`
+	} `json:"_links"`
+}
+
+// WebUrl returns the URL of the page of the pipeline, which lists its runs.
+func (p Pipeline) WebUrl() string {
+	return p.Links.Web.Href
+}
+
+type listResponse[T any] struct {
+	Count int `json:"count"`
+	Value []T `json:"value"`
+}
+
+// Client calls the Azure DevOps REST APIs of an organization, authenticated with a personal access token.
+type Client struct {
+	organizationUrl     string
+	personalAccessToken string
+}
+
+// NewClient creates a Client for the organization at organizationUrl, such as https://dev.azure.com/contoso.
+func NewClient(organizationUrl string, personalAccessToken string) *Client {
+	return &Client{
+		organizationUrl:     strings.TrimSuffix(organizationUrl, "/"),
+		personalAccessToken: personalAccessToken,
+	}
+}
+
+// GetProject returns the project with the given name, or ErrNotFound.
+func (c *Client) GetProject(ctx context.Context, name string) (Project, error) {
+	var project Project
+	if err := c.send(ctx, http.MethodGet, c.url("", "_apis/projects/"+url.PathEscape(name), apiVersion, nil), nil, &project); err != nil {
+		return Project{}, fmt.Errorf("getting project %s: %w", name, err)
+	}
+
+	return project, nil
+}
+
+// GetRepository returns the git repository with the given name in a project, or ErrNotFound.
+func (c *Client) GetRepository(ctx context.Context, project Project, name string) (GitRepository, error) {
+	var repository GitRepository
+	if err := c.send(ctx, http.MethodGet, c.url(project.Id, "_apis/git/repositories/"+url.PathEscape(name), apiVersion, nil), nil, &repository); err != nil {
+		return GitRepository{}, fmt.Errorf("getting repository %s: %w", name, err)
+	}
+
+	return repository, nil
+}
+
+// CreateOrUpdateServiceConnection creates an Azure Resource Manager service connection authenticated with a service
+// principal, or updates the credentials of the existing connection with the same name.
+func (c *Client) CreateOrUpdateServiceConnection(ctx context.Context, project Project, name string, principal ServicePrincipal) (ServiceEndpoint, error) {
+	var existing listResponse[ServiceEndpoint]
+	query := url.Values{"endpointNames": {name}}
+	if err := c.send(ctx, http.MethodGet, c.url(project.Id, "_apis/serviceendpoint/endpoints", apiVersion, query), nil, &existing); err != nil {
+		return ServiceEndpoint{}, fmt.Errorf("getting service connection %s: %w", name, err)
+	}
+
+	endpoint := ServiceEndpoint{
+		Name:        name,
+		Type:        "azurerm",
+		Url:         "https://management.azure.com/",
+		Description: "Created by the Azure Developer CLI",
+		Owner:       "library",
+		Authorization: ServiceEndpointAuthorization{
+			Scheme: "ServicePrincipal",
+			Parameters: map[string]string{
+				"tenantid":            principal.TenantId,
+				"serviceprincipalid":  principal.ClientId,
+				"serviceprincipalkey": principal.ClientSecret,
+				"authenticationType":  "spnKey",
+			},
+		},
+		Data: map[string]string{
+			"environment":      "AzureCloud",
+			"scopeLevel":       "Subscription",
+			"subscriptionId":   principal.SubscriptionId,
+			"subscriptionName": principal.SubscriptionName,
+			"creationMode":     "Manual",
+		},
+		ServiceEndpointProjectReferences: []ServiceEndpointProjectReference{
+			{ProjectReference: ProjectReference{Id: project.Id, Name: project.Name}, Name: name},
+		},
+	}
+
+	method, path := http.MethodPost, "_apis/serviceendpoint/endpoints"
+	if len(existing.Value) > 0 {
+		endpoint.Id = existing.Value[0].Id
+		method, path = http.MethodPut, "_apis/serviceendpoint/endpoints/"+endpoint.Id
+	}
+
+	var result ServiceEndpoint
+	if err := c.send(ctx, method, c.url("", path, apiVersion, nil), endpoint, &result); err != nil {
+		return ServiceEndpoint{}, fmt.Errorf("saving service connection %s: %w", name, err)
+	}
+
+	return result, nil
+}
+
+// CreateOrUpdateVariableGroup creates a variable group, or replaces the variables of the existing group with the
+// same name.
+func (c *Client) CreateOrUpdateVariableGroup(ctx context.Context, project Project, name string, variables map[string]Variable) (VariableGroup, error) {
+	var existing listResponse[VariableGroup]
+	query := url.Values{"groupName": {name}}
+	if err := c.send(ctx, http.MethodGet, c.url(project.Id, "_apis/distributedtask/variablegroups", apiVersion, query), nil, &existing); err != nil {
+		return VariableGroup{}, fmt.Errorf("getting variable group %s: %w", name, err)
+	}
+
+	group := VariableGroup{
+		Name:        name,
+		Description: "Created by the Azure Developer CLI",
+		Type:        "Vsts",
+		Variables:   variables,
+		VariableGroupProjectReferences: []VariableGroupProjectReference{
+			{ProjectReference: ProjectReference{Id: project.Id, Name: project.Name}, Name: name},
+		},
+	}
+
+	method, path := http.MethodPost, "_apis/distributedtask/variablegroups"
+	if len(existing.Value) > 0 {
+		group.Id = existing.Value[0].Id
+		method, path = http.MethodPut, fmt.Sprintf("_apis/distributedtask/variablegroups/%d", group.Id)
+	}
+
+	var result VariableGroup
+	if err := c.send(ctx, method, c.url("", path, apiVersion, nil), group, &result); err != nil {
+		return VariableGroup{}, fmt.Errorf("saving variable group %s: %w", name, err)
+	}
+
+	return result, nil
+}
+
+// AuthorizeForAllPipelines allows every pipeline of the project to use a resource, such as a service connection or
+// a variable group, so runs are not blocked waiting for approval.
+func (c *Client) AuthorizeForAllPipelines(ctx context.Context, project Project, resourceType string, resourceId string) error {
+	body := map[string]interface{}{
+		"resource":     map[string]string{"type": resourceType, "id": resourceId},
+		"allPipelines": map[string]bool{"authorized": true},
+	}
+
+	path := fmt.Sprintf("_apis/pipelines/pipelinePermissions/%s/%s", resourceType, resourceId)
+	if err := c.send(ctx, http.MethodPatch, c.url(project.Id, path, pipelinePermissionsApiVersion, nil), body, nil); err != nil {
+		return fmt.Errorf("authorizing %s %s for all pipelines: %w", resourceType, resourceId, err)
+	}
+
+	return nil
+}
+
+// GetOrCreatePipeline returns the pipeline with the given name, creating it from the YAML file at yamlPath in the
+// repository when it does not exist.
+func (c *Client) GetOrCreatePipeline(ctx context.Context, project Project, name string, repository GitRepository, yamlPath string) (Pipeline, bool, error) {
+	var existing listResponse[Pipeline]
+	query := url.Values{"$top": {"1000"}}
+	if err := c.send(ctx, http.MethodGet, c.url(project.Id, "_apis/pipelines", apiVersion, query), nil, &existing); err != nil {
+		return Pipeline{}, false, fmt.Errorf("listing pipelines: %w", err)
+	}
+
+	for _, pipeline := range existing.Value {
+		if pipeline.Name == name {
+			return pipeline, false, nil
+		}
+	}
+
+	body := map[string]interface{}{
+		"name":   name,
+		"folder": "\\",
+		"configuration": map[string]interface{}{
+			"type": "yaml",
+			"path": "/" + strings.TrimPrefix(yamlPath, "/"),
+			"repository": map[string]string{
+				"id":   repository.Id,
+				"name": repository.Name,
+				"type": "azureReposGit",
+			},
+		},
+	}
+
+	var pipeline Pipeline
+	if err := c.send(ctx, http.MethodPost, c.url(project.Id, "_apis/pipelines", apiVersion, nil), body, &pipeline); err != nil {
+		return Pipeline{}, false, fmt.Errorf("creating pipeline %s: %w", name, err)
+	}
+
+	return pipeline, true, nil
+}
+
+// RunPipeline queues a run of the pipeline on the given branch, such as refs/heads/main.
+func (c *Client) RunPipeline(ctx context.Context, project Project, pipeline Pipeline, branch string) error {
+	body := map[string]interface{}{
+		"resources": map[string]interface{}{
+			"repositories": map[string]interface{}{
+				"self": map[string]string{
+					"refName": branch,
+				},
+			},
+		},
+	}
+
+	path := fmt.Sprintf("_apis/pipelines/%d/runs", pipeline.Id)
+	if err := c.send(ctx, http.MethodPost, c.url(project.Id, path, apiVersion, nil), body, nil); err != nil {
+		return fmt.Errorf("running pipeline %s: %w", pipeline.Name, err)
+	}
+
+	return nil
+}
+
+// url returns the URL of an API of the organization or, when project is set, of a project.
+func (c *Client) url(project string, path string, version string, query url.Values) string {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("api-version", version)
+
+	base := c.organizationUrl
+	if project != "" {
+		base += "/" + url.PathEscape(project)
+	}
+
+	return fmt.Sprintf("%s/%s?%s", base, path, query.Encode())
+}
+
+func (c *Client) send(ctx context.Context, method string, requestUrl string, body interface{}, result interface{}) error {
+	request := &httpUtil.HttpRequestMessage{
+		Url:    requestUrl,
+		Method: method,
+		Headers: map[string]string{
+			"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(":"+c.personalAccessToken)),
+		},
+	}
+
+	if body != nil {
+		bytes, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshalling request: %w", err)
+		}
+		request.Body = string(bytes)
+	}
+
+	res, err := httpUtil.GetHttpUtilFromContext(ctx).Send(request)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case res.Status == http.StatusNotFound:
+		return ErrNotFound
+	// Azure DevOps redirects to a sign in page, which is returned with 203, when the token is not valid
+	case res.Status == http.StatusNonAuthoritativeInfo || res.Status == http.StatusUnauthorized:
+		return &AzDoError{
+			StatusCode: res.Status,
+			Message:    fmt.Sprintf("the personal access token is not valid, or has expired. Set a valid token in %s", PersonalAccessTokenEnvVarName),
+		}
+	case res.Status < 200 || res.Status > 299:
+		var wire struct {
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(res.Body, &wire)
+		return &AzDoError{StatusCode: res.Status, Message: wire.Message}
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(res.Body, result); err != nil {
+		return fmt.Errorf("unmarshalling response: %w", err)
+	}
+
+	return nil
+}
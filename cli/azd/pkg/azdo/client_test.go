@@ -0,0 +1,223 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package azdo
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/httpUtil"
+	"github.com/stretchr/testify/require"
+)
+
+// stubServer is a local server standing in for Azure DevOps, which records the requests it receives.
+type stubServer struct {
+	t        *testing.T
+	server   *httptest.Server
+	requests []string
+	bodies   map[string]map[string]interface{}
+	// responses maps "METHOD path" to the status and body returned
+	responses map[string]stubResponse
+}
+
+type stubResponse struct {
+	status int
+	body   interface{}
+}
+
+func newStubServer(t *testing.T, responses map[string]stubResponse) *stubServer {
+	s := &stubServer{t: t, bodies: map[string]map[string]interface{}{}, responses: responses}
+
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte(":pat")), r.Header.Get("Authorization"))
+		require.NotEmpty(t, r.URL.Query().Get("api-version"))
+
+		key := fmt.Sprintf("%s %s", r.Method, r.URL.Path)
+		s.requests = append(s.requests, key)
+
+		if body, err := io.ReadAll(r.Body); err == nil && len(body) > 0 {
+			var parsed map[string]interface{}
+			require.NoError(t, json.Unmarshal(body, &parsed))
+			s.bodies[key] = parsed
+		}
+
+		res, has := s.responses[key]
+		if !has {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(res.status)
+		if res.body != nil {
+			require.NoError(t, json.NewEncoder(w).Encode(res.body))
+		}
+	}))
+	t.Cleanup(s.server.Close)
+
+	return s
+}
+
+func (s *stubServer) client() (*Client, context.Context) {
+	ctx := context.WithValue(context.Background(), environment.HttpUtilContextKey, httpUtil.NewHttpUtil())
+	return NewClient(s.server.URL+"/contoso", "pat"), ctx
+}
+
+var testProject = Project{Id: "project-id", Name: "web"}
+
+func TestGetProject(t *testing.T) {
+	s := newStubServer(t, map[string]stubResponse{
+		"GET /contoso/_apis/projects/web": {status: http.StatusOK, body: testProject},
+	})
+	client, ctx := s.client()
+
+	project, err := client.GetProject(ctx, "web")
+	require.NoError(t, err)
+	require.Equal(t, testProject, project)
+
+	_, err = client.GetProject(ctx, "missing")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestInvalidPersonalAccessToken(t *testing.T) {
+	s := newStubServer(t, map[string]stubResponse{
+		"GET /contoso/_apis/projects/web": {status: http.StatusNonAuthoritativeInfo},
+	})
+	client, ctx := s.client()
+
+	_, err := client.GetProject(ctx, "web")
+
+	var azdoErr *AzDoError
+	require.ErrorAs(t, err, &azdoErr)
+	require.Contains(t, azdoErr.Error(), PersonalAccessTokenEnvVarName)
+}
+
+func TestCreateOrUpdateServiceConnection(t *testing.T) {
+	principal := ServicePrincipal{
+		TenantId:         "tenant",
+		ClientId:         "client",
+		ClientSecret:     "secret",
+		SubscriptionId:   "subscription",
+		SubscriptionName: "Contoso",
+	}
+
+	t.Run("Create", func(t *testing.T) {
+		s := newStubServer(t, map[string]stubResponse{
+			"GET /contoso/project-id/_apis/serviceendpoint/endpoints": {status: http.StatusOK, body: listResponse[ServiceEndpoint]{}},
+			"POST /contoso/_apis/serviceendpoint/endpoints":           {status: http.StatusOK, body: ServiceEndpoint{Id: "new-id", Name: "azconnection"}},
+		})
+		client, ctx := s.client()
+
+		endpoint, err := client.CreateOrUpdateServiceConnection(ctx, testProject, "azconnection", principal)
+		require.NoError(t, err)
+		require.Equal(t, "new-id", endpoint.Id)
+
+		body := s.bodies["POST /contoso/_apis/serviceendpoint/endpoints"]
+		require.Equal(t, "azurerm", body["type"])
+		parameters := body["authorization"].(map[string]interface{})["parameters"].(map[string]interface{})
+		require.Equal(t, "secret", parameters["serviceprincipalkey"])
+		require.Equal(t, "Contoso", body["data"].(map[string]interface{})["subscriptionName"])
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		s := newStubServer(t, map[string]stubResponse{
+			"GET /contoso/project-id/_apis/serviceendpoint/endpoints": {
+				status: http.StatusOK,
+				body:   listResponse[ServiceEndpoint]{Count: 1, Value: []ServiceEndpoint{{Id: "existing-id", Name: "azconnection"}}},
+			},
+			"PUT /contoso/_apis/serviceendpoint/endpoints/existing-id": {status: http.StatusOK, body: ServiceEndpoint{Id: "existing-id"}},
+		})
+		client, ctx := s.client()
+
+		endpoint, err := client.CreateOrUpdateServiceConnection(ctx, testProject, "azconnection", principal)
+		require.NoError(t, err)
+		require.Equal(t, "existing-id", endpoint.Id)
+		require.Equal(t, "existing-id", s.bodies["PUT /contoso/_apis/serviceendpoint/endpoints/existing-id"]["id"])
+	})
+}
+
+func TestCreateOrUpdateVariableGroup(t *testing.T) {
+	s := newStubServer(t, map[string]stubResponse{
+		"GET /contoso/project-id/_apis/distributedtask/variablegroups": {
+			status: http.StatusOK,
+			body:   listResponse[VariableGroup]{Count: 1, Value: []VariableGroup{{Id: 7, Name: "azure-dev"}}},
+		},
+		"PUT /contoso/_apis/distributedtask/variablegroups/7": {status: http.StatusOK, body: VariableGroup{Id: 7, Name: "azure-dev"}},
+	})
+	client, ctx := s.client()
+
+	group, err := client.CreateOrUpdateVariableGroup(ctx, testProject, "azure-dev", map[string]Variable{
+		"AZURE_ENV_NAME": {Value: "dev"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 7, group.Id)
+
+	variables := s.bodies["PUT /contoso/_apis/distributedtask/variablegroups/7"]["variables"].(map[string]interface{})
+	require.Equal(t, "dev", variables["AZURE_ENV_NAME"].(map[string]interface{})["value"])
+}
+
+func TestAuthorizeForAllPipelines(t *testing.T) {
+	s := newStubServer(t, map[string]stubResponse{
+		"PATCH /contoso/project-id/_apis/pipelines/pipelinePermissions/variablegroup/7": {status: http.StatusOK},
+	})
+	client, ctx := s.client()
+
+	require.NoError(t, client.AuthorizeForAllPipelines(ctx, testProject, VariableGroupResourceType, "7"))
+	require.Equal(t,
+		map[string]interface{}{
+			"resource":     map[string]interface{}{"type": "variablegroup", "id": "7"},
+			"allPipelines": map[string]interface{}{"authorized": true},
+		},
+		s.bodies["PATCH /contoso/project-id/_apis/pipelines/pipelinePermissions/variablegroup/7"])
+}
+
+func TestGetOrCreatePipeline(t *testing.T) {
+	repository := GitRepository{Id: "repo-id", Name: "todo"}
+
+	t.Run("Existing", func(t *testing.T) {
+		s := newStubServer(t, map[string]stubResponse{
+			"GET /contoso/project-id/_apis/pipelines": {
+				status: http.StatusOK,
+				body:   listResponse[Pipeline]{Count: 1, Value: []Pipeline{{Id: 3, Name: "azure-dev"}}},
+			},
+		})
+		client, ctx := s.client()
+
+		pipeline, created, err := client.GetOrCreatePipeline(ctx, testProject, "azure-dev", repository, ".azdo/pipelines/azure-dev.yml")
+		require.NoError(t, err)
+		require.False(t, created)
+		require.Equal(t, 3, pipeline.Id)
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		s := newStubServer(t, map[string]stubResponse{
+			"GET /contoso/project-id/_apis/pipelines":         {status: http.StatusOK, body: listResponse[Pipeline]{}},
+			"POST /contoso/project-id/_apis/pipelines":        {status: http.StatusOK, body: Pipeline{Id: 4, Name: "azure-dev"}},
+			"POST /contoso/project-id/_apis/pipelines/4/runs": {status: http.StatusOK},
+		})
+		client, ctx := s.client()
+
+		pipeline, created, err := client.GetOrCreatePipeline(ctx, testProject, "azure-dev", repository, ".azdo/pipelines/azure-dev.yml")
+		require.NoError(t, err)
+		require.True(t, created)
+		require.Equal(t, 4, pipeline.Id)
+
+		configuration := s.bodies["POST /contoso/project-id/_apis/pipelines"]["configuration"].(map[string]interface{})
+		require.Equal(t, "/.azdo/pipelines/azure-dev.yml", configuration["path"])
+		require.Equal(t, "repo-id", configuration["repository"].(map[string]interface{})["id"])
+
+		require.NoError(t, client.RunPipeline(ctx, testProject, pipeline, "refs/heads/main"))
+		require.Equal(t, []string{
+			"GET /contoso/project-id/_apis/pipelines",
+			"POST /contoso/project-id/_apis/pipelines",
+			"POST /contoso/project-id/_apis/pipelines/4/runs",
+		}, s.requests)
+	})
+}
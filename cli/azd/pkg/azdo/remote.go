@@ -0,0 +1,78 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package azdo
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var ErrRemoteHostIsNotAzDo = errors.New("not an azure devops host")
+
+// https://dev.azure.com/{organization}/{project}/_git/{repository}, optionally with a user name before the host.
+var azdoRemoteHttpsUrlRegex = regexp.MustCompile(`^https://(?:[^@/]+@)?dev\.azure\.com/([^/]+)/([^/]+)/_git/([^/]+?)/?$`)
+
+// git@ssh.dev.azure.com:v3/{organization}/{project}/{repository}
+var azdoRemoteSshUrlRegex = regexp.MustCompile(`^(?:ssh://)?git@ssh\.dev\.azure\.com[:/]v3/([^/]+)/([^/]+)/([^/]+?)/?$`)
+
+// https://{organization}.visualstudio.com/[DefaultCollection/]{project}/_git/{repository}, the legacy form of remotes.
+var azdoRemoteLegacyUrlRegex = regexp.MustCompile(
+	`^https://(?:[^@/]+@)?([^.@/]+)\.visualstudio\.com/(?:DefaultCollection/)?([^/]+)/_git/([^/]+?)/?$`)
+
+// Repository is a git repository hosted by Azure DevOps.
+type Repository struct {
+	// OrganizationUrl is the URL of the organization, which the REST APIs are relative to.
+	OrganizationUrl string
+	Organization    string
+	Project         string
+	Name            string
+}
+
+// Slug returns the `{organization}/{project}/{repository}` name of the repository.
+func (r Repository) Slug() string {
+	return fmt.Sprintf("%s/%s/%s", r.Organization, r.Project, r.Name)
+}
+
+// GetRepositoryForRemote returns the repository a remote URL refers to, or ErrRemoteHostIsNotAzDo when the remote is
+// not hosted by Azure DevOps.
+func GetRepositoryForRemote(remoteUrl string) (Repository, error) {
+	var captures []string
+	var organizationUrl string
+
+	if captures = azdoRemoteHttpsUrlRegex.FindStringSubmatch(remoteUrl); captures != nil {
+		organizationUrl = "https://dev.azure.com/" + captures[1]
+	} else if captures = azdoRemoteSshUrlRegex.FindStringSubmatch(remoteUrl); captures != nil {
+		organizationUrl = "https://dev.azure.com/" + captures[1]
+	} else if captures = azdoRemoteLegacyUrlRegex.FindStringSubmatch(remoteUrl); captures != nil {
+		organizationUrl = fmt.Sprintf("https://%s.visualstudio.com", captures[1])
+	} else {
+		return Repository{}, ErrRemoteHostIsNotAzDo
+	}
+
+	// Names with spaces are escaped in remote URLs.
+	names := make([]string, 3)
+	for i, capture := range captures[1:] {
+		name, err := url.PathUnescape(capture)
+		if err != nil {
+			return Repository{}, fmt.Errorf("parsing remote url %s: %w", remoteUrl, err)
+		}
+		names[i] = name
+	}
+
+	return Repository{
+		OrganizationUrl: organizationUrl,
+		Organization:    names[0],
+		Project:         names[1],
+		Name:            names[2],
+	}, nil
+}
+
+// IsAzDoRemote returns true when the remote URL refers to a repository hosted by Azure DevOps.
+func IsAzDoRemote(remoteUrl string) bool {
+	_, err := GetRepositoryForRemote(strings.TrimSpace(remoteUrl))
+	return err == nil
+}
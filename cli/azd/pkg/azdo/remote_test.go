@@ -0,0 +1,55 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package azdo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRepositoryForRemote(t *testing.T) {
+	contoso := Repository{
+		OrganizationUrl: "https://dev.azure.com/contoso",
+		Organization:    "contoso",
+		Project:         "web",
+		Name:            "todo",
+	}
+	legacy := contoso
+	legacy.OrganizationUrl = "https://contoso.visualstudio.com"
+
+	cases := []struct {
+		remote  string
+		result  Repository
+		isError bool
+	}{
+		{remote: "https://dev.azure.com/contoso/web/_git/todo", result: contoso},
+		{remote: "https://contoso@dev.azure.com/contoso/web/_git/todo", result: contoso},
+		{remote: "git@ssh.dev.azure.com:v3/contoso/web/todo", result: contoso},
+		{remote: "https://contoso.visualstudio.com/web/_git/todo", result: legacy},
+		{remote: "https://contoso.visualstudio.com/DefaultCollection/web/_git/todo", result: legacy},
+		{
+			remote: "https://dev.azure.com/contoso/My%20Project/_git/todo",
+			result: Repository{OrganizationUrl: "https://dev.azure.com/contoso", Organization: "contoso", Project: "My Project", Name: "todo"},
+		},
+
+		{remote: "https://github.com/contoso/todo.git", isError: true},
+		{remote: "https://dev.azure.com/contoso/web", isError: true},
+		{remote: "not-a-remote", isError: true},
+		{remote: "", isError: true},
+	}
+
+	for _, tst := range cases {
+		repo, err := GetRepositoryForRemote(tst.remote)
+
+		if tst.isError {
+			require.ErrorIs(t, err, ErrRemoteHostIsNotAzDo, "expected error for %s", tst.remote)
+		} else {
+			require.NoError(t, err, "expected no error for %s", tst.remote)
+		}
+
+		require.Equal(t, tst.result, repo, "expected equal for %s", tst.remote)
+		require.Equal(t, !tst.isError, IsAzDoRemote(tst.remote))
+	}
+}
@@ -3,7 +3,10 @@
 
 package azure
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // Creates Azure subscription resource ID
 func SubscriptionRID(subscriptionId string) string {
@@ -23,6 +26,12 @@ func ResourceGroupRID(subscriptionId, resourceGroupName string) string {
 	return returnValue
 }
 
+// Creates resource group-level deployment resource ID
+func ResourceGroupDeploymentRID(subscriptionId, resourceGroupName, deploymentId string) string {
+	returnValue := fmt.Sprintf("%s/providers/Microsoft.Resources/deployments/%s", ResourceGroupRID(subscriptionId, resourceGroupName), deploymentId)
+	return returnValue
+}
+
 func WebsiteRID(subscriptionId, resourceGroupName, websiteName string) string {
 	returnValue := fmt.Sprintf("%s/providers/Microsoft.Web/sites/%s", ResourceGroupRID(subscriptionId, resourceGroupName), websiteName)
 	return returnValue
@@ -37,3 +46,38 @@ func StaticWebAppRID(subscriptionId, resourceGroupName, staticSiteName string) s
 	returnValue := fmt.Sprintf("%s/providers/Microsoft.Web/staticSites/%s", ResourceGroupRID(subscriptionId, resourceGroupName), staticSiteName)
 	return returnValue
 }
+
+// ParseResourceTypeAndName returns the fully qualified type and the name of the resource identified by resourceId, for
+// example `Microsoft.Web/sites` and `my-app`. Child resources have a type and a name with a segment for each level,
+// for example `Microsoft.Web/sites/slots` and `my-app/staging`.
+func ParseResourceTypeAndName(resourceId string) (string, string) {
+	segments := strings.Split(strings.Trim(resourceId, "/"), "/")
+
+	providersIndex := -1
+	for i, segment := range segments {
+		if strings.EqualFold(segment, "providers") {
+			providersIndex = i
+		}
+	}
+
+	if providersIndex == -1 || providersIndex+1 >= len(segments) {
+		switch {
+		case len(segments) >= 4 && strings.EqualFold(segments[2], "resourceGroups"):
+			return "Microsoft.Resources/resourceGroups", segments[3]
+		case len(segments) >= 2 && strings.EqualFold(segments[0], "subscriptions"):
+			return "Microsoft.Resources/subscriptions", segments[1]
+		default:
+			return "", resourceId
+		}
+	}
+
+	typeSegments := []string{segments[providersIndex+1]}
+	var nameSegments []string
+
+	for i := providersIndex + 2; i+1 < len(segments); i += 2 {
+		typeSegments = append(typeSegments, segments[i])
+		nameSegments = append(nameSegments, segments[i+1])
+	}
+
+	return strings.Join(typeSegments, "/"), strings.Join(nameSegments, "/")
+}
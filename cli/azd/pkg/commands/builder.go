@@ -25,11 +25,25 @@ func Build(action Action, rootOptions *GlobalCommandOptions, use string, short s
 			ctx = context.WithValue(ctx, environment.AzdContextKey, azdCtx)
 			ctx = context.WithValue(ctx, environment.OptionsContextKey, rootOptions)
 
+			// Commands run and requests sent by the action are recorded, or played back, in tests
+			if rootOptions.Recorder != nil {
+				ctx = rootOptions.Recorder.WithContext(ctx)
+			}
+
 			// Create and set the AzCli that will be used for the command
 			azCli := GetAzCliFromContext(ctx)
 			ctx = context.WithValue(ctx, environment.AzdCliContextKey, azCli)
 
-			return action.Run(ctx, cmd, args, azdCtx)
+			err = action.Run(ctx, cmd, args, azdCtx)
+
+			// The interactions are saved even when the action fails, so failures can be played back.
+			if rootOptions.Recorder != nil {
+				if saveErr := rootOptions.Recorder.Save(); saveErr != nil && err == nil {
+					err = fmt.Errorf("saving recording: %w", saveErr)
+				}
+			}
+
+			return err
 		},
 	}
 	cmd.Flags().BoolP("help", "h", false, fmt.Sprintf("Gets help for %s.", cmd.Name()))
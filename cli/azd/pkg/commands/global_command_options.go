@@ -1,5 +1,10 @@
 package commands
 
+import (
+	"github.com/azure/azure-dev/cli/azd/pkg/recording"
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+)
+
 type GlobalCommandOptions struct {
 	EnvironmentName string
 
@@ -22,4 +27,21 @@ type GlobalCommandOptions struct {
 	// AZURE_DEV_COLLECT_TELEMETRY is set to 'no'.
 	// Defaults to true.
 	EnableTelemetry bool
+
+	// AzCliKind selects the implementation of tools.AzCli which is used to call Azure, either tools.AzCliKindCli,
+	// which runs the Azure CLI, or tools.AzCliKindRest, which calls the Azure REST APIs directly.
+	// The rootCmd sets this from the environment variable AZURE_DEV_AZURE_CLIENT.
+	// Defaults to tools.AzCliKindCli, or to tools.AzCliKindRest when Credential is set.
+	AzCliKind string
+
+	// Credential provides the access tokens used to call Azure, when azd is logged in to a service principal or a
+	// managed identity, either with `azd login` or with the AZURE_CLIENT_* environment variables.
+	// The rootCmd sets this with auth.Manager.CurrentCredential. When nil, the Azure CLI provides access tokens, unless
+	// AzCliKind is tools.AzCliKindRest, which never runs the Azure CLI, so calls to Azure fail with tools.ErrNoCredential.
+	Credential tools.TokenCredential
+
+	// Recorder, when set, records the commands run and the HTTP requests sent by a command, or plays them back.
+	// The rootCmd sets this from the environment variables AZURE_DEV_RECORD_MODE and AZURE_DEV_CASSETTE, which are
+	// used by tests.
+	Recorder *recording.Recorder
 }
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"context"
+	"os"
 	"strings"
 
 	"github.com/azure/azure-dev/cli/azd/internal"
@@ -27,8 +28,20 @@ func GetAzCliFromContext(ctx context.Context) tools.AzCli {
 
 		azCliArgs.EnableDebug = options.EnableDebugLogging
 		azCliArgs.EnableTelemetry = options.EnableTelemetry
-
-		azCli = tools.NewAzCli(azCliArgs)
+		azCliArgs.Credential = options.Credential
+
+		if options.AzCliKind == tools.AzCliKindRest {
+			// Without a credential, requests fail with tools.ErrNoCredential, rather than falling back to the Azure CLI.
+			// The endpoints can be overridden to run against a local server, for testing.
+			azCli = tools.NewAzRestCli(tools.NewAzRestCliArgs{
+				Credential:              options.Credential,
+				ResourceManagerEndpoint: os.Getenv("AZURE_DEV_RESOURCE_MANAGER_ENDPOINT"),
+				GraphEndpoint:           os.Getenv("AZURE_DEV_GRAPH_ENDPOINT"),
+				EnableDebug:             options.EnableDebugLogging,
+			})
+		} else {
+			azCli = tools.NewAzCli(azCliArgs)
+		}
 	}
 
 	selectedTemplate := ""
@@ -6,11 +6,15 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sort"
+	"strings"
 
 	"github.com/azure/azure-dev/cli/azd/pkg/osutil"
+	"github.com/drone/envsubst"
 )
 
 const ProjectFileName = "azure.yaml"
@@ -27,6 +31,9 @@ type EnvironmentView struct {
 	Name       string
 	IsDefault  bool
 	DotEnvPath string
+	// Set when the environment is in the remote state of the project, in which case DotEnvPath is empty when it was
+	// not pulled yet
+	IsRemote bool
 }
 
 func (c *AzdContext) ProjectDirectory() string {
@@ -85,12 +92,206 @@ func (c *AzdContext) BicepParameters(env string, module string) (map[string]inte
 
 	ret := make(map[string]interface{})
 	for key, value := range unmarshalled["parameters"].(map[string]interface{}) {
-		ret[key] = (value.(map[string]interface{}))["value"]
+		parameter := value.(map[string]interface{})
+		if reference, has := parameter["reference"]; has {
+			ret[key] = BicepParameterReference(reference.(map[string]interface{}))
+			continue
+		}
+
+		ret[key] = parameter["value"]
 	}
 
 	return ret, nil
 }
 
+// BicepParameterReference is a deployment parameter which references a Key Vault secret, instead of having a value.
+// Resource Manager resolves the secret when the template is deployed, so its value is not written to the parameters
+// file.
+type BicepParameterReference map[string]interface{}
+
+// NewBicepParameterReference creates a deployment parameter referencing a secret of the Key Vault with the given
+// resource id.
+func NewBicepParameterReference(vaultId string, secretName string) BicepParameterReference {
+	return BicepParameterReference{
+		"keyVault": map[string]interface{}{
+			"id": vaultId,
+		},
+		"secretName": secretName,
+	}
+}
+
+// WithKeyVaultReferences replaces the values of the parameters of a deployment parameters file which reference a Key
+// Vault secret, such as a value set with `azd env set --secret`, by Key Vault references. Resource Manager resolves them
+// when the template is deployed, so the values of secrets are not written to the parameters file. vaultId returns the
+// resource id of the vault with the given name.
+func WithKeyVaultReferences(parametersJson string, vaultId func(vaultName string) (string, error)) (string, error) {
+	if !strings.Contains(parametersJson, KeyVaultSecretReferenceScheme) {
+		return parametersJson, nil
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(parametersJson), &doc); err != nil {
+		return "", fmt.Errorf("parsing parameter file: %w", err)
+	}
+
+	parameters, _ := doc["parameters"].(map[string]interface{})
+	vaultIds := map[string]string{}
+
+	for name, value := range parameters {
+		parameter, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		parameterValue, ok := parameter["value"].(string)
+		if !ok || !strings.Contains(parameterValue, KeyVaultSecretReferenceScheme) {
+			continue
+		}
+
+		reference, ok := ParseKeyVaultSecretReference(parameterValue)
+		if !ok {
+			return "", fmt.Errorf(
+				"the value of parameter '%s' must be a secret as a whole, since secrets are resolved when the template is deployed", name)
+		}
+
+		id, has := vaultIds[reference.VaultName]
+		if !has {
+			var err error
+			if id, err = vaultId(reference.VaultName); err != nil {
+				return "", fmt.Errorf("getting key vault of parameter '%s': %w", name, err)
+			}
+
+			vaultIds[reference.VaultName] = id
+		}
+
+		parameters[name] = map[string]interface{}{
+			"reference": map[string]interface{}(NewBicepParameterReference(id, reference.SecretName)),
+		}
+	}
+
+	result, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshaling parameters: %w", err)
+	}
+
+	return string(result), nil
+}
+
+// SubstituteBicepParameters substitutes the references to the values of env in the template of a parameters file,
+// falling back to the environment of azd for values env does not have. The parameters which reference transient values
+// of env are left out of the returned parameters file, and are returned separately, so they are only kept in memory.
+func SubstituteBicepParameters(template string, env *Environment) (string, map[string]interface{}, error) {
+	substitute := func(withTransient bool) (string, error) {
+		return envsubst.Eval(template, func(name string) string {
+			value, has := env.Lookup(name)
+			switch {
+			case has && !withTransient && env.IsTransient(name):
+				return ""
+			case has:
+				return value
+			default:
+				return os.Getenv(name)
+			}
+		})
+	}
+
+	persisted, err := substitute(false)
+	if err != nil {
+		return "", nil, err
+	}
+
+	substituted, err := substitute(true)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if substituted == persisted {
+		return persisted, nil, nil
+	}
+
+	var persistedDoc, substitutedDoc map[string]interface{}
+	if err := json.Unmarshal([]byte(persisted), &persistedDoc); err != nil {
+		return "", nil, fmt.Errorf("parsing parameter file: %w", err)
+	}
+	if err := json.Unmarshal([]byte(substituted), &substitutedDoc); err != nil {
+		return "", nil, fmt.Errorf("parsing parameter file: %w", err)
+	}
+
+	persistedParameters, _ := persistedDoc["parameters"].(map[string]interface{})
+	substitutedParameters, _ := substitutedDoc["parameters"].(map[string]interface{})
+
+	inMemory := map[string]interface{}{}
+	for name, parameter := range substitutedParameters {
+		if !reflect.DeepEqual(parameter, persistedParameters[name]) {
+			inMemory[name] = parameter
+			delete(persistedParameters, name)
+		}
+	}
+
+	result, err := json.MarshalIndent(persistedDoc, "", "  ")
+	if err != nil {
+		return "", nil, fmt.Errorf("marshaling parameters: %w", err)
+	}
+
+	return string(result), inMemory, nil
+}
+
+// TemporaryBicepParametersFile writes the parameters file at parametersPath, along with the given parameters which are
+// only kept in memory, to a temporary file which remove deletes once it is deployed. parametersPath is returned as is
+// when there are no such parameters.
+func TemporaryBicepParametersFile(
+	parametersPath string, inMemory map[string]interface{},
+) (path string, remove func(), err error) {
+	if len(inMemory) == 0 {
+		return parametersPath, func() {}, nil
+	}
+
+	contents, err := ioutil.ReadFile(parametersPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("reading parameters file: %w", err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(contents, &doc); err != nil {
+		return "", nil, fmt.Errorf("parsing parameters file: %w", err)
+	}
+
+	parameters, _ := doc["parameters"].(map[string]interface{})
+	if parameters == nil {
+		parameters = map[string]interface{}{}
+		doc["parameters"] = parameters
+	}
+
+	for name, parameter := range inMemory {
+		parameters[name] = parameter
+	}
+
+	contents, err = json.Marshal(doc)
+	if err != nil {
+		return "", nil, fmt.Errorf("marshaling parameters: %w", err)
+	}
+
+	// Temporary files are only readable by the current user.
+	file, err := os.CreateTemp("", "azd-parameters-*.json")
+	if err != nil {
+		return "", nil, fmt.Errorf("creating parameters file: %w", err)
+	}
+	defer file.Close()
+
+	remove = func() {
+		if err := os.Remove(file.Name()); err != nil {
+			log.Printf("failed removing temporary parameters file: %v", err)
+		}
+	}
+
+	if _, err := file.Write(contents); err != nil {
+		remove()
+		return "", nil, fmt.Errorf("writing parameters file: %w", err)
+	}
+
+	return file.Name(), remove, nil
+}
+
 // BicepParametersTemplateFilePath gets the path to the deployment parameter file template for
 // a module.
 func (c *AzdContext) BicepParametersTemplateFilePath(module string) string {
@@ -113,7 +314,11 @@ func (c *AzdContext) WriteBicepParameters(env string, module string, parameters
 	doc["parameters"] = make(map[string]interface{})
 	for name, value := range parameters {
 		valueObj := make(map[string]interface{})
-		valueObj["value"] = value
+		if reference, ok := value.(BicepParameterReference); ok {
+			valueObj["reference"] = map[string]interface{}(reference)
+		} else {
+			valueObj["value"] = value
+		}
 		(doc["parameters"].(map[string]interface{}))[name] = valueObj
 	}
 
@@ -145,7 +350,9 @@ func (c *AzdContext) ListEnvironments() ([]EnvironmentView, error) {
 	}
 
 	ents, err := os.ReadDir(c.EnvironmentDirectory())
-	if err != nil {
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
 		return nil, fmt.Errorf("listing entries: %w", err)
 	}
 
@@ -222,6 +429,88 @@ func (c *AzdContext) NewEnvironment(name string) error {
 	return nil
 }
 
+// DeleteEnvironment removes the directory of an environment, and unsets the default environment when it is the one
+// removed.
+func (c *AzdContext) DeleteEnvironment(name string) error {
+	dir, err := c.environmentDirectoryOf(name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("environment '%s' does not exist: %w", name, err)
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("removing environment directory: %w", err)
+	}
+
+	return c.replaceDefaultEnvironmentName(name, "")
+}
+
+// RenameEnvironment moves the directory of an environment to the directory of newName, and updates the default
+// environment when it is the one renamed. The values of the environment are not changed, so its AZURE_ENV_NAME value
+// still names the environment it was deployed as.
+func (c *AzdContext) RenameEnvironment(name string, newName string) error {
+	dir, err := c.environmentDirectoryOf(name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("environment '%s' does not exist: %w", name, err)
+	}
+
+	newDir, err := c.environmentDirectoryOf(newName)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(newDir); err == nil {
+		return ErrEnvironmentExists
+	}
+
+	if err := os.Rename(dir, newDir); err != nil {
+		return fmt.Errorf("renaming environment directory: %w", err)
+	}
+
+	return c.replaceDefaultEnvironmentName(name, newName)
+}
+
+// environmentDirectoryOf returns the directory of the environment named name, which must be a valid name, so the
+// directory is always a direct child of the environment directory, and never the project directory or its parent.
+func (c *AzdContext) environmentDirectoryOf(name string) (string, error) {
+	if !IsValidEnvironmentName(name) {
+		return "", fmt.Errorf("environment name '%s' is invalid (it should contain only alphanumeric characters and hyphens)", name)
+	}
+
+	root := filepath.Clean(c.EnvironmentDirectory())
+	dir := filepath.Join(root, name)
+	if filepath.Dir(dir) != root {
+		return "", fmt.Errorf("environment name '%s' is invalid, its directory is not in %s", name, root)
+	}
+
+	return dir, nil
+}
+
+// replaceDefaultEnvironmentName replaces the default environment with replacement when it is name.
+func (c *AzdContext) replaceDefaultEnvironmentName(name string, replacement string) error {
+	defaultName, err := c.GetDefaultEnvironmentName()
+	if err != nil {
+		return err
+	}
+
+	if defaultName != name {
+		return nil
+	}
+
+	if err := c.SetDefaultEnvironmentName(replacement); err != nil {
+		return fmt.Errorf("setting default environment: %w", err)
+	}
+
+	return nil
+}
+
 // GetAzdContext attempts to retrieve the AzdContext from the go context
 func GetAzdContext(ctx context.Context) (*AzdContext, error) {
 	azdCtx, ok := ctx.Value(AzdContextKey).(*AzdContext)
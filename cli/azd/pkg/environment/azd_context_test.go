@@ -2,9 +2,12 @@ package environment
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetAzdContextFails(t *testing.T) {
@@ -25,3 +28,96 @@ func TestGetAzdContextSuccess(t *testing.T) {
 	assert.NotNil(t, actualContext)
 	assert.Same(t, expectedContext, actualContext)
 }
+
+func TestDeleteAndRenameEnvironment(t *testing.T) {
+	azdCtx := &AzdContext{}
+	azdCtx.SetProjectDirectory(t.TempDir())
+
+	for _, name := range []string{"dev", "test"} {
+		require.NoError(t, azdCtx.NewEnvironment(name))
+		env := Empty(azdCtx.GetEnvironmentFilePath(name))
+		env.SetEnvName(name)
+		require.NoError(t, env.Save())
+	}
+	require.NoError(t, azdCtx.SetDefaultEnvironmentName("dev"))
+
+	require.NoError(t, azdCtx.RenameEnvironment("dev", "staging"))
+	require.Equal(t, ErrEnvironmentExists, azdCtx.RenameEnvironment("staging", "test"))
+	require.Error(t, azdCtx.RenameEnvironment("staging", "not valid"))
+
+	defaultName, err := azdCtx.GetDefaultEnvironmentName()
+	require.NoError(t, err)
+	require.Equal(t, "staging", defaultName)
+
+	// The values are kept, including the name the environment is deployed as.
+	env, err := azdCtx.GetEnvironment("staging")
+	require.NoError(t, err)
+	require.Equal(t, "dev", env.GetEnvName())
+
+	require.NoError(t, azdCtx.DeleteEnvironment("test"))
+	require.NoError(t, azdCtx.DeleteEnvironment("staging"))
+	require.Error(t, azdCtx.DeleteEnvironment("staging"))
+
+	envs, err := azdCtx.ListEnvironments()
+	require.NoError(t, err)
+	require.Empty(t, envs)
+
+	defaultName, err = azdCtx.GetDefaultEnvironmentName()
+	require.NoError(t, err)
+	require.Equal(t, "", defaultName)
+}
+
+func TestDeleteEnvironmentOutsideEnvironmentDirectory(t *testing.T) {
+	projectDir := t.TempDir()
+	azdCtx := &AzdContext{}
+	azdCtx.SetProjectDirectory(projectDir)
+
+	require.NoError(t, azdCtx.NewEnvironment("dev"))
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, ".env"), []byte("A=1\n"), 0600))
+
+	for _, name := range []string{"", ".", "..", "../..", "dev/.."} {
+		require.Error(t, azdCtx.DeleteEnvironment(name), name)
+		require.Error(t, azdCtx.RenameEnvironment(name, "test"), name)
+		require.Error(t, azdCtx.RenameEnvironment("dev", name), name)
+	}
+
+	_, err := os.Stat(filepath.Join(projectDir, ".env"))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(azdCtx.EnvironmentDirectory(), "dev"))
+	require.NoError(t, err)
+}
+
+func TestSubstituteBicepParameters(t *testing.T) {
+	env := Empty("")
+	env.SetUserValue("DATABASE_NAME", "mine")
+	env.SetTransientValue("DATABASE_PASSWORD", "secret")
+
+	parameters, inMemory, err := SubstituteBicepParameters(`{
+  "parameters": {
+    "databaseName": {"value": "${DATABASE_NAME}"},
+    "databasePassword": {"value": "${DATABASE_PASSWORD}"}
+  }
+}`, &env)
+	require.NoError(t, err)
+	require.NotContains(t, parameters, "secret")
+	require.Contains(t, parameters, "mine")
+	require.Equal(t, map[string]interface{}{
+		"databasePassword": map[string]interface{}{"value": "secret"},
+	}, inMemory)
+
+	path := filepath.Join(t.TempDir(), "main.parameters.json")
+	require.NoError(t, os.WriteFile(path, []byte(parameters), 0600))
+
+	deploymentPath, remove, err := TemporaryBicepParametersFile(path, inMemory)
+	require.NoError(t, err)
+	require.NotEqual(t, path, deploymentPath)
+
+	contents, err := os.ReadFile(deploymentPath)
+	require.NoError(t, err)
+	require.Contains(t, string(contents), "mine")
+	require.Contains(t, string(contents), "secret")
+
+	remove()
+	_, err = os.Stat(deploymentPath)
+	require.True(t, os.IsNotExist(err))
+}
@@ -8,4 +8,5 @@ const (
 	TemplateContextKey ContextKeyNames = "template"
 	AzdCliContextKey   ContextKeyNames = "azdcli"
 	HttpUtilContextKey ContextKeyNames = "httputil"
+	RecorderContextKey ContextKeyNames = "recorder"
 )
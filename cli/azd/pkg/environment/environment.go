@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sync"
 
 	"github.com/azure/azure-dev/cli/azd/pkg/osutil"
 	"github.com/joho/godotenv"
@@ -37,17 +38,30 @@ const ResourceGroupEnvVarName = "AZURE_RESOURCE_GROUP"
 type Environment struct {
 	// Values is a map of setting names to values.
 	Values map[string]string
+	// Metadata records where values came from, and is saved next to File.
+	Metadata map[string]ValueMetadata
 	// File is a path to the file that backs this environment. If empty, the Environment
 	// will not be persisted when `Save` is called. This allows the zero value to be used
 	// for testing.
 	File string
+
+	// transient is the names of the values which are only kept in memory, and are not saved.
+	transient map[string]bool
+
+	// mu guards Values and Metadata, and serializes saves, for the services which are deployed at the same time. It is
+	// shared by copies of the environment, and is nil for the zero value, which is not guarded.
+	mu *sync.RWMutex
 }
 
-// Same restrictions as a deployment name (ref: https://docs.microsoft.com/azure/azure-resource-manager/management/resource-name-rules#microsoftresources)
-var environmentNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9-\(\)_\.]{1,64}$`)
+// Same restrictions as a deployment name (ref: https://docs.microsoft.com/azure/azure-resource-manager/management/resource-name-rules#microsoftresources),
+// except names must have a character other than a period, since names such as `..` are not directories of environments.
+var environmentNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9-\(\)_\.]*[a-zA-Z0-9-\(\)_][a-zA-Z0-9-\(\)_\.]*$`)
+
+// The maximum length of the name of an environment, as of a deployment name
+const environmentNameMaxLength = 64
 
 func IsValidEnvironmentName(name string) bool {
-	return environmentNameRegexp.MatchString(name)
+	return len(name) <= environmentNameMaxLength && environmentNameRegexp.MatchString(name)
 }
 
 // FromFile loads an environment from a file on disk. On error,
@@ -55,8 +69,11 @@ func IsValidEnvironmentName(name string) bool {
 // to file, is returned.
 func FromFile(file string) (Environment, error) {
 	env := Environment{
-		Values: make(map[string]string),
-		File:   file,
+		Values:    make(map[string]string),
+		Metadata:  make(map[string]ValueMetadata),
+		File:      file,
+		transient: make(map[string]bool),
+		mu:        &sync.RWMutex{},
 	}
 
 	e, err := godotenv.Read(file)
@@ -66,6 +83,10 @@ func FromFile(file string) (Environment, error) {
 	}
 
 	env.Values = e
+	if err := env.readMetadata(); err != nil {
+		return env, err
+	}
+
 	return env, nil
 }
 
@@ -73,8 +94,11 @@ func FromFile(file string) (Environment, error) {
 // to a given file when saved.
 func Empty(file string) Environment {
 	return Environment{
-		File:   file,
-		Values: make(map[string]string),
+		File:      file,
+		Values:    make(map[string]string),
+		Metadata:  make(map[string]ValueMetadata),
+		transient: make(map[string]bool),
+		mu:        &sync.RWMutex{},
 	}
 }
 
@@ -85,43 +109,104 @@ func (e *Environment) Save() error {
 		return nil
 	}
 
+	e.lock()
+	defer e.unlock()
+
 	err := os.MkdirAll(filepath.Dir(e.File), osutil.PermissionDirectory)
 	if err != nil {
 		return fmt.Errorf("failed to create a directory: %w", err)
 	}
 
-	err = godotenv.Write(e.Values, e.File)
+	values := make(map[string]string, len(e.Values))
+	for name, value := range e.Values {
+		if !e.transient[name] {
+			values[name] = value
+		}
+	}
+
+	err = godotenv.Write(values, e.File)
 	if err != nil {
 		return fmt.Errorf("can't write '%s': %w", e.File, err)
 	}
 
-	return nil
+	return e.writeMetadata()
+}
+
+// Lookup returns the value with the given name, and whether the environment has it. Unlike reading Values, it is safe
+// while other services are deployed to the environment.
+func (e *Environment) Lookup(name string) (string, bool) {
+	e.rlock()
+	defer e.runlock()
+
+	value, has := e.Values[name]
+	return value, has
+}
+
+// Snapshot returns a copy of the values of the environment, which is safe while other services are deployed to the
+// environment.
+func (e *Environment) Snapshot() map[string]string {
+	e.rlock()
+	defer e.runlock()
+
+	values := make(map[string]string, len(e.Values))
+	for name, value := range e.Values {
+		values[name] = value
+	}
+
+	return values
+}
+
+func (e *Environment) lock() {
+	if e.mu != nil {
+		e.mu.Lock()
+	}
+}
+
+func (e *Environment) unlock() {
+	if e.mu != nil {
+		e.mu.Unlock()
+	}
+}
+
+func (e *Environment) rlock() {
+	if e.mu != nil {
+		e.mu.RLock()
+	}
+}
+
+func (e *Environment) runlock() {
+	if e.mu != nil {
+		e.mu.RUnlock()
+	}
 }
 
 func (e *Environment) GetEnvName() string {
-	return e.Values[EnvNameEnvVarName]
+	value, _ := e.Lookup(EnvNameEnvVarName)
+	return value
 }
 
 func (e *Environment) SetEnvName(envname string) {
-	e.Values[EnvNameEnvVarName] = envname
+	e.SetSystemValue(EnvNameEnvVarName, envname)
 }
 
 func (e *Environment) GetSubscriptionId() string {
-	return e.Values[SubscriptionIdEnvVarName]
+	value, _ := e.Lookup(SubscriptionIdEnvVarName)
+	return value
 }
 
 func (e *Environment) GetTenantId() string {
-	return e.Values[TenantIdEnvVarName]
+	value, _ := e.Lookup(TenantIdEnvVarName)
+	return value
 }
 
 func (e *Environment) SetSubscriptionId(id string) {
-	e.Values[SubscriptionIdEnvVarName] = id
+	e.SetSystemValue(SubscriptionIdEnvVarName, id)
 }
 
 func (e *Environment) SetLocation(location string) {
-	e.Values[LocationEnvVarName] = location
+	e.SetSystemValue(LocationEnvVarName, location)
 }
 
 func (e *Environment) SetPrincipalId(principalID string) {
-	e.Values[PrincipalIdEnvVarName] = principalID
+	e.SetSystemValue(PrincipalIdEnvVarName, principalID)
 }
@@ -14,7 +14,14 @@ func TestIsValidEnvironmentName(t *testing.T) {
 	assert.True(t, IsValidEnvironmentName("a-name-with-hyphens"))
 	assert.True(t, IsValidEnvironmentName("C()mPl3x_ExAmPl3-ThatIsVeryLong"))
 
+	assert.True(t, IsValidEnvironmentName("v1.0"))
+	assert.True(t, IsValidEnvironmentName(".hidden"))
+
 	assert.False(t, IsValidEnvironmentName(""))
+	assert.False(t, IsValidEnvironmentName("."))
+	assert.False(t, IsValidEnvironmentName(".."))
+	assert.False(t, IsValidEnvironmentName("..."))
+	assert.False(t, IsValidEnvironmentName("../dev"))
 	assert.False(t, IsValidEnvironmentName("no*allowed"))
 	assert.False(t, IsValidEnvironmentName("no spaces"))
 	assert.False(t, IsValidEnvironmentName("12345678901234567890123456789012345678901234567890123456789012345"))
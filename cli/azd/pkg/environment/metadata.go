@@ -0,0 +1,263 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package environment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/osutil"
+)
+
+// MetadataFileName is the name of the file, next to the .env file of an environment, which records where its values
+// came from.
+const MetadataFileName = ".env.metadata.json"
+
+// ValueSource is where a value of an environment came from.
+type ValueSource string
+
+const (
+	// UserSource values are set by users, such as with azd env set.
+	UserSource ValueSource = "user"
+	// OutputSource values are outputs of the deployment of a module.
+	OutputSource ValueSource = "output"
+	// SystemSource values are set by azd, such as the subscription of the environment.
+	SystemSource ValueSource = "system"
+)
+
+// ValueMetadata records where a value of an environment came from. Values without metadata were set before azd
+// recorded it, or by editing the .env file.
+type ValueMetadata struct {
+	Source ValueSource `json:"source"`
+	// The module the value is an output of, for outputs
+	Module string `json:"module,omitempty"`
+}
+
+// OutputChanges describes how the outputs of a deployment changed the values of an environment. Each list of names is
+// sorted.
+type OutputChanges struct {
+	Added   []string
+	Changed []string
+	Removed []string
+	// Outputs which were not saved, since the environment has a different value of the same name which was not an
+	// output
+	Skipped []string
+}
+
+// SetUserValue sets a value set by a user.
+func (e *Environment) SetUserValue(name string, value string) {
+	e.setValue(name, value, ValueMetadata{Source: UserSource})
+}
+
+// SetSystemValue sets a value set by azd.
+func (e *Environment) SetSystemValue(name string, value string) {
+	e.setValue(name, value, ValueMetadata{Source: SystemSource})
+}
+
+// SetImportedValue sets a value imported from elsewhere, such as with azd env import, whose source is not known. As
+// values set by editing the .env file, it has no metadata, so the outputs of deployments replace it.
+func (e *Environment) SetImportedValue(name string, value string) {
+	e.lock()
+	defer e.unlock()
+
+	e.Values[name] = value
+	delete(e.Metadata, name)
+	delete(e.transient, name)
+}
+
+// SetTransientValue sets a value which is only kept in memory, and is not saved, such as a secret when there is no Key
+// Vault to save it to.
+func (e *Environment) SetTransientValue(name string, value string) {
+	e.lock()
+	defer e.unlock()
+
+	if e.transient == nil {
+		e.transient = make(map[string]bool)
+	}
+
+	e.Values[name] = value
+	e.transient[name] = true
+	delete(e.Metadata, name)
+}
+
+// IsTransient returns true for values which are only kept in memory.
+func (e *Environment) IsTransient(name string) bool {
+	e.rlock()
+	defer e.runlock()
+
+	return e.transient[name]
+}
+
+// LookupMetadata returns the metadata of the value named name, which values set before azd recorded it, or by editing
+// the .env file, do not have.
+func (e *Environment) LookupMetadata(name string) (ValueMetadata, bool) {
+	e.rlock()
+	defer e.runlock()
+
+	metadata, has := e.Metadata[name]
+	return metadata, has
+}
+
+func (e *Environment) setValue(name string, value string, metadata ValueMetadata) {
+	e.lock()
+	defer e.unlock()
+
+	e.setValueLocked(name, value, metadata)
+}
+
+func (e *Environment) setValueLocked(name string, value string, metadata ValueMetadata) {
+	if e.Metadata == nil {
+		e.Metadata = make(map[string]ValueMetadata)
+	}
+
+	e.Values[name] = value
+	e.Metadata[name] = metadata
+	delete(e.transient, name)
+}
+
+// OutputNames returns the names of the values which are outputs of deployments.
+func (e *Environment) OutputNames() map[string]bool {
+	e.rlock()
+	defer e.runlock()
+
+	return e.outputNames()
+}
+
+func (e *Environment) outputNames() map[string]bool {
+	names := map[string]bool{}
+
+	for name, metadata := range e.Metadata {
+		if metadata.Source == OutputSource {
+			names[name] = true
+		}
+	}
+
+	return names
+}
+
+// ApplyOutputs saves the outputs of the latest deployment of module, and removes the values which were outputs of a
+// previous deployment of the module but no longer are. Outputs do not replace values of the same name set by users or
+// by azd, which are reported as skipped instead, unless their values are the same.
+func (e *Environment) ApplyOutputs(module string, outputs map[string]string) OutputChanges {
+	e.lock()
+	defer e.unlock()
+
+	changes := OutputChanges{}
+
+	for name, value := range outputs {
+		current, hasValue := e.Values[name]
+		metadata, hasMetadata := e.Metadata[name]
+
+		if hasValue && hasMetadata && metadata.Source != OutputSource {
+			if current != value {
+				changes.Skipped = append(changes.Skipped, name)
+			}
+
+			continue
+		}
+
+		if !hasValue {
+			changes.Added = append(changes.Added, name)
+		} else if current != value {
+			changes.Changed = append(changes.Changed, name)
+		}
+
+		e.setValueLocked(name, value, ValueMetadata{Source: OutputSource, Module: module})
+	}
+
+	for name, metadata := range e.Metadata {
+		if _, has := outputs[name]; metadata.Source == OutputSource && metadata.Module == module && !has {
+			changes.Removed = append(changes.Removed, name)
+			delete(e.Values, name)
+			delete(e.Metadata, name)
+		}
+	}
+
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Changed)
+	sort.Strings(changes.Removed)
+	sort.Strings(changes.Skipped)
+
+	return changes
+}
+
+// RemoveOutputs removes the values which are outputs of deployments, such as once the infrastructure of the environment
+// is destroyed, and returns their sorted names.
+func (e *Environment) RemoveOutputs() []string {
+	e.lock()
+	defer e.unlock()
+
+	removed := []string{}
+
+	for name := range e.outputNames() {
+		removed = append(removed, name)
+		delete(e.Values, name)
+		delete(e.Metadata, name)
+	}
+
+	sort.Strings(removed)
+	return removed
+}
+
+func (e *Environment) metadataFile() string {
+	return filepath.Join(filepath.Dir(e.File), MetadataFileName)
+}
+
+// readMetadata reads the metadata of the values of the environment, ignoring the metadata of values which were removed
+// by editing the .env file.
+func (e *Environment) readMetadata() error {
+	e.Metadata = make(map[string]ValueMetadata)
+
+	contents, err := os.ReadFile(e.metadataFile())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("reading environment metadata: %w", err)
+	}
+
+	metadata := map[string]ValueMetadata{}
+	if err := json.Unmarshal(contents, &metadata); err != nil {
+		return fmt.Errorf("parsing environment metadata: %w", err)
+	}
+
+	for name, valueMetadata := range metadata {
+		if _, has := e.Values[name]; has {
+			e.Metadata[name] = valueMetadata
+		}
+	}
+
+	return nil
+}
+
+func (e *Environment) writeMetadata() error {
+	metadata := map[string]ValueMetadata{}
+	for name, valueMetadata := range e.Metadata {
+		if _, has := e.Values[name]; has {
+			metadata[name] = valueMetadata
+		}
+	}
+
+	if len(metadata) == 0 {
+		if err := os.Remove(e.metadataFile()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing environment metadata: %w", err)
+		}
+
+		return nil
+	}
+
+	contents, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling environment metadata: %w", err)
+	}
+
+	if err := os.WriteFile(e.metadataFile(), contents, osutil.PermissionFile); err != nil {
+		return fmt.Errorf("writing environment metadata: %w", err)
+	}
+
+	return nil
+}
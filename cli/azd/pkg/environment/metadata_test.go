@@ -0,0 +1,106 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package environment
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyOutputs(t *testing.T) {
+	env := Environment{Values: map[string]string{
+		// Set before azd recorded where values came from
+		"WEBSITE_URL": "https://old",
+	}}
+	env.SetUserValue("DATABASE_NAME", "mine")
+	env.SetSystemValue(LocationEnvVarName, "westus2")
+
+	changes := env.ApplyOutputs("main", map[string]string{
+		"WEBSITE_URL":      "https://web",
+		"API_URL":          "https://api",
+		"DATABASE_NAME":    "theirs",
+		LocationEnvVarName: "westus2",
+	})
+	require.Equal(t, OutputChanges{
+		Added:   []string{"API_URL"},
+		Changed: []string{"WEBSITE_URL"},
+		Skipped: []string{"DATABASE_NAME"},
+	}, changes)
+
+	require.Equal(t, "mine", env.Values["DATABASE_NAME"])
+	require.Equal(t, map[string]bool{"WEBSITE_URL": true, "API_URL": true}, env.OutputNames())
+	require.Equal(t, ValueMetadata{Source: SystemSource}, env.Metadata[LocationEnvVarName])
+
+	changes = env.ApplyOutputs("main", map[string]string{"API_URL": "https://api"})
+	require.Equal(t, OutputChanges{Removed: []string{"WEBSITE_URL"}}, changes)
+	require.NotContains(t, env.Values, "WEBSITE_URL")
+
+	// Outputs of other modules are kept.
+	env.ApplyOutputs("web", map[string]string{"SERVICE_WEB_NAME": "web"})
+	env.ApplyOutputs("main", map[string]string{"API_URL": "https://api"})
+	require.Equal(t, "web", env.Values["SERVICE_WEB_NAME"])
+
+	require.Equal(t, []string{"API_URL", "SERVICE_WEB_NAME"}, env.RemoveOutputs())
+	require.Equal(t, map[string]string{"DATABASE_NAME": "mine", LocationEnvVarName: "westus2"}, env.Values)
+}
+
+func TestSaveMetadata(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "dev", ".env")
+
+	env := Empty(file)
+	env.SetUserValue("DATABASE_NAME", "mine")
+	env.ApplyOutputs("main", map[string]string{"API_URL": "https://api"})
+	require.NoError(t, env.Save())
+
+	loaded, err := FromFile(file)
+	require.NoError(t, err)
+	require.Equal(t, env.Values, loaded.Values)
+	require.Equal(t, env.Metadata, loaded.Metadata)
+
+	// The metadata of values removed by editing the .env file is ignored.
+	require.NoError(t, os.WriteFile(file, []byte("DATABASE_NAME=mine\n"), 0600))
+
+	loaded, err = FromFile(file)
+	require.NoError(t, err)
+	require.Equal(t, map[string]ValueMetadata{"DATABASE_NAME": {Source: UserSource}}, loaded.Metadata)
+
+	loaded.Values = map[string]string{}
+	require.NoError(t, loaded.Save())
+	_, err = os.Stat(filepath.Join(filepath.Dir(file), MetadataFileName))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestApplyOutputsReplacesImportedValues(t *testing.T) {
+	env := Empty("")
+	env.SetUserValue("API_URL", "https://user.example.com")
+
+	env.SetImportedValue("API_URL", "https://imported.example.com")
+	require.NotContains(t, env.Metadata, "API_URL")
+
+	changes := env.ApplyOutputs("main", map[string]string{"API_URL": "https://output.example.com"})
+	require.Equal(t, []string{"API_URL"}, changes.Changed)
+	require.Empty(t, changes.Skipped)
+	require.True(t, env.OutputNames()["API_URL"])
+}
+
+func TestSaveSkipsTransientValues(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "dev", ".env")
+
+	env := Empty(file)
+	env.SetUserValue("DATABASE_NAME", "mine")
+	env.SetTransientValue("DATABASE_PASSWORD", "secret")
+	require.True(t, env.IsTransient("DATABASE_PASSWORD"))
+	require.NoError(t, env.Save())
+
+	loaded, err := FromFile(file)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"DATABASE_NAME": "mine"}, loaded.Values)
+
+	// Values set otherwise are saved again.
+	env.SetUserValue("DATABASE_PASSWORD", "saved")
+	require.False(t, env.IsTransient("DATABASE_PASSWORD"))
+}
@@ -0,0 +1,85 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package environment
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// KeyVaultNameEnvVarName is the name of the key used to store the name of the Key Vault secret values are stored in.
+const KeyVaultNameEnvVarName = "AZURE_KEY_VAULT_NAME"
+
+// KeyVaultSecretReferenceScheme prefixes the values of an environment which reference a Key Vault secret, such as
+// akvs://my-vault/my-secret.
+const KeyVaultSecretReferenceScheme = "akvs://"
+
+// KeyVaultSecretReference references a Key Vault secret, which holds a secret value of an environment. Only the
+// reference is saved in the environment file, and the value is resolved when it is used.
+type KeyVaultSecretReference struct {
+	VaultName  string
+	SecretName string
+}
+
+func (r KeyVaultSecretReference) String() string {
+	return fmt.Sprintf("%s%s/%s", KeyVaultSecretReferenceScheme, r.VaultName, r.SecretName)
+}
+
+var keyVaultSecretReferenceRegex = regexp.MustCompile(`^akvs://([a-zA-Z0-9-]+)/([a-zA-Z0-9-]+)$`)
+
+// ParseKeyVaultSecretReference parses value as a reference to a Key Vault secret. false is returned when value is not a
+// reference.
+func ParseKeyVaultSecretReference(value string) (KeyVaultSecretReference, bool) {
+	captures := keyVaultSecretReferenceRegex.FindStringSubmatch(value)
+	if captures == nil {
+		return KeyVaultSecretReference{}, false
+	}
+
+	return KeyVaultSecretReference{VaultName: captures[1], SecretName: captures[2]}, true
+}
+
+var invalidSecretNameCharsRegex = regexp.MustCompile(`[^a-zA-Z0-9-]+`)
+
+// SecretName returns the name of the Key Vault secret which stores the value with the given name in an environment,
+// such as "dev-DB-PASSWORD" for DB_PASSWORD in the dev environment. Secrets are named after the environment, since a
+// vault may be shared by environments.
+func SecretName(envName string, name string) string {
+	return strings.Trim(invalidSecretNameCharsRegex.ReplaceAllString(envName+"-"+name, "-"), "-")
+}
+
+// SecretResolver gets the values of the Key Vault secrets referenced by environments, which tools.AzCli implements.
+type SecretResolver interface {
+	GetKeyVaultSecret(ctx context.Context, vaultName string, secretName string) (string, error)
+}
+
+// ResolvedValues returns the values of the environment, where values which reference Key Vault secrets are replaced
+// by the values of the secrets. The values of the secrets are only kept in memory, and are resolved with the AzCli of
+// ctx, since it implements SecretResolver.
+func (e *Environment) ResolvedValues(ctx context.Context) (map[string]string, error) {
+	values := e.Snapshot()
+
+	// Secrets are resolved without holding the lock of the environment, since they are resolved with the az CLI.
+	for name, value := range values {
+		reference, isReference := ParseKeyVaultSecretReference(value)
+		if !isReference {
+			continue
+		}
+
+		resolver, ok := ctx.Value(AzdCliContextKey).(SecretResolver)
+		if !ok {
+			return nil, fmt.Errorf("resolving %s: secrets cannot be resolved in this context", name)
+		}
+
+		secret, err := resolver.GetKeyVaultSecret(ctx, reference.VaultName, reference.SecretName)
+		if err != nil {
+			return nil, fmt.Errorf("resolving %s from %s: %w", name, reference, err)
+		}
+
+		values[name] = secret
+	}
+
+	return values, nil
+}
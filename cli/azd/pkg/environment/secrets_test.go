@@ -0,0 +1,59 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package environment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeyVaultSecretReference(t *testing.T) {
+	reference, ok := ParseKeyVaultSecretReference("akvs://my-vault/dev-DB-PASSWORD")
+	require.True(t, ok)
+	require.Equal(t, KeyVaultSecretReference{VaultName: "my-vault", SecretName: "dev-DB-PASSWORD"}, reference)
+	require.Equal(t, "akvs://my-vault/dev-DB-PASSWORD", reference.String())
+
+	for _, value := range []string{"", "value", "akvs://my-vault", "akvs://my-vault/", "prefix akvs://my-vault/secret"} {
+		_, ok := ParseKeyVaultSecretReference(value)
+		assert.False(t, ok, value)
+	}
+}
+
+func TestSecretName(t *testing.T) {
+	assert.Equal(t, "dev-DB-PASSWORD", SecretName("dev", "DB_PASSWORD"))
+	assert.Equal(t, "my-env-API-KEY", SecretName("my_env", "API__KEY"))
+}
+
+type fakeSecretResolver map[string]string
+
+func (r fakeSecretResolver) GetKeyVaultSecret(ctx context.Context, vaultName string, secretName string) (string, error) {
+	return r[vaultName+"/"+secretName], nil
+}
+
+func TestResolvedValues(t *testing.T) {
+	env := Environment{Values: map[string]string{
+		"PLAIN":  "value",
+		"SECRET": "akvs://my-vault/dev-SECRET",
+	}}
+
+	t.Run("Resolved", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), AzdCliContextKey, fakeSecretResolver{
+			"my-vault/dev-SECRET": "secret-value",
+		})
+
+		values, err := env.ResolvedValues(ctx)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"PLAIN": "value", "SECRET": "secret-value"}, values)
+		require.Equal(t, "akvs://my-vault/dev-SECRET", env.Values["SECRET"])
+	})
+
+	t.Run("NoResolver", func(t *testing.T) {
+		_, err := env.ResolvedValues(context.Background())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "SECRET")
+	})
+}
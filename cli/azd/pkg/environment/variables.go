@@ -0,0 +1,118 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package environment
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// VariableType is the type of the value of a VariableConfig.
+type VariableType string
+
+const (
+	StringVariable VariableType = "string"
+	NumberVariable VariableType = "number"
+	BoolVariable   VariableType = "bool"
+)
+
+// VariableConfig declares a value of the environments of a project, in the `env` section of azure.yaml.
+type VariableConfig struct {
+	// The name of the value, such as DATABASE_NAME
+	Name string `yaml:"name"`
+	// The type of the value, either `string` (the default), `number` or `bool`
+	Type VariableType `yaml:"type,omitempty"`
+	// Whether environments must have the value, which is prompted for when they do not
+	Required bool `yaml:"required,omitempty"`
+	// The value environments get when it is not set
+	Default string `yaml:"default,omitempty"`
+	// Describes the value, and is displayed when it is prompted for
+	Description string `yaml:"description,omitempty"`
+	// The values allowed, when only some are
+	Allowed []string `yaml:"allowed,omitempty"`
+	// Whether the value is a secret, which is stored in Key Vault when the environment has a vault
+	Secret bool `yaml:"secret,omitempty"`
+}
+
+// VariableType returns the type of the value, which defaults to StringVariable.
+func (c *VariableConfig) VariableType() VariableType {
+	if c.Type == "" {
+		return StringVariable
+	}
+
+	return c.Type
+}
+
+// Validate returns an error describing why value is not valid for the variable. References to Key Vault secrets are
+// valid, since secrets are only resolved when they are used.
+func (c *VariableConfig) Validate(value string) error {
+	if _, isReference := ParseKeyVaultSecretReference(value); isReference {
+		return nil
+	}
+
+	switch c.VariableType() {
+	case NumberVariable:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("'%s' is not a number", value)
+		}
+	case BoolVariable:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("'%s' is not true or false", value)
+		}
+	}
+
+	if len(c.Allowed) > 0 {
+		for _, allowed := range c.Allowed {
+			if value == allowed {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("'%s' is not one of the allowed values: %s", value, strings.Join(c.Allowed, ", "))
+	}
+
+	return nil
+}
+
+var variableNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ValidateVariables ensures that the variables of a project have valid names, which are unique, and supported types,
+// and that their defaults are valid.
+func ValidateVariables(variables []VariableConfig) error {
+	names := map[string]bool{}
+
+	for _, variable := range variables {
+		if !variableNameRegexp.MatchString(variable.Name) {
+			return fmt.Errorf("environment variable name '%s' is invalid (it should contain only alphanumeric characters and underscores)", variable.Name)
+		}
+
+		if names[variable.Name] {
+			return fmt.Errorf("environment variable '%s' is declared more than once", variable.Name)
+		}
+		names[variable.Name] = true
+
+		switch variable.VariableType() {
+		case StringVariable, NumberVariable, BoolVariable:
+		default:
+			return fmt.Errorf("environment variable '%s' has unsupported type '%s', supported types are: %s, %s, %s",
+				variable.Name, variable.Type, StringVariable, NumberVariable, BoolVariable)
+		}
+
+		for _, allowed := range variable.Allowed {
+			if err := (&VariableConfig{Type: variable.Type}).Validate(allowed); err != nil {
+				return fmt.Errorf("allowed value of environment variable '%s': %w", variable.Name, err)
+			}
+		}
+
+		if variable.Default != "" {
+			if err := variable.Validate(variable.Default); err != nil {
+				return fmt.Errorf("default of environment variable '%s': %w", variable.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package environment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVariableConfigValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		variable VariableConfig
+		value    string
+		valid    bool
+	}{
+		{"String", VariableConfig{}, "anything", true},
+		{"Number", VariableConfig{Type: NumberVariable}, "3.5", true},
+		{"NotNumber", VariableConfig{Type: NumberVariable}, "three", false},
+		{"Bool", VariableConfig{Type: BoolVariable}, "true", true},
+		{"NotBool", VariableConfig{Type: BoolVariable}, "yes", false},
+		{"Allowed", VariableConfig{Allowed: []string{"S1", "P1"}}, "P1", true},
+		{"NotAllowed", VariableConfig{Allowed: []string{"S1", "P1"}}, "F1", false},
+		{"SecretReference", VariableConfig{Type: NumberVariable}, "akvs://my-vault/dev-PORT", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.variable.Validate(test.value)
+			if test.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateVariables(t *testing.T) {
+	require.NoError(t, ValidateVariables([]VariableConfig{
+		{Name: "SKU", Allowed: []string{"S1", "P1"}, Default: "S1"},
+		{Name: "REPLICAS", Type: NumberVariable, Required: true},
+		{Name: "DB_PASSWORD", Secret: true},
+	}))
+
+	tests := []struct {
+		name      string
+		variables []VariableConfig
+		message   string
+	}{
+		{"InvalidName", []VariableConfig{{Name: "MY-VALUE"}}, "is invalid"},
+		{"Duplicate", []VariableConfig{{Name: "SKU"}, {Name: "SKU"}}, "more than once"},
+		{"UnsupportedType", []VariableConfig{{Name: "SKU", Type: "object"}}, "unsupported type"},
+		{"InvalidAllowed", []VariableConfig{{Name: "PORT", Type: NumberVariable, Allowed: []string{"http"}}}, "allowed value"},
+		{"InvalidDefault", []VariableConfig{{Name: "SKU", Allowed: []string{"S1"}, Default: "P1"}}, "default"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateVariables(test.variables)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), test.message)
+		})
+	}
+}
@@ -15,6 +15,8 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
 )
 
 // Settings to modify the way CmdTree is executed
@@ -22,10 +24,29 @@ type CmdTreeOptions struct {
 	Interactive bool
 }
 
+// Recorder observes or replaces the commands run by this package, when one is set in the context with
+// environment.RecorderContextKey. It is used to record the commands run by azd and to play them back in tests.
+type Recorder interface {
+	// Run is called instead of running a command, where run runs the command. Commands run with a list of commands,
+	// such as RunCommandList, have an empty args.Cmd.
+	Run(ctx context.Context, args RunArgs, run func() (RunResult, error)) (RunResult, error)
+}
+
+// withRecorder runs the command with the Recorder of the context, if there is one.
+func withRecorder(ctx context.Context, args RunArgs, run func() (RunResult, error)) (RunResult, error) {
+	if recorder, ok := ctx.Value(environment.RecorderContextKey).(Recorder); ok {
+		return recorder.Run(ctx, args, run)
+	}
+
+	return run()
+}
+
 // RunCommand runs a specific command with a given set of arguments.
 func RunCommand(ctx context.Context, cmd string, args ...string) (RunResult, error) {
-	process := CmdTree{Cmd: exec.CommandContext(ctx, cmd, args...)}
-	return execCmdTree(process)
+	return withRecorder(ctx, RunArgs{Cmd: cmd, Args: args}, func() (RunResult, error) {
+		process := CmdTree{Cmd: exec.CommandContext(ctx, cmd, args...)}
+		return execCmdTree(process)
+	})
 }
 
 // RunCommandWithCurrentStdio runs a command, reusing the current stdout, stderr and stdin of the
@@ -33,39 +54,45 @@ func RunCommand(ctx context.Context, cmd string, args ...string) (RunResult, err
 // will be empty strings. This is useful when the command you want to run is "interactive", like
 // logging into GitHub.
 func RunCommandWithCurrentStdio(ctx context.Context, cmd string, args ...string) (RunResult, error) {
-	process := CmdTree{Cmd: exec.CommandContext(ctx, cmd, args...), CmdTreeOptions: CmdTreeOptions{Interactive: true}}
-	process.Cmd.Stdin = os.Stdin
-	process.Cmd.Stdout = os.Stdout
-	process.Cmd.Stderr = os.Stderr
-	return execCmdTree(process)
+	return withRecorder(ctx, RunArgs{Cmd: cmd, Args: args}, func() (RunResult, error) {
+		process := CmdTree{Cmd: exec.CommandContext(ctx, cmd, args...), CmdTreeOptions: CmdTreeOptions{Interactive: true}}
+		process.Cmd.Stdin = os.Stdin
+		process.Cmd.Stdout = os.Stdout
+		process.Cmd.Stderr = os.Stderr
+		return execCmdTree(process)
+	})
 }
 
 // RunCommandWithShellAndEnvAndCwd runs your command, with a custom 'env' and 'cwd'.
 // Returns the exit code of the program, the stdout, the stderr and any error, if applicable.
 func RunCommandWithShellAndEnvAndCwd(ctx context.Context, cmd string, args []string, env []string, cwd string) (RunResult, error) {
-	process, err := newCmdTree(ctx, cmd, args, true)
-	if err != nil {
-		return NewRunResult(-1, "", ""), err
-	}
+	return withRecorder(ctx, RunArgs{Cmd: cmd, Args: args, Env: env, Cwd: cwd}, func() (RunResult, error) {
+		process, err := newCmdTree(ctx, cmd, args, true)
+		if err != nil {
+			return NewRunResult(-1, "", ""), err
+		}
 
-	process.Cmd.Dir = cwd
-	process.Env = appendEnv(env)
+		process.Cmd.Dir = cwd
+		process.Env = appendEnv(env)
 
-	return execCmdTree(process)
+		return execCmdTree(process)
+	})
 }
 
 // RunCommandList runs a list of commands in shell.
 // The command list is constructed using '&&' operator, so the first failing command causes the whole list run to fail.
 func RunCommandList(ctx context.Context, commands []string, env []string, cwd string) (RunResult, error) {
-	process, err := newCmdTree(ctx, "", commands, true)
-	if err != nil {
-		return NewRunResult(-1, "", ""), err
-	}
+	return withRecorder(ctx, RunArgs{Args: commands, Env: env, Cwd: cwd}, func() (RunResult, error) {
+		process, err := newCmdTree(ctx, "", commands, true)
+		if err != nil {
+			return NewRunResult(-1, "", ""), err
+		}
 
-	process.Cmd.Dir = cwd
-	process.Env = appendEnv(env)
+		process.Cmd.Dir = cwd
+		process.Env = appendEnv(env)
 
-	return execCmdTree(process)
+		return execCmdTree(process)
+	})
 }
 
 func execCmdTree(process CmdTree) (RunResult, error) {
@@ -188,6 +215,15 @@ type RunArgs struct {
 	// NOTE: RunResult.Stderr will still contain stderr output.
 	Stderr io.Writer
 
+	// Stdout will receive a copy of the text written to Stdout by
+	// the command.
+	// NOTE: RunResult.Stdout will still contain stdout output.
+	Stdout io.Writer
+
+	// Stdin is read by the command as its standard input. When nil, the command
+	// receives no input.
+	Stdin io.Reader
+
 	// Debug will `log.Printf` the command and it's results after it completes.
 	Debug bool
 
@@ -211,6 +247,12 @@ func (rr RunResult) String() string {
 // NOTE: on Windows the command will automatically be run within a shell. This means .bat/.cmd
 // file based commands should just work.
 func RunWithResult(ctx context.Context, args RunArgs) (RunResult, error) {
+	return withRecorder(ctx, args, func() (RunResult, error) {
+		return runWithResult(ctx, args)
+	})
+}
+
+func runWithResult(ctx context.Context, args RunArgs) (RunResult, error) {
 	// use the shell on Windows since most commands are actually just batch files wrapping
 	// real commands. And even if they're not, this will work fine without having to do any
 	// probing or checking.
@@ -230,8 +272,17 @@ func RunWithResult(ctx context.Context, args RunArgs) (RunResult, error) {
 		cmd.Stderr = &stderr
 	}
 
-	cmd.Stdout = &stdout
-	cmd.Stdin = &bytes.Buffer{}
+	if args.Stdout != nil {
+		cmd.Stdout = io.MultiWriter(args.Stdout, &stdout)
+	} else {
+		cmd.Stdout = &stdout
+	}
+
+	if args.Stdin != nil {
+		cmd.Stdin = args.Stdin
+	} else {
+		cmd.Stdin = &bytes.Buffer{}
+	}
 	cmd.Env = appendEnv(args.Env)
 
 	log.Printf("RunWithResult exec: '%s %s'", args.Cmd, strings.Join(args.Args, " "))
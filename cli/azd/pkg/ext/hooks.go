@@ -0,0 +1,283 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package ext
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/executil"
+)
+
+// The names of the lifecycle hooks that can be declared in azure.yaml.
+const (
+	PreProvisionHook  = "preprovision"
+	PostProvisionHook = "postprovision"
+	PreDeployHook     = "predeploy"
+	PostDeployHook    = "postdeploy"
+	PreRestoreHook    = "prerestore"
+	PostRestoreHook   = "postrestore"
+)
+
+// ProjectHooks are the hooks which may be declared at the root of azure.yaml.
+var ProjectHooks = []string{
+	PreProvisionHook, PostProvisionHook, PreDeployHook, PostDeployHook, PreRestoreHook, PostRestoreHook,
+}
+
+// ServiceHooks are the hooks which may be declared on a service in azure.yaml.
+var ServiceHooks = []string{
+	PreDeployHook, PostDeployHook, PreRestoreHook, PostRestoreHook,
+}
+
+type ShellType string
+
+const (
+	ShellTypeSh   ShellType = "sh"
+	ShellTypePwsh ShellType = "pwsh"
+)
+
+type HookConfig struct {
+	// The shell used to run the script, either `sh` or `pwsh`. When omitted, `pwsh` is used for `.ps1` files and on
+	// Windows, `sh` otherwise.
+	Shell ShellType `yaml:"shell,omitempty"`
+	// Either an inline script or a path to a script file, relative to the working directory of the hook
+	Run string `yaml:"run"`
+	// When true, a failure of the hook is reported but does not stop the command
+	ContinueOnError bool `yaml:"continueOnError,omitempty"`
+	// The working directory of the hook, relative to the project or service that declares it
+	Cwd string `yaml:"cwd,omitempty"`
+}
+
+// ValidateHooks ensures that every hook in hooks is one of the allowed hook names and has a script to run.
+func ValidateHooks(hooks map[string]*HookConfig, allowed []string) error {
+	names := make([]string, 0, len(hooks))
+	for name := range hooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		known := false
+		for _, allowedName := range allowed {
+			if name == allowedName {
+				known = true
+				break
+			}
+		}
+
+		if !known {
+			return fmt.Errorf("unsupported hook '%s', supported hooks are: %s", name, strings.Join(allowed, ", "))
+		}
+
+		hook := hooks[name]
+		if hook == nil || strings.TrimSpace(hook.Run) == "" {
+			return fmt.Errorf("hook '%s' does not specify a script to run", name)
+		}
+
+		switch hook.Shell {
+		case "", ShellTypeSh, ShellTypePwsh:
+		default:
+			return fmt.Errorf("hook '%s' uses unsupported shell '%s', supported shells are: sh, pwsh", name, hook.Shell)
+		}
+	}
+
+	return nil
+}
+
+type HooksRunnerArgs struct {
+	// Output receives the output of the hook scripts. When nil, output is discarded.
+	Output io.Writer
+	// RunWithResultFn allows us to stub out the command execution for testing
+	RunWithResultFn func(ctx context.Context, args executil.RunArgs) (executil.RunResult, error)
+}
+
+// HooksRunner runs the lifecycle hooks declared by a project or a service.
+type HooksRunner struct {
+	hooks           map[string]*HookConfig
+	cwd             string
+	env             *environment.Environment
+	output          io.Writer
+	runWithResultFn func(ctx context.Context, args executil.RunArgs) (executil.RunResult, error)
+}
+
+func NewHooksRunner(hooks map[string]*HookConfig, cwd string, env *environment.Environment, args HooksRunnerArgs) *HooksRunner {
+	if args.RunWithResultFn == nil {
+		args.RunWithResultFn = executil.RunWithResult
+	}
+
+	if args.Output == nil {
+		args.Output = io.Discard
+	}
+
+	return &HooksRunner{
+		hooks:           hooks,
+		cwd:             cwd,
+		env:             env,
+		output:          args.Output,
+		runWithResultFn: args.RunWithResultFn,
+	}
+}
+
+// Run runs the hook with the given name, if it has been declared. When the hook fails and it is not
+// configured to continue on error, an error is returned.
+func (h *HooksRunner) Run(ctx context.Context, name string) error {
+	hook, has := h.hooks[name]
+	if !has || hook == nil {
+		return nil
+	}
+
+	cwd := h.cwd
+	if hook.Cwd != "" {
+		cwd = filepath.Join(h.cwd, hook.Cwd)
+	}
+
+	shell := hook.Shell
+	scriptPath, isFile := scriptFilePath(cwd, hook.Run)
+
+	if shell == "" {
+		if runtime.GOOS == "windows" || (isFile && strings.EqualFold(filepath.Ext(scriptPath), ".ps1")) {
+			shell = ShellTypePwsh
+		} else {
+			shell = ShellTypeSh
+		}
+	}
+
+	// Inline scripts are written to a temporary file so they can be passed to the shell without having to deal
+	// with quoting rules of each platform.
+	if !isFile {
+		tempScript, err := createTempScript(hook.Run, shell)
+		if err != nil {
+			return fmt.Errorf("preparing %s hook: %w", name, err)
+		}
+		defer os.Remove(tempScript)
+
+		scriptPath = tempScript
+	}
+
+	var args []string
+	switch shell {
+	case ShellTypePwsh:
+		args = []string{"-NoProfile", "-NonInteractive", "-File", scriptPath}
+	default:
+		args = []string{scriptPath}
+	}
+
+	env, err := h.environ(ctx)
+	if err != nil {
+		return fmt.Errorf("preparing %s hook: %w", name, err)
+	}
+
+	log.Printf("running %s hook with %s in %s", name, shell, cwd)
+
+	_, err = h.runWithResultFn(ctx, executil.RunArgs{
+		Cmd:         string(shell),
+		Args:        args,
+		Cwd:         cwd,
+		Env:         env,
+		Stdout:      h.output,
+		Stderr:      h.output,
+		EnrichError: true,
+	})
+
+	if err != nil {
+		if hook.ContinueOnError {
+			fmt.Fprintf(h.output, "warning: %s hook failed, continuing since continueOnError is set: %v\n", name, err)
+			return nil
+		}
+
+		return fmt.Errorf("running %s hook: %w", name, err)
+	}
+
+	return nil
+}
+
+// environ returns the environment values as a list of KEY=VALUE pairs, which are made available to the hook scripts.
+// Values referencing secrets are resolved.
+func (h *HooksRunner) environ(ctx context.Context) ([]string, error) {
+	if h.env == nil {
+		return nil, nil
+	}
+
+	values, err := h.env.ResolvedValues(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	envs := make([]string, 0, len(values))
+	for k, v := range values {
+		envs = append(envs, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	sort.Strings(envs)
+	return envs, nil
+}
+
+// scriptFilePath returns the path to the script referenced by run when run is the path to an existing script file.
+func scriptFilePath(cwd string, run string) (string, bool) {
+	run = strings.TrimSpace(run)
+	if strings.ContainsAny(run, "\n") {
+		return "", false
+	}
+
+	switch strings.ToLower(filepath.Ext(run)) {
+	case ".sh", ".ps1":
+	default:
+		return "", false
+	}
+
+	path := run
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cwd, run)
+	}
+
+	if stat, err := os.Stat(path); err == nil && !stat.IsDir() {
+		return path, true
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("failed checking for hook script %s: %v", path, err)
+	}
+
+	return "", false
+}
+
+// Inline scripts are written to temporary files which only the current user can read, write and run.
+const tempScriptPermission os.FileMode = 0700
+
+func createTempScript(script string, shell ShellType) (string, error) {
+	ext := ".sh"
+	if shell == ShellTypePwsh {
+		ext = ".ps1"
+	}
+
+	file, err := os.CreateTemp("", "azd-hook-*"+ext)
+	if err != nil {
+		return "", fmt.Errorf("creating script file: %w", err)
+	}
+
+	if _, err := file.WriteString(script); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", fmt.Errorf("writing script file: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("writing script file: %w", err)
+	}
+
+	if err := os.Chmod(file.Name(), tempScriptPermission); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("marking script executable: %w", err)
+	}
+
+	return file.Name(), nil
+}
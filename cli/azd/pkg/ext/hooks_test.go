@@ -0,0 +1,172 @@
+package ext
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/executil"
+	"github.com/azure/azure-dev/cli/azd/pkg/osutil"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ValidateHooks(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		err := ValidateHooks(map[string]*HookConfig{
+			PreProvisionHook: {Run: "echo hello"},
+			PostDeployHook:   {Run: "./post.ps1", Shell: ShellTypePwsh},
+		}, ProjectHooks)
+		require.NoError(t, err)
+	})
+
+	t.Run("UnknownHook", func(t *testing.T) {
+		err := ValidateHooks(map[string]*HookConfig{
+			PreProvisionHook: {Run: "echo hello"},
+		}, ServiceHooks)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unsupported hook 'preprovision'")
+	})
+
+	t.Run("MissingRun", func(t *testing.T) {
+		err := ValidateHooks(map[string]*HookConfig{
+			PreDeployHook: {Shell: ShellTypeSh},
+		}, ProjectHooks)
+		require.Error(t, err)
+	})
+
+	t.Run("UnknownShell", func(t *testing.T) {
+		err := ValidateHooks(map[string]*HookConfig{
+			PreDeployHook: {Run: "echo hello", Shell: "cmd"},
+		}, ProjectHooks)
+		require.Error(t, err)
+	})
+}
+
+func Test_HooksRunner_Run(t *testing.T) {
+	cwd := t.TempDir()
+	env := environment.Environment{
+		Values: map[string]string{
+			"AZURE_ENV_NAME": "dev",
+			"AZURE_LOCATION": "westus2",
+		},
+	}
+
+	t.Run("NotConfigured", func(t *testing.T) {
+		runner := NewHooksRunner(map[string]*HookConfig{}, cwd, &env, HooksRunnerArgs{
+			RunWithResultFn: func(ctx context.Context, args executil.RunArgs) (executil.RunResult, error) {
+				t.Fatal("no hook should have been run")
+				return executil.RunResult{}, nil
+			},
+		})
+
+		require.NoError(t, runner.Run(context.Background(), PreDeployHook))
+	})
+
+	t.Run("InlineScript", func(t *testing.T) {
+		ran := false
+		hooks := map[string]*HookConfig{
+			PreDeployHook: {Run: "echo $AZURE_ENV_NAME", Shell: ShellTypeSh, Cwd: "api"},
+		}
+
+		runner := NewHooksRunner(hooks, cwd, &env, HooksRunnerArgs{
+			RunWithResultFn: func(ctx context.Context, args executil.RunArgs) (executil.RunResult, error) {
+				ran = true
+
+				require.Equal(t, "sh", args.Cmd)
+				require.Equal(t, filepath.Join(cwd, "api"), args.Cwd)
+				require.Equal(t, []string{"AZURE_ENV_NAME=dev", "AZURE_LOCATION=westus2"}, args.Env)
+				require.Len(t, args.Args, 1)
+
+				script, err := os.ReadFile(args.Args[0])
+				require.NoError(t, err)
+				require.Equal(t, "echo $AZURE_ENV_NAME", string(script))
+
+				return executil.RunResult{}, nil
+			},
+		})
+
+		require.NoError(t, runner.Run(context.Background(), PreDeployHook))
+		require.True(t, ran)
+	})
+
+	t.Run("ScriptFile", func(t *testing.T) {
+		scriptPath := filepath.Join(cwd, "predeploy.ps1")
+		require.NoError(t, os.WriteFile(scriptPath, []byte("Write-Host 'hello'"), osutil.PermissionFile))
+
+		ran := false
+		hooks := map[string]*HookConfig{
+			PreDeployHook: {Run: "predeploy.ps1"},
+		}
+
+		runner := NewHooksRunner(hooks, cwd, &env, HooksRunnerArgs{
+			RunWithResultFn: func(ctx context.Context, args executil.RunArgs) (executil.RunResult, error) {
+				ran = true
+
+				require.Equal(t, "pwsh", args.Cmd)
+				require.Equal(t, []string{"-NoProfile", "-NonInteractive", "-File", scriptPath}, args.Args)
+
+				return executil.RunResult{}, nil
+			},
+		})
+
+		require.NoError(t, runner.Run(context.Background(), PreDeployHook))
+		require.True(t, ran)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		hooks := map[string]*HookConfig{
+			PostProvisionHook: {Run: "exit 1", Shell: ShellTypeSh},
+		}
+
+		runner := NewHooksRunner(hooks, cwd, &env, HooksRunnerArgs{
+			RunWithResultFn: func(ctx context.Context, args executil.RunArgs) (executil.RunResult, error) {
+				return executil.RunResult{ExitCode: 1}, errors.New("exit code: 1")
+			},
+		})
+
+		err := runner.Run(context.Background(), PostProvisionHook)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "postprovision")
+	})
+
+	t.Run("ContinueOnError", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		hooks := map[string]*HookConfig{
+			PostProvisionHook: {Run: "exit 1", Shell: ShellTypeSh, ContinueOnError: true},
+		}
+
+		runner := NewHooksRunner(hooks, cwd, &env, HooksRunnerArgs{
+			Output: output,
+			RunWithResultFn: func(ctx context.Context, args executil.RunArgs) (executil.RunResult, error) {
+				return executil.RunResult{ExitCode: 1}, errors.New("exit code: 1")
+			},
+		})
+
+		require.NoError(t, runner.Run(context.Background(), PostProvisionHook))
+		require.Contains(t, output.String(), "warning: postprovision hook failed")
+	})
+}
+
+func Test_HooksRunner_RunSh(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on windows")
+	}
+
+	output := &bytes.Buffer{}
+	env := environment.Environment{
+		Values: map[string]string{"AZURE_ENV_NAME": "dev"},
+	}
+	hooks := map[string]*HookConfig{
+		PreRestoreHook: {Run: "echo \"env: $AZURE_ENV_NAME\"", Shell: ShellTypeSh},
+	}
+
+	runner := NewHooksRunner(hooks, t.TempDir(), &env, HooksRunnerArgs{Output: output})
+
+	require.NoError(t, runner.Run(context.Background(), PreRestoreHook))
+	require.Equal(t, "env: dev\n", output.String())
+}
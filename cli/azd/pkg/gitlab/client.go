@@ -0,0 +1,153 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+// Package gitlab provides a client for the GitLab REST APIs used to configure GitLab CI/CD.
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/httpUtil"
+)
+
+// TokenEnvVarName is the environment variable the GitLab CLI reads the personal access token from, which azd uses as
+// well.
+const TokenEnvVarName = "GITLAB_TOKEN"
+
+var ErrNotFound = errors.New("not found")
+
+// GitLabError is returned when a GitLab REST API fails.
+type GitLabError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *GitLabError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("gitlab request failed with status %d", e.StatusCode)
+	}
+
+	return fmt.Sprintf("gitlab request failed with status %d: %s", e.StatusCode, e.Message)
+}
+
+// Variable is a CI/CD variable of a project, exposed to jobs as an environment variable.
+type Variable struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	// Masked variables are hidden in job logs, which requires values of at least 8 characters, see CanMask.
+	Masked bool `json:"masked"`
+	// Protected variables are only exposed to pipelines running on protected branches and tags.
+	Protected bool `json:"protected"`
+}
+
+// The values GitLab can mask, which are single line values of at least 8 characters from the Base64 alphabet, or `@`,
+// `:`, `.`, `~`, `-` and `_`.
+var maskableValueRegex = regexp.MustCompile(`^[A-Za-z0-9+/=@:.~_-]{8,}$`)
+
+// CanMask returns true when the value of a variable can be masked in job logs.
+func CanMask(value string) bool {
+	return maskableValueRegex.MatchString(value)
+}
+
+// Client calls the REST APIs of a GitLab instance, authenticated with a personal access token.
+type Client struct {
+	baseUrl string
+	token   string
+}
+
+// NewClient creates a Client for the instance at baseUrl, such as https://gitlab.com.
+func NewClient(baseUrl string, token string) *Client {
+	return &Client{
+		baseUrl: strings.TrimSuffix(baseUrl, "/"),
+		token:   token,
+	}
+}
+
+// CreateOrUpdateVariable creates a CI/CD variable of the project at projectPath, or updates the existing variable with
+// the same key.
+func (c *Client) CreateOrUpdateVariable(ctx context.Context, projectPath string, variable Variable) error {
+	body := struct {
+		Variable
+		VariableType string `json:"variable_type"`
+	}{Variable: variable, VariableType: "env_var"}
+
+	projectUrl := c.url("projects/" + url.PathEscape(projectPath))
+
+	err := c.send(ctx, http.MethodPut, fmt.Sprintf("%s/variables/%s", projectUrl, url.PathEscape(variable.Key)), body, nil)
+	if errors.Is(err, ErrNotFound) {
+		err = c.send(ctx, http.MethodPost, projectUrl+"/variables", body, nil)
+	}
+
+	if err != nil {
+		return fmt.Errorf("saving variable %s: %w", variable.Key, err)
+	}
+
+	return nil
+}
+
+func (c *Client) url(path string) string {
+	return fmt.Sprintf("%s/api/v4/%s", c.baseUrl, path)
+}
+
+func (c *Client) send(ctx context.Context, method string, requestUrl string, body interface{}, result interface{}) error {
+	request := &httpUtil.HttpRequestMessage{
+		Url:    requestUrl,
+		Method: method,
+		Headers: map[string]string{
+			"PRIVATE-TOKEN": c.token,
+		},
+	}
+
+	if body != nil {
+		bytes, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshalling request: %w", err)
+		}
+		request.Body = string(bytes)
+	}
+
+	res, err := httpUtil.GetHttpUtilFromContext(ctx).Send(request)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case res.Status == http.StatusNotFound:
+		return ErrNotFound
+	case res.Status == http.StatusUnauthorized:
+		return &GitLabError{
+			StatusCode: res.Status,
+			Message:    fmt.Sprintf("the personal access token is not valid, or has expired. Set a valid token in %s", TokenEnvVarName),
+		}
+	case res.Status < 200 || res.Status > 299:
+		// The message is a string, or an object listing the errors of each field.
+		var wire struct {
+			Message json.RawMessage `json:"message"`
+		}
+		_ = json.Unmarshal(res.Body, &wire)
+
+		var message string
+		if err := json.Unmarshal(wire.Message, &message); err != nil {
+			message = string(wire.Message)
+		}
+
+		return &GitLabError{StatusCode: res.Status, Message: message}
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(res.Body, result); err != nil {
+		return fmt.Errorf("unmarshalling response: %w", err)
+	}
+
+	return nil
+}
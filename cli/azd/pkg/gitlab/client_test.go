@@ -0,0 +1,106 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/httpUtil"
+	"github.com/stretchr/testify/require"
+)
+
+// newStubServer starts a local server standing in for GitLab, which records the requests it receives and responds
+// with the status mapped to "METHOD path", or 404.
+func newStubServer(t *testing.T, statuses map[string]int) (*Client, context.Context, *[]string, map[string]map[string]interface{}) {
+	requests := []string{}
+	bodies := map[string]map[string]interface{}{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "token", r.Header.Get("PRIVATE-TOKEN"))
+
+		key := r.Method + " " + r.URL.EscapedPath()
+		requests = append(requests, key)
+
+		if body, err := io.ReadAll(r.Body); err == nil && len(body) > 0 {
+			var parsed map[string]interface{}
+			require.NoError(t, json.Unmarshal(body, &parsed))
+			bodies[key] = parsed
+		}
+
+		status, has := statuses[key]
+		if !has {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"404 Variable Not Found"}`))
+			return
+		}
+
+		w.WriteHeader(status)
+		if status == http.StatusBadRequest {
+			_, _ = w.Write([]byte(`{"message":{"value":["is invalid"]}}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	ctx := context.WithValue(context.Background(), environment.HttpUtilContextKey, httpUtil.NewHttpUtil())
+	return NewClient(server.URL+"/", "token"), ctx, &requests, bodies
+}
+
+func TestCreateOrUpdateVariableUpdatesExisting(t *testing.T) {
+	client, ctx, requests, bodies := newStubServer(t, map[string]int{
+		"PUT /api/v4/projects/contoso%2Fweb%2Ftodo/variables/AZURE_CLIENT_SECRET": http.StatusOK,
+	})
+
+	err := client.CreateOrUpdateVariable(ctx, "contoso/web/todo", Variable{Key: "AZURE_CLIENT_SECRET", Value: "secret-value", Masked: true})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"PUT /api/v4/projects/contoso%2Fweb%2Ftodo/variables/AZURE_CLIENT_SECRET"}, *requests)
+	require.Equal(t, map[string]interface{}{
+		"key":           "AZURE_CLIENT_SECRET",
+		"value":         "secret-value",
+		"masked":        true,
+		"protected":     false,
+		"variable_type": "env_var",
+	}, bodies["PUT /api/v4/projects/contoso%2Fweb%2Ftodo/variables/AZURE_CLIENT_SECRET"])
+}
+
+func TestCreateOrUpdateVariableCreatesMissing(t *testing.T) {
+	client, ctx, requests, bodies := newStubServer(t, map[string]int{
+		"POST /api/v4/projects/contoso%2Ftodo/variables": http.StatusCreated,
+	})
+
+	err := client.CreateOrUpdateVariable(ctx, "contoso/todo", Variable{Key: "AZURE_LOCATION", Value: "westus2", Protected: true})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		"PUT /api/v4/projects/contoso%2Ftodo/variables/AZURE_LOCATION",
+		"POST /api/v4/projects/contoso%2Ftodo/variables",
+	}, *requests)
+	require.Equal(t, true, bodies["POST /api/v4/projects/contoso%2Ftodo/variables"]["protected"])
+}
+
+func TestCreateOrUpdateVariableError(t *testing.T) {
+	client, ctx, _, _ := newStubServer(t, map[string]int{
+		"PUT /api/v4/projects/contoso%2Ftodo/variables/AZURE_LOCATION": http.StatusBadRequest,
+	})
+
+	err := client.CreateOrUpdateVariable(ctx, "contoso/todo", Variable{Key: "AZURE_LOCATION", Value: "westus2"})
+
+	var gitLabErr *GitLabError
+	require.ErrorAs(t, err, &gitLabErr)
+	require.Equal(t, http.StatusBadRequest, gitLabErr.StatusCode)
+	require.Equal(t, `{"value":["is invalid"]}`, gitLabErr.Message)
+}
+
+func TestCanMask(t *testing.T) {
+	require.True(t, CanMask("abc.DEF~123_-"))
+	require.False(t, CanMask("short"))
+	require.False(t, CanMask("has spaces in it"))
+	require.False(t, CanMask(`{"clientSecret":"value"}`))
+}
@@ -0,0 +1,89 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package gitlab
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DefaultBaseUrl is the URL of GitLab.com, which is used unless the URL of a self-managed instance is set.
+const DefaultBaseUrl = "https://gitlab.com"
+
+var ErrRemoteHostIsNotGitLab = errors.New("not a gitlab host")
+
+// git@{host}:{namespace}/{project}.git, the scp-like form of SSH remotes.
+var gitLabRemoteScpUrlRegex = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+
+// Project is a project, the git repository, hosted by a GitLab instance.
+type Project struct {
+	// BaseUrl is the URL of the instance, which the REST APIs are relative to.
+	BaseUrl string
+	// Path is the full path of the project, including its groups and subgroups, such as `contoso/web/app`.
+	Path string
+}
+
+// WebUrl returns the URL of the page of the project.
+func (p Project) WebUrl() string {
+	return fmt.Sprintf("%s/%s", p.BaseUrl, p.Path)
+}
+
+// GetProjectForRemote returns the project a remote URL refers to, or ErrRemoteHostIsNotGitLab when the remote is not
+// hosted by the instance at baseUrl, which is GitLab.com when empty.
+func GetProjectForRemote(remoteUrl string, baseUrl string) (Project, error) {
+	if baseUrl == "" {
+		baseUrl = DefaultBaseUrl
+	}
+	baseUrl = strings.TrimSuffix(baseUrl, "/")
+
+	base, err := url.Parse(baseUrl)
+	if err != nil || base.Host == "" {
+		return Project{}, fmt.Errorf("invalid gitlab url %s", baseUrl)
+	}
+
+	var host, path string
+	remoteUrl = strings.TrimSpace(remoteUrl)
+
+	if strings.HasPrefix(remoteUrl, "https://") || strings.HasPrefix(remoteUrl, "http://") || strings.HasPrefix(remoteUrl, "ssh://") {
+		remote, err := url.Parse(remoteUrl)
+		if err != nil {
+			return Project{}, ErrRemoteHostIsNotGitLab
+		}
+
+		host, path = remote.Hostname(), remote.Path
+		// Instances installed under a relative URL serve their HTTP remotes from it as well.
+		if remote.Scheme != "ssh" {
+			if !strings.HasPrefix(path, base.Path+"/") {
+				return Project{}, ErrRemoteHostIsNotGitLab
+			}
+			path = strings.TrimPrefix(path, base.Path)
+		}
+	} else if captures := gitLabRemoteScpUrlRegex.FindStringSubmatch(remoteUrl); captures != nil {
+		host, path = captures[1], captures[2]
+	} else {
+		return Project{}, ErrRemoteHostIsNotGitLab
+	}
+
+	if !strings.EqualFold(host, base.Hostname()) {
+		return Project{}, ErrRemoteHostIsNotGitLab
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	// Projects always belong to a user or a group.
+	if !strings.Contains(path, "/") {
+		return Project{}, ErrRemoteHostIsNotGitLab
+	}
+
+	return Project{BaseUrl: baseUrl, Path: path}, nil
+}
+
+// IsGitLabRemote returns true when the remote URL refers to a project hosted by the instance at baseUrl, which is
+// GitLab.com when empty.
+func IsGitLabRemote(remoteUrl string, baseUrl string) bool {
+	_, err := GetProjectForRemote(remoteUrl, baseUrl)
+	return err == nil
+}
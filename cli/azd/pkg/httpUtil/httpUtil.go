@@ -42,9 +42,10 @@ func (hu *httpUtil) Send(req *HttpRequestMessage) (*HttpResponseMessage, error)
 	request.Header.Add("Content-Type", "application/json")
 	request.Header.Add("Accept", "application/json")
 
+	// Headers of the request replace the defaults, so the content type can be overridden.
 	if req.Headers != nil {
 		for k, v := range req.Headers {
-			request.Header.Add(k, v)
+			request.Header.Set(k, v)
 		}
 	}
 
@@ -60,9 +61,16 @@ func (hu *httpUtil) Send(req *HttpRequestMessage) (*HttpResponseMessage, error)
 		return nil, fmt.Errorf("reading response")
 	}
 
+	// Only the first value of each header is kept, using the canonical form of its name.
+	headers := map[string]string{}
+	for k := range response.Header {
+		headers[k] = response.Header.Get(k)
+	}
+
 	responseMessage := &HttpResponseMessage{
-		Status: response.StatusCode,
-		Body:   responseBytes,
+		Headers: headers,
+		Status:  response.StatusCode,
+		Body:    responseBytes,
 	}
 
 	return responseMessage, nil
@@ -9,6 +9,8 @@ import (
 type DeploymentTarget interface {
 	// Deploy a given template with a set of parameters.
 	Deploy(ctx context.Context, templatePath string, parametersPath string) error
+	// WhatIf previews the changes deploying a given template with a set of parameters would make.
+	WhatIf(ctx context.Context, templatePath string, parametersPath string) (tools.AzCliWhatIfResult, error)
 	// GetDeployment fetches the result of the most recent deployment.
 	GetDeployment(ctx context.Context) (tools.AzCliDeployment, error)
 }
@@ -50,6 +52,10 @@ func (target *rgTarget) Deploy(ctx context.Context, bicepPath string, parameters
 	return err
 }
 
+func (target *rgTarget) WhatIf(ctx context.Context, bicepPath string, parametersPath string) (tools.AzCliWhatIfResult, error) {
+	return target.azCli.WhatIfDeployToResourceGroup(ctx, target.subscriptionId, target.resourceGroupName, target.deploymentName, bicepPath, parametersPath)
+}
+
 func (target *rgTarget) GetDeployment(ctx context.Context) (tools.AzCliDeployment, error) {
 	return target.azCli.GetResourceGroupDeployment(ctx, target.subscriptionId, target.resourceGroupName, target.deploymentName)
 }
@@ -59,6 +65,10 @@ func (target *subTarget) Deploy(ctx context.Context, bicepPath string, parameter
 	return err
 }
 
+func (target *subTarget) WhatIf(ctx context.Context, bicepPath string, parametersPath string) (tools.AzCliWhatIfResult, error) {
+	return target.azCli.WhatIfDeployToSubscription(ctx, target.subscriptionId, target.deploymentName, bicepPath, parametersPath, target.location)
+}
+
 func (target *subTarget) GetDeployment(ctx context.Context) (tools.AzCliDeployment, error) {
 	return target.azCli.GetSubscriptionDeployment(ctx, target.subscriptionId, target.deploymentName)
 }
@@ -0,0 +1,463 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package provisioning
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/azure/azure-dev/cli/azd/pkg/azureutil"
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/iac/bicep"
+	"github.com/azure/azure-dev/cli/azd/pkg/infra"
+	"github.com/azure/azure-dev/cli/azd/pkg/osutil"
+	"github.com/azure/azure-dev/cli/azd/pkg/spin"
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+)
+
+// BicepProvider provisions infrastructure with a Bicep template, deployed at subscription scope.
+type BicepProvider struct {
+	azCli     tools.AzCli
+	bicepCli  tools.BicepCli
+	azdCtx    *environment.AzdContext
+	env       *environment.Environment
+	options   Options
+	prompters Prompters
+
+	// The parameters whose values are only kept in memory, such as secure values when the environment has no Key Vault
+	// to save them to
+	inMemoryParameters map[string]interface{}
+}
+
+type bicepDeploymentPlan struct {
+	location       string
+	templatePath   string
+	parametersPath string
+	template       bicep.CompiledTemplate
+	// Parameters which are not in the parameters file, since their values are only kept in memory
+	inMemoryParameters map[string]interface{}
+}
+
+func NewBicepProvider(
+	azCli tools.AzCli, azdCtx *environment.AzdContext, env *environment.Environment, options Options, prompters Prompters,
+) *BicepProvider {
+	return &BicepProvider{
+		azCli:     azCli,
+		bicepCli:  tools.NewBicepCli(azCli),
+		azdCtx:    azdCtx,
+		env:       env,
+		options:   options,
+		prompters: prompters,
+
+		inMemoryParameters: map[string]interface{}{},
+	}
+}
+
+func (p *BicepProvider) Name() string {
+	return "Bicep"
+}
+
+func (p *BicepProvider) RequiredExternalTools() []tools.ExternalTool {
+	return []tools.ExternalTool{p.azCli, p.bicepCli}
+}
+
+func (p *BicepProvider) templatePath() string {
+	return filepath.Join(p.options.Path, p.options.Module+".bicep")
+}
+
+func (p *BicepProvider) parametersTemplatePath() string {
+	return filepath.Join(p.options.Path, p.options.Module+".parameters.json")
+}
+
+func (p *BicepProvider) parametersPath() string {
+	return p.azdCtx.BicepParametersFilePath(p.env.GetEnvName(), p.options.Module)
+}
+
+// Init copies the parameter template file to the environment working directory, substituting references to
+// environment values. Parameters referencing values which are only kept in memory are left out of the file.
+func (p *BicepProvider) Init(ctx context.Context) error {
+	parametersBytes, err := ioutil.ReadFile(p.parametersTemplatePath())
+	if err != nil {
+		return fmt.Errorf("reading parameter file template: %w", err)
+	}
+	replaced, inMemory, err := environment.SubstituteBicepParameters(string(parametersBytes), p.env)
+	if err != nil {
+		return fmt.Errorf("substituting parameter file: %w", err)
+	}
+
+	p.inMemoryParameters = map[string]interface{}{}
+	for name, parameter := range inMemory {
+		p.inMemoryParameters[name] = parameter
+	}
+
+	replaced, err = environment.WithKeyVaultReferences(replaced, func(vaultName string) (string, error) {
+		vault, err := p.azCli.GetKeyVault(ctx, p.env.GetSubscriptionId(), vaultName)
+		return vault.Id, err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p.parametersPath()), osutil.PermissionDirectory); err != nil {
+		return fmt.Errorf("creating environment directory: %w", err)
+	}
+
+	err = ioutil.WriteFile(p.parametersPath(), []byte(replaced), osutil.PermissionFile)
+	if err != nil {
+		return fmt.Errorf("writing parameter file: %w", err)
+	}
+
+	return nil
+}
+
+// Plan compiles the template, prompting for any parameters which do not have a value yet, and determines the location
+// used to store the deployment metadata.
+func (p *BicepProvider) Plan(ctx context.Context) (*DeploymentPlan, error) {
+	// Fetch the parameters from the template and ensure we have a value for each one, otherwise
+	// prompt.
+	template, err := bicep.Compile(ctx, p.bicepCli, p.templatePath())
+	if err != nil {
+		return nil, err
+	}
+
+	// When creating a deployment, we need an azure location which is used to store the deployment metadata. This can be
+	// any azure location and the choice doesn't impact what location individual resources in the deployment use. By default
+	// we'll just use whatever value is being passed to the `location` parameter for bicep, and if that's not defined,
+	// we'll prompt the user as to what location they want to use.
+	//
+	// TODO: The UX here could be improved. One problem is the concept of "the location used to store deployment metadata,
+	// but not the resources" is sort of confusing and hard to clearly articulate.
+	var location string
+
+	if len(template.Parameters) > 0 {
+		configuredParameters, err := p.azdCtx.BicepParameters(p.env.GetEnvName(), p.options.Module)
+		if err != nil {
+			return nil, fmt.Errorf("reading existing parameters: %w", err)
+		}
+
+		updatedParameters := false
+		for parameter, value := range template.Parameters {
+			// If this parameter has a default, then there is no need for us to configure it
+			if _, hasDefault := value["defaultValue"]; hasDefault {
+				continue
+			}
+			if _, inMemory := p.inMemoryParameters[parameter]; inMemory {
+				continue
+			}
+			if _, has := configuredParameters[parameter]; !has {
+				// The values of secure parameters are not echoed, and are only saved to the Key Vault of the environment.
+				// Without one, they are only kept in memory for this deployment.
+				parameterType, _ := value["type"].(string)
+				secure := strings.EqualFold(parameterType, "securestring") || strings.EqualFold(parameterType, "secureobject")
+				vaultName := p.env.Values[environment.KeyVaultNameEnvVarName]
+
+				message := fmt.Sprintf("Please enter a value for the '%s' deployment parameter:", parameter)
+				var prompt survey.Prompt = &survey.Input{Message: message}
+				if secure {
+					prompt = &survey.Password{Message: message}
+				}
+
+				var val string
+				if err := p.prompters.AskOne(prompt, &val); err != nil {
+					return nil, fmt.Errorf("prompting for deployment parameter: %w", err)
+				}
+
+				if secure && vaultName == "" {
+					fmt.Printf(
+						"The value of secure parameter '%s' is not saved, since the environment has no Key Vault to save it "+
+							"to. Set %s to save it.\n", parameter, environment.KeyVaultNameEnvVarName)
+					p.inMemoryParameters[parameter] = map[string]interface{}{"value": val}
+					continue
+				}
+
+				saveParameter := true
+				if err := p.prompters.AskOne(&survey.Confirm{
+					Message: "Save the value in the environment for future use",
+				}, &saveParameter); err != nil {
+					return nil, fmt.Errorf("prompting to save deployment parameter: %w", err)
+				}
+
+				// Only references to the Key Vault are written to the parameters file for secure parameters, whose
+				// values are otherwise kept in memory.
+				switch {
+				case saveParameter && secure:
+					reference, err := p.saveSecretParameter(ctx, vaultName, parameter, val)
+					if err != nil {
+						return nil, err
+					}
+
+					configuredParameters[parameter] = reference
+				case secure:
+					p.inMemoryParameters[parameter] = map[string]interface{}{"value": val}
+					continue
+				case saveParameter:
+					configuredParameters[parameter] = val
+					p.env.SetUserValue(parameter, val)
+				default:
+					configuredParameters[parameter] = val
+				}
+
+				updatedParameters = true
+			}
+
+			if parameter == "location" {
+				location = configuredParameters[parameter].(string)
+			}
+		}
+
+		if updatedParameters {
+			if err := p.azdCtx.WriteBicepParameters(p.env.GetEnvName(), p.options.Module, configuredParameters); err != nil {
+				return nil, fmt.Errorf("saving deployment parameters: %w", err)
+			}
+
+			if err := p.env.Save(); err != nil {
+				return nil, fmt.Errorf("writing env file: %w", err)
+			}
+		}
+	}
+
+	for location == "" {
+		// TODO: We will want to store this information somewhere (so we don't have to prompt the
+		// user on every deployment if they don't have a `location` parameter in their bicep file.
+		// When we store it, we should store it /per environment/ not as a property of the entire
+		// project.
+		selected, err := p.prompters.PromptLocation(ctx, "Please select an Azure location to use to store deployment metadata:")
+		if err != nil {
+			return nil, fmt.Errorf("prompting for deployment metadata region: %w", err)
+		}
+
+		location = selected
+	}
+
+	return &DeploymentPlan{
+		Details: bicepDeploymentPlan{
+			location:       location,
+			templatePath:   p.templatePath(),
+			parametersPath: p.parametersPath(),
+			template:       template,
+
+			inMemoryParameters: p.inMemoryParameters,
+		},
+	}, nil
+}
+
+// saveSecretParameter saves the value of a secure parameter as a secret of the Key Vault of the environment, which the
+// environment references, and returns the deployment parameter referencing it.
+func (p *BicepProvider) saveSecretParameter(ctx context.Context, vaultName string, parameter string, value string) (environment.BicepParameterReference, error) {
+	reference := environment.KeyVaultSecretReference{
+		VaultName:  vaultName,
+		SecretName: environment.SecretName(p.env.GetEnvName(), parameter),
+	}
+
+	vault, err := p.azCli.GetKeyVault(ctx, p.env.GetSubscriptionId(), vaultName)
+	if err != nil {
+		return nil, fmt.Errorf("getting key vault to save deployment parameter: %w", err)
+	}
+
+	if err := p.azCli.SetKeyVaultSecret(ctx, vaultName, reference.SecretName, value); err != nil {
+		return nil, fmt.Errorf("saving deployment parameter: %w", err)
+	}
+
+	p.env.SetUserValue(parameter, reference.String())
+
+	return environment.NewBicepParameterReference(vault.Id, reference.SecretName), nil
+}
+
+// Apply deploys the template at subscription scope. The deployment is named after the environment.
+func (p *BicepProvider) Apply(ctx context.Context, plan *DeploymentPlan) (*Deployment, error) {
+	bicepPlan, ok := plan.Details.(bicepDeploymentPlan)
+	if !ok {
+		return nil, errors.New("deployment plan was not created by the bicep provider")
+	}
+
+	parametersPath, remove, err := environment.TemporaryBicepParametersFile(
+		bicepPlan.parametersPath, bicepPlan.inMemoryParameters)
+	if err != nil {
+		return nil, err
+	}
+	defer remove()
+
+	deploymentTarget := bicep.NewSubscriptionDeploymentTarget(p.azCli, bicepPlan.location, p.env.GetSubscriptionId(), p.env.GetEnvName())
+	res, err := bicep.Deploy(ctx, deploymentTarget, bicepPlan.templatePath, parametersPath)
+	if err != nil {
+		return nil, err
+	}
+
+	bicepPlan.template.CanonicalizeDeploymentOutputs(&res.Properties.Outputs)
+
+	return &Deployment{
+		Outputs: convertBicepOutputs(res.Properties.Outputs),
+		Details: res,
+	}, nil
+}
+
+// Preview runs an ARM what-if operation for the subscription deployment of the environment.
+func (p *BicepProvider) Preview(ctx context.Context, plan *DeploymentPlan) (*DeploymentPreview, error) {
+	bicepPlan, ok := plan.Details.(bicepDeploymentPlan)
+	if !ok {
+		return nil, errors.New("deployment plan was not created by the bicep provider")
+	}
+
+	parametersPath, remove, err := environment.TemporaryBicepParametersFile(
+		bicepPlan.parametersPath, bicepPlan.inMemoryParameters)
+	if err != nil {
+		return nil, err
+	}
+	defer remove()
+
+	deploymentTarget := bicep.NewSubscriptionDeploymentTarget(p.azCli, bicepPlan.location, p.env.GetSubscriptionId(), p.env.GetEnvName())
+	res, err := deploymentTarget.WhatIf(ctx, bicepPlan.templatePath, parametersPath)
+	if err != nil {
+		return nil, fmt.Errorf("previewing deployment: %w", err)
+	}
+
+	return newDeploymentPreview(res), nil
+}
+
+// Outputs fetches the outputs of the subscription deployment for the environment.
+func (p *BicepProvider) Outputs(ctx context.Context) (*Deployment, error) {
+	template, err := bicep.Compile(ctx, p.bicepCli, p.templatePath())
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := p.azCli.GetSubscriptionDeployment(ctx, p.env.GetSubscriptionId(), p.env.GetEnvName())
+	if errors.Is(err, tools.ErrDeploymentNotFound) {
+		return nil, &DeploymentNotFoundError{EnvName: p.env.GetEnvName()}
+	} else if err != nil {
+		return nil, fmt.Errorf("fetching latest deployment: %w", err)
+	}
+
+	template.CanonicalizeDeploymentOutputs(&res.Properties.Outputs)
+
+	return &Deployment{
+		Outputs: convertBicepOutputs(res.Properties.Outputs),
+		Details: res,
+	}, nil
+}
+
+// Destroy deletes the resource groups created by the subscription deployment for the environment, as well as the
+// deployment itself.
+func (p *BicepProvider) Destroy(ctx context.Context, options DestroyOptions) (*DestroyResult, error) {
+	resourceGroups, err := azureutil.GetResourceGroupsForDeployment(ctx, p.azCli, p.env.GetSubscriptionId(), p.env.GetEnvName())
+	if err != nil {
+		return nil, fmt.Errorf("discovering resource groups from deployment: %w", err)
+	}
+
+	var allResources []tools.AzCliResource
+
+	for _, resourceGroup := range resourceGroups {
+		resources, err := p.azCli.ListResourceGroupResources(ctx, p.env.GetSubscriptionId(), resourceGroup)
+		if err != nil {
+			return nil, fmt.Errorf("listing resource group %s: %w", resourceGroup, err)
+		}
+
+		allResources = append(allResources, resources...)
+	}
+
+	if len(allResources) > 0 && !options.Force {
+		var ok bool
+		err := p.prompters.AskOne(&survey.Confirm{
+			Message: fmt.Sprintf("This will delete %d resources, are you sure you want to continue?", len(allResources)),
+			Default: false,
+		}, &ok)
+		if err != nil {
+			return nil, fmt.Errorf("prompting for confirmation: %w", err)
+		}
+		if !ok {
+			return nil, nil
+		}
+	}
+
+	// Azure KeyVaults have a "soft delete" functionality (now enabled by default) where a vault may be marked
+	// such that when it is deleted it can be recovered for a period of time. During that time, the name may
+	// not be reused.
+	//
+	// This means that running `az dev provision`, then `az dev infra delete` and finally `az dev provision`
+	// again would lead to a deployment error since the vault name is in use.
+	//
+	// Since that's behavior we'd like to support, we run a purge operation for each KeyVault after
+	// it has been deleted.
+	//
+	// See https://docs.microsoft.com/azure/key-vault/general/key-vault-recovery?tabs=azure-portal#what-are-soft-delete-and-purge-protection
+	// for more information on this feature.
+	var keyVaultsToPurge []string
+
+	for _, resource := range allResources {
+		if resource.Type == string(infra.AzureResourceTypeKeyVault) {
+			vault, err := p.azCli.GetKeyVault(ctx, p.env.GetSubscriptionId(), resource.Name)
+			if err != nil {
+				return nil, fmt.Errorf("listing keyvault %s properties: %w", resource.Name, err)
+			}
+			if vault.Properties.EnableSoftDelete && !vault.Properties.EnablePurgeProtection {
+				keyVaultsToPurge = append(keyVaultsToPurge, resource.Name)
+			}
+		}
+	}
+
+	purgeDelete := options.Purge
+
+	if len(keyVaultsToPurge) > 0 && !purgeDelete {
+		fmt.Printf(""+
+			"This operation will delete %d Key Vaults. These Key Vaults have soft delete enabled allowing them to be recovered for a period \n"+
+			"of time after deletion. During this period, their names may not be reused.\n",
+			len(keyVaultsToPurge))
+		err := p.prompters.AskOne(&survey.Confirm{
+			Message: "Would you like to *permanently* delete these Key Vaults instead, allowing their names to be reused?",
+			Default: false,
+		}, &purgeDelete)
+		if err != nil {
+			return nil, fmt.Errorf("prompting for purge confirmation: %w", err)
+		}
+	}
+
+	// Do the deleting. The calls to `DeleteResourceGroup` and `DeleteSubscriptionDeployment` block
+	// until everything has been deleted which can take a bit, so indicate we are working with a spinner.
+	deleteFn := func() error {
+		for _, resourceGroup := range resourceGroups {
+			if err := p.azCli.DeleteResourceGroup(ctx, p.env.GetSubscriptionId(), resourceGroup); err != nil {
+				return fmt.Errorf("deleting resource group %s: %w", resourceGroup, err)
+			}
+		}
+
+		if purgeDelete {
+			for _, vaultName := range keyVaultsToPurge {
+				err := p.azCli.PurgeKeyVault(ctx, p.env.GetSubscriptionId(), vaultName)
+				if err != nil {
+					return fmt.Errorf("purging key vault %s: %w", vaultName, err)
+				}
+			}
+		}
+
+		if err := p.azCli.DeleteSubscriptionDeployment(ctx, p.env.GetSubscriptionId(), p.env.GetEnvName()); err != nil {
+			return fmt.Errorf("deleting subscription deployment: %w", err)
+		}
+		return nil
+	}
+
+	spinner := spin.NewSpinner("Deleting Azure resources")
+	if err := spinner.Run(deleteFn); err != nil {
+		return nil, fmt.Errorf("destroying: %w", err)
+	}
+
+	return &DestroyResult{}, nil
+}
+
+func convertBicepOutputs(outputs map[string]tools.AzCliDeploymentOutput) map[string]OutputParameter {
+	converted := make(map[string]OutputParameter, len(outputs))
+	for name, output := range outputs {
+		converted[name] = OutputParameter{
+			Type:  output.Type,
+			Value: output.Value,
+		}
+	}
+
+	return converted
+}
@@ -0,0 +1,122 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package provisioning
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+	"github.com/stretchr/testify/require"
+)
+
+const testBicepTemplate = `{
+  "parameters": {
+    "location": {"type": "string"},
+    "dbPassword": {"type": "securestring"}
+  },
+  "outputs": {}
+}`
+
+// fakeKeyVaultAzCli implements the Key Vault calls of saving secure parameters, recording the secrets which are set.
+type fakeKeyVaultAzCli struct {
+	tools.AzCli
+
+	secrets map[string]string
+}
+
+func (cli *fakeKeyVaultAzCli) GetKeyVault(_ context.Context, _ string, vaultName string) (tools.AzCliKeyVault, error) {
+	return tools.AzCliKeyVault{Id: "/vaults/" + vaultName, Name: vaultName}, nil
+}
+
+func (cli *fakeKeyVaultAzCli) SetKeyVaultSecret(_ context.Context, _ string, secretName string, value string) error {
+	cli.secrets[secretName] = value
+	return nil
+}
+
+type fakeBicepCli struct{}
+
+func (cli *fakeBicepCli) Name() string                                   { return "Bicep CLI" }
+func (cli *fakeBicepCli) InstallUrl() string                             { return "" }
+func (cli *fakeBicepCli) CheckInstalled(_ context.Context) (bool, error) { return true, nil }
+
+func (cli *fakeBicepCli) Build(_ context.Context, _ string) (string, error) {
+	return testBicepTemplate, nil
+}
+
+// createTestBicepProvider creates a provider for an environment with a Key Vault, which answers save to the prompt to
+// save the values of parameters.
+func createTestBicepProvider(t *testing.T, save bool) (*BicepProvider, *fakeKeyVaultAzCli) {
+	projectDir := t.TempDir()
+	azdCtx := &environment.AzdContext{}
+	azdCtx.SetProjectDirectory(projectDir)
+	require.NoError(t, azdCtx.NewEnvironment("dev"))
+
+	env := environment.Empty(azdCtx.GetEnvironmentFilePath("dev"))
+	env.SetEnvName("dev")
+	env.SetSubscriptionId("SUBSCRIPTION_ID")
+	env.Values[environment.KeyVaultNameEnvVarName] = "my-vault"
+
+	azCli := &fakeKeyVaultAzCli{secrets: map[string]string{}}
+	provider := NewBicepProvider(azCli, azdCtx, &env, Options{Path: filepath.Join(projectDir, "infra"), Module: "main"},
+		Prompters{
+			AskOne: func(p survey.Prompt, response interface{}) error {
+				switch p.(type) {
+				case *survey.Password:
+					*(response.(*string)) = "p@ssw0rd"
+				case *survey.Confirm:
+					*(response.(*bool)) = save
+				default:
+					*(response.(*string)) = "westus2"
+				}
+				return nil
+			},
+		})
+	provider.bicepCli = &fakeBicepCli{}
+
+	return provider, azCli
+}
+
+// Secure values which are not saved to the Key Vault are only kept in memory, so they are never written to disk.
+func TestBicepPlanKeepsDeclinedSecureValuesInMemory(t *testing.T) {
+	provider, azCli := createTestBicepProvider(t, false)
+
+	plan, err := provider.Plan(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, azCli.secrets)
+
+	bicepPlan := plan.Details.(bicepDeploymentPlan)
+	require.Equal(t, "westus2", bicepPlan.location)
+	require.Equal(t, map[string]interface{}{"dbPassword": map[string]interface{}{"value": "p@ssw0rd"}},
+		bicepPlan.inMemoryParameters)
+
+	parameters, err := os.ReadFile(bicepPlan.parametersPath)
+	require.NoError(t, err)
+	require.Contains(t, string(parameters), "westus2")
+	require.NotContains(t, string(parameters), "p@ssw0rd")
+
+	values, err := os.ReadFile(provider.env.File)
+	require.NoError(t, err)
+	require.NotContains(t, string(values), "p@ssw0rd")
+}
+
+func TestBicepPlanSavesSecureValuesToKeyVault(t *testing.T) {
+	provider, azCli := createTestBicepProvider(t, true)
+
+	plan, err := provider.Plan(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"dev-dbPassword": "p@ssw0rd"}, azCli.secrets)
+
+	bicepPlan := plan.Details.(bicepDeploymentPlan)
+	require.Empty(t, bicepPlan.inMemoryParameters)
+
+	parameters, err := os.ReadFile(bicepPlan.parametersPath)
+	require.NoError(t, err)
+	require.Contains(t, string(parameters), "/vaults/my-vault")
+	require.NotContains(t, string(parameters), "p@ssw0rd")
+}
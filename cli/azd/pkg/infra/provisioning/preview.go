@@ -0,0 +1,233 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package provisioning
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/azure"
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+	"github.com/fatih/color"
+)
+
+type ChangeType string
+
+const (
+	ChangeTypeCreate      ChangeType = "Create"
+	ChangeTypeDelete      ChangeType = "Delete"
+	ChangeTypeModify      ChangeType = "Modify"
+	ChangeTypeDeploy      ChangeType = "Deploy"
+	ChangeTypeNoChange    ChangeType = "NoChange"
+	ChangeTypeIgnore      ChangeType = "Ignore"
+	ChangeTypeUnsupported ChangeType = "Unsupported"
+)
+
+type PropertyChangeType string
+
+const (
+	PropertyChangeTypeCreate   PropertyChangeType = "Create"
+	PropertyChangeTypeDelete   PropertyChangeType = "Delete"
+	PropertyChangeTypeModify   PropertyChangeType = "Modify"
+	PropertyChangeTypeArray    PropertyChangeType = "Array"
+	PropertyChangeTypeNoEffect PropertyChangeType = "NoEffect"
+)
+
+// DeploymentPreview describes the changes applying a deployment plan would make, without making them.
+type DeploymentPreview struct {
+	Changes []ResourceChange `json:"changes"`
+}
+
+// ResourceChange is the change a deployment would make to a single resource.
+type ResourceChange struct {
+	ResourceId   string           `json:"resourceId"`
+	ResourceType string           `json:"resourceType"`
+	Name         string           `json:"name"`
+	ChangeType   ChangeType       `json:"changeType"`
+	Delta        []PropertyChange `json:"delta,omitempty"`
+}
+
+// PropertyChange is the change a deployment would make to a single property of a resource. Changes to arrays and
+// objects may be described by the changes to their children.
+type PropertyChange struct {
+	Path       string             `json:"path"`
+	ChangeType PropertyChangeType `json:"changeType"`
+	Before     interface{}        `json:"before,omitempty"`
+	After      interface{}        `json:"after,omitempty"`
+	Children   []PropertyChange   `json:"children,omitempty"`
+}
+
+// newDeploymentPreview converts the result of an ARM what-if operation, ordering the changes by resource id.
+func newDeploymentPreview(result tools.AzCliWhatIfResult) *DeploymentPreview {
+	changes := make([]ResourceChange, 0, len(result.Changes))
+	for _, change := range result.Changes {
+		resourceType, name := azure.ParseResourceTypeAndName(change.ResourceId)
+		changes = append(changes, ResourceChange{
+			ResourceId:   change.ResourceId,
+			ResourceType: resourceType,
+			Name:         name,
+			ChangeType:   ChangeType(change.ChangeType),
+			Delta:        convertWhatIfPropertyChanges(change.Delta),
+		})
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return strings.ToLower(changes[i].ResourceId) < strings.ToLower(changes[j].ResourceId)
+	})
+
+	return &DeploymentPreview{Changes: changes}
+}
+
+func convertWhatIfPropertyChanges(delta []tools.AzCliWhatIfPropertyChange) []PropertyChange {
+	if len(delta) == 0 {
+		return nil
+	}
+
+	changes := make([]PropertyChange, 0, len(delta))
+	for _, change := range delta {
+		changes = append(changes, PropertyChange{
+			Path:       change.Path,
+			ChangeType: PropertyChangeType(change.PropertyChangeType),
+			Before:     change.Before,
+			After:      change.After,
+			Children:   convertWhatIfPropertyChanges(change.Children),
+		})
+	}
+
+	return changes
+}
+
+// Count returns the number of resources with the given type of change.
+func (p *DeploymentPreview) Count(changeType ChangeType) int {
+	count := 0
+	for _, change := range p.Changes {
+		if change.ChangeType == changeType {
+			count++
+		}
+	}
+
+	return count
+}
+
+// previewSymbols are the symbols printed before each resource and property, by type of change.
+var previewSymbols = map[string]string{
+	string(ChangeTypeCreate):           "+",
+	string(ChangeTypeDelete):           "-",
+	string(ChangeTypeModify):           "~",
+	string(ChangeTypeDeploy):           "!",
+	string(ChangeTypeNoChange):         "=",
+	string(ChangeTypeIgnore):           "*",
+	string(PropertyChangeTypeArray):    "~",
+	string(PropertyChangeTypeNoEffect): "x",
+}
+
+var previewColors = map[string]*color.Color{
+	string(ChangeTypeCreate):        color.New(color.FgGreen),
+	string(ChangeTypeDelete):        color.New(color.FgRed),
+	string(ChangeTypeModify):        color.New(color.FgYellow),
+	string(ChangeTypeDeploy):        color.New(color.FgBlue),
+	string(PropertyChangeTypeArray): color.New(color.FgYellow),
+}
+
+func previewSymbol(changeType string) string {
+	symbol, has := previewSymbols[changeType]
+	if !has {
+		symbol = "?"
+	}
+
+	if c, has := previewColors[changeType]; has {
+		return c.Sprint(symbol)
+	}
+
+	return symbol
+}
+
+// RenderPreview writes a human readable description of the changes in a preview, grouping resources by the type of
+// change. Resources which are not changed are only counted, since listing them obscures the changes that matter.
+func RenderPreview(w io.Writer, preview *DeploymentPreview) error {
+	var sb strings.Builder
+
+	groups := []struct {
+		changeType ChangeType
+		title      string
+	}{
+		{ChangeTypeDelete, "Resources to delete"},
+		{ChangeTypeModify, "Resources to modify"},
+		{ChangeTypeCreate, "Resources to create"},
+		{ChangeTypeDeploy, "Resources to deploy (changes cannot be predicted)"},
+		{ChangeTypeUnsupported, "Resources which cannot be analyzed"},
+	}
+
+	for _, group := range groups {
+		if preview.Count(group.changeType) == 0 {
+			continue
+		}
+
+		sb.WriteString(fmt.Sprintf("%s:\n\n", group.title))
+		for _, change := range preview.Changes {
+			if change.ChangeType != group.changeType {
+				continue
+			}
+
+			sb.WriteString(fmt.Sprintf("  %s %s %s\n", previewSymbol(string(change.ChangeType)), change.ResourceType, change.Name))
+			if change.ChangeType == ChangeTypeModify {
+				renderPropertyChanges(&sb, change.Delta, 2)
+			}
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString(fmt.Sprintf(
+		"Resource changes: %d to create, %d to modify, %d to delete, %d to deploy, %d unchanged, %d ignored.\n",
+		preview.Count(ChangeTypeCreate),
+		preview.Count(ChangeTypeModify),
+		preview.Count(ChangeTypeDelete),
+		preview.Count(ChangeTypeDeploy),
+		preview.Count(ChangeTypeNoChange),
+		preview.Count(ChangeTypeIgnore)))
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func renderPropertyChanges(sb *strings.Builder, changes []PropertyChange, depth int) {
+	indent := strings.Repeat("  ", depth+1)
+
+	for _, change := range changes {
+		symbol := previewSymbol(string(change.ChangeType))
+
+		switch {
+		case len(change.Children) > 0:
+			sb.WriteString(fmt.Sprintf("%s%s %s:\n", indent, symbol, change.Path))
+			renderPropertyChanges(sb, change.Children, depth+1)
+		case change.ChangeType == PropertyChangeTypeCreate:
+			sb.WriteString(fmt.Sprintf("%s%s %s: %s\n", indent, symbol, change.Path, formatPreviewValue(change.After)))
+		case change.ChangeType == PropertyChangeTypeDelete:
+			sb.WriteString(fmt.Sprintf("%s%s %s: %s\n", indent, symbol, change.Path, formatPreviewValue(change.Before)))
+		default:
+			sb.WriteString(fmt.Sprintf(
+				"%s%s %s: %s => %s\n",
+				indent, symbol, change.Path, formatPreviewValue(change.Before), formatPreviewValue(change.After)))
+		}
+	}
+}
+
+func formatPreviewValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("%q", v)
+	default:
+		bytes, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+
+		return string(bytes)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package provisioning
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/require"
+)
+
+func testWhatIfResult() tools.AzCliWhatIfResult {
+	return tools.AzCliWhatIfResult{
+		Status: "Succeeded",
+		Changes: []tools.AzCliWhatIfChange{
+			{
+				ResourceId: "/subscriptions/SUBSCRIPTION_ID/resourceGroups/rg-test/providers/Microsoft.Web/sites/web/slots/staging",
+				ChangeType: "Create",
+			},
+			{
+				ResourceId: "/subscriptions/SUBSCRIPTION_ID/resourceGroups/rg-test",
+				ChangeType: "NoChange",
+			},
+			{
+				ResourceId: "/subscriptions/SUBSCRIPTION_ID/resourceGroups/rg-test/providers/Microsoft.Web/sites/web",
+				ChangeType: "Modify",
+				Delta: []tools.AzCliWhatIfPropertyChange{
+					{Path: "properties.httpsOnly", PropertyChangeType: "Modify", Before: false, After: true},
+					{
+						Path:               "properties.siteConfig.appSettings",
+						PropertyChangeType: "Array",
+						Children: []tools.AzCliWhatIfPropertyChange{
+							{Path: "0", PropertyChangeType: "Delete", Before: map[string]interface{}{"name": "DEBUG"}},
+						},
+					},
+				},
+			},
+			{
+				ResourceId: "/subscriptions/SUBSCRIPTION_ID/resourceGroups/rg-test/providers/Microsoft.Storage/storageAccounts/st",
+				ChangeType: "Delete",
+			},
+		},
+	}
+}
+
+func TestNewDeploymentPreview(t *testing.T) {
+	preview := newDeploymentPreview(testWhatIfResult())
+
+	require.Len(t, preview.Changes, 4)
+	require.Equal(t, "Microsoft.Resources/resourceGroups", preview.Changes[0].ResourceType)
+	require.Equal(t, "rg-test", preview.Changes[0].Name)
+	require.Equal(t, "Microsoft.Storage/storageAccounts", preview.Changes[1].ResourceType)
+	require.Equal(t, "Microsoft.Web/sites", preview.Changes[2].ResourceType)
+	require.Equal(t, "Microsoft.Web/sites/slots", preview.Changes[3].ResourceType)
+	require.Equal(t, "web/staging", preview.Changes[3].Name)
+
+	require.Equal(t, []PropertyChange{
+		{Path: "properties.httpsOnly", ChangeType: PropertyChangeTypeModify, Before: false, After: true},
+		{
+			Path:       "properties.siteConfig.appSettings",
+			ChangeType: PropertyChangeTypeArray,
+			Children: []PropertyChange{
+				{Path: "0", ChangeType: PropertyChangeTypeDelete, Before: map[string]interface{}{"name": "DEBUG"}},
+			},
+		},
+	}, preview.Changes[2].Delta)
+
+	require.Equal(t, 1, preview.Count(ChangeTypeCreate))
+	require.Equal(t, 1, preview.Count(ChangeTypeNoChange))
+}
+
+func TestRenderPreview(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	var sb strings.Builder
+	err := RenderPreview(&sb, newDeploymentPreview(testWhatIfResult()))
+	require.NoError(t, err)
+
+	require.Equal(t, `Resources to delete:
+
+  - Microsoft.Storage/storageAccounts st
+
+Resources to modify:
+
+  ~ Microsoft.Web/sites web
+      ~ properties.httpsOnly: false => true
+      ~ properties.siteConfig.appSettings:
+        - 0: {"name":"DEBUG"}
+
+Resources to create:
+
+  + Microsoft.Web/sites/slots web/staging
+
+Resource changes: 1 to create, 1 to modify, 1 to delete, 0 to deploy, 1 unchanged, 0 ignored.
+`, sb.String())
+}
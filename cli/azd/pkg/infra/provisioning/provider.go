@@ -0,0 +1,144 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package provisioning
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+)
+
+type ProviderKind string
+
+const (
+	Bicep     ProviderKind = "bicep"
+	Terraform ProviderKind = "terraform"
+)
+
+// Options are the infrastructure settings from the `infra` section of azure.yaml.
+type Options struct {
+	// The infrastructure provider, either `bicep` (the default) or `terraform`
+	Provider ProviderKind `yaml:"provider,omitempty"`
+	// The path to the infrastructure files, relative to the project root. Defaults to `infra`.
+	Path string `yaml:"path,omitempty"`
+	// The name of the root module. Defaults to `main`.
+	Module string `yaml:"module,omitempty"`
+}
+
+// ModuleName returns the name of the root module, which defaults to `main`.
+func (o Options) ModuleName() string {
+	if o.Module == "" {
+		return "main"
+	}
+
+	return o.Module
+}
+
+// OutputParameter is a single output of provisioned infrastructure.
+type OutputParameter struct {
+	Type  string
+	Value interface{}
+}
+
+// Deployment is the result of provisioning infrastructure.
+type Deployment struct {
+	// The outputs of the deployment, which are saved to the environment
+	Outputs map[string]OutputParameter
+	// The provider specific description of the deployment, which is displayed when JSON output is requested
+	Details interface{}
+}
+
+// DeploymentPlan describes the changes a provider will make when the plan is applied.
+type DeploymentPlan struct {
+	// The provider specific details of the plan
+	Details interface{}
+}
+
+type DestroyOptions struct {
+	// Destroy without asking the user for confirmation
+	Force bool
+	// Permanently delete resources which are soft-deleted by default
+	Purge bool
+}
+
+// DestroyResult is the result of destroying infrastructure. The outputs of its deployments are removed from the
+// environment by the caller, since the environment records which of its values are outputs.
+type DestroyResult struct{}
+
+// DeploymentNotFoundError is returned by Provider.Outputs when the environment was never provisioned.
+type DeploymentNotFoundError struct {
+	EnvName string
+}
+
+func (e *DeploymentNotFoundError) Error() string {
+	return fmt.Sprintf("no deployment for environment '%s' found. Have you run `infra create`?", e.EnvName)
+}
+
+// Provider provisions the infrastructure described by a project.
+type Provider interface {
+	Name() string
+	RequiredExternalTools() []tools.ExternalTool
+	// Init prepares the provider for the environment, prompting for any values it requires.
+	Init(ctx context.Context) error
+	// Plan computes the changes required to provision the infrastructure.
+	Plan(ctx context.Context) (*DeploymentPlan, error)
+	// Apply provisions the infrastructure as described by a plan.
+	Apply(ctx context.Context, plan *DeploymentPlan) (*Deployment, error)
+	// Preview describes the changes applying a plan would make to existing infrastructure, without making them.
+	Preview(ctx context.Context, plan *DeploymentPlan) (*DeploymentPreview, error)
+	// Destroy removes all of the provisioned infrastructure. When the user declines to continue, nil is returned
+	// for both the result and the error.
+	Destroy(ctx context.Context, options DestroyOptions) (*DestroyResult, error)
+	// Outputs fetches the outputs of the most recent deployment.
+	Outputs(ctx context.Context) (*Deployment, error)
+}
+
+// Prompters are used by providers to ask the user for values.
+type Prompters struct {
+	AskOne         func(p survey.Prompt, response interface{}) error
+	PromptLocation func(ctx context.Context, message string) (string, error)
+}
+
+// NewProvider creates the provider configured by options for an environment.
+func NewProvider(
+	ctx context.Context,
+	azCli tools.AzCli,
+	azdCtx *environment.AzdContext,
+	env *environment.Environment,
+	options Options,
+	prompters Prompters,
+) (Provider, error) {
+	if options.Path == "" {
+		options.Path = environment.InfraDirectoryName
+	}
+
+	options.Module = options.ModuleName()
+
+	if !filepath.IsAbs(options.Path) {
+		options.Path = filepath.Join(azdCtx.ProjectDirectory(), options.Path)
+	}
+
+	switch options.Provider {
+	case "", Bicep:
+		return NewBicepProvider(azCli, azdCtx, env, options, prompters), nil
+	case Terraform:
+		return NewTerraformProvider(azdCtx, env, options, prompters), nil
+	default:
+		return nil, fmt.Errorf("unsupported infrastructure provider '%s'", options.Provider)
+	}
+}
+
+// OutputNames returns the names of the outputs in a deployment.
+func (d *Deployment) OutputNames() []string {
+	names := make([]string, 0, len(d.Outputs))
+	for name := range d.Outputs {
+		names = append(names, name)
+	}
+
+	return names
+}
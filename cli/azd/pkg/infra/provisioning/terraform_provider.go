@@ -0,0 +1,364 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package provisioning
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/executil"
+	"github.com/azure/azure-dev/cli/azd/pkg/osutil"
+	"github.com/azure/azure-dev/cli/azd/pkg/spin"
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+	"github.com/drone/envsubst"
+)
+
+// TerraformProvider provisions infrastructure with a Terraform configuration. Unless the configuration declares a
+// backend, state is kept in the environment directory so each environment is managed independently.
+type TerraformProvider struct {
+	azdCtx    *environment.AzdContext
+	env       *environment.Environment
+	options   Options
+	prompters Prompters
+	// runWithResultFn allows us to stub out the command execution for testing
+	runWithResultFn func(ctx context.Context, args executil.RunArgs) (executil.RunResult, error)
+}
+
+type terraformDeploymentPlan struct {
+	planFilePath string
+}
+
+func NewTerraformProvider(azdCtx *environment.AzdContext, env *environment.Environment, options Options, prompters Prompters) *TerraformProvider {
+	return &TerraformProvider{
+		azdCtx:          azdCtx,
+		env:             env,
+		options:         options,
+		prompters:       prompters,
+		runWithResultFn: executil.RunWithResult,
+	}
+}
+
+func (p *TerraformProvider) Name() string {
+	return "Terraform"
+}
+
+// RequiredExternalTools returns the terraform CLI, which is only checked for, so the values of the environment are
+// not resolved.
+func (p *TerraformProvider) RequiredExternalTools() []tools.ExternalTool {
+	return []tools.ExternalTool{tools.NewTerraformCli(tools.TerraformCliArgs{RunWithResultFn: p.runWithResultFn})}
+}
+
+// cli creates the terraform CLI used to run commands. The environment values are made available to terraform, so
+// `TF_VAR_` prefixed values can be used to set variables, along with the subscription to deploy to and a data directory
+// scoped to the environment. Values which reference Key Vault secrets are resolved, as for hooks.
+func (p *TerraformProvider) cli(ctx context.Context) (tools.TerraformCli, error) {
+	values, err := p.env.ResolvedValues(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("resolving environment values: %w", err)
+	}
+
+	env := []string{
+		fmt.Sprintf("TF_DATA_DIR=%s", filepath.Join(p.environmentDirectory(), ".terraform")),
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		env = append(env, fmt.Sprintf("%s=%s", key, values[key]))
+	}
+
+	if subscriptionId := p.env.GetSubscriptionId(); subscriptionId != "" {
+		env = append(env, fmt.Sprintf("ARM_SUBSCRIPTION_ID=%s", subscriptionId))
+	}
+
+	if tenantId := p.env.GetTenantId(); tenantId != "" {
+		env = append(env, fmt.Sprintf("ARM_TENANT_ID=%s", tenantId))
+	}
+
+	return tools.NewTerraformCli(tools.TerraformCliArgs{
+		Env:             env,
+		RunWithResultFn: p.runWithResultFn,
+	}), nil
+}
+
+func (p *TerraformProvider) environmentDirectory() string {
+	return filepath.Join(p.azdCtx.EnvironmentDirectory(), p.env.GetEnvName())
+}
+
+func (p *TerraformProvider) variablesTemplatePath() string {
+	return filepath.Join(p.options.Path, p.options.Module+".tfvars.json")
+}
+
+func (p *TerraformProvider) variablesPath() string {
+	return filepath.Join(p.environmentDirectory(), p.options.Module+".tfvars.json")
+}
+
+func (p *TerraformProvider) planFilePath() string {
+	return filepath.Join(p.environmentDirectory(), p.options.Module+".tfplan")
+}
+
+func (p *TerraformProvider) statePath() string {
+	return filepath.Join(p.environmentDirectory(), "terraform.tfstate")
+}
+
+var terraformBackendRegex = regexp.MustCompile(`(?m)^\s*backend\s+"[^"]+"`)
+
+// hasBackend returns true when the terraform configuration declares a backend, in which case terraform manages the
+// location of the state.
+func (p *TerraformProvider) hasBackend() bool {
+	files, err := filepath.Glob(filepath.Join(p.options.Path, "*.tf"))
+	if err != nil {
+		return false
+	}
+
+	for _, file := range files {
+		contents, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Printf("failed reading terraform file %s: %v", file, err)
+			continue
+		}
+
+		if terraformBackendRegex.Match(contents) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stateArgs returns the arguments which select the state of the environment.
+func (p *TerraformProvider) stateArgs() []string {
+	if p.hasBackend() {
+		return nil
+	}
+
+	return []string{"-state=" + p.statePath()}
+}
+
+// variableArgs returns the arguments which pass the variables file of the environment, when there is one.
+func (p *TerraformProvider) variableArgs() []string {
+	if _, err := os.Stat(p.variablesPath()); err != nil {
+		return nil
+	}
+
+	return []string{"-var-file=" + p.variablesPath()}
+}
+
+// Init copies the variables template file to the environment working directory, substituting references to
+// environment values, ensures a location has been selected and initializes the terraform working directory.
+func (p *TerraformProvider) Init(ctx context.Context) error {
+	if err := os.MkdirAll(p.environmentDirectory(), osutil.PermissionDirectory); err != nil {
+		return fmt.Errorf("creating environment directory: %w", err)
+	}
+
+	// Terraform configurations commonly use the location of the environment, so ensure one has been selected before
+	// the variables file is written.
+	if location, _ := p.env.Lookup(environment.LocationEnvVarName); location == "" {
+		location, err := p.prompters.PromptLocation(ctx, "Please select an Azure location to use:")
+		if err != nil {
+			return fmt.Errorf("prompting for location: %w", err)
+		}
+
+		p.env.SetLocation(location)
+		if err := p.env.Save(); err != nil {
+			return fmt.Errorf("writing env file: %w", err)
+		}
+	}
+
+	variablesBytes, err := ioutil.ReadFile(p.variablesTemplatePath())
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		log.Printf("no terraform variables file found at %s", p.variablesTemplatePath())
+	case err != nil:
+		return fmt.Errorf("reading variables file template: %w", err)
+	default:
+		replaced, err := envsubst.Eval(string(variablesBytes), func(name string) string {
+			if val, has := p.env.Lookup(name); has {
+				return val
+			}
+			return os.Getenv(name)
+		})
+		if err != nil {
+			return fmt.Errorf("substituting variables file: %w", err)
+		}
+
+		if err := ioutil.WriteFile(p.variablesPath(), []byte(replaced), osutil.PermissionFile); err != nil {
+			return fmt.Errorf("writing variables file: %w", err)
+		}
+	}
+
+	cli, err := p.cli(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := cli.Init(ctx, p.options.Path); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Plan creates a terraform execution plan, saved to the environment working directory.
+func (p *TerraformProvider) Plan(ctx context.Context) (*DeploymentPlan, error) {
+	cli, err := p.cli(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	args := append(p.variableArgs(), p.stateArgs()...)
+	if _, err := cli.Plan(ctx, p.options.Path, p.planFilePath(), args...); err != nil {
+		return nil, err
+	}
+
+	return &DeploymentPlan{
+		Details: terraformDeploymentPlan{
+			planFilePath: p.planFilePath(),
+		},
+	}, nil
+}
+
+// Apply applies a saved execution plan and returns the resulting outputs.
+func (p *TerraformProvider) Apply(ctx context.Context, plan *DeploymentPlan) (*Deployment, error) {
+	terraformPlan, ok := plan.Details.(terraformDeploymentPlan)
+	if !ok {
+		return nil, errors.New("deployment plan was not created by the terraform provider")
+	}
+
+	cli, err := p.cli(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := cli.Apply(ctx, p.options.Path, terraformPlan.planFilePath, p.stateArgs()...); err != nil {
+		return nil, err
+	}
+
+	return p.Outputs(ctx)
+}
+
+// Preview is not supported by the terraform provider, since the plan output of terraform already describes the changes.
+func (p *TerraformProvider) Preview(ctx context.Context, plan *DeploymentPlan) (*DeploymentPreview, error) {
+	return nil, errors.New("previewing changes is not supported by the terraform provider, run `terraform plan` instead")
+}
+
+// Outputs reads the outputs from the terraform state of the environment.
+func (p *TerraformProvider) Outputs(ctx context.Context) (*Deployment, error) {
+	if !p.hasBackend() {
+		if _, err := os.Stat(p.statePath()); errors.Is(err, os.ErrNotExist) {
+			return nil, &DeploymentNotFoundError{EnvName: p.env.GetEnvName()}
+		}
+	}
+
+	cli, err := p.cli(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs, err := cli.Output(ctx, p.options.Path, p.stateArgs()...)
+	if err != nil {
+		return nil, fmt.Errorf("reading outputs: %w", err)
+	}
+
+	return &Deployment{
+		Outputs: convertTerraformOutputs(outputs),
+		Details: outputs,
+	}, nil
+}
+
+// Destroy destroys all of the resources managed by the terraform configuration for the environment.
+func (p *TerraformProvider) Destroy(ctx context.Context, options DestroyOptions) (*DestroyResult, error) {
+	if !options.Force {
+		var ok bool
+		err := p.prompters.AskOne(&survey.Confirm{
+			Message: fmt.Sprintf(
+				"This will destroy all resources managed by Terraform for environment '%s', are you sure you want to continue?",
+				p.env.GetEnvName()),
+			Default: false,
+		}, &ok)
+		if err != nil {
+			return nil, fmt.Errorf("prompting for confirmation: %w", err)
+		}
+		if !ok {
+			return nil, nil
+		}
+	}
+
+	if options.Purge {
+		log.Println("purging soft-deleted resources is not supported by the terraform provider, configure it in the azurerm provider features instead")
+	}
+
+	cli, err := p.cli(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	args := append(p.variableArgs(), p.stateArgs()...)
+	spinner := spin.NewSpinner("Deleting Azure resources")
+	if err := spinner.Run(func() error {
+		_, err := cli.Destroy(ctx, p.options.Path, args...)
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("destroying: %w", err)
+	}
+
+	return &DestroyResult{}, nil
+}
+
+func convertTerraformOutputs(outputs map[string]tools.TerraformOutput) map[string]OutputParameter {
+	converted := make(map[string]OutputParameter, len(outputs))
+	for name, output := range outputs {
+		converted[name] = OutputParameter{
+			Type:  terraformOutputType(output.Type),
+			Value: output.Value,
+		}
+	}
+
+	return converted
+}
+
+// terraformOutputType maps the type of a terraform output to the name of the equivalent ARM template type.
+func terraformOutputType(raw json.RawMessage) string {
+	var typeName string
+	if err := json.Unmarshal(raw, &typeName); err == nil {
+		switch typeName {
+		case "number":
+			return "int"
+		default:
+			return typeName
+		}
+	}
+
+	// Complex types are described as a list, whose first element is the kind of the type, for example
+	// ["list", "string"] or ["object", {"name": "string"}].
+	var complexType []json.RawMessage
+	if err := json.Unmarshal(raw, &complexType); err == nil && len(complexType) > 0 {
+		var kind string
+		if err := json.Unmarshal(complexType[0], &kind); err == nil {
+			switch strings.ToLower(kind) {
+			case "list", "set", "tuple":
+				return "array"
+			case "map", "object":
+				return "object"
+			}
+		}
+	}
+
+	return "string"
+}
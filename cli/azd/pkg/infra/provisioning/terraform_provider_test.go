@@ -0,0 +1,173 @@
+package provisioning
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/executil"
+	"github.com/azure/azure-dev/cli/azd/pkg/osutil"
+	"github.com/stretchr/testify/require"
+)
+
+const testTerraformOutputs = `{
+  "AZURE_LOCATION": {"sensitive": false, "type": "string", "value": "westus2"},
+  "WEBSITE_URLS": {"sensitive": false, "type": ["list", "string"], "value": ["https://web"]},
+  "INSTANCE_COUNT": {"sensitive": false, "type": "number", "value": 2}
+}`
+
+func createTestTerraformProvider(t *testing.T, commands *[][]string) *TerraformProvider {
+	projectDir := t.TempDir()
+	infraDir := filepath.Join(projectDir, "infra")
+	require.NoError(t, os.MkdirAll(infraDir, osutil.PermissionDirectory))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(infraDir, "main.tfvars.json"), []byte(`{"location": "${AZURE_LOCATION}"}`), osutil.PermissionFile))
+
+	azdCtx := &environment.AzdContext{}
+	azdCtx.SetProjectDirectory(projectDir)
+
+	env := environment.Environment{Values: map[string]string{
+		environment.EnvNameEnvVarName:        "test-env",
+		environment.LocationEnvVarName:       "westus2",
+		environment.SubscriptionIdEnvVarName: "SUBSCRIPTION_ID",
+	}}
+
+	provider := NewTerraformProvider(azdCtx, &env, Options{Provider: Terraform, Path: infraDir, Module: "main"}, Prompters{
+		AskOne: func(p survey.Prompt, response interface{}) error {
+			t.Fatal("no prompt was expected")
+			return nil
+		},
+	})
+
+	provider.runWithResultFn = func(ctx context.Context, args executil.RunArgs) (executil.RunResult, error) {
+		require.Equal(t, "terraform", args.Cmd)
+		require.Contains(t, args.Env, "ARM_SUBSCRIPTION_ID=SUBSCRIPTION_ID")
+		*commands = append(*commands, args.Args)
+
+		stdout := ""
+		if len(args.Args) > 1 && args.Args[1] == "output" {
+			stdout = testTerraformOutputs
+		}
+
+		if len(args.Args) > 1 && (args.Args[1] == "apply") {
+			// Simulate terraform creating the state for the environment
+			require.NoError(t, os.WriteFile(provider.statePath(), []byte("{}"), osutil.PermissionFile))
+		}
+
+		return executil.RunResult{Stdout: stdout}, nil
+	}
+
+	return provider
+}
+
+func TestTerraformProviderProvision(t *testing.T) {
+	var commands [][]string
+	provider := createTestTerraformProvider(t, &commands)
+	envDir := filepath.Join(provider.azdCtx.EnvironmentDirectory(), "test-env")
+	infraDir := provider.options.Path
+
+	err := provider.Init(context.Background())
+	require.NoError(t, err)
+
+	variables, err := os.ReadFile(filepath.Join(envDir, "main.tfvars.json"))
+	require.NoError(t, err)
+	require.Equal(t, `{"location": "westus2"}`, string(variables))
+
+	plan, err := provider.Plan(context.Background())
+	require.NoError(t, err)
+
+	deployment, err := provider.Apply(context.Background(), plan)
+	require.NoError(t, err)
+
+	require.Equal(t, [][]string{
+		{"-chdir=" + infraDir, "init", "-input=false", "-upgrade"},
+		{
+			"-chdir=" + infraDir, "plan", "-input=false", "-out=" + filepath.Join(envDir, "main.tfplan"),
+			"-var-file=" + filepath.Join(envDir, "main.tfvars.json"),
+			"-state=" + filepath.Join(envDir, "terraform.tfstate"),
+		},
+		{
+			"-chdir=" + infraDir, "apply", "-input=false", "-auto-approve",
+			"-state=" + filepath.Join(envDir, "terraform.tfstate"),
+			filepath.Join(envDir, "main.tfplan"),
+		},
+		{"-chdir=" + infraDir, "output", "-json", "-state=" + filepath.Join(envDir, "terraform.tfstate")},
+	}, commands)
+
+	require.Equal(t, map[string]OutputParameter{
+		"AZURE_LOCATION": {Type: "string", Value: "westus2"},
+		"WEBSITE_URLS":   {Type: "array", Value: []interface{}{"https://web"}},
+		"INSTANCE_COUNT": {Type: "int", Value: float64(2)},
+	}, deployment.Outputs)
+}
+
+func TestTerraformProviderOutputsWithoutState(t *testing.T) {
+	var commands [][]string
+	provider := createTestTerraformProvider(t, &commands)
+
+	_, err := provider.Outputs(context.Background())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no deployment for environment 'test-env' found")
+	require.Empty(t, commands)
+}
+
+func TestTerraformProviderDestroy(t *testing.T) {
+	var commands [][]string
+	provider := createTestTerraformProvider(t, &commands)
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(provider.statePath()), osutil.PermissionDirectory))
+	require.NoError(t, os.WriteFile(provider.statePath(), []byte("{}"), osutil.PermissionFile))
+
+	res, err := provider.Destroy(context.Background(), DestroyOptions{Force: true})
+	require.NoError(t, err)
+	require.NotNil(t, res)
+
+	require.Len(t, commands, 1)
+	require.Equal(t, "destroy", commands[0][1])
+	require.True(t, strings.HasPrefix(commands[0][len(commands[0])-1], "-state="))
+}
+
+func TestTerraformProviderWithBackend(t *testing.T) {
+	var commands [][]string
+	provider := createTestTerraformProvider(t, &commands)
+
+	require.NoError(t, os.WriteFile(filepath.Join(provider.options.Path, "provider.tf"), []byte(`
+terraform {
+  backend "azurerm" {
+  }
+}
+`), osutil.PermissionFile))
+
+	_, err := provider.Outputs(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"-chdir=" + provider.options.Path, "output", "-json"}}, commands)
+}
+
+type fakeSecretResolver struct{}
+
+func (fakeSecretResolver) GetKeyVaultSecret(_ context.Context, vaultName string, secretName string) (string, error) {
+	return vaultName + "/" + secretName + "-value", nil
+}
+
+// Values which reference Key Vault secrets are resolved before they are made available to terraform.
+func TestTerraformProviderResolvesSecrets(t *testing.T) {
+	var commands [][]string
+	provider := createTestTerraformProvider(t, &commands)
+	provider.env.Values["TF_VAR_db_password"] = "akvs://my-vault/test-env-db-password"
+
+	var env []string
+	provider.runWithResultFn = func(ctx context.Context, args executil.RunArgs) (executil.RunResult, error) {
+		env = args.Env
+		return executil.RunResult{}, nil
+	}
+
+	ctx := context.WithValue(context.Background(), environment.AzdCliContextKey, fakeSecretResolver{})
+	_, err := provider.Plan(ctx)
+	require.NoError(t, err)
+	require.Contains(t, env, "TF_VAR_db_password=my-vault/test-env-db-password-value")
+	require.Contains(t, env, "ARM_SUBSCRIPTION_ID=SUBSCRIPTION_ID")
+}
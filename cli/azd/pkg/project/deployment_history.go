@@ -0,0 +1,330 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package project
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/osutil"
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+)
+
+const (
+	deploymentHistoryFileName = "deployments.json"
+	// The artifacts of each deployment are stored in a directory named after the deployment, under this directory
+	deploymentArtifactsDirectoryName = "deployments"
+	// The number of deployments of each service whose artifacts are kept for rollback. History is kept for every
+	// deployment, but older artifacts are removed to bound the disk space used.
+	deploymentArtifactRetention = 10
+)
+
+// DeploymentRecord describes a single deployment of a service.
+type DeploymentRecord struct {
+	Id        string    `json:"id"`
+	Service   string    `json:"service"`
+	Timestamp time.Time `json:"timestamp"`
+	// The artifact which was deployed. Kind is empty when the artifact could not be kept, in which case the deployment
+	// cannot be rolled back to.
+	Package ServicePackage `json:"package"`
+	// The SHA256 hash of the zip archive which was deployed
+	ArtifactHash     string   `json:"artifactHash,omitempty"`
+	TargetResourceId string   `json:"targetResourceId"`
+	Endpoints        []string `json:"endpoints"`
+	// The commit of the project repository at the time of the deployment
+	GitCommit string `json:"gitCommit,omitempty"`
+	// The hash of the source of the service, which is empty when the service was deployed from a package
+	SourceHash string `json:"sourceHash,omitempty"`
+	// The result of the health check of the service after it was deployed, when the service has one
+	Health *HealthCheckResult `json:"health,omitempty"`
+}
+
+// Healthy returns true unless the health check of the deployment failed. Deployments without a health check are
+// considered healthy.
+func (r *DeploymentRecord) Healthy() bool {
+	return r.Health == nil || r.Health.Healthy
+}
+
+// CanRollback returns true when the artifact of the deployment is still available to be redeployed.
+func (r *DeploymentRecord) CanRollback() bool {
+	return r.Package.Kind != ""
+}
+
+// DeploymentHistory is the record of the deployments of the services of a project to an environment, stored in the
+// directory of the environment.
+type DeploymentHistory struct {
+	dir    string
+	gitCli tools.GitCli
+	mu     sync.Mutex
+}
+
+// NewDeploymentHistory creates the deployment history stored in dir, which is typically `.azure/<environment>`.
+func NewDeploymentHistory(dir string) *DeploymentHistory {
+	return &DeploymentHistory{
+		dir:    dir,
+		gitCli: tools.NewGitCli(),
+	}
+}
+
+// Dir returns the directory the history is stored in. The paths of the packages of the records are relative to it.
+func (h *DeploymentHistory) Dir() string {
+	return h.dir
+}
+
+// List returns all of the recorded deployments, from oldest to newest.
+func (h *DeploymentHistory) List() ([]DeploymentRecord, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.read()
+}
+
+// Get returns the deployment with the given id.
+func (h *DeploymentHistory) Get(id string) (*DeploymentRecord, error) {
+	records, err := h.List()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		if record.Id == id {
+			return &record, nil
+		}
+	}
+
+	return nil, fmt.Errorf("deployment '%s' was not found", id)
+}
+
+// Previous returns the most recent healthy deployment of a service before its most recent deployment, which is the
+// default to roll back to.
+func (h *DeploymentHistory) Previous(service string) (*DeploymentRecord, error) {
+	records, err := h.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var serviceRecords []DeploymentRecord
+	for _, record := range records {
+		if record.Service == service {
+			serviceRecords = append(serviceRecords, record)
+		}
+	}
+
+	for i := len(serviceRecords) - 2; i >= 0; i-- {
+		if serviceRecords[i].Healthy() {
+			return &serviceRecords[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("service '%s' has no previous deployment to roll back to", service)
+}
+
+// Latest returns the most recent deployment of a service, or nil when the service has not been deployed.
+func (h *DeploymentHistory) Latest(service string) (*DeploymentRecord, error) {
+	records, err := h.List()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Service == service {
+			return &records[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Invalidate forgets the source hashes of the recorded deployments, so that every service is deployed again even when
+// it has not changed, such as once the infrastructure the services were deployed to is destroyed. The deployments
+// are kept, so they can still be rolled back to.
+func (h *DeploymentHistory) Invalidate() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	records, err := h.read()
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	for i := range records {
+		records[i].SourceHash = ""
+	}
+
+	return h.write(records)
+}
+
+// Record adds a deployment of a service to the history. When the deployed artifact is a directory, it is archived so
+// it can be redeployed later.
+func (h *DeploymentHistory) Record(
+	ctx context.Context, config *ServiceConfig, artifact string, result ServiceDeploymentResult, sourceHash string,
+) (*DeploymentRecord, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	records, err := h.read()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	record := DeploymentRecord{
+		Id:               newDeploymentId(records, now, config.Name),
+		Service:          config.Name,
+		Timestamp:        now,
+		TargetResourceId: result.TargetResourceId,
+		Endpoints:        result.Endpoints,
+		SourceHash:       sourceHash,
+		Health:           result.Health,
+	}
+
+	if commit, err := h.gitCli.GetCurrentCommit(ctx, config.Project.Path); err != nil {
+		log.Printf("failed reading current commit for deployment history: %v", err)
+	} else {
+		record.GitCommit = commit
+	}
+
+	host := config.GetServiceTargetKind()
+	switch {
+	case result.Image != "":
+		record.Package = ServicePackage{Kind: ImagePackage, Image: result.Image, Host: host, Language: config.Language}
+	case host == StaticWebAppTarget:
+		// Static web apps are deployed from the build output of the project instead of the artifact, so there is no
+		// artifact to keep.
+	default:
+		zipPath := filepath.Join(deploymentArtifactsDirectoryName, record.Id, fmt.Sprintf("%s.zip", config.Name))
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(h.dir, zipPath)), osutil.PermissionDirectory); err != nil {
+			return nil, fmt.Errorf("creating deployment artifact directory: %w", err)
+		}
+
+		if err := createZip(artifact, filepath.Join(h.dir, zipPath)); err != nil {
+			return nil, fmt.Errorf("archiving deployment artifact: %w", err)
+		}
+
+		hash, err := fileHash(filepath.Join(h.dir, zipPath))
+		if err != nil {
+			return nil, fmt.Errorf("hashing deployment artifact: %w", err)
+		}
+
+		record.Package = ServicePackage{Kind: ZipPackage, Path: zipPath, Host: host, Language: config.Language}
+		record.ArtifactHash = hash
+	}
+
+	records = append(records, record)
+	h.pruneArtifacts(records)
+
+	if err := h.write(records); err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// newDeploymentId returns an id for a deployment of a service which is readable and sorts by time, unique among the
+// existing records.
+func newDeploymentId(records []DeploymentRecord, timestamp time.Time, service string) string {
+	ids := map[string]bool{}
+	for _, record := range records {
+		ids[record.Id] = true
+	}
+
+	id := fmt.Sprintf("%s-%s", NewPackageVersion(timestamp), service)
+	for i := 2; ids[id]; i++ {
+		id = fmt.Sprintf("%s-%s-%d", NewPackageVersion(timestamp), service, i)
+	}
+
+	return id
+}
+
+// pruneArtifacts removes the archived artifacts of all but the most recent deployments of each service.
+func (h *DeploymentHistory) pruneArtifacts(records []DeploymentRecord) {
+	kept := map[string]int{}
+
+	for i := len(records) - 1; i >= 0; i-- {
+		record := &records[i]
+		if record.Package.Kind != ZipPackage {
+			continue
+		}
+
+		kept[record.Service]++
+		if kept[record.Service] <= deploymentArtifactRetention {
+			continue
+		}
+
+		if err := os.RemoveAll(filepath.Join(h.dir, deploymentArtifactsDirectoryName, record.Id)); err != nil {
+			log.Printf("failed removing artifact of deployment %s: %v", record.Id, err)
+			continue
+		}
+
+		record.Package = ServicePackage{}
+	}
+}
+
+func (h *DeploymentHistory) read() ([]DeploymentRecord, error) {
+	bytes, err := ioutil.ReadFile(filepath.Join(h.dir, deploymentHistoryFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return []DeploymentRecord{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading deployment history: %w", err)
+	}
+
+	var records []DeploymentRecord
+	if err := json.Unmarshal(bytes, &records); err != nil {
+		return nil, fmt.Errorf("parsing deployment history: %w", err)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Timestamp.Before(records[j].Timestamp)
+	})
+
+	return records, nil
+}
+
+func (h *DeploymentHistory) write(records []DeploymentRecord) error {
+	bytes, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling deployment history: %w", err)
+	}
+
+	if err := os.MkdirAll(h.dir, osutil.PermissionDirectory); err != nil {
+		return fmt.Errorf("creating environment directory: %w", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(h.dir, deploymentHistoryFileName), bytes, osutil.PermissionFile); err != nil {
+		return fmt.Errorf("writing deployment history: %w", err)
+	}
+
+	return nil
+}
+
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
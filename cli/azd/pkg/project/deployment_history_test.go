@@ -0,0 +1,165 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package project
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/osutil"
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeGitCli struct {
+	tools.GitCli
+	commit string
+}
+
+func (cli *fakeGitCli) GetCurrentCommit(ctx context.Context, repositoryPath string) (string, error) {
+	return cli.commit, nil
+}
+
+func createTestDeploymentHistory(t *testing.T) (*DeploymentHistory, *ProjectConfig) {
+	projectConfig, err := ParseProjectConfig(projectYaml, env)
+	require.NoError(t, err)
+	projectConfig.Path = t.TempDir()
+
+	history := NewDeploymentHistory(filepath.Join(projectConfig.Path, ".azure", "test-env"))
+	history.gitCli = &fakeGitCli{commit: "0123456789abcdef0123456789abcdef01234567"}
+
+	return history, projectConfig
+}
+
+func TestDeploymentHistoryRecord(t *testing.T) {
+	history, projectConfig := createTestDeploymentHistory(t)
+
+	artifact := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(artifact, "index.js"), []byte("hello"), osutil.PermissionFile))
+
+	record, err := history.Record(context.Background(), projectConfig.Services["api"], artifact, ServiceDeploymentResult{
+		TargetResourceId: "target-resource-id",
+		Endpoints:        mockEndpoints,
+	}, "source-hash")
+	require.NoError(t, err)
+	require.Equal(t, "api", record.Service)
+	require.Equal(t, "0123456789abcdef0123456789abcdef01234567", record.GitCommit)
+	require.Equal(t, "source-hash", record.SourceHash)
+	require.Equal(t, ZipPackage, record.Package.Kind)
+	require.Equal(t, AppServiceTarget, record.Package.Host)
+	require.Len(t, record.ArtifactHash, 64)
+	require.True(t, record.CanRollback())
+	require.FileExists(t, filepath.Join(history.Dir(), record.Package.Path))
+
+	imageConfig := *projectConfig.Services["api"]
+	imageConfig.Host = string(ContainerAppTarget)
+	imageRecord, err := history.Record(context.Background(), &imageConfig, "local-image-id", ServiceDeploymentResult{
+		Image: "registry.azurecr.io/api/api:azdev-deploy-1",
+	}, "")
+	require.NoError(t, err)
+	require.Equal(t, ServicePackage{
+		Kind:     ImagePackage,
+		Image:    "registry.azurecr.io/api/api:azdev-deploy-1",
+		Host:     ContainerAppTarget,
+		Language: "js",
+	}, imageRecord.Package)
+	require.NotEqual(t, record.Id, imageRecord.Id)
+
+	records, err := history.List()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	found, err := history.Get(record.Id)
+	require.NoError(t, err)
+	require.Equal(t, record.ArtifactHash, found.ArtifactHash)
+
+	previous, err := history.Previous("api")
+	require.NoError(t, err)
+	require.Equal(t, record.Id, previous.Id)
+
+	latest, err := history.Latest("api")
+	require.NoError(t, err)
+	require.Equal(t, imageRecord.Id, latest.Id)
+
+	latest, err = history.Latest("web")
+	require.NoError(t, err)
+	require.Nil(t, latest)
+
+	_, err = history.Get("missing")
+	require.Error(t, err)
+}
+
+func TestDeploymentHistoryPreviousWithoutDeployments(t *testing.T) {
+	history, _ := createTestDeploymentHistory(t)
+
+	_, err := history.Previous("api")
+	require.EqualError(t, err, "service 'api' has no previous deployment to roll back to")
+}
+
+func TestDeploymentHistoryPreviousSkipsUnhealthyDeployments(t *testing.T) {
+	history, projectConfig := createTestDeploymentHistory(t)
+	artifact := t.TempDir()
+
+	record := func(healthy bool) *DeploymentRecord {
+		record, err := history.Record(context.Background(), projectConfig.Services["api"], artifact, ServiceDeploymentResult{
+			Endpoints: mockEndpoints,
+			Health:    &HealthCheckResult{Url: mockEndpoints[0], Healthy: healthy, Attempts: 1},
+		}, "")
+		require.NoError(t, err)
+		return record
+	}
+
+	healthy := record(true)
+	unhealthy := record(false)
+	require.True(t, healthy.Healthy())
+	require.False(t, unhealthy.Healthy())
+
+	// The unhealthy deployment is rolled back to the healthy one before it.
+	previous, err := history.Previous("api")
+	require.NoError(t, err)
+	require.Equal(t, healthy.Id, previous.Id)
+
+	// Unhealthy deployments are not rolled back to.
+	record(true)
+
+	previous, err = history.Previous("api")
+	require.NoError(t, err)
+	require.Equal(t, healthy.Id, previous.Id)
+
+	latest, err := history.Latest("api")
+	require.NoError(t, err)
+	require.True(t, latest.Healthy())
+}
+
+func TestDeploymentHistoryPrunesArtifacts(t *testing.T) {
+	history, projectConfig := createTestDeploymentHistory(t)
+	artifact := t.TempDir()
+
+	var records []*DeploymentRecord
+	for i := 0; i < deploymentArtifactRetention+2; i++ {
+		require.NoError(t, os.WriteFile(
+			filepath.Join(artifact, "index.js"), []byte(fmt.Sprintf("version %d", i)), osutil.PermissionFile))
+
+		record, err := history.Record(context.Background(), projectConfig.Services["api"], artifact, ServiceDeploymentResult{}, "")
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+
+	all, err := history.List()
+	require.NoError(t, err)
+	require.Len(t, all, deploymentArtifactRetention+2)
+
+	for i, record := range all {
+		if i < 2 {
+			require.False(t, record.CanRollback())
+			require.NoDirExists(t, filepath.Join(history.Dir(), deploymentArtifactsDirectoryName, records[i].Id))
+		} else {
+			require.True(t, record.CanRollback())
+			require.FileExists(t, filepath.Join(history.Dir(), record.Package.Path))
+		}
+	}
+}
@@ -36,9 +36,14 @@ func (np *npmProject) Package(ctx context.Context, progress chan<- string) (stri
 		return "", err
 	}
 
-	// Run Build, injecting env.
-	envs := make([]string, 0, len(np.env.Values)+1)
-	for k, v := range np.env.Values {
+	// Run Build, injecting env, with the values of secrets resolved.
+	values, err := np.env.ResolvedValues(ctx)
+	if err != nil {
+		return "", fmt.Errorf("resolving environment of %s: %w", np.config.Name, err)
+	}
+
+	envs := make([]string, 0, len(values)+1)
+	for k, v := range values {
 		envs = append(envs, fmt.Sprintf("%s=%s", k, v))
 	}
 	envs = append(envs, "NODE_ENV=production")
@@ -0,0 +1,144 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package project
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultHealthCheckExpectedStatus = http.StatusOK
+	defaultHealthCheckTimeout        = 30 * time.Second
+	defaultHealthCheckRetries        = 10
+)
+
+// pollInterval is the time waited between the attempts of waitUntil.
+var pollInterval = 5 * time.Second
+
+// HealthCheckConfig configures the probe of the endpoint of a service, which azd deploy runs once the service is
+// deployed.
+type HealthCheckConfig struct {
+	// The path probed, relative to the first endpoint of the service, such as /health
+	Path string `yaml:"path"`
+	// The status the endpoint responds with once the service is healthy. Defaults to 200.
+	ExpectedStatus int `yaml:"expectedStatus,omitempty"`
+	// The time to wait for each response, such as 10s. Defaults to 30s.
+	Timeout string `yaml:"timeout,omitempty"`
+	// The number of times the endpoint is probed again while the service is not healthy. Defaults to 10.
+	Retries int `yaml:"retries,omitempty"`
+}
+
+// HealthCheckResult is the result of the health check of a service after it is deployed.
+type HealthCheckResult struct {
+	Url     string `json:"url"`
+	Healthy bool   `json:"healthy"`
+	// The status of the last response, which is 0 when the endpoint did not respond
+	Status   int `json:"status,omitempty"`
+	Attempts int `json:"attempts"`
+	// Why the last probe failed, when the service is not healthy
+	Error string `json:"error,omitempty"`
+}
+
+// checkHealth probes the endpoint of a service configured by config until it responds with the expected status, or
+// until it was retried as many times as configured. An error is returned when the health check cannot run, such as
+// when the service has no endpoint, but not when the service is not healthy.
+func checkHealth(ctx context.Context, config *HealthCheckConfig, endpoints []string, progress chan<- string) (*HealthCheckResult, error) {
+	if len(endpoints) == 0 {
+		return nil, errors.New("the service does not have an endpoint to check the health of")
+	}
+
+	expectedStatus := config.ExpectedStatus
+	if expectedStatus == 0 {
+		expectedStatus = defaultHealthCheckExpectedStatus
+	}
+
+	timeout := defaultHealthCheckTimeout
+	if config.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(config.Timeout); err != nil {
+			return nil, fmt.Errorf("parsing health check timeout: %w", err)
+		}
+	}
+
+	retries := config.Retries
+	if retries == 0 {
+		retries = defaultHealthCheckRetries
+	}
+
+	result := &HealthCheckResult{
+		Url: strings.TrimSuffix(endpoints[0], "/") + "/" + strings.TrimPrefix(config.Path, "/"),
+	}
+	client := &http.Client{Timeout: timeout}
+
+	healthy, state, err := waitUntil(ctx, progress, "Checking health", retries+1, func() (bool, string, error) {
+		result.Attempts++
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, result.Url, nil)
+		if err != nil {
+			return false, "", fmt.Errorf("creating health check request: %w", err)
+		}
+
+		// Failed requests are retried, since the service may not be listening yet.
+		res, err := client.Do(req)
+		if err != nil {
+			result.Status = 0
+			return false, err.Error(), nil
+		}
+		_, _ = io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+
+		result.Status = res.StatusCode
+		return res.StatusCode == expectedStatus,
+			fmt.Sprintf("responded with status %d instead of %d", res.StatusCode, expectedStatus), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Healthy = healthy
+	if !healthy {
+		result.Error = fmt.Sprintf("%s %s", result.Url, state)
+	}
+
+	return result, nil
+}
+
+// waitUntil calls check until it returns true, at most attempts times, waiting pollInterval between calls. progress
+// reports message, with a dot added on each new attempt. The state check returns describes why it is not done yet,
+// and the state of the last call is returned when check never returned true.
+func waitUntil(ctx context.Context, progress chan<- string, message string, attempts int, check func() (bool, string, error)) (bool, string, error) {
+	var state string
+
+	for attempt := 1; ; attempt++ {
+		progress <- message
+
+		done, currentState, err := check()
+		if err != nil {
+			return false, "", err
+		}
+
+		state = currentState
+		if done {
+			return true, state, nil
+		}
+
+		if attempt >= attempts {
+			return false, state, nil
+		}
+
+		message += "."
+
+		select {
+		case <-ctx.Done():
+			return false, state, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package project
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectWithHealthCheck(t *testing.T) {
+	const testProj = `
+name: test-proj
+services:
+  api:
+    project: src/api
+    language: js
+    host: appservice
+    healthCheck:
+      path: /health
+      expectedStatus: 204
+      timeout: 10s
+      retries: 3
+`
+
+	e := environment.Empty("")
+	e.SetEnvName("test-env")
+
+	projectConfig, err := ParseProjectConfig(testProj, &e)
+	require.NoError(t, err)
+
+	require.Equal(t, &HealthCheckConfig{Path: "/health", ExpectedStatus: 204, Timeout: "10s", Retries: 3},
+		projectConfig.Services["api"].HealthCheck)
+}
+
+func TestCheckHealth(t *testing.T) {
+	defaultPollInterval := pollInterval
+	pollInterval = time.Millisecond
+	t.Cleanup(func() { pollInterval = defaultPollInterval })
+
+	newProgress := func(t *testing.T) chan<- string {
+		progress := make(chan string)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for range progress {
+			}
+		}()
+		t.Cleanup(func() {
+			close(progress)
+			<-done
+		})
+		return progress
+	}
+
+	t.Run("BecomesHealthy", func(t *testing.T) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/health", r.URL.Path)
+			requests++
+			if requests < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		result, err := checkHealth(context.Background(), &HealthCheckConfig{Path: "health"}, []string{server.URL + "/"}, newProgress(t))
+		require.NoError(t, err)
+		require.Equal(t, &HealthCheckResult{Url: server.URL + "/health", Healthy: true, Status: http.StatusOK, Attempts: 3}, result)
+	})
+
+	t.Run("NeverHealthy", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		result, err := checkHealth(context.Background(), &HealthCheckConfig{Path: "/health", Retries: 2}, []string{server.URL}, newProgress(t))
+		require.NoError(t, err)
+		require.False(t, result.Healthy)
+		require.Equal(t, 3, result.Attempts)
+		require.Equal(t, http.StatusInternalServerError, result.Status)
+		require.Equal(t, server.URL+"/health responded with status 500 instead of 200", result.Error)
+	})
+
+	t.Run("NoEndpoint", func(t *testing.T) {
+		_, err := checkHealth(context.Background(), &HealthCheckConfig{Path: "/health"}, nil, newProgress(t))
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,195 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package project
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/osutil"
+	"github.com/azure/azure-dev/cli/azd/pkg/rzip"
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+)
+
+// PackageManifestFileName is the name of the file describing the artifacts in a package directory.
+const PackageManifestFileName = "manifest.json"
+
+type PackageKind string
+
+const (
+	// ZipPackage is a zip archive of the files to deploy
+	ZipPackage PackageKind = "zip"
+	// ImagePackage is a reference to a container image in the local docker image store
+	ImagePackage PackageKind = "image"
+)
+
+// PackageManifest describes the artifacts created by `azd package`, which can be deployed to any environment with
+// `azd deploy --from-package`.
+type PackageManifest struct {
+	Version   string                    `json:"version"`
+	CreatedAt time.Time                 `json:"createdAt"`
+	Services  map[string]ServicePackage `json:"services"`
+}
+
+// ServicePackage is the deployable artifact of a single service.
+type ServicePackage struct {
+	Kind PackageKind `json:"kind"`
+	// The path of the zip archive, relative to the directory of the manifest
+	Path string `json:"path,omitempty"`
+	// The reference of the container image
+	Image string `json:"image,omitempty"`
+	// The host the artifact was created for
+	Host ServiceTargetKind `json:"host"`
+	// The language of the service
+	Language string `json:"language,omitempty"`
+}
+
+// NewPackageVersion returns the default version for packages created at the given time.
+func NewPackageVersion(t time.Time) string {
+	return t.UTC().Format("20060102150405")
+}
+
+// NewPackageManifest creates an empty manifest.
+func NewPackageManifest(version string, createdAt time.Time) *PackageManifest {
+	return &PackageManifest{
+		Version:   version,
+		CreatedAt: createdAt,
+		Services:  map[string]ServicePackage{},
+	}
+}
+
+// LoadPackageManifest reads the manifest at path, which is either a package directory or the manifest file itself.
+// The directory containing the manifest is returned along with it, since the paths of the artifacts are relative to it.
+func LoadPackageManifest(path string) (*PackageManifest, string, error) {
+	if info, err := os.Stat(path); err != nil {
+		return nil, "", fmt.Errorf("reading package: %w", err)
+	} else if info.IsDir() {
+		path = filepath.Join(path, PackageManifestFileName)
+	}
+
+	bytes, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", fmt.Errorf("package manifest %s was not found, was the package created with `azd package`?", path)
+	} else if err != nil {
+		return nil, "", fmt.Errorf("reading package manifest: %w", err)
+	}
+
+	var manifest PackageManifest
+	if err := json.Unmarshal(bytes, &manifest); err != nil {
+		return nil, "", fmt.Errorf("parsing package manifest %s: %w", path, err)
+	}
+
+	if manifest.Services == nil {
+		manifest.Services = map[string]ServicePackage{}
+	}
+
+	return &manifest, filepath.Dir(path), nil
+}
+
+// Save writes the manifest to the package directory.
+func (m *PackageManifest) Save(dir string) error {
+	bytes, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling package manifest: %w", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, PackageManifestFileName), bytes, osutil.PermissionFile); err != nil {
+		return fmt.Errorf("writing package manifest: %w", err)
+	}
+
+	return nil
+}
+
+// GetServiceTargetKind returns the kind of host the service is deployed to.
+func (sc *ServiceConfig) GetServiceTargetKind() ServiceTargetKind {
+	if sc.Host == "" {
+		return AppServiceTarget
+	}
+
+	return ServiceTargetKind(sc.Host)
+}
+
+// SupportsPackage returns false for services whose host does not support deploying from a package, such as static
+// web apps, which build the service as they deploy it.
+func (sc *ServiceConfig) SupportsPackage() bool {
+	return sc.GetServiceTargetKind() != StaticWebAppTarget
+}
+
+// Package builds the service and writes its deployable artifact to the package directory. Container images are
+// tagged with the package version instead, since they are stored by docker.
+func (sc *ServiceConfig) Package(
+	ctx context.Context, env *environment.Environment, dir string, version string, progress chan<- string,
+) (ServicePackage, error) {
+	host := sc.GetServiceTargetKind()
+	if !sc.SupportsPackage() {
+		return ServicePackage{}, fmt.Errorf(
+			"service '%s' is hosted by a static web app, which does not support deploying from a package", sc.Name)
+	}
+
+	framework, err := sc.GetFrameworkService(ctx, env)
+	if err != nil {
+		return ServicePackage{}, fmt.Errorf("creating framework service: %w", err)
+	}
+
+	log.Printf("packing service %s", sc.Name)
+
+	progress <- "Preparing packaging"
+	artifact, err := (*framework).Package(ctx, progress)
+	if err != nil {
+		return ServicePackage{}, fmt.Errorf("packaging service %s: %w", sc.Name, err)
+	}
+
+	servicePackage := ServicePackage{
+		Host:     host,
+		Language: sc.Language,
+	}
+
+	if host == ContainerAppTarget {
+		image := strings.ToLower(fmt.Sprintf("%s-%s:azd-package-%s", sc.Project.Name, sc.Name, version))
+
+		progress <- "Tagging image"
+		docker := tools.NewDocker(tools.DockerArgs{})
+		if err := docker.Tag(ctx, sc.Path(), artifact, image); err != nil {
+			return ServicePackage{}, fmt.Errorf("tagging image: %w", err)
+		}
+
+		servicePackage.Kind = ImagePackage
+		servicePackage.Image = image
+		return servicePackage, nil
+	}
+
+	progress <- "Compressing deployment artifacts"
+	zipName := fmt.Sprintf("%s.zip", sc.Name)
+	if err := createZip(artifact, filepath.Join(dir, zipName)); err != nil {
+		return ServicePackage{}, fmt.Errorf("creating package for service %s: %w", sc.Name, err)
+	}
+
+	servicePackage.Kind = ZipPackage
+	servicePackage.Path = zipName
+	return servicePackage, nil
+}
+
+func createZip(source string, path string) error {
+	zipFile, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, osutil.PermissionFile)
+	if err != nil {
+		return err
+	}
+
+	if err := rzip.CreateFromDirectory(source, zipFile); err != nil {
+		zipFile.Close()
+		os.Remove(path)
+		return err
+	}
+
+	return zipFile.Close()
+}
@@ -0,0 +1,138 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package project
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/osutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageManifestSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	manifest := NewPackageManifest("1.0.0", time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC))
+	manifest.Services["api"] = ServicePackage{Kind: ZipPackage, Path: "api.zip", Host: AppServiceTarget, Language: "js"}
+	manifest.Services["web"] = ServicePackage{Kind: ImagePackage, Image: "test-proj-web:azd-package-1.0.0", Host: ContainerAppTarget}
+	require.NoError(t, manifest.Save(dir))
+
+	loaded, loadedDir, err := LoadPackageManifest(dir)
+	require.NoError(t, err)
+	require.Equal(t, dir, loadedDir)
+	require.Equal(t, manifest, loaded)
+
+	loaded, loadedDir, err = LoadPackageManifest(filepath.Join(dir, PackageManifestFileName))
+	require.NoError(t, err)
+	require.Equal(t, dir, loadedDir)
+	require.Equal(t, manifest, loaded)
+}
+
+func TestLoadPackageManifestMissing(t *testing.T) {
+	_, _, err := LoadPackageManifest(t.TempDir())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "was not found")
+}
+
+func TestServiceSupportsPackage(t *testing.T) {
+	require.True(t, (&ServiceConfig{Host: string(ContainerAppTarget)}).SupportsPackage())
+	require.True(t, (&ServiceConfig{}).SupportsPackage())
+	require.False(t, (&ServiceConfig{Host: string(StaticWebAppTarget)}).SupportsPackage())
+
+	_, err := (&ServiceConfig{Name: "web", Host: string(StaticWebAppTarget)}).Package(
+		context.Background(), nil, t.TempDir(), "1.0.0", make(chan string))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "does not support deploying from a package")
+}
+
+type recordingServiceTarget struct {
+	mockServiceTarget
+	deploy func(path string)
+}
+
+func (st *recordingServiceTarget) Deploy(
+	ctx context.Context, azdCtx *environment.AzdContext, path string, progress chan<- string,
+) (ServiceDeploymentResult, error) {
+	st.deploy(path)
+	return st.mockServiceTarget.Deploy(ctx, azdCtx, path, progress)
+}
+
+func deployPackage(t *testing.T, svc *Service, dir string, servicePackage ServicePackage) *ServiceDeploymentChannelResponse {
+	result, progress := svc.DeployPackage(context.Background(), &environment.AzdContext{}, dir, servicePackage)
+	go func() {
+		for range progress {
+		}
+	}()
+
+	return <-result
+}
+
+func TestDeployPackage(t *testing.T) {
+	projectConfig, err := ParseProjectConfig(projectYaml, env)
+	require.NoError(t, err)
+
+	sourceDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(sourceDir, "dist"), osutil.PermissionDirectory))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "dist", "index.js"), []byte("hello"), osutil.PermissionFile))
+
+	packageDir := t.TempDir()
+	require.NoError(t, createZip(sourceDir, filepath.Join(packageDir, "api.zip")))
+
+	t.Run("Zip", func(t *testing.T) {
+		var deployedPath string
+		svc := &Service{
+			Config:    projectConfig.Services["api"],
+			Framework: &mockFrameworkService{},
+			Target: &recordingServiceTarget{deploy: func(path string) {
+				deployedPath = path
+				contents, err := os.ReadFile(filepath.Join(path, "dist", "index.js"))
+				require.NoError(t, err)
+				require.Equal(t, "hello", string(contents))
+			}},
+		}
+
+		response := deployPackage(t, svc, packageDir, ServicePackage{Kind: ZipPackage, Path: "api.zip", Host: AppServiceTarget})
+		require.NoError(t, response.Error)
+		require.Equal(t, mockEndpoints, response.Result.Endpoints)
+
+		// The extracted package is removed once it has been deployed
+		_, err := os.Stat(deployedPath)
+		require.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("Image", func(t *testing.T) {
+		var deployedPath string
+		config := *projectConfig.Services["api"]
+		config.Host = string(ContainerAppTarget)
+
+		svc := &Service{
+			Config:    &config,
+			Framework: &mockFrameworkService{},
+			Target:    &recordingServiceTarget{deploy: func(path string) { deployedPath = path }},
+		}
+
+		response := deployPackage(t, svc, packageDir, ServicePackage{Kind: ImagePackage, Image: "api:v1", Host: ContainerAppTarget})
+		require.NoError(t, response.Error)
+		require.Equal(t, "api:v1", deployedPath)
+	})
+
+	t.Run("HostMismatch", func(t *testing.T) {
+		svc := &Service{
+			Config:    projectConfig.Services["api"],
+			Framework: &mockFrameworkService{},
+			Target: &recordingServiceTarget{deploy: func(path string) {
+				t.Fatal("the package should not be deployed")
+			}},
+		}
+
+		response := deployPackage(t, svc, packageDir, ServicePackage{Kind: ImagePackage, Image: "api:v1", Host: ContainerAppTarget})
+		require.Error(t, response.Error)
+		require.Contains(t, response.Error.Error(), "was created for host 'containerapp'")
+	})
+}
@@ -0,0 +1,234 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package project
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/infra/provisioning"
+	"github.com/drone/envsubst"
+)
+
+// PipelineConfig configures the deployment pipeline created by `azd pipeline config`.
+type PipelineConfig struct {
+	// The GitHub environments azd environments are deployed from, by azd environment name
+	Environments map[string]*PipelineEnvironmentConfig `yaml:"environments,omitempty"`
+	// The names of the environment values pushed to the pipeline as plain variables
+	Variables []string `yaml:"variables,omitempty"`
+	// The names of the environment values pushed to the pipeline as secrets
+	Secrets []string `yaml:"secrets,omitempty"`
+}
+
+// PipelineEnvironmentConfig configures the GitHub environment an azd environment is deployed from.
+type PipelineEnvironmentConfig struct {
+	// The users, or teams written as `organization/team`, who must approve deployments of the environment
+	Reviewers []string `yaml:"reviewers,omitempty"`
+}
+
+// PipelineValue is a value of the environment which the pipeline needs to provision and deploy the project.
+type PipelineValue struct {
+	Name   string
+	Value  string
+	Secret bool
+}
+
+// Values every pipeline is configured with, or which are specific to the identity running the pipeline, and are
+// never part of the values returned by PipelineValues.
+var basePipelineValueNames = map[string]bool{
+	environment.EnvNameEnvVarName:        true,
+	environment.LocationEnvVarName:       true,
+	environment.SubscriptionIdEnvVarName: true,
+	environment.PrincipalIdEnvVarName:    true,
+}
+
+// PipelineValues returns the values of env the pipeline is configured with, besides the name, location and
+// subscription of the environment. They are the values listed in `pipeline.variables` and `pipeline.secrets`, and the
+// values referenced by the parameters of the infrastructure. Referenced values which are not set in env are skipped,
+// while listed values must be set.
+//
+// Values are plain variables, unless they are listed as secrets, declared as secrets in the `env` section, referenced
+// by secure parameters of the infrastructure, or reference Key Vault secrets.
+func (pc *ProjectConfig) PipelineValues(env *environment.Environment) ([]PipelineValue, error) {
+	secrets := map[string]bool{}
+	for _, name := range pc.Pipeline.Secrets {
+		secrets[name] = true
+	}
+	for _, variable := range pc.Env {
+		if variable.Secret {
+			secrets[variable.Name] = true
+		}
+	}
+
+	values := map[string]PipelineValue{}
+	add := func(name string, required bool) error {
+		if basePipelineValueNames[name] {
+			return nil
+		}
+
+		value, has := env.Values[name]
+		if !has {
+			if required {
+				return fmt.Errorf("'%s' is listed in the pipeline configuration, but is not set in environment '%s'", name, env.GetEnvName())
+			}
+			return nil
+		}
+
+		_, isReference := environment.ParseKeyVaultSecretReference(value)
+		values[name] = PipelineValue{Name: name, Value: value, Secret: secrets[name] || isReference}
+		return nil
+	}
+
+	for _, name := range append(append([]string{}, pc.Pipeline.Variables...), pc.Pipeline.Secrets...) {
+		if err := add(name, true); err != nil {
+			return nil, err
+		}
+	}
+
+	referenced, err := pc.infraParameterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	for name, secure := range referenced {
+		if secure {
+			secrets[name] = true
+		}
+	}
+
+	for name := range referenced {
+		if err := add(name, false); err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]PipelineValue, 0, len(values))
+	for _, value := range values {
+		result = append(result, value)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// infraParameterReferences returns the names of the environment values referenced by the parameters file of the
+// infrastructure, `main.parameters.json` for Bicep or `main.tfvars.json` for Terraform by default, and whether each is
+// referenced by a secure parameter.
+func (pc *ProjectConfig) infraParameterReferences() (map[string]bool, error) {
+	infraPath := pc.Infra.Path
+	if infraPath == "" {
+		infraPath = environment.InfraDirectoryName
+	}
+	if !filepath.IsAbs(infraPath) {
+		infraPath = filepath.Join(pc.Path, infraPath)
+	}
+
+	module := pc.Infra.Module
+	if module == "" {
+		module = "main"
+	}
+
+	parametersPath := filepath.Join(infraPath, module+".parameters.json")
+	if pc.Infra.Provider == provisioning.Terraform {
+		parametersPath = filepath.Join(infraPath, module+".tfvars.json")
+	}
+
+	bytes, err := ioutil.ReadFile(parametersPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading parameters file: %w", err)
+	}
+
+	// Bicep parameters files have their values under `parameters`, while Terraform variables files have them at the top.
+	var parameters map[string]json.RawMessage
+	if pc.Infra.Provider == provisioning.Terraform {
+		err = json.Unmarshal(bytes, &parameters)
+	} else {
+		var doc struct {
+			Parameters map[string]json.RawMessage `json:"parameters"`
+		}
+		err = json.Unmarshal(bytes, &doc)
+		parameters = doc.Parameters
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing parameters file: %w", err)
+	}
+
+	secure, err := secureInfraParameters(pc.Infra.Provider, infraPath, module)
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for parameter, value := range parameters {
+		// References are collected by substituting them, the same way they are substituted when provisioning.
+		_, err = envsubst.Eval(string(value), func(name string) string {
+			names[name] = names[name] || secure[parameter]
+			return ""
+		})
+		if err != nil {
+			return nil, fmt.Errorf("parsing environment references in parameters file: %w", err)
+		}
+	}
+
+	return names, nil
+}
+
+var (
+	// Matches the Bicep parameters decorated with @secure(), which are securestring or secureObject parameters once
+	// compiled, along with any other decorators of the parameter.
+	bicepSecureParamRegex = regexp.MustCompile(`@secure\(\)\s*(?:@\w+\((?:'[^']*'|[^)])*\)\s*)*param\s+(\w+)`)
+	// Matches the blocks of Terraform variables. Variables are sensitive when their block sets sensitive to true.
+	terraformVariableRegex  = regexp.MustCompile(`variable\s+"(\w+)"\s*\{((?:[^{}]|\{[^{}]*\})*)\}`)
+	terraformSensitiveRegex = regexp.MustCompile(`(?m)^\s*sensitive\s*=\s*true\b`)
+)
+
+// secureInfraParameters returns the names of the secure parameters of the module of the infrastructure in infraPath:
+// the parameters of the Bicep module decorated with @secure(), or the sensitive variables of the Terraform module.
+func secureInfraParameters(provider provisioning.ProviderKind, infraPath string, module string) (map[string]bool, error) {
+	secure := map[string]bool{}
+
+	if provider == provisioning.Terraform {
+		files, err := filepath.Glob(filepath.Join(infraPath, "*.tf"))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			contents, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %w", file, err)
+			}
+
+			for _, match := range terraformVariableRegex.FindAllStringSubmatch(string(contents), -1) {
+				if terraformSensitiveRegex.MatchString(match[2]) {
+					secure[match[1]] = true
+				}
+			}
+		}
+
+		return secure, nil
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(infraPath, module+".bicep"))
+	if errors.Is(err, os.ErrNotExist) {
+		return secure, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading module: %w", err)
+	}
+
+	for _, match := range bicepSecureParamRegex.FindAllStringSubmatch(string(contents), -1) {
+		secure[match[1]] = true
+	}
+
+	return secure, nil
+}
@@ -0,0 +1,172 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/stretchr/testify/require"
+)
+
+const pipelineTestProj = `
+name: test-proj
+services:
+  web:
+    project: src/web
+    language: js
+    host: appservice
+pipeline:
+  variables:
+    - WEB_SKU
+  secrets:
+    - DB_PASSWORD
+`
+
+const pipelineTestParameters = `{
+  "$schema": "https://schema.management.azure.com/schemas/2019-04-01/deploymentParameters.json#",
+  "contentVersion": "1.0.0.0",
+  "parameters": {
+    "name": { "value": "${AZURE_ENV_NAME}" },
+    "location": { "value": "${AZURE_LOCATION}" },
+    "principalId": { "value": "${AZURE_PRINCIPAL_ID}" },
+    "dbPassword": { "value": "${DB_PASSWORD}" },
+    "apiVersion": { "value": "${API_VERSION}" },
+    "unset": { "value": "${NOT_SET}" }
+  }
+}`
+
+func newPipelineTestEnvironment() *environment.Environment {
+	e := &environment.Environment{Values: map[string]string{
+		environment.LocationEnvVarName:    "westus2",
+		environment.PrincipalIdEnvVarName: "principal",
+		"WEB_SKU":                         "B1",
+		"DB_PASSWORD":                     "password",
+		"API_VERSION":                     "2",
+	}}
+	e.SetEnvName("test-env")
+
+	return e
+}
+
+func TestPipelineValues(t *testing.T) {
+	e := newPipelineTestEnvironment()
+
+	projectConfig, err := ParseProjectConfig(pipelineTestProj, e)
+	require.NoError(t, err)
+
+	projectConfig.Path = t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(projectConfig.Path, "infra"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(projectConfig.Path, "infra", "main.parameters.json"), []byte(pipelineTestParameters), 0600))
+
+	values, err := projectConfig.PipelineValues(e)
+	require.NoError(t, err)
+
+	require.Equal(t, []PipelineValue{
+		{Name: "API_VERSION", Value: "2"},
+		{Name: "DB_PASSWORD", Value: "password", Secret: true},
+		{Name: "WEB_SKU", Value: "B1"},
+	}, values)
+}
+
+func TestPipelineValuesListedValueNotSet(t *testing.T) {
+	e := newPipelineTestEnvironment()
+	delete(e.Values, "WEB_SKU")
+
+	projectConfig, err := ParseProjectConfig(pipelineTestProj, e)
+	require.NoError(t, err)
+
+	// Without infrastructure, only the listed values are returned.
+	projectConfig.Path = t.TempDir()
+
+	_, err = projectConfig.PipelineValues(e)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "WEB_SKU")
+}
+
+func TestPipelineValuesSecrets(t *testing.T) {
+	e := newPipelineTestEnvironment()
+	e.Values["ADMIN_PASSWORD"] = "admin"
+	e.Values["API_KEY"] = "akvs://vault/test-env-API-KEY"
+	e.Values["TOKEN"] = "token"
+
+	projectConfig, err := ParseProjectConfig(pipelineTestProj+`env:
+  - name: TOKEN
+    secret: true
+`, e)
+	require.NoError(t, err)
+
+	projectConfig.Path = t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(projectConfig.Path, "infra"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(projectConfig.Path, "infra", "main.parameters.json"), []byte(`{
+  "parameters": {
+    "adminPassword": { "value": "${ADMIN_PASSWORD}" },
+    "apiKey": { "value": "${API_KEY}" },
+    "apiVersion": { "value": "${API_VERSION}" },
+    "token": { "value": "${TOKEN}" }
+  }
+}`), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(projectConfig.Path, "infra", "main.bicep"), []byte(`
+param apiVersion string
+
+@secure()
+@description('The password of the administrator (of the database)')
+param adminPassword string
+`), 0600))
+
+	values, err := projectConfig.PipelineValues(e)
+	require.NoError(t, err)
+
+	require.Equal(t, []PipelineValue{
+		{Name: "ADMIN_PASSWORD", Value: "admin", Secret: true},
+		{Name: "API_KEY", Value: "akvs://vault/test-env-API-KEY", Secret: true},
+		{Name: "API_VERSION", Value: "2"},
+		{Name: "DB_PASSWORD", Value: "password", Secret: true},
+		{Name: "TOKEN", Value: "token", Secret: true},
+		{Name: "WEB_SKU", Value: "B1"},
+	}, values)
+}
+
+func TestPipelineValuesTerraformSensitiveVariables(t *testing.T) {
+	e := newPipelineTestEnvironment()
+	e.Values["ADMIN_PASSWORD"] = "admin"
+
+	projectConfig, err := ParseProjectConfig(pipelineTestProj+`infra:
+  provider: terraform
+`, e)
+	require.NoError(t, err)
+
+	projectConfig.Path = t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(projectConfig.Path, "infra"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(projectConfig.Path, "infra", "main.tfvars.json"), []byte(`{
+  "admin_password": "${ADMIN_PASSWORD}",
+  "api_version": "${API_VERSION}"
+}`), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(projectConfig.Path, "infra", "variables.tf"), []byte(`
+variable "api_version" {
+  type = string
+}
+
+variable "admin_password" {
+  type      = string
+  sensitive = true
+  validation {
+    condition     = length(var.admin_password) > 4
+    error_message = "too short"
+  }
+}
+`), 0600))
+
+	values, err := projectConfig.PipelineValues(e)
+	require.NoError(t, err)
+
+	require.Equal(t, []PipelineValue{
+		{Name: "ADMIN_PASSWORD", Value: "admin", Secret: true},
+		{Name: "API_VERSION", Value: "2"},
+		{Name: "DB_PASSWORD", Value: "password", Secret: true},
+		{Name: "WEB_SKU", Value: "B1"},
+	}, values)
+}
@@ -24,6 +24,21 @@ type Project struct {
 	Config   *ProjectConfig
 	Metadata *ProjectMetadata
 	Services []*Service
+	// The dependencies between the services of the project
+	Graph *ServiceGraph
+	// Where the deployments of the services are recorded. Deployments are not recorded when nil.
+	History *DeploymentHistory
+}
+
+// GetService returns the service with the given friendly name, or nil when the project has no such service.
+func (p *Project) GetService(name string) *Service {
+	for _, svc := range p.Services {
+		if svc.Config.Name == name {
+			return svc
+		}
+	}
+
+	return nil
 }
 
 // ReadProject reads a project file and sets the configured template
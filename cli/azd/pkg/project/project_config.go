@@ -7,11 +7,13 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 
 	"github.com/azure/azure-dev/cli/azd/pkg/azureutil"
 	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/ext"
+	"github.com/azure/azure-dev/cli/azd/pkg/infra/provisioning"
+	"github.com/azure/azure-dev/cli/azd/pkg/state"
 	"github.com/drone/envsubst"
 	"gopkg.in/yaml.v3"
 )
@@ -19,11 +21,16 @@ import (
 // ProjectConfig is the top level object serialized into an azure.yaml file.
 // When changing project structure, make sure to update the JSON schema file for azure.yaml (<workspace root>/schemas/vN.M/azure.yaml.json).
 type ProjectConfig struct {
-	Name              string                    `yaml:"name"`
-	ResourceGroupName string                    `yaml:"resourceGroup,omitempty"`
-	Path              string                    `yaml:",omitempty"`
-	Metadata          *ProjectMetadata          `yaml:"metadata,omitempty"`
-	Services          map[string]*ServiceConfig `yaml:",omitempty"`
+	Name              string                       `yaml:"name"`
+	ResourceGroupName string                       `yaml:"resourceGroup,omitempty"`
+	Path              string                       `yaml:",omitempty"`
+	Metadata          *ProjectMetadata             `yaml:"metadata,omitempty"`
+	Services          map[string]*ServiceConfig    `yaml:",omitempty"`
+	Infra             provisioning.Options         `yaml:"infra,omitempty"`
+	Hooks             map[string]*ext.HookConfig   `yaml:"hooks,omitempty"`
+	Pipeline          PipelineConfig               `yaml:"pipeline,omitempty"`
+	State             state.Options                `yaml:"state,omitempty"`
+	Env               []environment.VariableConfig `yaml:"env,omitempty"`
 }
 
 type ProjectMetadata struct {
@@ -49,11 +56,17 @@ func (p *ProjectConfig) HasService(name string) bool {
 func (pc *ProjectConfig) GetProject(ctx context.Context, env *environment.Environment) (*Project, error) {
 	serviceMap := map[string]*Service{}
 
+	graph, err := NewServiceGraph(pc.Services)
+	if err != nil {
+		return nil, fmt.Errorf("validating service dependencies: %w", err)
+	}
+
 	project := Project{
 		Name:     pc.Name,
 		Metadata: pc.Metadata,
 		Config:   pc,
 		Services: make([]*Service, 0),
+		Graph:    graph,
 	}
 
 	// This sets the current template within the go context
@@ -94,14 +107,9 @@ func (pc *ProjectConfig) GetProject(ctx context.Context, env *environment.Enviro
 		serviceMap[key] = service
 	}
 
-	// Sort services by friendly name an then collect them into a list. This provides a stable ordering of services.
-	serviceKeys := make([]string, 0, len(serviceMap))
-	for k := range serviceMap {
-		serviceKeys = append(serviceKeys, k)
-	}
-	sort.Strings(serviceKeys)
-
-	for _, key := range serviceKeys {
+	// Collect services in dependency order, services which don't depend on each other are sorted by friendly name.
+	// This provides a stable ordering of services.
+	for _, key := range graph.Order() {
 		project.Services = append(project.Services, serviceMap[key])
 	}
 
@@ -138,6 +146,14 @@ func ParseProjectConfig(yamlContent string, env *environment.Environment) (*Proj
 		projectFile.ResourceGroupName = environment.GetResourceGroupNameFromEnvVar(env)
 	}
 
+	if err := ext.ValidateHooks(projectFile.Hooks, ext.ProjectHooks); err != nil {
+		return nil, fmt.Errorf("validating project hooks: %w", err)
+	}
+
+	if err := environment.ValidateVariables(projectFile.Env); err != nil {
+		return nil, fmt.Errorf("validating environment variables: %w", err)
+	}
+
 	for key, svc := range projectFile.Services {
 		svc.Name = key
 		svc.Project = &projectFile
@@ -147,6 +163,10 @@ func ParseProjectConfig(yamlContent string, env *environment.Environment) (*Proj
 		if svc.Module == "" {
 			svc.Module = key
 		}
+
+		if err := ext.ValidateHooks(svc.Hooks, ext.ServiceHooks); err != nil {
+			return nil, fmt.Errorf("validating hooks for service %s: %w", key, err)
+		}
 	}
 
 	return &projectFile, nil
@@ -169,3 +169,31 @@ services:
 
 	require.Equal(t, "./api/api", service.Module)
 }
+
+func TestProjectConfigPipelineEnvironments(t *testing.T) {
+	const testProj = `
+name: test-proj
+services:
+  web:
+    project: src/web
+    language: js
+    host: appservice
+pipeline:
+  environments:
+    dev:
+    prod:
+      reviewers:
+        - octocat
+        - contoso/ops
+`
+
+	e := environment.Environment{Values: make(map[string]string)}
+	e.SetEnvName("test-env")
+
+	projectConfig, err := ParseProjectConfig(testProj, &e)
+	require.NoError(t, err)
+
+	require.Len(t, projectConfig.Pipeline.Environments, 2)
+	require.Nil(t, projectConfig.Pipeline.Environments["dev"])
+	require.Equal(t, []string{"octocat", "contoso/ops"}, projectConfig.Pipeline.Environments["prod"].Reviewers)
+}
@@ -7,10 +7,13 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/azure/azure-dev/cli/azd/pkg/commands"
 	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/rzip"
 	"github.com/azure/azure-dev/cli/azd/pkg/tools"
 )
 
@@ -25,6 +28,8 @@ type Service struct {
 	Target ServiceTarget
 	// The deployment scope of the service, ex) subscriptionId, resource group name & resource name
 	Scope *environment.DeploymentScope
+	// The environment the service is deployed to
+	Env *environment.Environment
 }
 
 type ServiceDeploymentChannelResponse struct {
@@ -43,6 +48,97 @@ func (svc *Service) RequiredExternalTools() []tools.ExternalTool {
 }
 
 func (svc *Service) Deploy(ctx context.Context, azdCtx *environment.AzdContext) (<-chan *ServiceDeploymentChannelResponse, <-chan string) {
+	// The source is hashed before it is packaged, since building may write to the source tree.
+	var sourceHash string
+	if svc.Project != nil && svc.Project.History != nil {
+		hash, err := svc.SourceHash()
+		if err != nil {
+			log.Printf("failed hashing source of service %s: %v", svc.Config.Name, err)
+		}
+		sourceHash = hash
+	}
+
+	return svc.deploy(ctx, azdCtx, sourceHash, func(progress chan<- string) (string, func(), error) {
+		log.Printf("packing service %s", svc.Config.Name)
+
+		progress <- "Preparing packaging"
+		artifact, err := svc.Framework.Package(ctx, progress)
+		if err != nil {
+			return "", nil, fmt.Errorf("packaging service %s: %w", svc.Config.Name, err)
+		}
+
+		return artifact, func() {}, nil
+	})
+}
+
+// UnchangedDeployment returns the most recent deployment of the service when it is healthy and its source has not
+// changed since, in which case the service does not need to be deployed again. nil is returned when the service has
+// changed, when its most recent deployment is not healthy, or when its deployments are not recorded.
+func (svc *Service) UnchangedDeployment() (*DeploymentRecord, error) {
+	if svc.Project == nil || svc.Project.History == nil {
+		return nil, nil
+	}
+
+	latest, err := svc.Project.History.Latest(svc.Config.Name)
+	if err != nil || latest == nil || latest.SourceHash == "" || !latest.Healthy() {
+		return nil, err
+	}
+
+	sourceHash, err := svc.SourceHash()
+	if err != nil {
+		return nil, err
+	}
+
+	if sourceHash != latest.SourceHash {
+		return nil, nil
+	}
+
+	return latest, nil
+}
+
+// DeployPackage deploys an artifact created by `azd package` instead of packaging the service. dir is the directory
+// containing the package manifest.
+func (svc *Service) DeployPackage(
+	ctx context.Context, azdCtx *environment.AzdContext, dir string, servicePackage ServicePackage,
+) (<-chan *ServiceDeploymentChannelResponse, <-chan string) {
+	return svc.deploy(ctx, azdCtx, "", func(progress chan<- string) (string, func(), error) {
+		if servicePackage.Host != svc.Config.GetServiceTargetKind() {
+			return "", nil, fmt.Errorf(
+				"the package for service %s was created for host '%s' but the service is hosted by '%s'",
+				svc.Config.Name, servicePackage.Host, svc.Config.GetServiceTargetKind())
+		}
+
+		switch servicePackage.Kind {
+		case ImagePackage:
+			return servicePackage.Image, func() {}, nil
+		case ZipPackage:
+			progress <- "Extracting package"
+			extractDir, err := os.MkdirTemp("", "azd")
+			if err != nil {
+				return "", nil, fmt.Errorf("creating directory for package of %s: %w", svc.Config.Name, err)
+			}
+
+			cleanup := func() { os.RemoveAll(extractDir) }
+			if err := rzip.ExtractToDirectory(filepath.Join(dir, servicePackage.Path), extractDir); err != nil {
+				cleanup()
+				return "", nil, fmt.Errorf("extracting package of %s: %w", svc.Config.Name, err)
+			}
+
+			return extractDir, cleanup, nil
+		default:
+			return "", nil, fmt.Errorf("unsupported package kind '%s' for service %s", servicePackage.Kind, svc.Config.Name)
+		}
+	})
+}
+
+// deploy deploys the artifact returned by getArtifact, which also returns a function to clean up the artifact
+// once it has been deployed. sourceHash is recorded with the deployment, when it was built from source.
+func (svc *Service) deploy(
+	ctx context.Context,
+	azdCtx *environment.AzdContext,
+	sourceHash string,
+	getArtifact func(progress chan<- string) (string, func(), error),
+) (<-chan *ServiceDeploymentChannelResponse, <-chan string) {
 	result := make(chan *ServiceDeploymentChannelResponse, 1)
 	progress := make(chan string)
 
@@ -50,13 +146,10 @@ func (svc *Service) Deploy(ctx context.Context, azdCtx *environment.AzdContext)
 		defer close(result)
 		defer close(progress)
 
-		log.Printf("packing service %s", svc.Config.Name)
-
-		progress <- "Preparing packaging"
-		artifact, err := svc.Framework.Package(ctx, progress)
+		artifact, cleanup, err := getArtifact(progress)
 		if err != nil {
 			result <- &ServiceDeploymentChannelResponse{
-				Error: fmt.Errorf("packaging service %s: %w", svc.Config.Name, err),
+				Error: err,
 			}
 
 			return
@@ -66,11 +159,37 @@ func (svc *Service) Deploy(ctx context.Context, azdCtx *environment.AzdContext)
 
 		progress <- "Preparing for deployment"
 		res, err := svc.Target.Deploy(ctx, azdCtx, artifact, progress)
+		if err == nil && svc.Config.HealthCheck != nil {
+			res.Health, err = checkHealth(ctx, svc.Config.HealthCheck, res.Endpoints, progress)
+			if err != nil {
+				err = fmt.Errorf("checking health: %w", err)
+			}
+		}
+		// Deployments of services which are not healthy are recorded along with their health, so they are neither
+		// rolled back to nor skipped when deployed again.
+		if err == nil && svc.Project != nil && svc.Project.History != nil {
+			// The deployment has already succeeded, so failing to record it is not an error.
+			progress <- "Recording deployment"
+			if _, recordErr := svc.Project.History.Record(ctx, svc.Config, artifact, res, sourceHash); recordErr != nil {
+				log.Printf("failed recording deployment of service %s: %v", svc.Config.Name, recordErr)
+			}
+		}
+		if err == nil && res.Health != nil && !res.Health.Healthy {
+			err = fmt.Errorf("service is not healthy: %s", res.Health.Error)
+		}
+		cleanup()
 		if err != nil {
-			result <- &ServiceDeploymentChannelResponse{
+			response := &ServiceDeploymentChannelResponse{
 				Error: fmt.Errorf("deploying service %s package: %w", svc.Config.Name, err),
 			}
 
+			// The result of a service which is not healthy is reported along with the error.
+			if res.Health != nil {
+				response.Result = &res
+			}
+
+			result <- response
+
 			return
 		}
 
@@ -7,6 +7,7 @@ import (
 
 	"github.com/azure/azure-dev/cli/azd/pkg/commands"
 	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/ext"
 	"github.com/azure/azure-dev/cli/azd/pkg/tools"
 )
 
@@ -29,6 +30,12 @@ type ServiceConfig struct {
 	Module string `yaml:"module"`
 	// The optional docker options
 	Docker DockerProjectOptions `yaml:"docker"`
+	// The names of the services that must be deployed before this service
+	DependsOn []string `yaml:"dependsOn,omitempty"`
+	// The lifecycle hooks to run for this service
+	Hooks map[string]*ext.HookConfig `yaml:"hooks,omitempty"`
+	// The optional probe of the endpoint of the service, run once it is deployed
+	HealthCheck *HealthCheckConfig `yaml:"healthCheck,omitempty"`
 }
 
 // Path returns the fully qualified path to the project
@@ -54,6 +61,7 @@ func (sc *ServiceConfig) GetService(ctx context.Context, project *Project, env *
 		Framework: *framework,
 		Target:    *serviceTarget,
 		Scope:     scope,
+		Env:       env,
 	}, nil
 }
 
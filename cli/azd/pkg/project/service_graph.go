@@ -0,0 +1,215 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package project
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.uber.org/multierr"
+)
+
+// ServiceGraph is the dependency graph between the services of a project, as declared by the `dependsOn` property of
+// each service in azure.yaml.
+type ServiceGraph struct {
+	// The names of all services in dependency order. Services which do not depend on each other are ordered by name.
+	order []string
+	// The index of each service in order
+	index map[string]int
+	// The direct dependencies of each service
+	dependsOn map[string][]string
+}
+
+// NewServiceGraph builds the dependency graph for a set of services. An error is returned when a service depends on a
+// service which does not exist, or when the dependencies form a cycle.
+func NewServiceGraph(services map[string]*ServiceConfig) (*ServiceGraph, error) {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	dependsOn := map[string][]string{}
+	for _, name := range names {
+		seen := map[string]bool{}
+		for _, dep := range services[name].DependsOn {
+			if _, has := services[dep]; !has {
+				return nil, fmt.Errorf("service '%s' depends on service '%s' which does not exist", name, dep)
+			}
+
+			if dep == name {
+				return nil, fmt.Errorf("service '%s' depends on itself", name)
+			}
+
+			if !seen[dep] {
+				seen[dep] = true
+				dependsOn[name] = append(dependsOn[name], dep)
+			}
+		}
+	}
+
+	order := make([]string, 0, len(names))
+	state := map[string]int{}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+
+	// visit does a depth first traversal of the dependencies of name, appending services to order once all of their
+	// dependencies have been added. path tracks the services currently being visited so a cycle can be reported.
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			start := 0
+			for i, p := range path {
+				if p == name {
+					start = i
+					break
+				}
+			}
+
+			cycle := append(append([]string{}, path[start:]...), name)
+			return fmt.Errorf("services have a circular dependency: %s", strings.Join(cycle, " -> "))
+		}
+
+		state[name] = visiting
+		deps := append([]string{}, dependsOn[name]...)
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		order = append(order, name)
+
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	index := make(map[string]int, len(order))
+	for i, name := range order {
+		index[name] = i
+	}
+
+	return &ServiceGraph{
+		order:     order,
+		index:     index,
+		dependsOn: dependsOn,
+	}, nil
+}
+
+// Order returns the names of all services such that each service comes after all of the services it depends on.
+func (g *ServiceGraph) Order() []string {
+	return append([]string{}, g.order...)
+}
+
+// DependsOn returns the names of the services the given service directly depends on.
+func (g *ServiceGraph) DependsOn(name string) []string {
+	return append([]string{}, g.dependsOn[name]...)
+}
+
+// Walk invokes fn for each of the named services, running at most parallelism invocations at the same time. fn is only
+// invoked for a service after it has returned successfully for each of the named services it depends on. Dependencies
+// which are not part of services are considered satisfied.
+//
+// When fn fails for a service, no further services are started, services which are already running are allowed to
+// complete, and the errors are returned.
+func (g *ServiceGraph) Walk(
+	ctx context.Context, services []string, parallelism int, fn func(ctx context.Context, name string) error) error {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	selected := map[string]bool{}
+	for _, name := range services {
+		if _, has := g.index[name]; !has {
+			return fmt.Errorf("service '%s' is not part of the graph", name)
+		}
+
+		selected[name] = true
+	}
+
+	// pending tracks the number of dependencies of each service which have not completed yet and dependents tracks the
+	// services which are waiting on each service.
+	pending := map[string]int{}
+	dependents := map[string][]string{}
+	var ready []string
+
+	for _, name := range g.order {
+		if !selected[name] {
+			continue
+		}
+
+		for _, dep := range g.dependsOn[name] {
+			if selected[dep] {
+				pending[name]++
+				dependents[dep] = append(dependents[dep], name)
+			}
+		}
+
+		if pending[name] == 0 {
+			ready = append(ready, name)
+		}
+	}
+
+	type walkResult struct {
+		name string
+		err  error
+	}
+
+	results := make(chan walkResult)
+	running := 0
+
+	var errs error
+
+	for {
+		for errs == nil && running < parallelism && len(ready) > 0 {
+			name := ready[0]
+			ready = ready[1:]
+			running++
+
+			go func() {
+				results <- walkResult{name: name, err: fn(ctx, name)}
+			}()
+		}
+
+		if running == 0 {
+			break
+		}
+
+		res := <-results
+		running--
+
+		if res.err != nil {
+			errs = multierr.Append(errs, res.err)
+			continue
+		}
+
+		for _, dependent := range dependents[res.name] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+
+		// Keep the services which are ready to run in dependency order so services start in a predictable order.
+		sort.Slice(ready, func(i, j int) bool {
+			return g.index[ready[i]] < g.index[ready[j]]
+		})
+	}
+
+	return errs
+}
@@ -0,0 +1,135 @@
+package project
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServices(dependsOn map[string][]string) map[string]*ServiceConfig {
+	services := map[string]*ServiceConfig{}
+	for name, deps := range dependsOn {
+		services[name] = &ServiceConfig{Name: name, DependsOn: deps}
+	}
+
+	return services
+}
+
+func TestServiceGraphOrder(t *testing.T) {
+	graph, err := NewServiceGraph(newTestServices(map[string][]string{
+		"web":    {"api"},
+		"api":    {"db", "worker"},
+		"worker": {"db"},
+		"db":     nil,
+		"admin":  nil,
+	}))
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"admin", "db", "worker", "api", "web"}, graph.Order())
+	require.Equal(t, []string{"db", "worker"}, graph.DependsOn("api"))
+}
+
+func TestServiceGraphErrors(t *testing.T) {
+	t.Run("UnknownService", func(t *testing.T) {
+		_, err := NewServiceGraph(newTestServices(map[string][]string{
+			"web": {"api"},
+		}))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "service 'web' depends on service 'api' which does not exist")
+	})
+
+	t.Run("SelfReference", func(t *testing.T) {
+		_, err := NewServiceGraph(newTestServices(map[string][]string{
+			"web": {"web"},
+		}))
+		require.Error(t, err)
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		_, err := NewServiceGraph(newTestServices(map[string][]string{
+			"api":    {"worker"},
+			"web":    {"api"},
+			"worker": {"web"},
+		}))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "circular dependency: api -> worker -> web -> api")
+	})
+}
+
+func TestServiceGraphWalk(t *testing.T) {
+	graph, err := NewServiceGraph(newTestServices(map[string][]string{
+		"web":    {"api"},
+		"api":    {"db"},
+		"worker": {"db"},
+		"db":     nil,
+	}))
+	require.NoError(t, err)
+
+	t.Run("Serial", func(t *testing.T) {
+		var visited []string
+		err := graph.Walk(context.Background(), graph.Order(), 1, func(ctx context.Context, name string) error {
+			visited = append(visited, name)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"db", "api", "web", "worker"}, graph.Order())
+		require.Equal(t, graph.Order(), visited)
+	})
+
+	t.Run("Parallel", func(t *testing.T) {
+		var mu sync.Mutex
+		completed := map[string]bool{}
+		running, maxRunning := 0, 0
+
+		err := graph.Walk(context.Background(), graph.Order(), 2, func(ctx context.Context, name string) error {
+			mu.Lock()
+			for _, dep := range graph.DependsOn(name) {
+				require.True(t, completed[dep], "%s started before its dependency %s completed", name, dep)
+			}
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			completed[name] = true
+			mu.Unlock()
+
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, completed, 4)
+		require.Equal(t, 2, maxRunning)
+	})
+
+	t.Run("Subset", func(t *testing.T) {
+		var visited []string
+		err := graph.Walk(context.Background(), []string{"web"}, 1, func(ctx context.Context, name string) error {
+			visited = append(visited, name)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"web"}, visited)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		var visited []string
+		err := graph.Walk(context.Background(), graph.Order(), 1, func(ctx context.Context, name string) error {
+			visited = append(visited, name)
+			if name == "api" {
+				return errors.New("deploying api failed")
+			}
+			return nil
+		})
+		require.Error(t, err)
+		require.Equal(t, []string{"db", "api"}, visited)
+	})
+}
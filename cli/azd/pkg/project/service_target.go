@@ -26,6 +26,13 @@ type ServiceDeploymentResult struct {
 	Kind             ServiceTargetKind `json:"kind"`
 	Details          interface{}       `json:"details"`
 	Endpoints        []string          `json:"endpoints"`
+	// The container image which was deployed, for targets which run container images
+	Image string `json:"image,omitempty"`
+	// True when the service was not deployed since it has not changed since its last deployment, in which case the
+	// result describes that deployment
+	Skipped bool `json:"skipped,omitempty"`
+	// The result of the health check of the service, when one is configured
+	Health *HealthCheckResult `json:"health,omitempty"`
 }
 
 type ServiceTarget interface {
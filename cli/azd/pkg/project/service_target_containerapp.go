@@ -18,15 +18,15 @@ import (
 	"github.com/azure/azure-dev/cli/azd/pkg/iac/bicep"
 	"github.com/azure/azure-dev/cli/azd/pkg/osutil"
 	"github.com/azure/azure-dev/cli/azd/pkg/tools"
-	"github.com/drone/envsubst"
 )
 
 type containerAppTarget struct {
-	config *ServiceConfig
-	env    *environment.Environment
-	scope  *environment.DeploymentScope
-	cli    tools.AzCli
-	docker *tools.Docker
+	config   *ServiceConfig
+	env      *environment.Environment
+	scope    *environment.DeploymentScope
+	cli      tools.AzCli
+	bicepCli tools.BicepCli
+	docker   *tools.Docker
 }
 
 func (at *containerAppTarget) RequiredExternalTools() []tools.ExternalTool {
@@ -37,13 +37,13 @@ func (at *containerAppTarget) Deploy(ctx context.Context, azdCtx *environment.Az
 	bicepPath := azdCtx.BicepModulePath(at.config.Module)
 
 	progress <- "Creating deployment template"
-	template, err := bicep.Compile(ctx, tools.NewBicepCli(at.cli), bicepPath)
+	template, err := bicep.Compile(ctx, at.bicepCli, bicepPath)
 	if err != nil {
 		return ServiceDeploymentResult{}, err
 	}
 
 	// Login to container registry.
-	loginServer, has := at.env.Values[environment.ContainerRegistryEndpointEnvVarName]
+	loginServer, has := at.env.Lookup(environment.ContainerRegistryEndpointEnvVarName)
 	if !has {
 		return ServiceDeploymentResult{}, fmt.Errorf("could not determine container registry endpoint, ensure %s is set as an output of your infrastructure", environment.ContainerRegistryEndpointEnvVarName)
 	}
@@ -55,27 +55,32 @@ func (at *containerAppTarget) Deploy(ctx context.Context, azdCtx *environment.Az
 		return ServiceDeploymentResult{}, fmt.Errorf("logging into registry '%s': %w", loginServer, err)
 	}
 
-	fullTag := fmt.Sprintf("%s/%s/%s:azdev-deploy-%d", loginServer, at.scope.ResourceName(), at.scope.ResourceName(), time.Now().Unix())
-
-	// Tag image.
-	log.Printf("tagging image %s as %s", path, fullTag)
-	progress <- "Tagging image"
-	if err := at.docker.Tag(ctx, at.config.Path(), path, fullTag); err != nil {
-		return ServiceDeploymentResult{}, fmt.Errorf("tagging image: %w", err)
-	}
+	// Images which are already in the registry, such as the image of a previous deployment which is being rolled back
+	// to, are deployed as is.
+	fullTag := path
+	if !strings.HasPrefix(path, loginServer+"/") {
+		fullTag = fmt.Sprintf("%s/%s/%s:azdev-deploy-%d", loginServer, at.scope.ResourceName(), at.scope.ResourceName(), time.Now().Unix())
+
+		// Tag image.
+		log.Printf("tagging image %s as %s", path, fullTag)
+		progress <- "Tagging image"
+		if err := at.docker.Tag(ctx, at.config.Path(), path, fullTag); err != nil {
+			return ServiceDeploymentResult{}, fmt.Errorf("tagging image: %w", err)
+		}
 
-	log.Printf("pushing %s to registry", fullTag)
+		log.Printf("pushing %s to registry", fullTag)
 
-	// Push image.
-	progress <- "Pushing container image"
-	if err := at.docker.Push(ctx, at.config.Path(), fullTag); err != nil {
-		return ServiceDeploymentResult{}, fmt.Errorf("pushing image: %w", err)
+		// Push image.
+		progress <- "Pushing container image"
+		if err := at.docker.Push(ctx, at.config.Path(), fullTag); err != nil {
+			return ServiceDeploymentResult{}, fmt.Errorf("pushing image: %w", err)
+		}
 	}
 
 	log.Printf("writing image name to environment")
 
 	// Save the name of the image we pushed into the environment with a well known key.
-	at.env.Values[fmt.Sprintf("SERVICE_%s_IMAGE_NAME", strings.ToUpper(at.config.Name))] = fullTag
+	at.env.SetSystemValue(fmt.Sprintf("SERVICE_%s_IMAGE_NAME", strings.ToUpper(at.config.Name)), fullTag)
 
 	if err := at.env.Save(); err != nil {
 		return ServiceDeploymentResult{}, fmt.Errorf("saving image name to environment: %w", err)
@@ -90,16 +95,21 @@ func (at *containerAppTarget) Deploy(ctx context.Context, azdCtx *environment.Az
 		return ServiceDeploymentResult{}, fmt.Errorf("reading parameter file template: %w", err)
 	}
 
-	replaced, err := envsubst.Eval(string(templateBytes), func(name string) string {
-		if val, has := at.env.Values[name]; has {
-			return val
-		}
-		return os.Getenv(name)
-	})
+	// Parameters referencing values which are only kept in memory are not written to the parameters file.
+	replaced, inMemoryParameters, err := environment.SubstituteBicepParameters(string(templateBytes), at.env)
 	if err != nil {
 		return ServiceDeploymentResult{}, fmt.Errorf("substituting parameter file: %w", err)
 	}
 
+	// Values which reference Key Vault secrets are passed as Key Vault references, as when provisioning.
+	replaced, err = environment.WithKeyVaultReferences(replaced, func(vaultName string) (string, error) {
+		vault, err := at.cli.GetKeyVault(ctx, at.env.GetSubscriptionId(), vaultName)
+		return vault.Id, err
+	})
+	if err != nil {
+		return ServiceDeploymentResult{}, err
+	}
+
 	parametersFile := azdCtx.BicepParametersFilePath(at.env.GetEnvName(), at.config.Module)
 
 	// If the bicep uses nested modules ensure the full directory tree
@@ -115,11 +125,17 @@ func (at *containerAppTarget) Deploy(ctx context.Context, azdCtx *environment.Az
 	}
 	log.Printf("generated deployment parameters file %s", parametersFile)
 
+	deploymentParametersFile, remove, err := environment.TemporaryBicepParametersFile(parametersFile, inMemoryParameters)
+	if err != nil {
+		return ServiceDeploymentResult{}, err
+	}
+	defer remove()
+
 	log.Printf("running ARM deployment to update container")
 	deploymentTarget := bicep.NewResourceGroupDeploymentTarget(at.cli, at.env.GetSubscriptionId(), at.scope.ResourceGroupName(), at.scope.ResourceName())
 
 	progress <- "Updating container app image reference"
-	res, err := bicep.Deploy(ctx, deploymentTarget, azdCtx.BicepModulePath(at.config.Module), parametersFile)
+	res, err := bicep.Deploy(ctx, deploymentTarget, azdCtx.BicepModulePath(at.config.Module), deploymentParametersFile)
 	if err != nil {
 		return ServiceDeploymentResult{}, fmt.Errorf("updating infrastructure: %w", err)
 	}
@@ -129,8 +145,14 @@ func (at *containerAppTarget) Deploy(ctx context.Context, azdCtx *environment.Az
 
 		template.CanonicalizeDeploymentOutputs(&res.Properties.Outputs)
 
+		outputs := make(map[string]string, len(res.Properties.Outputs))
 		for name, o := range res.Properties.Outputs {
-			at.env.Values[name] = fmt.Sprintf("%v", o.Value)
+			outputs[name] = fmt.Sprintf("%v", o.Value)
+		}
+
+		changes := at.env.ApplyOutputs(at.config.Module, outputs)
+		for _, name := range changes.Skipped {
+			log.Printf("not saving output %s, since the environment has a value of the same name which was not set by a deployment", name)
 		}
 
 		if err := at.env.Save(); err != nil {
@@ -149,6 +171,7 @@ func (at *containerAppTarget) Deploy(ctx context.Context, azdCtx *environment.Az
 		Kind:             ContainerAppTarget,
 		Details:          res,
 		Endpoints:        endpoints,
+		Image:            fullTag,
 	}, nil
 }
 
@@ -163,10 +186,11 @@ func (at *containerAppTarget) Endpoints(ctx context.Context) ([]string, error) {
 
 func NewContainerAppTarget(config *ServiceConfig, env *environment.Environment, scope *environment.DeploymentScope, azCli tools.AzCli, docker *tools.Docker) ServiceTarget {
 	return &containerAppTarget{
-		config: config,
-		env:    env,
-		scope:  scope,
-		cli:    azCli,
-		docker: docker,
+		config:   config,
+		env:      env,
+		scope:    scope,
+		cli:      azCli,
+		bicepCli: tools.NewBicepCli(azCli),
+		docker:   docker,
 	}
 }
@@ -0,0 +1,213 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package project
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/executil"
+	"github.com/azure/azure-dev/cli/azd/pkg/osutil"
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeContainerAppAzCli implements the calls of container app deployments, recording the parameters of each module
+// which is deployed.
+type fakeContainerAppAzCli struct {
+	tools.AzCli
+
+	mu         sync.Mutex
+	parameters map[string]map[string]interface{}
+}
+
+func (cli *fakeContainerAppAzCli) LoginAcr(_ context.Context, _ string, _ string) error {
+	return nil
+}
+
+func (cli *fakeContainerAppAzCli) GetKeyVault(_ context.Context, _ string, vaultName string) (tools.AzCliKeyVault, error) {
+	return tools.AzCliKeyVault{Id: "/vaults/" + vaultName, Name: vaultName}, nil
+}
+
+func (cli *fakeContainerAppAzCli) DeployToResourceGroup(
+	_ context.Context, _ string, _ string, deploymentName string, _ string, parametersPath string,
+) (tools.AzCliDeploymentResult, error) {
+	contents, err := os.ReadFile(parametersPath)
+	if err != nil {
+		return tools.AzCliDeploymentResult{}, err
+	}
+
+	var doc struct {
+		Parameters map[string]interface{} `json:"parameters"`
+	}
+	if err := json.Unmarshal(contents, &doc); err != nil {
+		return tools.AzCliDeploymentResult{}, err
+	}
+
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	cli.parameters[deploymentName] = doc.Parameters
+	return tools.AzCliDeploymentResult{}, nil
+}
+
+func (cli *fakeContainerAppAzCli) GetResourceGroupDeployment(
+	_ context.Context, _ string, _ string, deploymentName string,
+) (tools.AzCliDeployment, error) {
+	return tools.AzCliDeployment{
+		Name: deploymentName,
+		Properties: tools.AzCliDeploymentProperties{
+			Outputs: map[string]tools.AzCliDeploymentOutput{
+				"SERVICE_" + deploymentName + "_URL": {Type: "string", Value: "https://" + deploymentName},
+			},
+		},
+	}, nil
+}
+
+func (cli *fakeContainerAppAzCli) GetContainerAppProperties(
+	_ context.Context, _ string, _ string, applicationName string,
+) (tools.AzCliContainerAppProperties, error) {
+	properties := tools.AzCliContainerAppProperties{}
+	properties.Properties.Configuration.Ingress.Fqdn = applicationName + ".example.com"
+	return properties, nil
+}
+
+type fakeBicepCli struct{}
+
+func (cli *fakeBicepCli) Name() string                                   { return "Bicep CLI" }
+func (cli *fakeBicepCli) InstallUrl() string                             { return "" }
+func (cli *fakeBicepCli) CheckInstalled(_ context.Context) (bool, error) { return true, nil }
+
+func (cli *fakeBicepCli) Build(_ context.Context, _ string) (string, error) {
+	return `{"parameters": {}, "outputs": {}}`, nil
+}
+
+// newTestContainerAppTargets creates the targets of container app services, which deploy the modules of the same names
+// with the given parameters file, to a new environment with the given values.
+func newTestContainerAppTargets(
+	t *testing.T, parameters string, values map[string]string, names ...string,
+) (*environment.AzdContext, *environment.Environment, *fakeContainerAppAzCli, []ServiceTarget) {
+	projectDir := t.TempDir()
+	azdCtx := &environment.AzdContext{}
+	azdCtx.SetProjectDirectory(projectDir)
+
+	require.NoError(t, os.MkdirAll(azdCtx.InfrastructureDirectory(), osutil.PermissionDirectory))
+
+	env := environment.Empty(azdCtx.GetEnvironmentFilePath("dev"))
+	env.SetEnvName("dev")
+	env.SetSubscriptionId("SUBSCRIPTION_ID")
+	env.Values[environment.ContainerRegistryEndpointEnvVarName] = "registry.example.com"
+	for name, value := range values {
+		env.Values[name] = value
+	}
+
+	azCli := &fakeContainerAppAzCli{parameters: map[string]map[string]interface{}{}}
+	docker := tools.NewDocker(tools.DockerArgs{
+		RunWithResultFn: func(_ context.Context, _ executil.RunArgs) (executil.RunResult, error) {
+			return executil.RunResult{}, nil
+		},
+	})
+
+	prj := &ProjectConfig{Path: projectDir}
+	targets := []ServiceTarget{}
+	for _, name := range names {
+		require.NoError(t, os.WriteFile(
+			azdCtx.BicepParametersTemplateFilePath(name), []byte(parameters), osutil.PermissionFile))
+
+		target := NewContainerAppTarget(
+			&ServiceConfig{Project: prj, Name: name, Module: name, Host: string(ContainerAppTarget)},
+			&env,
+			environment.NewDeploymentScope("SUBSCRIPTION_ID", "rg", name),
+			azCli,
+			docker,
+		).(*containerAppTarget)
+		target.bicepCli = &fakeBicepCli{}
+		targets = append(targets, target)
+	}
+
+	return azdCtx, &env, azCli, targets
+}
+
+func deployContainerApp(t *testing.T, azdCtx *environment.AzdContext, target ServiceTarget) ServiceDeploymentResult {
+	progress := make(chan string)
+	go func() {
+		for range progress {
+		}
+	}()
+	defer close(progress)
+
+	result, err := target.Deploy(context.Background(), azdCtx, "image:v1", progress)
+	require.NoError(t, err)
+	return result
+}
+
+func TestContainerAppDeployWithSecretReference(t *testing.T) {
+	azdCtx, env, azCli, targets := newTestContainerAppTargets(t, `{
+  "parameters": {
+    "dbPassword": {"value": "${DB_PASSWORD}"},
+    "imageName": {"value": "${SERVICE_WEB_IMAGE_NAME}"}
+  }
+}`, map[string]string{"DB_PASSWORD": "akvs://my-vault/dev-DB-PASSWORD"}, "web")
+
+	result := deployContainerApp(t, azdCtx, targets[0])
+	require.Equal(t, []string{"https://web.example.com/"}, result.Endpoints)
+
+	require.Equal(t, map[string]interface{}{
+		"reference": map[string]interface{}{
+			"keyVault":   map[string]interface{}{"id": "/vaults/my-vault"},
+			"secretName": "dev-DB-PASSWORD",
+		},
+	}, azCli.parameters["web"]["dbPassword"])
+	require.Equal(t, map[string]interface{}{"value": result.Image}, azCli.parameters["web"]["imageName"])
+
+	// The reference is saved in the environment, rather than the value of the secret.
+	require.Equal(t, "akvs://my-vault/dev-DB-PASSWORD", env.Values["DB_PASSWORD"])
+}
+
+// Services deployed at the same time share the environment, which `go test -race` checks is safe.
+func TestContainerAppDeployConcurrently(t *testing.T) {
+	azdCtx, env, azCli, targets := newTestContainerAppTargets(t, `{
+  "parameters": {
+    "environmentName": {"value": "${AZURE_ENV_NAME}"}
+  }
+}`, nil, "api", "web")
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(targets))
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target ServiceTarget) {
+			defer wg.Done()
+
+			progress := make(chan string)
+			go func() {
+				for range progress {
+				}
+			}()
+			defer close(progress)
+
+			_, errs[i] = target.Deploy(context.Background(), azdCtx, "image:v1", progress)
+		}(i, target)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	for _, name := range []string{"api", "web"} {
+		require.Equal(t, map[string]interface{}{"value": "dev"}, azCli.parameters[name]["environmentName"])
+		require.Contains(t, env.Values, "SERVICE_"+strings.ToUpper(name)+"_IMAGE_NAME")
+		require.Equal(t, "https://"+name, env.Values["SERVICE_"+name+"_URL"])
+	}
+
+	saved, err := environment.FromFile(env.File)
+	require.NoError(t, err)
+	require.Equal(t, env.Values, saved.Values)
+	require.Equal(t, env.Metadata, saved.Metadata)
+}
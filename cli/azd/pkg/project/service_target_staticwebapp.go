@@ -8,7 +8,6 @@ import (
 	"fmt"
 	"log"
 	"strings"
-	"time"
 
 	"github.com/azure/azure-dev/cli/azd/pkg/azure"
 	"github.com/azure/azure-dev/cli/azd/pkg/environment"
@@ -94,29 +93,22 @@ func (at *staticWebAppTarget) Endpoints(ctx context.Context) ([]string, error) {
 }
 
 func (at *staticWebAppTarget) verifyDeployment(ctx context.Context, progress chan<- string) error {
-	verifyMsg := "Verifying deployment"
-	retries := 0
 	const maxRetries = 10
 
-	for {
-		progress <- verifyMsg
+	ready, status, err := waitUntil(ctx, progress, "Verifying deployment", maxRetries, func() (bool, string, error) {
 		envProps, err := at.cli.GetStaticWebAppEnvironmentProperties(ctx, at.env.GetSubscriptionId(), at.scope.ResourceGroupName(), at.scope.ResourceName(), DefaultStaticWebAppEnvironmentName)
 		if err != nil {
-			return fmt.Errorf("failed verifying static web app deployment: %w", err)
+			return false, "", fmt.Errorf("failed verifying static web app deployment: %w", err)
 		}
 
-		if envProps.Status == "Ready" {
-			break
-		}
-
-		retries++
-
-		if retries >= maxRetries {
-			return fmt.Errorf("failed verifying static web app deployment. Still in %s state", envProps.Status)
-		}
+		return envProps.Status == "Ready", envProps.Status, nil
+	})
+	if err != nil {
+		return err
+	}
 
-		verifyMsg += "."
-		time.Sleep(5 * time.Second)
+	if !ready {
+		return fmt.Errorf("failed verifying static web app deployment. Still in %s state", status)
 	}
 
 	return nil
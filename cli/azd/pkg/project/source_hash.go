@@ -0,0 +1,387 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package project
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/drone/envsubst"
+)
+
+// The ignore files which are respected when hashing the source of a service. Both use the syntax of .gitignore files.
+var sourceIgnoreFileNames = []string{".gitignore", ".azdignore"}
+
+// ignoreRule is a single pattern from an ignore file.
+type ignoreRule struct {
+	// The directory containing the ignore file, relative to the project root, using forward slashes
+	base    string
+	regex   *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// parseIgnoreRules parses the contents of an ignore file located in the directory base.
+func parseIgnoreRules(base string, r io.Reader) ([]ignoreRule, error) {
+	var rules []ignoreRule
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{base: base}
+
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+
+		line = strings.TrimPrefix(line, `\`)
+
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+
+		// Patterns containing a separator are relative to the directory of the ignore file, others match at any depth.
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			continue
+		}
+
+		expr := globToRegex(line)
+		if anchored {
+			expr = "^" + expr + "$"
+		} else {
+			expr = "^(.*/)?" + expr + "$"
+		}
+
+		regex, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ignore pattern '%s': %w", line, err)
+		}
+
+		rule.regex = regex
+		rules = append(rules, rule)
+	}
+
+	return rules, scanner.Err()
+}
+
+// globToRegex converts a glob pattern, which may contain `**` to match any number of directories, to a regular
+// expression.
+func globToRegex(pattern string) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			sb.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "/**") && i+3 == len(pattern):
+			sb.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end == -1 {
+				sb.WriteString(`\[`)
+				continue
+			}
+
+			class := pattern[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += end
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return sb.String()
+}
+
+// isIgnored returns true when the path, relative to the project root and using forward slashes, is excluded by
+// the rules. As with git, the last matching rule wins.
+func isIgnored(rules []ignoreRule, path string, isDir bool) bool {
+	ignored := false
+
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		rel := path
+		if rule.base != "" {
+			if !strings.HasPrefix(path, rule.base+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(path, rule.base+"/")
+		}
+
+		if rule.regex.MatchString(rel) {
+			ignored = !rule.negate
+		}
+	}
+
+	return ignored
+}
+
+// hashDirectory writes the path and contents of each file under dir which is not ignored to hash, in lexical order.
+// Paths are relative to the project root, so the ignore files of the directories between the project root and dir
+// apply along with the ignore files found under dir.
+func hashDirectory(projectRoot string, dir string, hash io.Writer) error {
+	relDir, err := filepath.Rel(projectRoot, dir)
+	if err != nil {
+		return err
+	}
+
+	var rules []ignoreRule
+
+	if relDir != "." && !strings.HasPrefix(relDir, "..") {
+		ancestor := ""
+		for _, segment := range strings.Split(filepath.ToSlash(relDir), "/") {
+			ancestorRules, err := readIgnoreFiles(filepath.Join(projectRoot, ancestor), ancestor)
+			if err != nil {
+				return err
+			}
+			rules = append(rules, ancestorRules...)
+
+			ancestor = path.Join(ancestor, segment)
+		}
+	}
+
+	return filepath.WalkDir(dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(projectRoot, filePath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if entry.IsDir() {
+			if rel == "." {
+				rel = ""
+			} else if filePath != dir && (entry.Name() == ".git" || isIgnored(rules, rel, true)) {
+				return filepath.SkipDir
+			}
+
+			// WalkDir visits the entries of a directory in lexical order, so the rules of a directory are loaded
+			// before any of the files they apply to are visited.
+			dirRules, err := readIgnoreFiles(filePath, rel)
+			if err != nil {
+				return err
+			}
+			rules = append(rules, dirRules...)
+
+			return nil
+		}
+
+		if isIgnored(rules, rel, false) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintf(hash, "%s\x00%d\x00", rel, info.Mode().Perm()); err != nil {
+			return err
+		}
+
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(hash, f)
+		return err
+	})
+}
+
+// readIgnoreFiles reads the rules of the ignore files in dir, whose path relative to the project root is base.
+func readIgnoreFiles(dir string, base string) ([]ignoreRule, error) {
+	var rules []ignoreRule
+
+	for _, name := range sourceIgnoreFileNames {
+		fileRules, err := readIgnoreFile(filepath.Join(dir, name), base)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, fileRules...)
+	}
+
+	return rules, nil
+}
+
+func readIgnoreFile(path string, base string) ([]ignoreRule, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading ignore file: %w", err)
+	}
+	defer f.Close()
+
+	rules, err := parseIgnoreRules(base, f)
+	if err != nil {
+		return nil, fmt.Errorf("parsing ignore file %s: %w", path, err)
+	}
+
+	return rules, nil
+}
+
+// sourceRoots returns the directories whose contents are used to build the service. This is the project directory of
+// the service and, for container apps, the docker build context when it is outside of the project directory.
+func (sc *ServiceConfig) sourceRoots() []string {
+	roots := []string{sc.Path()}
+
+	if sc.GetServiceTargetKind() == ContainerAppTarget {
+		dockerOptions := getDockerOptionsWithDefaults(sc.Docker)
+		context := filepath.Clean(filepath.Join(sc.Path(), dockerOptions.Context))
+
+		if rel, err := filepath.Rel(sc.Path(), context); err == nil && strings.HasPrefix(rel, "..") {
+			roots = append(roots, context)
+		}
+	}
+
+	sort.Strings(roots)
+	return roots
+}
+
+// imageNameEnvVarRegex matches the names of the values which record the images of container apps, which change with
+// each deployment.
+var imageNameEnvVarRegex = regexp.MustCompile(`^SERVICE_.+_IMAGE_NAME$`)
+
+// environmentValues returns the values of the environment which are used to build and deploy the service: every value
+// for services built with npm, since they are injected into the build, and the values substituted into the parameters
+// of the module of container apps. The names of images are not included, since they change with each deployment.
+func (svc *Service) environmentValues() (map[string]string, error) {
+	values := map[string]string{}
+	if svc.Env == nil {
+		return values, nil
+	}
+
+	switch {
+	case svc.Config.GetServiceTargetKind() == ContainerAppTarget:
+		azdCtx := &environment.AzdContext{}
+		azdCtx.SetProjectDirectory(svc.Config.Project.Path)
+
+		template, err := os.ReadFile(azdCtx.BicepParametersTemplateFilePath(svc.Config.Module))
+		if errors.Is(err, os.ErrNotExist) {
+			return values, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("reading parameter file template: %w", err)
+		}
+
+		// The values are substituted as when deploying, which falls back to the environment of azd.
+		if _, err := envsubst.Eval(string(template), func(name string) string {
+			if value, has := svc.Env.Lookup(name); has {
+				values[name] = value
+			} else {
+				values[name] = os.Getenv(name)
+			}
+			return ""
+		}); err != nil {
+			return nil, fmt.Errorf("substituting parameter file: %w", err)
+		}
+	case svc.Config.Language == "js" || svc.Config.Language == "ts":
+		values = svc.Env.Snapshot()
+	}
+
+	for name := range values {
+		if imageNameEnvVarRegex.MatchString(name) {
+			delete(values, name)
+		}
+	}
+
+	return values, nil
+}
+
+// SourceHash computes a hash of the source of the service, along with the configuration and the values of the
+// environment used to build and deploy it. Files excluded by .gitignore or .azdignore files are not included.
+func (svc *Service) SourceHash() (string, error) {
+	hash := sha256.New()
+
+	// Building or deploying differently, or deploying to a different resource, invalidates the hash.
+	config, err := json.Marshal(struct {
+		Host, Language, OutputPath, Module string
+		Docker                             DockerProjectOptions
+		Scope                              []string
+	}{
+		Host:       string(svc.Config.GetServiceTargetKind()),
+		Language:   svc.Config.Language,
+		OutputPath: svc.Config.OutputPath,
+		Module:     svc.Config.Module,
+		Docker:     svc.Config.Docker,
+		Scope:      []string{svc.Scope.SubscriptionId(), svc.Scope.ResourceGroupName(), svc.Scope.ResourceName()},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := hash.Write(config); err != nil {
+		return "", err
+	}
+
+	values, err := svc.environmentValues()
+	if err != nil {
+		return "", fmt.Errorf("hashing environment of service %s: %w", svc.Config.Name, err)
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, err := fmt.Fprintf(hash, "%s\x00%s\x00", name, values[name]); err != nil {
+			return "", err
+		}
+	}
+
+	for _, root := range svc.Config.sourceRoots() {
+		if err := hashDirectory(svc.Config.Project.Path, root, hash); err != nil {
+			return "", fmt.Errorf("hashing source of service %s: %w", svc.Config.Name, err)
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
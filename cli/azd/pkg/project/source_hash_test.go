@@ -0,0 +1,232 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package project
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/osutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsIgnored(t *testing.T) {
+	rules, err := parseIgnoreRules("", strings.NewReader(`
+# comment
+node_modules/
+*.log
+!keep.log
+/build
+docs/**/*.md
+`))
+	require.NoError(t, err)
+
+	nestedRules, err := parseIgnoreRules("src/api", strings.NewReader("secrets.json\n"))
+	require.NoError(t, err)
+	rules = append(rules, nestedRules...)
+
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"node_modules", true, true},
+		{"src/api/node_modules", true, true},
+		{"node_modules", false, false},
+		{"app.log", false, true},
+		{"src/api/app.log", false, true},
+		{"keep.log", false, false},
+		{"build", true, true},
+		{"src/build", true, false},
+		{"docs/README.md", false, true},
+		{"docs/guide/intro.md", false, true},
+		{"docs/guide/intro.txt", false, false},
+		{"src/api/secrets.json", false, true},
+		{"src/web/secrets.json", false, false},
+		{"src/api/index.js", false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			require.Equal(t, test.ignored, isIgnored(rules, test.path, test.isDir))
+		})
+	}
+}
+
+func writeTestFile(t *testing.T, path string, contents string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), osutil.PermissionDirectory))
+	require.NoError(t, os.WriteFile(path, []byte(contents), osutil.PermissionFile))
+}
+
+func createTestService(t *testing.T) *Service {
+	projectConfig, err := ParseProjectConfig(projectYaml, env)
+	require.NoError(t, err)
+	projectConfig.Path = t.TempDir()
+
+	writeTestFile(t, filepath.Join(projectConfig.Path, ".gitignore"), "*.log\n")
+	writeTestFile(t, filepath.Join(projectConfig.Path, "src", "api", ".azdignore"), "dist/\n")
+	writeTestFile(t, filepath.Join(projectConfig.Path, "src", "api", "index.js"), "hello")
+
+	return &Service{
+		Project: &Project{Config: projectConfig, History: NewDeploymentHistory(filepath.Join(projectConfig.Path, ".azure", "test-env"))},
+		Config:  projectConfig.Services["api"],
+		Scope:   deploymentScope,
+	}
+}
+
+func TestSourceHash(t *testing.T) {
+	svc := createTestService(t)
+	apiPath := svc.Config.Path()
+
+	hash, err := svc.SourceHash()
+	require.NoError(t, err)
+	require.Len(t, hash, 64)
+
+	// Ignored files and files outside of the service do not change the hash
+	writeTestFile(t, filepath.Join(apiPath, "debug.log"), "log")
+	writeTestFile(t, filepath.Join(apiPath, "dist", "index.js"), "built")
+	writeTestFile(t, filepath.Join(svc.Config.Project.Path, "src", "web", "index.js"), "web")
+
+	unchangedHash, err := svc.SourceHash()
+	require.NoError(t, err)
+	require.Equal(t, hash, unchangedHash)
+
+	writeTestFile(t, filepath.Join(apiPath, "index.js"), "goodbye")
+
+	changedHash, err := svc.SourceHash()
+	require.NoError(t, err)
+	require.NotEqual(t, hash, changedHash)
+
+	svc.Config.Language = "ts"
+
+	configHash, err := svc.SourceHash()
+	require.NoError(t, err)
+	require.NotEqual(t, changedHash, configHash)
+}
+
+func TestSourceHashEnvironmentValues(t *testing.T) {
+	svc := createTestService(t)
+	testEnv := environment.Empty("")
+	testEnv.SetUserValue("API_URL", "https://api.example.com")
+	svc.Env = &testEnv
+
+	hash, err := svc.SourceHash()
+	require.NoError(t, err)
+
+	// The names of images change with each deployment of container apps
+	testEnv.SetSystemValue("SERVICE_WEB_IMAGE_NAME", "registry.example.com/web:v2")
+
+	unchangedHash, err := svc.SourceHash()
+	require.NoError(t, err)
+	require.Equal(t, hash, unchangedHash)
+
+	// Values are injected into the builds of npm services
+	testEnv.SetUserValue("API_URL", "https://api.contoso.com")
+
+	changedHash, err := svc.SourceHash()
+	require.NoError(t, err)
+	require.NotEqual(t, hash, changedHash)
+
+	// Only the values substituted into the parameters of the module of container apps are used
+	svc.Config.Host = string(ContainerAppTarget)
+	svc.Config.Module = "api"
+	writeTestFile(t, filepath.Join(svc.Config.Project.Path, "infra", "api.parameters.json"),
+		`{"parameters": {"apiUrl": {"value": "${API_URL}"}, "imageName": {"value": "${SERVICE_API_IMAGE_NAME}"}}}`)
+
+	hash, err = svc.SourceHash()
+	require.NoError(t, err)
+
+	testEnv.SetUserValue("OTHER", "value")
+	testEnv.SetSystemValue("SERVICE_API_IMAGE_NAME", "registry.example.com/api:v2")
+
+	unchangedHash, err = svc.SourceHash()
+	require.NoError(t, err)
+	require.Equal(t, hash, unchangedHash)
+
+	testEnv.SetUserValue("API_URL", "https://api.example.com")
+
+	changedHash, err = svc.SourceHash()
+	require.NoError(t, err)
+	require.NotEqual(t, hash, changedHash)
+}
+
+func TestUnchangedDeployment(t *testing.T) {
+	svc := createTestService(t)
+	svc.Project.History.gitCli = &fakeGitCli{}
+
+	// A service which has not been deployed has changed
+	unchanged, err := svc.UnchangedDeployment()
+	require.NoError(t, err)
+	require.Nil(t, unchanged)
+
+	sourceHash, err := svc.SourceHash()
+	require.NoError(t, err)
+
+	record, err := svc.Project.History.Record(context.Background(), svc.Config, svc.Config.Path(), ServiceDeploymentResult{
+		TargetResourceId: "target-resource-id",
+		Endpoints:        mockEndpoints,
+	}, sourceHash)
+	require.NoError(t, err)
+
+	unchanged, err = svc.UnchangedDeployment()
+	require.NoError(t, err)
+	require.NotNil(t, unchanged)
+	require.Equal(t, record.Id, unchanged.Id)
+
+	writeTestFile(t, filepath.Join(svc.Config.Path(), "index.js"), "goodbye")
+
+	unchanged, err = svc.UnchangedDeployment()
+	require.NoError(t, err)
+	require.Nil(t, unchanged)
+}
+
+func TestUnchangedDeploymentNotHealthy(t *testing.T) {
+	svc := createTestService(t)
+	svc.Project.History.gitCli = &fakeGitCli{}
+
+	sourceHash, err := svc.SourceHash()
+	require.NoError(t, err)
+
+	_, err = svc.Project.History.Record(context.Background(), svc.Config, svc.Config.Path(), ServiceDeploymentResult{
+		TargetResourceId: "target-resource-id",
+		Endpoints:        mockEndpoints,
+		Health:           &HealthCheckResult{Url: mockEndpoints[0], Status: 500, Attempts: 3, Error: "unexpected status 500"},
+	}, sourceHash)
+	require.NoError(t, err)
+
+	// Services whose most recent deployment is not healthy are deployed again
+	unchanged, err := svc.UnchangedDeployment()
+	require.NoError(t, err)
+	require.Nil(t, unchanged)
+}
+
+func TestUnchangedDeploymentAfterInvalidate(t *testing.T) {
+	svc := createTestService(t)
+	svc.Project.History.gitCli = &fakeGitCli{}
+
+	sourceHash, err := svc.SourceHash()
+	require.NoError(t, err)
+
+	_, err = svc.Project.History.Record(context.Background(), svc.Config, svc.Config.Path(), ServiceDeploymentResult{
+		TargetResourceId: "target-resource-id",
+		Endpoints:        mockEndpoints,
+	}, sourceHash)
+	require.NoError(t, err)
+
+	require.NoError(t, svc.Project.History.Invalidate())
+
+	unchanged, err := svc.UnchangedDeployment()
+	require.NoError(t, err)
+	require.Nil(t, unchanged)
+
+	// The deployment can still be rolled back to.
+	records, err := svc.Project.History.List()
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.True(t, records[0].CanRollback())
+}
@@ -0,0 +1,318 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+// Package recording records the commands azd runs and the HTTP requests it sends to cassette files, and plays them
+// back, so tests can run azd end-to-end without Azure or the tools azd depends on.
+package recording
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/executil"
+	"github.com/azure/azure-dev/cli/azd/pkg/httpUtil"
+	"github.com/azure/azure-dev/cli/azd/pkg/osutil"
+)
+
+const (
+	// ModeEnvVarName selects whether interactions are recorded or played back.
+	ModeEnvVarName = "AZURE_DEV_RECORD_MODE"
+	// CassetteEnvVarName is the path of the cassette interactions are recorded to or played back from.
+	CassetteEnvVarName = "AZURE_DEV_CASSETTE"
+)
+
+type Mode string
+
+const (
+	// ModeLive runs commands and sends requests without recording them, which is the default.
+	ModeLive Mode = "live"
+	// ModeRecord runs commands and sends requests, and saves them to the cassette.
+	ModeRecord Mode = "record"
+	// ModePlayback returns the results saved in the cassette instead of running commands and sending requests.
+	ModePlayback Mode = "playback"
+)
+
+// cwdPlaceholder replaces the working directory of azd in recorded interactions, since tests run in a different
+// temporary directory every time.
+const cwdPlaceholder = "$AZD_CWD"
+
+// Values of secrets are replaced with sanitizedValue before interactions are saved.
+const sanitizedValue = "SANITIZED"
+
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`("(?:accessToken|access_token|refreshToken|refresh_token|password|client_secret|clientSecret|serviceprincipalkey)"\s*:\s*")[^"]*`),
+	regexp.MustCompile(`((?:client_secret|client_assertion|password)=)[^&\s]*`),
+}
+
+// Cassette contains the interactions of one run of azd.
+type Cassette struct {
+	Commands []CommandInteraction `json:"commands"`
+	Requests []HttpInteraction    `json:"requests"`
+}
+
+// CommandInteraction is a command run by azd, and its result.
+type CommandInteraction struct {
+	Cmd      string   `json:"cmd"`
+	Args     []string `json:"args"`
+	ExitCode int      `json:"exitCode"`
+	Stdout   string   `json:"stdout"`
+	Stderr   string   `json:"stderr"`
+	// The message of the error returned when running the command, if any
+	Error string `json:"error,omitempty"`
+}
+
+// HttpInteraction is an HTTP request sent by azd, and its response. Request headers are not recorded, since they
+// contain credentials.
+type HttpInteraction struct {
+	Method          string            `json:"method"`
+	Url             string            `json:"url"`
+	Body            string            `json:"body,omitempty"`
+	Status          int               `json:"status"`
+	ResponseHeaders map[string]string `json:"responseHeaders,omitempty"`
+	ResponseBody    string            `json:"responseBody,omitempty"`
+	// The message of the error returned when sending the request, if any
+	Error string `json:"error,omitempty"`
+}
+
+// Recorder records interactions to, or plays them back from, a cassette. It implements executil.Recorder, and
+// wraps httpUtil.HttpUtil with HttpUtil.
+type Recorder struct {
+	mode Mode
+	path string
+	cwd  string
+
+	mu       sync.Mutex
+	cassette Cassette
+	// Interactions of the cassette which have been played back, so each is only returned once
+	playedCommands []bool
+	playedRequests []bool
+}
+
+// NewRecorder creates a Recorder for the cassette at path. In playback mode, the cassette is loaded. cwd is the
+// working directory of azd, which is replaced with a placeholder in recorded interactions.
+func NewRecorder(mode Mode, path string, cwd string) (*Recorder, error) {
+	r := &Recorder{mode: mode, path: path, cwd: cwd}
+
+	switch mode {
+	case ModeRecord:
+	case ModePlayback:
+		bytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading cassette: %w", err)
+		}
+
+		if err := json.Unmarshal(bytes, &r.cassette); err != nil {
+			return nil, fmt.Errorf("parsing cassette %s: %w", path, err)
+		}
+
+		r.playedCommands = make([]bool, len(r.cassette.Commands))
+		r.playedRequests = make([]bool, len(r.cassette.Requests))
+	default:
+		return nil, fmt.Errorf("unsupported recording mode '%s'", mode)
+	}
+
+	return r, nil
+}
+
+// FromEnvironment creates the Recorder configured with AZURE_DEV_RECORD_MODE and AZURE_DEV_CASSETTE, or returns nil
+// when interactions are not recorded.
+func FromEnvironment(cwd string) (*Recorder, error) {
+	mode := Mode(os.Getenv(ModeEnvVarName))
+	if mode == "" || mode == ModeLive {
+		return nil, nil
+	}
+
+	path := os.Getenv(CassetteEnvVarName)
+	if path == "" {
+		return nil, fmt.Errorf("%s must be set when %s is '%s'", CassetteEnvVarName, ModeEnvVarName, mode)
+	}
+
+	return NewRecorder(mode, path, cwd)
+}
+
+// WithContext returns a context whose commands and HTTP requests go through the Recorder.
+func (r *Recorder) WithContext(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, environment.HttpUtilContextKey, r.HttpUtil(httpUtil.GetHttpUtilFromContext(ctx)))
+	return context.WithValue(ctx, environment.RecorderContextKey, r)
+}
+
+// Save writes the recorded interactions to the cassette. It does nothing in playback mode.
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	bytes, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling cassette: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), osutil.PermissionDirectory); err != nil {
+		return fmt.Errorf("creating cassette directory: %w", err)
+	}
+
+	if err := ioutil.WriteFile(r.path, bytes, osutil.PermissionFile); err != nil {
+		return fmt.Errorf("writing cassette: %w", err)
+	}
+
+	return nil
+}
+
+// Run implements executil.Recorder.
+func (r *Recorder) Run(ctx context.Context, args executil.RunArgs, run func() (executil.RunResult, error)) (executil.RunResult, error) {
+	recordedArgs := make([]string, len(args.Args))
+	for i, arg := range args.Args {
+		recordedArgs[i] = r.record(arg)
+	}
+
+	if r.mode == ModePlayback {
+		return r.playCommand(args.Cmd, recordedArgs)
+	}
+
+	res, err := run()
+
+	interaction := CommandInteraction{
+		Cmd:      args.Cmd,
+		Args:     recordedArgs,
+		ExitCode: res.ExitCode,
+		Stdout:   r.record(res.Stdout),
+		Stderr:   r.record(res.Stderr),
+	}
+	if err != nil {
+		interaction.Error = r.record(err.Error())
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Commands = append(r.cassette.Commands, interaction)
+
+	return res, err
+}
+
+func (r *Recorder) playCommand(cmd string, args []string) (executil.RunResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.cassette.Commands {
+		if r.playedCommands[i] || interaction.Cmd != cmd || !equalArgs(interaction.Args, args) {
+			continue
+		}
+
+		r.playedCommands[i] = true
+
+		res := executil.NewRunResult(interaction.ExitCode, r.play(interaction.Stdout), r.play(interaction.Stderr))
+		if interaction.Error != "" {
+			return res, errors.New(r.play(interaction.Error))
+		}
+
+		return res, nil
+	}
+
+	return executil.NewRunResult(-1, "", ""),
+		fmt.Errorf("no command '%s' recorded in cassette %s", strings.TrimSpace(cmd+" "+strings.Join(args, " ")), r.path)
+}
+
+// HttpUtil wraps client, so its requests go through the Recorder.
+func (r *Recorder) HttpUtil(client httpUtil.HttpUtil) httpUtil.HttpUtil {
+	return &recordingHttpUtil{recorder: r, client: client}
+}
+
+type recordingHttpUtil struct {
+	recorder *Recorder
+	client   httpUtil.HttpUtil
+}
+
+func (hu *recordingHttpUtil) Send(req *httpUtil.HttpRequestMessage) (*httpUtil.HttpResponseMessage, error) {
+	r := hu.recorder
+	url, body := r.record(req.Url), r.record(req.Body)
+
+	if r.mode == ModePlayback {
+		return r.playRequest(req.Method, url, body)
+	}
+
+	res, err := hu.client.Send(req)
+
+	interaction := HttpInteraction{Method: req.Method, Url: url, Body: body}
+	if err != nil {
+		interaction.Error = r.record(err.Error())
+	} else {
+		interaction.Status = res.Status
+		interaction.ResponseHeaders = res.Headers
+		interaction.ResponseBody = r.record(string(res.Body))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Requests = append(r.cassette.Requests, interaction)
+
+	return res, err
+}
+
+func (r *Recorder) playRequest(method string, url string, body string) (*httpUtil.HttpResponseMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.cassette.Requests {
+		if r.playedRequests[i] || interaction.Method != method || interaction.Url != url || interaction.Body != body {
+			continue
+		}
+
+		r.playedRequests[i] = true
+
+		if interaction.Error != "" {
+			return nil, errors.New(r.play(interaction.Error))
+		}
+
+		return &httpUtil.HttpResponseMessage{
+			Status:  interaction.Status,
+			Headers: interaction.ResponseHeaders,
+			Body:    []byte(r.play(interaction.ResponseBody)),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("no request '%s %s' recorded in cassette %s", method, url, r.path)
+}
+
+// record replaces the working directory and the values of secrets in a recorded value.
+func (r *Recorder) record(value string) string {
+	if r.cwd != "" {
+		value = strings.ReplaceAll(value, r.cwd, cwdPlaceholder)
+	}
+
+	for _, pattern := range secretPatterns {
+		value = pattern.ReplaceAllString(value, "${1}"+sanitizedValue)
+	}
+
+	return value
+}
+
+// play restores the working directory in a recorded value.
+func (r *Recorder) play(value string) string {
+	return strings.ReplaceAll(value, cwdPlaceholder, r.cwd)
+}
+
+func equalArgs(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
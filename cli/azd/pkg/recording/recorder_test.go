@@ -0,0 +1,187 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package recording
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/executil"
+	"github.com/azure/azure-dev/cli/azd/pkg/httpUtil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndPlayCommands(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "recordings", "cassette.json")
+
+	recorder, err := NewRecorder(ModeRecord, cassette, "/work/project")
+	require.NoError(t, err)
+
+	ran := 0
+	res, err := recorder.Run(context.Background(),
+		executil.RunArgs{Cmd: "az", Args: []string{"bicep", "build", "--file", "/work/project/infra/main.bicep"}},
+		func() (executil.RunResult, error) {
+			ran++
+			return executil.NewRunResult(0, `{"template": "/work/project/infra"}`, ""), nil
+		})
+	require.NoError(t, err)
+	require.Equal(t, `{"template": "/work/project/infra"}`, res.Stdout)
+
+	_, err = recorder.Run(context.Background(),
+		executil.RunArgs{Cmd: "az", Args: []string{"account", "get-access-token"}},
+		func() (executil.RunResult, error) {
+			ran++
+			return executil.NewRunResult(1, `{"accessToken": "SECRET_TOKEN"}`, "failed"), errors.New("exit status 1")
+		})
+	require.Error(t, err)
+	require.Equal(t, 2, ran)
+
+	require.NoError(t, recorder.Save())
+
+	contents, err := os.ReadFile(cassette)
+	require.NoError(t, err)
+	require.NotContains(t, string(contents), "SECRET_TOKEN")
+	require.NotContains(t, string(contents), "/work/project")
+
+	// The project is played back from another directory.
+	player, err := NewRecorder(ModePlayback, cassette, "/other/project")
+	require.NoError(t, err)
+
+	notRun := func() (executil.RunResult, error) {
+		require.Fail(t, "commands must not run in playback")
+		return executil.RunResult{}, nil
+	}
+
+	res, err = player.Run(context.Background(),
+		executil.RunArgs{Cmd: "az", Args: []string{"bicep", "build", "--file", "/other/project/infra/main.bicep"}}, notRun)
+	require.NoError(t, err)
+	require.Equal(t, `{"template": "/other/project/infra"}`, res.Stdout)
+
+	res, err = player.Run(context.Background(),
+		executil.RunArgs{Cmd: "az", Args: []string{"account", "get-access-token"}}, notRun)
+	require.EqualError(t, err, "exit status 1")
+	require.Equal(t, 1, res.ExitCode)
+	require.Equal(t, `{"accessToken": "SANITIZED"}`, res.Stdout)
+	require.Equal(t, "failed", res.Stderr)
+
+	// Each interaction is only played back once.
+	_, err = player.Run(context.Background(),
+		executil.RunArgs{Cmd: "az", Args: []string{"account", "get-access-token"}}, notRun)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no command 'az account get-access-token' recorded")
+}
+
+// sendFn is an httpUtil.HttpUtil sending requests with a function. test/helpers can't be used here, since it
+// depends on this package.
+type sendFn func(req *httpUtil.HttpRequestMessage) (*httpUtil.HttpResponseMessage, error)
+
+func (fn sendFn) Send(req *httpUtil.HttpRequestMessage) (*httpUtil.HttpResponseMessage, error) {
+	return fn(req)
+}
+
+func TestRecordAndPlayRequests(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "cassette.json")
+
+	polls := 0
+	client := sendFn(func(req *httpUtil.HttpRequestMessage) (*httpUtil.HttpResponseMessage, error) {
+		polls++
+		status := http.StatusAccepted
+		if polls > 1 {
+			status = http.StatusOK
+		}
+
+		return &httpUtil.HttpResponseMessage{
+			Status:  status,
+			Headers: map[string]string{"Retry-After": "1"},
+			Body:    []byte(`{"access_token": "SECRET_TOKEN"}`),
+		}, nil
+	})
+
+	recorder, err := NewRecorder(ModeRecord, cassette, "")
+	require.NoError(t, err)
+
+	ctx := recorder.WithContext(context.WithValue(context.Background(), environment.HttpUtilContextKey, client))
+	request := &httpUtil.HttpRequestMessage{
+		Method: http.MethodPost,
+		Url:    "https://login.microsoftonline.com/tenant/oauth2/v2.0/token",
+		Body:   "client_id=client&client_secret=SECRET&grant_type=client_credentials",
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := httpUtil.GetHttpUtilFromContext(ctx).Send(request)
+		require.NoError(t, err)
+	}
+	require.NoError(t, recorder.Save())
+
+	contents, err := os.ReadFile(cassette)
+	require.NoError(t, err)
+	require.NotContains(t, string(contents), "SECRET")
+
+	player, err := NewRecorder(ModePlayback, cassette, "")
+	require.NoError(t, err)
+
+	// Requests are played back in the order they were recorded.
+	ctx = player.WithContext(context.Background())
+	res, err := httpUtil.GetHttpUtilFromContext(ctx).Send(request)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusAccepted, res.Status)
+	require.Equal(t, "1", res.Headers["Retry-After"])
+
+	res, err = httpUtil.GetHttpUtilFromContext(ctx).Send(request)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.Status)
+	require.Equal(t, `{"access_token": "SANITIZED"}`, string(res.Body))
+
+	_, err = httpUtil.GetHttpUtilFromContext(ctx).Send(&httpUtil.HttpRequestMessage{Method: http.MethodGet, Url: request.Url})
+	require.Error(t, err)
+
+	require.Equal(t, 2, polls)
+}
+
+func TestExecutilUsesRecorder(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "cassette.json")
+	require.NoError(t, os.WriteFile(cassette, []byte(`{
+  "commands": [
+    { "cmd": "not-a-real-command", "args": ["--version"], "exitCode": 0, "stdout": "1.0.0", "stderr": "" }
+  ]
+}`), 0600))
+
+	player, err := NewRecorder(ModePlayback, cassette, "")
+	require.NoError(t, err)
+
+	ctx := player.WithContext(context.Background())
+
+	res, err := executil.RunWithResult(ctx, executil.RunArgs{Cmd: "not-a-real-command", Args: []string{"--version"}})
+	require.NoError(t, err)
+	require.Equal(t, "1.0.0", res.Stdout)
+
+	_, err = executil.RunCommand(ctx, "not-a-real-command", "--version")
+	require.Error(t, err)
+}
+
+func TestFromEnvironment(t *testing.T) {
+	t.Setenv(ModeEnvVarName, "")
+	recorder, err := FromEnvironment("")
+	require.NoError(t, err)
+	require.Nil(t, recorder)
+
+	t.Setenv(ModeEnvVarName, string(ModeRecord))
+	t.Setenv(CassetteEnvVarName, "")
+	_, err = FromEnvironment("")
+	require.Error(t, err)
+
+	t.Setenv(CassetteEnvVarName, filepath.Join(t.TempDir(), "cassette.json"))
+	recorder, err = FromEnvironment("")
+	require.NoError(t, err)
+	require.NotNil(t, recorder)
+
+	t.Setenv(ModeEnvVarName, "replay")
+	_, err = FromEnvironment("")
+	require.Error(t, err)
+}
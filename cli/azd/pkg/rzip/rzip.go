@@ -5,11 +5,14 @@ package rzip
 
 import (
 	"archive/zip"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/osutil"
 )
 
 func CreateFromDirectory(source string, buf *os.File) error {
@@ -56,3 +59,63 @@ func CreateFromDirectory(source string, buf *os.File) error {
 
 	return w.Close()
 }
+
+// ExtractToDirectory extracts the files of a zip archive to destination, which is created if it does not exist.
+func ExtractToDirectory(source string, destination string) error {
+	r, err := zip.OpenReader(source)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	root, err := filepath.Abs(destination)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range r.File {
+		target := filepath.Join(root, filepath.FromSlash(file.Name))
+
+		// Guard against entries which would be written outside of the destination, i.e. `../../file`.
+		if target != root && !strings.HasPrefix(target, root+string(filepath.Separator)) {
+			return fmt.Errorf("invalid file path in archive: %s", file.Name)
+		}
+
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, osutil.PermissionDirectory); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := extractFile(file, target); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func extractFile(file *zip.File, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), osutil.PermissionDirectory); err != nil {
+		return err
+	}
+
+	in, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, file.Mode().Perm()|0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
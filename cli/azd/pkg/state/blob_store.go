@@ -0,0 +1,66 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+)
+
+// blobStore stores each environment as a blob of a storage container, named <prefix><environment>.json, whose ETag is
+// the ETag of the blob.
+type blobStore struct {
+	azCli         tools.AzCli
+	accountName   string
+	containerName string
+	prefix        string
+}
+
+func (s *blobStore) blobName(envName string) string {
+	return s.prefix + envName + stateFileExtension
+}
+
+func (s *blobStore) List(ctx context.Context) ([]string, error) {
+	blobs, err := s.azCli.ListBlobs(ctx, s.accountName, s.containerName, s.prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, blob := range blobs {
+		name := strings.TrimPrefix(blob.Name, s.prefix)
+		// Blobs of nested directories are not environments.
+		if strings.HasSuffix(name, stateFileExtension) && !strings.Contains(name, "/") {
+			names = append(names, strings.TrimSuffix(name, stateFileExtension))
+		}
+	}
+
+	return names, nil
+}
+
+func (s *blobStore) Get(ctx context.Context, envName string) ([]byte, string, error) {
+	contents, etag, err := s.azCli.GetBlob(ctx, s.accountName, s.containerName, s.blobName(envName))
+	if errors.Is(err, tools.ErrBlobNotFound) {
+		return nil, "", ErrNotFound
+	} else if err != nil {
+		return nil, "", fmt.Errorf("getting state of environment %s: %w", envName, err)
+	}
+
+	return contents, etag, nil
+}
+
+func (s *blobStore) Put(ctx context.Context, envName string, state []byte, etag string) (string, error) {
+	newEtag, err := s.azCli.PutBlob(ctx, s.accountName, s.containerName, s.blobName(envName), state, etag)
+	if errors.Is(err, tools.ErrBlobModified) {
+		return "", ErrConflict
+	} else if err != nil {
+		return "", fmt.Errorf("storing state of environment %s: %w", envName, err)
+	}
+
+	return newEtag, nil
+}
@@ -0,0 +1,87 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package state
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/osutil"
+)
+
+// localStore stores each environment as a file of a directory, named <environment>.json, whose ETag is the checksum
+// of the file. It is meant for tests and for directories shared by other means, since concurrent updates are only
+// detected within a process.
+type localStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func (s *localStore) path(envName string) string {
+	return filepath.Join(s.dir, envName+stateFileExtension)
+}
+
+func (s *localStore) List(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("listing environments of %s: %w", s.dir, err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), stateFileExtension) {
+			names = append(names, strings.TrimSuffix(entry.Name(), stateFileExtension))
+		}
+	}
+
+	return names, nil
+}
+
+func (s *localStore) Get(ctx context.Context, envName string) ([]byte, string, error) {
+	contents, err := ioutil.ReadFile(s.path(envName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", ErrNotFound
+	} else if err != nil {
+		return nil, "", fmt.Errorf("getting state of environment %s: %w", envName, err)
+	}
+
+	return contents, localEtag(contents), nil
+}
+
+func (s *localStore) Put(ctx context.Context, envName string, state []byte, etag string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, currentEtag, err := s.Get(ctx, envName)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return "", err
+	}
+
+	if currentEtag != etag {
+		return "", ErrConflict
+	}
+
+	if err := os.MkdirAll(s.dir, osutil.PermissionDirectory); err != nil {
+		return "", fmt.Errorf("creating state directory: %w", err)
+	}
+
+	if err := ioutil.WriteFile(s.path(envName), state, osutil.PermissionFile); err != nil {
+		return "", fmt.Errorf("storing state of environment %s: %w", envName, err)
+	}
+
+	return localEtag(state), nil
+}
+
+func localEtag(contents []byte) string {
+	return fmt.Sprintf("\"%x\"", sha256.Sum256(contents))
+}
@@ -0,0 +1,88 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+// Package state shares the environments of a project through a remote store, so that teammates and pipelines use the
+// same environments instead of each creating their own.
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/tools"
+)
+
+type BackendKind string
+
+const (
+	AzureBlobStorage BackendKind = "AzureBlobStorage"
+	Local            BackendKind = "Local"
+)
+
+// Options are the state settings from the `state` section of azure.yaml.
+type Options struct {
+	// The remote store of the environments of the project. Environments are only stored locally when unset.
+	Remote *RemoteOptions `yaml:"remote,omitempty"`
+}
+
+// RemoteOptions configures the remote store of the environments of a project.
+type RemoteOptions struct {
+	// The kind of store, either `AzureBlobStorage` or `Local`
+	Backend BackendKind `yaml:"backend"`
+	// The storage account and the container which store the environments, for `AzureBlobStorage`
+	AccountName   string `yaml:"accountName,omitempty"`
+	ContainerName string `yaml:"containerName,omitempty"`
+	// The directory which stores the environments, relative to the project root, for `Local`
+	Path string `yaml:"path,omitempty"`
+}
+
+// ErrNotFound is returned by RemoteStore.Get for environments which are not stored remotely.
+var ErrNotFound = errors.New("environment not found in remote state")
+
+// ErrConflict is returned when an environment was changed concurrently, so that changes would be lost.
+var ErrConflict = errors.New("environment was changed concurrently")
+
+// RemoteStore stores the state of environments remotely, with an ETag which changes on each update of an environment,
+// so that concurrent updates are detected.
+type RemoteStore interface {
+	// List returns the names of the environments in the store.
+	List(ctx context.Context) ([]string, error)
+	// Get returns the state of an environment and its ETag, or ErrNotFound.
+	Get(ctx context.Context, envName string) ([]byte, string, error)
+	// Put stores the state of an environment when its ETag is etag, and returns the new ETag. When etag is empty, the
+	// state is only stored when the environment is not in the store. ErrConflict is returned otherwise.
+	Put(ctx context.Context, envName string, state []byte, etag string) (string, error)
+}
+
+// NewRemoteStore creates the store configured by options, for the project with the given name and root directory.
+// The environments of projects sharing a storage container are stored under the name of their project.
+func NewRemoteStore(options RemoteOptions, projectName string, projectDir string, azCli tools.AzCli) (RemoteStore, error) {
+	switch options.Backend {
+	case AzureBlobStorage:
+		if options.AccountName == "" || options.ContainerName == "" {
+			return nil, fmt.Errorf("the %s backend requires an accountName and a containerName", options.Backend)
+		}
+
+		return &blobStore{
+			azCli:         azCli,
+			accountName:   options.AccountName,
+			containerName: options.ContainerName,
+			prefix:        projectName + "/",
+		}, nil
+	case Local:
+		if options.Path == "" {
+			return nil, fmt.Errorf("the %s backend requires a path", options.Backend)
+		}
+
+		dir := options.Path
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(projectDir, dir)
+		}
+
+		return &localStore{dir: dir}, nil
+	default:
+		return nil, fmt.Errorf("unsupported remote state backend '%s', use %s or %s", options.Backend, AzureBlobStorage, Local)
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package state
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/azure/azure-dev/cli/azd/pkg/osutil"
+)
+
+const stateFileExtension = ".json"
+
+// syncFileName is the file of the directory of an environment which records the state last pulled or pushed. It is
+// not synced itself.
+const syncFileName = ".remote.json"
+
+// environmentState is the state of an environment in a remote store, which holds the files of the directory of the
+// environment, such as its .env file and its deployment parameters.
+type environmentState struct {
+	Files map[string]string `json:"files"`
+}
+
+// syncRecord is the state of an environment as it was last pulled or pushed.
+type syncRecord struct {
+	// The name the environment was synced as, which differs from its current name once it is renamed
+	Name string `json:"name"`
+	ETag string `json:"etag"`
+	// The checksum of the files of the environment, which detects local changes made since
+	Checksum string `json:"checksum"`
+}
+
+// Pull updates the local environment envName with its remote state, or creates it when it only exists remotely. When
+// the local environment has changes which were not pushed and it was also changed remotely, ErrConflict is returned,
+// unless force is set, in which case the local changes are replaced by the remote state. false is returned when the
+// environment is not in the store.
+func Pull(ctx context.Context, store RemoteStore, azdCtx *environment.AzdContext, envName string, force bool) (bool, error) {
+	contents, etag, err := store.Get(ctx, envName)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	var remote environmentState
+	if err := json.Unmarshal(contents, &remote); err != nil {
+		return false, fmt.Errorf("parsing state of environment %s: %w", envName, err)
+	}
+
+	for name := range remote.Files {
+		if !isEnvironmentFileName(name) {
+			return false, fmt.Errorf("state of environment %s has an invalid file name '%s'", envName, name)
+		}
+	}
+
+	dir := filepath.Join(azdCtx.EnvironmentDirectory(), envName)
+	record, err := readSyncRecord(dir, envName)
+	if err != nil {
+		return false, err
+	}
+
+	if record.ETag == etag && !force {
+		return true, nil
+	}
+
+	local, err := readEnvironmentFiles(dir)
+	if err != nil {
+		return false, err
+	}
+
+	remoteChecksum := checksum(remote.Files)
+	if !force && len(local) > 0 && checksum(local) != record.Checksum && checksum(local) != remoteChecksum {
+		return false, fmt.Errorf(
+			"environment %s has local changes which were not pushed, and was changed remotely since: %w", envName, ErrConflict)
+	}
+
+	if err := os.MkdirAll(dir, osutil.PermissionDirectory); err != nil {
+		return false, fmt.Errorf("creating environment directory: %w", err)
+	}
+
+	// Files removed remotely are removed locally, since there are no local changes, or they are replaced.
+	for name := range local {
+		if _, has := remote.Files[name]; !has {
+			if err := os.Remove(filepath.Join(dir, name)); err != nil {
+				return false, fmt.Errorf("removing %s: %w", name, err)
+			}
+		}
+	}
+
+	for name, contents := range remote.Files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), osutil.PermissionFile); err != nil {
+			return false, fmt.Errorf("writing %s: %w", name, err)
+		}
+	}
+
+	return true, writeSyncRecord(dir, syncRecord{Name: envName, ETag: etag, Checksum: remoteChecksum})
+}
+
+// Push stores the local environment envName in the store, unless it is unchanged since it was last pulled or pushed.
+// When it was changed remotely since, ErrConflict is returned, so that the remote changes are not lost, unless force
+// is set, in which case the remote changes are replaced by the local environment.
+func Push(ctx context.Context, store RemoteStore, azdCtx *environment.AzdContext, envName string, force bool) error {
+	dir := filepath.Join(azdCtx.EnvironmentDirectory(), envName)
+	local, err := readEnvironmentFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	if len(local) == 0 {
+		return fmt.Errorf("environment %s does not exist", envName)
+	}
+
+	record, err := readSyncRecord(dir, envName)
+	if err != nil {
+		return err
+	}
+
+	localChecksum := checksum(local)
+	if !force && record.ETag != "" && record.Checksum == localChecksum {
+		return nil
+	}
+
+	// The remote state is replaced whatever it is, by updating the state with its current ETag.
+	etag := record.ETag
+	if force {
+		_, etag, err = store.Get(ctx, envName)
+		if errors.Is(err, ErrNotFound) {
+			etag = ""
+		} else if err != nil {
+			return err
+		}
+	}
+
+	contents, err := json.MarshalIndent(environmentState{Files: local}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling state of environment %s: %w", envName, err)
+	}
+
+	etag, err = store.Put(ctx, envName, contents, etag)
+	if errors.Is(err, ErrConflict) {
+		return fmt.Errorf("environment %s was changed remotely since it was last pulled: %w", envName, ErrConflict)
+	} else if err != nil {
+		return err
+	}
+
+	return writeSyncRecord(dir, syncRecord{Name: envName, ETag: etag, Checksum: localChecksum})
+}
+
+// isEnvironmentFileName returns true for the names of the files synced, which are the files of the directory of the
+// environment, besides the record of the last sync.
+func isEnvironmentFileName(name string) bool {
+	return name != "" && name != "." && name != ".." && name != syncFileName && filepath.Base(name) == name
+}
+
+// readEnvironmentFiles returns the contents of the files of an environment directory, keyed by name. Directories, such
+// as the working directories of providers, are not synced.
+func readEnvironmentFiles(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("listing environment files: %w", err)
+	}
+
+	files := map[string]string{}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !isEnvironmentFileName(entry.Name()) {
+			continue
+		}
+
+		contents, err := ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", entry.Name(), err)
+		}
+
+		files[entry.Name()] = string(contents)
+	}
+
+	return files, nil
+}
+
+// checksum returns a checksum of files, which are marshalled in the order of their names.
+func checksum(files map[string]string) string {
+	contents, _ := json.Marshal(files)
+	return fmt.Sprintf("%x", sha256.Sum256(contents))
+}
+
+// readSyncRecord reads the record of the last sync of the environment envName, which is empty when the environment
+// was never synced as envName.
+func readSyncRecord(dir string, envName string) (syncRecord, error) {
+	contents, err := ioutil.ReadFile(filepath.Join(dir, syncFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return syncRecord{}, nil
+	} else if err != nil {
+		return syncRecord{}, fmt.Errorf("reading %s: %w", syncFileName, err)
+	}
+
+	var record syncRecord
+	if err := json.Unmarshal(contents, &record); err != nil {
+		return syncRecord{}, fmt.Errorf("parsing %s: %w", syncFileName, err)
+	}
+
+	if record.Name != envName {
+		return syncRecord{}, nil
+	}
+
+	return record, nil
+}
+
+func writeSyncRecord(dir string, record syncRecord) error {
+	contents, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshalling %s: %w", syncFileName, err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, syncFileName), contents, osutil.PermissionFile); err != nil {
+		return fmt.Errorf("writing %s: %w", syncFileName, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package state
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/environment"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestProject returns the context of a project with a dev environment with the given .env file.
+func newTestProject(t *testing.T, dotEnv string) *environment.AzdContext {
+	azdCtx := &environment.AzdContext{}
+	azdCtx.SetProjectDirectory(t.TempDir())
+
+	if dotEnv != "" {
+		writeDotEnv(t, azdCtx, dotEnv)
+	}
+
+	return azdCtx
+}
+
+func writeDotEnv(t *testing.T, azdCtx *environment.AzdContext, dotEnv string) {
+	require.NoError(t, os.MkdirAll(filepath.Join(azdCtx.EnvironmentDirectory(), "dev"), 0755))
+	require.NoError(t, ioutil.WriteFile(azdCtx.GetEnvironmentFilePath("dev"), []byte(dotEnv), 0600))
+}
+
+func readDotEnv(t *testing.T, azdCtx *environment.AzdContext) string {
+	contents, err := ioutil.ReadFile(azdCtx.GetEnvironmentFilePath("dev"))
+	require.NoError(t, err)
+	return string(contents)
+}
+
+func TestPushAndPull(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewRemoteStore(RemoteOptions{Backend: Local, Path: t.TempDir()}, "project", "", nil)
+	require.NoError(t, err)
+
+	alice := newTestProject(t, "A=1\n")
+	require.NoError(t, Push(ctx, store, alice, "dev", false))
+
+	names, err := store.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"dev"}, names)
+
+	bob := newTestProject(t, "")
+	found, err := Pull(ctx, store, bob, "dev", false)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "A=1\n", readDotEnv(t, bob))
+
+	found, err = Pull(ctx, store, bob, "prod", false)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	t.Run("UpdatesPulledEnvironments", func(t *testing.T) {
+		writeDotEnv(t, alice, "A=2\n")
+		require.NoError(t, Push(ctx, store, alice, "dev", false))
+
+		_, err := Pull(ctx, store, bob, "dev", false)
+		require.NoError(t, err)
+		require.Equal(t, "A=2\n", readDotEnv(t, bob))
+	})
+
+	t.Run("ConflictingPush", func(t *testing.T) {
+		writeDotEnv(t, alice, "A=3\n")
+		require.NoError(t, Push(ctx, store, alice, "dev", false))
+
+		writeDotEnv(t, bob, "A=4\n")
+		err := Push(ctx, store, bob, "dev", false)
+		require.Error(t, err)
+		require.True(t, errors.Is(err, ErrConflict))
+
+		// Pulling does not overwrite the changes which were not pushed either.
+		_, err = Pull(ctx, store, bob, "dev", false)
+		require.True(t, errors.Is(err, ErrConflict))
+		require.Equal(t, "A=4\n", readDotEnv(t, bob))
+	})
+
+	t.Run("NewEnvironmentWithExistingName", func(t *testing.T) {
+		carol := newTestProject(t, "A=5\n")
+		err := Push(ctx, store, carol, "dev", false)
+		require.True(t, errors.Is(err, ErrConflict))
+	})
+}
+
+func TestPullConflict(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewRemoteStore(RemoteOptions{Backend: Local, Path: t.TempDir()}, "project", "", nil)
+	require.NoError(t, err)
+
+	alice := newTestProject(t, "A=1\n")
+	require.NoError(t, Push(ctx, store, alice, "dev", false))
+
+	bob := newTestProject(t, "")
+	_, err = Pull(ctx, store, bob, "dev", false)
+	require.NoError(t, err)
+
+	// Both change the environment, and alice pushes first.
+	writeDotEnv(t, alice, "A=2\n")
+	require.NoError(t, Push(ctx, store, alice, "dev", false))
+	writeDotEnv(t, bob, "A=3\n")
+
+	_, err = Pull(ctx, store, bob, "dev", false)
+	require.True(t, errors.Is(err, ErrConflict))
+	require.Equal(t, "A=3\n", readDotEnv(t, bob))
+
+	t.Run("KeepRemote", func(t *testing.T) {
+		found, err := Pull(ctx, store, bob, "dev", true)
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, "A=2\n", readDotEnv(t, bob))
+
+		// The conflict is resolved, so the environment is pulled and pushed as usual.
+		_, err = Pull(ctx, store, bob, "dev", false)
+		require.NoError(t, err)
+		writeDotEnv(t, bob, "A=4\n")
+		require.NoError(t, Push(ctx, store, bob, "dev", false))
+	})
+
+	t.Run("KeepLocal", func(t *testing.T) {
+		writeDotEnv(t, alice, "A=5\n")
+
+		_, err := Pull(ctx, store, alice, "dev", false)
+		require.True(t, errors.Is(err, ErrConflict))
+		require.True(t, errors.Is(Push(ctx, store, alice, "dev", false), ErrConflict))
+
+		require.NoError(t, Push(ctx, store, alice, "dev", true))
+
+		_, err = Pull(ctx, store, bob, "dev", false)
+		require.NoError(t, err)
+		require.Equal(t, "A=5\n", readDotEnv(t, bob))
+	})
+}
+
+func TestNewRemoteStore(t *testing.T) {
+	_, err := NewRemoteStore(RemoteOptions{Backend: AzureBlobStorage, AccountName: "account"}, "project", "", nil)
+	require.Error(t, err)
+
+	_, err = NewRemoteStore(RemoteOptions{Backend: "S3"}, "project", "", nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unsupported")
+
+	store, err := NewRemoteStore(RemoteOptions{Backend: Local, Path: "state"}, "project", "/project", nil)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join("/project", "state"), store.(*localStore).dir)
+}
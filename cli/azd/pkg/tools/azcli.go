@@ -6,10 +6,12 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strings"
 	"time"
@@ -29,6 +31,9 @@ var (
 	ErrClientAssertionExpired    = errors.New("client assertion expired")
 	ErrDeploymentNotFound        = errors.New("deployment not found")
 	ErrNoConfigurationValue      = errors.New("no value configured")
+	ErrApplicationNotFound       = errors.New("application not found")
+	ErrBlobNotFound              = errors.New("blob not found")
+	ErrBlobModified              = errors.New("blob was modified")
 )
 
 const (
@@ -61,11 +66,28 @@ type AzCli interface {
 	GetResource(ctx context.Context, subscriptionId string, resourceId string) (AzCliResourceExtended, error)
 	GetKeyVault(ctx context.Context, subscriptionId string, vaultName string) (AzCliKeyVault, error)
 	PurgeKeyVault(ctx context.Context, subscriptionId string, vaultName string) error
+	// GetKeyVaultSecret returns the value of a secret of a Key Vault.
+	GetKeyVaultSecret(ctx context.Context, vaultName string, secretName string) (string, error)
+	// SetKeyVaultSecret creates or updates a secret of a Key Vault. The value is sent in the body of a request, rather
+	// than as an argument of the Azure CLI, so it is not visible to other processes.
+	SetKeyVaultSecret(ctx context.Context, vaultName string, secretName string, value string) error
+	// ListBlobs returns the blobs of a container of a storage account whose names start with prefix.
+	ListBlobs(ctx context.Context, accountName string, containerName string, prefix string) ([]AzCliBlob, error)
+	// GetBlob returns the contents of a blob and its ETag, or ErrBlobNotFound.
+	GetBlob(ctx context.Context, accountName string, containerName string, blobName string) ([]byte, string, error)
+	// PutBlob creates or replaces a block blob when its ETag is etag, and returns the ETag of the new contents. When
+	// etag is empty, the blob is only created when it does not exist. ErrBlobModified is returned when the blob does
+	// not match etag.
+	PutBlob(ctx context.Context, accountName string, containerName string, blobName string, contents []byte, etag string) (string, error)
 	DeployAppServiceZip(ctx context.Context, subscriptionId string, resourceGroup string, appName string, deployZipPath string) (string, error)
 	DeployFunctionAppUsingZipFile(ctx context.Context, subscriptionID string, resourceGroup string, funcName string, deployZipPath string) (string, error)
 	GetFunctionAppProperties(ctx context.Context, subscriptionID string, resourceGroup string, funcName string) (AzCliFunctionAppProperties, error)
 	DeployToSubscription(ctx context.Context, subscriptionId string, deploymentName string, templatePath string, parametersPath string, location string) (AzCliDeploymentResult, error)
 	DeployToResourceGroup(ctx context.Context, subscriptionId string, resourceGroup string, deploymentName string, templatePath string, parametersPath string) (AzCliDeploymentResult, error)
+	// WhatIfDeployToSubscription previews the changes a subscription deployment would make, without making them.
+	WhatIfDeployToSubscription(ctx context.Context, subscriptionId string, deploymentName string, templatePath string, parametersPath string, location string) (AzCliWhatIfResult, error)
+	// WhatIfDeployToResourceGroup previews the changes a resource group deployment would make, without making them.
+	WhatIfDeployToResourceGroup(ctx context.Context, subscriptionId string, resourceGroup string, deploymentName string, templatePath string, parametersPath string) (AzCliWhatIfResult, error)
 	DeleteSubscriptionDeployment(ctx context.Context, subscriptionId string, deploymentName string) error
 	DeleteResourceGroup(ctx context.Context, subscriptionId string, resourceGroupName string) error
 	ListResourceGroupResources(ctx context.Context, subscriptionId string, resourceGroupName string) ([]AzCliResource, error)
@@ -78,8 +100,21 @@ type AzCli interface {
 	// principal is assigned a given role. If an existing principal exists with the given name,
 	// it is updated in place and its credentials are reset.
 	CreateOrUpdateServicePrincipal(ctx context.Context, subscriptionId string, applicationName string, roleToAssign string) (json.RawMessage, error)
+	// CreateOrUpdateFederatedServicePrincipal creates a service principal using a given name, assigns it a given role,
+	// and creates or updates its federated identity credentials, matched by name, so it can be used without a secret.
+	// Unlike CreateOrUpdateServicePrincipal, the credentials of an existing principal are not reset.
+	CreateOrUpdateFederatedServicePrincipal(ctx context.Context, subscriptionId string, applicationName string, roleToAssign string, credentials []FederatedIdentityCredential) (AzCliServicePrincipal, error)
+	// GetApplicationClientId returns the client ID of the application with the given display name, such as the
+	// application of a service principal created by CreateOrUpdateServicePrincipal, or ErrApplicationNotFound.
+	GetApplicationClientId(ctx context.Context, applicationName string) (string, error)
 	GetAppServiceProperties(ctx context.Context, subscriptionId string, resourceGroupName string, applicationName string) (AzCliAppServiceProperties, error)
 	GetContainerAppProperties(ctx context.Context, subscriptionId string, resourceGroupName string, applicationName string) (AzCliContainerAppProperties, error)
+	// StreamAppServiceLogs writes the log stream of an app service or a function app to writer, following it until ctx
+	// is cancelled.
+	StreamAppServiceLogs(ctx context.Context, subscriptionId string, resourceGroupName string, applicationName string, writer io.Writer) error
+	// StreamContainerAppLogs writes the console logs of the containers of a container app to writer, following them
+	// until ctx is cancelled. Lines are written whole, so logs of several containers may be written concurrently.
+	StreamContainerAppLogs(ctx context.Context, subscriptionId string, resourceGroupName string, applicationName string, writer io.Writer) error
 	GetStaticWebAppProperties(ctx context.Context, subscriptionID string, resourceGroup string, appName string) (AzCliStaticWebAppProperties, error)
 	GetStaticWebAppApiKey(ctx context.Context, subscriptionID string, resourceGroup string, appName string) (string, error)
 	GetStaticWebAppEnvironmentProperties(ctx context.Context, subscriptionID string, resourceGroup string, appName string, environmentName string) (AzCliStaticWebAppEnvironmentProperties, error)
@@ -88,6 +123,9 @@ type AzCli interface {
 
 	GetAccessToken(ctx context.Context) (AzCliAccessToken, error)
 	GraphQuery(ctx context.Context, query string, subscriptions []string) (*AzCliGraphQuery, error)
+	// QueryLogs runs a KQL query over the logs of an Application Insights component or a Log Analytics workspace, for
+	// the given ISO 8601 timespan, such as "PT1H" for the last hour.
+	QueryLogs(ctx context.Context, resourceId string, query string, timespan string) (AzCliLogsQueryResult, error)
 }
 
 type AzCliDeployment struct {
@@ -129,6 +167,31 @@ type AzCliDeploymentResultProperties struct {
 	Outputs map[string]AzCliDeploymentOutput `json:"outputs"`
 }
 
+// AzCliWhatIfResult is the result of an ARM what-if operation, which describes the changes a deployment would make.
+type AzCliWhatIfResult struct {
+	Status  string                        `json:"status"`
+	Error   *AzCliDeploymentErrorResponse `json:"error"`
+	Changes []AzCliWhatIfChange           `json:"changes"`
+}
+
+type AzCliWhatIfChange struct {
+	ResourceId string `json:"resourceId"`
+	// One of Create, Delete, Deploy, Ignore, Modify, NoChange or Unsupported
+	ChangeType string                      `json:"changeType"`
+	Before     map[string]interface{}      `json:"before"`
+	After      map[string]interface{}      `json:"after"`
+	Delta      []AzCliWhatIfPropertyChange `json:"delta"`
+}
+
+type AzCliWhatIfPropertyChange struct {
+	Path string `json:"path"`
+	// One of Array, Create, Delete, Modify or NoEffect
+	PropertyChangeType string                      `json:"propertyChangeType"`
+	Before             interface{}                 `json:"before"`
+	After              interface{}                 `json:"after"`
+	Children           []AzCliWhatIfPropertyChange `json:"children"`
+}
+
 type AzCliDeploymentErrorResponse struct {
 	Code           string                         `json:"code"`
 	Message        string                         `json:"message"`
@@ -263,6 +326,159 @@ type AzCliGraphQuery struct {
 	TotalRecords int             `json:"totalRecords"`
 }
 
+// AzCliLogsQueryResult is the result of a query of the logs of an Application Insights component or a Log Analytics
+// workspace.
+type AzCliLogsQueryResult struct {
+	Tables []AzCliLogsQueryTable `json:"tables"`
+}
+
+type AzCliLogsQueryTable struct {
+	Name    string                 `json:"name"`
+	Columns []AzCliLogsQueryColumn `json:"columns"`
+	Rows    [][]interface{}        `json:"rows"`
+}
+
+type AzCliLogsQueryColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// keyVaultSecretUrl returns the URL of a secret of a Key Vault in the public cloud.
+func keyVaultSecretUrl(vaultName string, secretName string) string {
+	return fmt.Sprintf("https://%s.vault.azure.net/secrets/%s?api-version=7.3", vaultName, url.PathEscape(secretName))
+}
+
+type keyVaultSecret struct {
+	Value string `json:"value"`
+}
+
+type AzCliBlob struct {
+	Name string
+	ETag string
+}
+
+// blobApiVersion is the version of the Blob service API, which must be at least 2017-11-09 for requests authenticated
+// with Azure AD.
+const blobApiVersion = "2021-08-06"
+
+// blobUrl returns the URL of a blob of a storage account in the public cloud.
+func blobUrl(accountName string, containerName string, blobName string) string {
+	return fmt.Sprintf("https://%s.blob.core.windows.net/%s/%s", accountName, containerName, url.PathEscape(blobName))
+}
+
+// blobHeaders returns the headers of a request to the Blob service, with the given conditional headers.
+func blobHeaders(conditions map[string]string) map[string]string {
+	headers := map[string]string{
+		"x-ms-version": blobApiVersion,
+		"Accept":       "application/xml",
+	}
+
+	for k, v := range conditions {
+		headers[k] = v
+	}
+
+	return headers
+}
+
+// putBlobHeaders returns the headers of a request which puts a block blob when its ETag is etag, or when it does not
+// exist when etag is empty.
+func putBlobHeaders(etag string) map[string]string {
+	conditions := map[string]string{
+		"x-ms-blob-type": "BlockBlob",
+		"Content-Type":   "application/octet-stream",
+	}
+
+	if etag == "" {
+		conditions["If-None-Match"] = "*"
+	} else {
+		conditions["If-Match"] = etag
+	}
+
+	return blobHeaders(conditions)
+}
+
+// blobError returns ErrBlobNotFound or ErrBlobModified for the failed requests to a blob which mean so, and err
+// otherwise.
+func blobError(err error) error {
+	var restErr *AzRestError
+	if errors.As(err, &restErr) {
+		switch restErr.StatusCode {
+		case http.StatusNotFound:
+			return ErrBlobNotFound
+		// A blob which should not exist is reported as a conflict, and a blob which does not match as a failed
+		// precondition.
+		case http.StatusConflict, http.StatusPreconditionFailed:
+			return ErrBlobModified
+		}
+	}
+
+	return err
+}
+
+type blobList struct {
+	Blobs struct {
+		Blob []struct {
+			Name       string `xml:"Name"`
+			Properties struct {
+				Etag string `xml:"Etag"`
+			} `xml:"Properties"`
+		} `xml:"Blob"`
+	} `xml:"Blobs"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// listBlobs lists the blobs of a container whose names start with prefix, where get returns the body of a successful
+// response to a GET request. Each page of the list is requested until the last one.
+func listBlobs(accountName string, containerName string, prefix string, get func(listUrl string) ([]byte, error)) ([]AzCliBlob, error) {
+	blobs := []AzCliBlob{}
+	marker := ""
+
+	for {
+		query := url.Values{}
+		query.Set("restype", "container")
+		query.Set("comp", "list")
+		query.Set("prefix", prefix)
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+
+		body, err := get(fmt.Sprintf("https://%s.blob.core.windows.net/%s?%s", accountName, containerName, query.Encode()))
+		if err != nil {
+			return nil, fmt.Errorf("listing blobs of container %s of storage account %s: %w", containerName, accountName, err)
+		}
+
+		var page blobList
+		if err := xml.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("could not unmarshal blob list: %w", err)
+		}
+
+		for _, blob := range page.Blobs.Blob {
+			blobs = append(blobs, AzCliBlob{Name: blob.Name, ETag: blob.Properties.Etag})
+		}
+
+		if page.NextMarker == "" {
+			return blobs, nil
+		}
+
+		marker = page.NextMarker
+	}
+}
+
+// logsQueryPath returns the path and api version of the Resource Manager endpoint which queries the logs of a
+// resource, which differs between Application Insights components and Log Analytics workspaces.
+func logsQueryPath(resourceId string) (string, string) {
+	if strings.Contains(strings.ToLower(resourceId), "/providers/microsoft.operationalinsights/workspaces/") {
+		return resourceId + "/api/query", "2020-08-01"
+	}
+
+	return resourceId + "/query", "2018-04-20"
+}
+
+type logsQueryRequest struct {
+	Query    string `json:"query"`
+	Timespan string `json:"timespan,omitempty"`
+}
+
 func (tok *AzCliAccessToken) UnmarshalJSON(data []byte) error {
 	var wire struct {
 		AccessToken string `json:"accessToken"`
@@ -308,6 +524,8 @@ type NewAzCliArgs struct {
 	EnableTelemetry bool
 	// RunWithResultFn allows us to stub out the command execution for testing
 	RunWithResultFn func(ctx context.Context, args executil.RunArgs) (executil.RunResult, error)
+	// Credential, when set, provides the access tokens returned by GetAccessToken instead of the Azure CLI.
+	Credential TokenCredential
 }
 
 func NewAzCli(args NewAzCliArgs) AzCli {
@@ -320,6 +538,7 @@ func NewAzCli(args NewAzCliArgs) AzCli {
 		enableDebug:     args.EnableDebug,
 		enableTelemetry: args.EnableTelemetry,
 		runWithResultFn: args.RunWithResultFn,
+		credential:      args.Credential,
 	}
 }
 
@@ -330,6 +549,9 @@ type azCli struct {
 
 	// runWithResultFn allows us to stub out the executil.RunWithResult, for testing.
 	runWithResultFn func(ctx context.Context, args executil.RunArgs) (executil.RunResult, error)
+
+	// credential, when set, provides access tokens instead of the account the Azure CLI is logged in to.
+	credential TokenCredential
 }
 
 func (cli *azCli) Name() string {
@@ -552,6 +774,41 @@ func (cli *azCli) GetContainerAppProperties(ctx context.Context, subscriptionId,
 	return containerAppProperties, nil
 }
 
+func (cli *azCli) StreamAppServiceLogs(ctx context.Context, subscriptionId string, resourceGroup string, appName string, writer io.Writer) error {
+	res, err := cli.runAzCommandWithArgs(ctx, executil.RunArgs{
+		Args:   []string{"webapp", "log", "tail", "--subscription", subscriptionId, "--resource-group", resourceGroup, "--name", appName},
+		Stdout: writer,
+	})
+	if isNotLoggedInMessage(res.Stderr) {
+		return ErrAzCliNotLoggedIn
+	} else if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed running az webapp log tail: %s: %w", res.String(), err)
+	}
+
+	return nil
+}
+
+func (cli *azCli) StreamContainerAppLogs(ctx context.Context, subscriptionId string, resourceGroup string, appName string, writer io.Writer) error {
+	res, err := cli.runAzCommandWithArgs(ctx, executil.RunArgs{
+		Args: []string{
+			"containerapp", "logs", "show",
+			"--subscription", subscriptionId,
+			"--resource-group", resourceGroup,
+			"--name", appName,
+			"--follow",
+			"--format", "text",
+		},
+		Stdout: writer,
+	})
+	if isNotLoggedInMessage(res.Stderr) {
+		return ErrAzCliNotLoggedIn
+	} else if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed running az containerapp logs show: %s: %w", res.String(), err)
+	}
+
+	return nil
+}
+
 func (cli *azCli) GetFunctionAppProperties(ctx context.Context, subscriptionID string, resourceGroup string, funcName string) (AzCliFunctionAppProperties, error) {
 	res, err := cli.runAzCommandWithArgs(context.Background(), executil.RunArgs{
 		Args: []string{
@@ -666,6 +923,41 @@ func (cli *azCli) DeployToSubscription(ctx context.Context, subscriptionId strin
 	return deploymentResult, nil
 }
 
+func (cli *azCli) WhatIfDeployToSubscription(ctx context.Context, subscriptionId string, deploymentName string, templateFile string, parametersFile string, location string) (AzCliWhatIfResult, error) {
+	res, err := cli.runAzCommand(ctx, "deployment", "sub", "what-if", "--subscription", subscriptionId, "--name", deploymentName, "--location", location, "--template-file", templateFile, "--parameters", fmt.Sprintf("@%s", parametersFile), "--no-pretty-print", "--output", "json")
+	return cli.parseWhatIfResult("az deployment sub what-if", res, err)
+}
+
+func (cli *azCli) WhatIfDeployToResourceGroup(ctx context.Context, subscriptionId string, resourceGroup string, deploymentName string, templateFile string, parametersFile string) (AzCliWhatIfResult, error) {
+	res, err := cli.runAzCommand(ctx, "deployment", "group", "what-if", "--subscription", subscriptionId, "--resource-group", resourceGroup, "--name", deploymentName, "--template-file", templateFile, "--parameters", fmt.Sprintf("@%s", parametersFile), "--no-pretty-print", "--output", "json")
+	return cli.parseWhatIfResult("az deployment group what-if", res, err)
+}
+
+func (cli *azCli) parseWhatIfResult(command string, res executil.RunResult, err error) (AzCliWhatIfResult, error) {
+	if isNotLoggedInMessage(res.Stderr) {
+		return AzCliWhatIfResult{}, ErrAzCliNotLoggedIn
+	} else if err != nil {
+		if isDeploymentError(res.Stderr) {
+			deploymentErrorJson := getDeploymentErrorJson(res.Stderr)
+			deploymentError := internal.NewAzureDeploymentError(deploymentErrorJson)
+			return AzCliWhatIfResult{}, fmt.Errorf("failed running %s: \n%w", command, deploymentError)
+		}
+
+		return AzCliWhatIfResult{}, fmt.Errorf("failed running %s: %s: %w", command, res.String(), err)
+	}
+
+	var whatIfResult AzCliWhatIfResult
+	if err := json.Unmarshal([]byte(res.Stdout), &whatIfResult); err != nil {
+		return AzCliWhatIfResult{}, fmt.Errorf("could not unmarshal output %s as an AzCliWhatIfResult: %w", res.Stdout, err)
+	}
+
+	if whatIfResult.Error != nil {
+		return AzCliWhatIfResult{}, fmt.Errorf("%s failed: %s: %s", command, whatIfResult.Error.Code, whatIfResult.Error.Message)
+	}
+
+	return whatIfResult, nil
+}
+
 func (cli *azCli) DeployToResourceGroup(ctx context.Context, subscriptionId string, resourceGroup string, deploymentName string, templateFile string, parametersFile string) (AzCliDeploymentResult, error) {
 	res, err := cli.runAzCommand(ctx, "deployment", "group", "create", "--subscription", subscriptionId, "--resource-group", resourceGroup, "--name", deploymentName, "--template-file", templateFile, "--parameters", fmt.Sprintf("@%s", parametersFile), "--output", "json")
 	if isNotLoggedInMessage(res.Stderr) {
@@ -860,6 +1152,22 @@ type AzureCredentials struct {
 	ResourceManagerEndpointUrl string `json:"resourceManagerEndpointUrl"`
 }
 
+// FederatedIdentityCredential trusts the tokens an external identity provider, such as GitHub Actions, issues for a
+// subject, so they can be exchanged for tokens of the application.
+type FederatedIdentityCredential struct {
+	Name        string   `json:"name"`
+	Issuer      string   `json:"issuer"`
+	Subject     string   `json:"subject"`
+	Description string   `json:"description,omitempty"`
+	Audiences   []string `json:"audiences"`
+}
+
+// AzCliServicePrincipal identifies the service principal of an application.
+type AzCliServicePrincipal struct {
+	ClientId string
+	TenantId string
+}
+
 func (cli *azCli) CreateOrUpdateServicePrincipal(ctx context.Context, subscriptionId string, applicationName string, roleName string) (json.RawMessage, error) {
 	// By default the role assignment is tied to the root of the currently active subscription (in the az cli), which may not be the same
 	// subscription that the user has requested, so build the scope ourselves.
@@ -902,7 +1210,135 @@ func (cli *azCli) CreateOrUpdateServicePrincipal(ctx context.Context, subscripti
 	return resultWithAzureCredentialsModel, nil
 }
 
+func (cli *azCli) GetApplicationClientId(ctx context.Context, applicationName string) (string, error) {
+	var clientIds []string
+	res, err := cli.runAzCommand(ctx, "ad", "app", "list", "--display-name", applicationName, "--query", "[].appId", "--output", "json")
+	if isNotLoggedInMessage(res.Stderr) {
+		return "", ErrAzCliNotLoggedIn
+	} else if err != nil {
+		return "", fmt.Errorf("failed running az ad app list: %s: %w", res.String(), err)
+	}
+
+	if err := json.Unmarshal([]byte(res.Stdout), &clientIds); err != nil {
+		return "", fmt.Errorf("could not unmarshal output %s as a []string: %w", res.Stdout, err)
+	}
+
+	if len(clientIds) == 0 {
+		return "", ErrApplicationNotFound
+	}
+
+	return clientIds[0], nil
+}
+
+func (cli *azCli) CreateOrUpdateFederatedServicePrincipal(ctx context.Context, subscriptionId string, applicationName string, roleName string, credentials []FederatedIdentityCredential) (AzCliServicePrincipal, error) {
+	tenantId, err := cli.GetSubscriptionTenant(ctx, subscriptionId)
+	if err != nil {
+		return AzCliServicePrincipal{}, err
+	}
+
+	type application struct {
+		Id    string `json:"id"`
+		AppId string `json:"appId"`
+	}
+
+	var applications []application
+	res, err := cli.runAzCommand(ctx, "ad", "app", "list", "--display-name", applicationName, "--query", "[].{id:id, appId:appId}", "--output", "json")
+	if isNotLoggedInMessage(res.Stderr) {
+		return AzCliServicePrincipal{}, ErrAzCliNotLoggedIn
+	} else if err != nil {
+		return AzCliServicePrincipal{}, fmt.Errorf("failed running az ad app list: %s: %w", res.String(), err)
+	}
+
+	if err := json.Unmarshal([]byte(res.Stdout), &applications); err != nil {
+		return AzCliServicePrincipal{}, fmt.Errorf("could not unmarshal output %s as applications: %w", res.Stdout, err)
+	}
+
+	var app application
+	if len(applications) > 0 {
+		app = applications[0]
+	} else {
+		res, err := cli.runAzCommand(ctx, "ad", "app", "create", "--display-name", applicationName, "--output", "json")
+		if err != nil {
+			return AzCliServicePrincipal{}, fmt.Errorf("failed running az ad app create: %s: %w", res.String(), err)
+		}
+
+		if err := json.Unmarshal([]byte(res.Stdout), &app); err != nil {
+			return AzCliServicePrincipal{}, fmt.Errorf("could not unmarshal output %s as an application: %w", res.Stdout, err)
+		}
+	}
+
+	var servicePrincipalIds []string
+	res, err = cli.runAzCommand(ctx, "ad", "sp", "list", "--filter", fmt.Sprintf("appId eq '%s'", app.AppId), "--query", "[].id", "--output", "json")
+	if err != nil {
+		return AzCliServicePrincipal{}, fmt.Errorf("failed running az ad sp list: %s: %w", res.String(), err)
+	}
+
+	if err := json.Unmarshal([]byte(res.Stdout), &servicePrincipalIds); err != nil {
+		return AzCliServicePrincipal{}, fmt.Errorf("could not unmarshal output %s as a []string: %w", res.Stdout, err)
+	}
+
+	var servicePrincipalId string
+	if len(servicePrincipalIds) > 0 {
+		servicePrincipalId = servicePrincipalIds[0]
+	} else {
+		res, err := cli.runAzCommand(ctx, "ad", "sp", "create", "--id", app.AppId, "--query", "id", "--output", "json")
+		if err != nil {
+			return AzCliServicePrincipal{}, fmt.Errorf("failed running az ad sp create: %s: %w", res.String(), err)
+		}
+
+		if err := json.Unmarshal([]byte(res.Stdout), &servicePrincipalId); err != nil {
+			return AzCliServicePrincipal{}, fmt.Errorf("could not unmarshal output %s as a string: %w", res.Stdout, err)
+		}
+	}
+
+	res, err = cli.runAzCommand(ctx, "role", "assignment", "create",
+		"--assignee-object-id", servicePrincipalId,
+		"--assignee-principal-type", "ServicePrincipal",
+		"--role", roleName,
+		"--scope", azure.SubscriptionRID(subscriptionId),
+		"--output", "json")
+	if err != nil {
+		return AzCliServicePrincipal{}, fmt.Errorf("failed running az role assignment create: %s: %w", res.String(), err)
+	}
+
+	var existing []FederatedIdentityCredential
+	res, err = cli.runAzCommand(ctx, "ad", "app", "federated-credential", "list", "--id", app.Id, "--output", "json")
+	if err != nil {
+		return AzCliServicePrincipal{}, fmt.Errorf("failed running az ad app federated-credential list: %s: %w", res.String(), err)
+	}
+
+	if err := json.Unmarshal([]byte(res.Stdout), &existing); err != nil {
+		return AzCliServicePrincipal{}, fmt.Errorf("could not unmarshal output %s as federated credentials: %w", res.Stdout, err)
+	}
+
+	for _, credential := range credentials {
+		parameters, err := json.Marshal(credential)
+		if err != nil {
+			return AzCliServicePrincipal{}, fmt.Errorf("marshalling federated credential: %w", err)
+		}
+
+		args := []string{"ad", "app", "federated-credential", "create", "--id", app.Id, "--parameters", string(parameters), "--output", "json"}
+		for _, e := range existing {
+			if e.Name == credential.Name {
+				args = []string{"ad", "app", "federated-credential", "update", "--id", app.Id, "--federated-credential-id", credential.Name, "--parameters", string(parameters)}
+				break
+			}
+		}
+
+		res, err := cli.runAzCommand(ctx, args...)
+		if err != nil {
+			return AzCliServicePrincipal{}, fmt.Errorf("failed running az ad app federated-credential %s: %s: %w", args[3], res.String(), err)
+		}
+	}
+
+	return AzCliServicePrincipal{ClientId: app.AppId, TenantId: tenantId}, nil
+}
+
 func (cli *azCli) GetAccessToken(ctx context.Context) (AzCliAccessToken, error) {
+	if cli.credential != nil {
+		return cli.credential.GetToken(ctx, ResourceManagerScope)
+	}
+
 	res, err := cli.runAzCommand(ctx, "account", "get-access-token", "--output", "json")
 	if isNotLoggedInMessage(res.Stderr) {
 		return AzCliAccessToken{}, ErrAzCliNotLoggedIn
@@ -1001,6 +1437,135 @@ func (cli *azCli) GraphQuery(ctx context.Context, query string, subscriptions []
 	return &graphQueryResult, nil
 }
 
+func (cli *azCli) GetKeyVaultSecret(ctx context.Context, vaultName string, secretName string) (string, error) {
+	res, err := cli.sendDataPlaneRequest(ctx, http.MethodGet, keyVaultSecretUrl(vaultName, secretName), KeyVaultScope, nil, "")
+	if err != nil {
+		return "", fmt.Errorf("getting secret %s of key vault %s: %w", secretName, vaultName, err)
+	}
+
+	var secret keyVaultSecret
+	if err := json.Unmarshal(res.Body, &secret); err != nil {
+		return "", fmt.Errorf("could not unmarshal key vault secret: %w", err)
+	}
+
+	return secret.Value, nil
+}
+
+func (cli *azCli) SetKeyVaultSecret(ctx context.Context, vaultName string, secretName string, value string) error {
+	body, err := json.Marshal(keyVaultSecret{Value: value})
+	if err != nil {
+		return fmt.Errorf("marshalling JSON body: %w", err)
+	}
+
+	if _, err := cli.sendDataPlaneRequest(ctx, http.MethodPut, keyVaultSecretUrl(vaultName, secretName), KeyVaultScope, nil, string(body)); err != nil {
+		return fmt.Errorf("setting secret %s of key vault %s: %w", secretName, vaultName, err)
+	}
+
+	return nil
+}
+
+func (cli *azCli) ListBlobs(ctx context.Context, accountName string, containerName string, prefix string) ([]AzCliBlob, error) {
+	return listBlobs(accountName, containerName, prefix, func(listUrl string) ([]byte, error) {
+		res, err := cli.sendDataPlaneRequest(ctx, http.MethodGet, listUrl, StorageScope, blobHeaders(nil), "")
+		if err != nil {
+			return nil, err
+		}
+
+		return res.Body, nil
+	})
+}
+
+func (cli *azCli) GetBlob(ctx context.Context, accountName string, containerName string, blobName string) ([]byte, string, error) {
+	res, err := cli.sendDataPlaneRequest(ctx, http.MethodGet, blobUrl(accountName, containerName, blobName), StorageScope, blobHeaders(nil), "")
+	if err != nil {
+		return nil, "", fmt.Errorf("getting blob %s: %w", blobName, blobError(err))
+	}
+
+	return res.Body, res.Headers["Etag"], nil
+}
+
+func (cli *azCli) PutBlob(ctx context.Context, accountName string, containerName string, blobName string, contents []byte, etag string) (string, error) {
+	res, err := cli.sendDataPlaneRequest(ctx, http.MethodPut, blobUrl(accountName, containerName, blobName), StorageScope, putBlobHeaders(etag), string(contents))
+	if err != nil {
+		return "", fmt.Errorf("putting blob %s: %w", blobName, blobError(err))
+	}
+
+	return res.Headers["Etag"], nil
+}
+
+// sendDataPlaneRequest sends a request to the data plane of a service, such as Key Vault, authenticated with a token
+// for scope of the account the Azure CLI is logged in to, unless the az CLI was created with a credential.
+func (cli *azCli) sendDataPlaneRequest(
+	ctx context.Context, method string, requestUrl string, scope string, headers map[string]string, body string,
+) (*httpUtil.HttpResponseMessage, error) {
+	var credential TokenCredential = &azCliCredential{cli: cli}
+	if cli.credential != nil {
+		credential = cli.credential
+	}
+
+	token, err := credential.GetToken(ctx, scope)
+	if err != nil {
+		return nil, fmt.Errorf("getting access token: %w", err)
+	}
+
+	requestHeaders := map[string]string{"Authorization": fmt.Sprintf("Bearer %s", token.AccessToken)}
+	for k, v := range headers {
+		requestHeaders[k] = v
+	}
+
+	res, err := httpUtil.GetHttpUtilFromContext(ctx).Send(&httpUtil.HttpRequestMessage{
+		Url:     requestUrl,
+		Method:  method,
+		Headers: requestHeaders,
+		Body:    body,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sending http request: %w", err)
+	}
+
+	if res.Status >= http.StatusBadRequest {
+		return nil, newAzRestError(res.Status, res.Body)
+	}
+
+	return res, nil
+}
+
+func (cli *azCli) QueryLogs(ctx context.Context, resourceId string, query string, timespan string) (AzCliLogsQueryResult, error) {
+	path, apiVersion := logsQueryPath(resourceId)
+	requestUrl := fmt.Sprintf("https://management.azure.com%s?api-version=%s", path, apiVersion)
+
+	requestJson, err := json.Marshal(logsQueryRequest{Query: query, Timespan: timespan})
+	if err != nil {
+		return AzCliLogsQueryResult{}, fmt.Errorf("marshalling JSON body: %w", err)
+	}
+
+	token, err := cli.GetAccessToken(ctx)
+	if err != nil {
+		return AzCliLogsQueryResult{}, fmt.Errorf("getting access token: %w", err)
+	}
+
+	response, err := httpUtil.GetHttpUtilFromContext(ctx).Send(&httpUtil.HttpRequestMessage{
+		Url:     requestUrl,
+		Method:  http.MethodPost,
+		Headers: map[string]string{"Authorization": fmt.Sprintf("Bearer %s", token.AccessToken)},
+		Body:    string(requestJson),
+	})
+	if err != nil {
+		return AzCliLogsQueryResult{}, fmt.Errorf("sending http request: %w", err)
+	}
+
+	if response.Status != http.StatusOK {
+		return AzCliLogsQueryResult{}, fmt.Errorf("failed querying logs: %w", newAzRestError(response.Status, response.Body))
+	}
+
+	var result AzCliLogsQueryResult
+	if err := json.Unmarshal(response.Body, &result); err != nil {
+		return AzCliLogsQueryResult{}, fmt.Errorf("could not unmarshal output %s as an AzCliLogsQueryResult: %w", string(response.Body), err)
+	}
+
+	return result, nil
+}
+
 func (cli *azCli) runAzCommand(ctx context.Context, args ...string) (executil.RunResult, error) {
 	return cli.runAzCommandWithArgs(ctx, executil.RunArgs{
 		Args: args,
@@ -0,0 +1,1471 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package tools
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	azdinternal "github.com/azure/azure-dev/cli/azd/internal"
+	"github.com/azure/azure-dev/cli/azd/pkg/azure"
+	"github.com/azure/azure-dev/cli/azd/pkg/httpUtil"
+	"github.com/azure/azure-dev/cli/azd/pkg/tools/internal"
+	"github.com/sethvargo/go-retry"
+)
+
+const (
+	// AzCliKindCli selects the implementation of AzCli which runs the Azure CLI.
+	AzCliKindCli = "cli"
+	// AzCliKindRest selects the implementation of AzCli which calls the Azure REST APIs directly.
+	AzCliKindRest = "rest"
+
+	defaultResourceManagerEndpoint = "https://management.azure.com"
+	defaultGraphEndpoint           = "https://graph.microsoft.com"
+
+	resourcesApiVersion     = "2021-04-01"
+	subscriptionsApiVersion = "2020-01-01"
+	webApiVersion           = "2022-03-01"
+	containerAppsApiVersion = "2022-03-01"
+	keyVaultApiVersion      = "2022-07-01"
+	authorizationApiVersion = "2022-04-01"
+	resourceGraphApiVersion = "2021-03-01"
+
+	// The interval between polls of a long running operation, when the service does not specify one
+	defaultPollInterval = 5 * time.Second
+	// Tokens are refreshed when they expire within this window, so they do not expire while a request is in flight
+	tokenRefreshWindow = 5 * time.Minute
+)
+
+// AzRestError is an error response from an Azure REST API.
+type AzRestError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	// The body of the response, which is a JSON document for most Azure APIs
+	Body string
+}
+
+func (e *AzRestError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("request failed with status %d: %s", e.StatusCode, e.Body)
+	}
+
+	return fmt.Sprintf("request failed with status %d: %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+func newAzRestError(status int, body []byte) *AzRestError {
+	var wire struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	// Not every error response has a body in the standard format, in which case the body is reported as is.
+	_ = json.Unmarshal(body, &wire)
+
+	return &AzRestError{
+		StatusCode: status,
+		Code:       wire.Error.Code,
+		Message:    wire.Error.Message,
+		Body:       string(body),
+	}
+}
+
+func isNotFoundError(err error) bool {
+	var restErr *AzRestError
+	return errors.As(err, &restErr) && restErr.StatusCode == http.StatusNotFound
+}
+
+type NewAzRestCliArgs struct {
+	// Credential provides the tokens used to authenticate requests. Without one, requests fail with ErrNoCredential.
+	Credential TokenCredential
+	// BicepCli compiles bicep templates before they are deployed. Defaults to the standalone bicep CLI.
+	BicepCli BicepCli
+	// ResourceManagerEndpoint is the base URL of Azure Resource Manager, which may be overridden to run against a local
+	// server. Defaults to the public cloud.
+	ResourceManagerEndpoint string
+	// GraphEndpoint is the base URL of Microsoft Graph. Defaults to the public cloud.
+	GraphEndpoint string
+	EnableDebug   bool
+}
+
+// NewAzRestCli creates an implementation of AzCli which calls Azure Resource Manager, Resource Graph, Microsoft Graph
+// and the data plane of services directly over HTTP, instead of running the Azure CLI. Requests are sent with the
+// HttpUtil of the context.
+func NewAzRestCli(args NewAzRestCliArgs) AzCli {
+	if args.Credential == nil {
+		args.Credential = noCredential{}
+	}
+
+	if args.BicepCli == nil {
+		args.BicepCli = NewStandaloneBicepCli()
+	}
+
+	if args.ResourceManagerEndpoint == "" {
+		args.ResourceManagerEndpoint = defaultResourceManagerEndpoint
+	}
+
+	if args.GraphEndpoint == "" {
+		args.GraphEndpoint = defaultGraphEndpoint
+	}
+
+	return &azRestCli{
+		credential:              args.Credential,
+		bicepCli:                args.BicepCli,
+		docker:                  NewDocker(DockerArgs{}),
+		resourceManagerEndpoint: strings.TrimSuffix(args.ResourceManagerEndpoint, "/"),
+		graphEndpoint:           strings.TrimSuffix(args.GraphEndpoint, "/"),
+		userAgent:               azdinternal.MakeUserAgentString(""),
+		enableDebug:             args.EnableDebug,
+		tokens:                  map[string]AzCliAccessToken{},
+		apiVersions:             map[string]string{},
+	}
+}
+
+type azRestCli struct {
+	credential              TokenCredential
+	bicepCli                BicepCli
+	docker                  *Docker
+	resourceManagerEndpoint string
+	graphEndpoint           string
+	userAgent               string
+	enableDebug             bool
+
+	// Tokens are cached by scope, since most commands send many requests
+	tokens map[string]AzCliAccessToken
+	// The latest api version of each resource type, used to get resources of any type
+	apiVersions map[string]string
+	mu          sync.Mutex
+}
+
+func (cli *azRestCli) Name() string {
+	if tool, ok := cli.credential.(ExternalTool); ok {
+		return tool.Name()
+	}
+
+	return "Azure REST API"
+}
+
+func (cli *azRestCli) InstallUrl() string {
+	if tool, ok := cli.credential.(ExternalTool); ok {
+		return tool.InstallUrl()
+	}
+
+	return ""
+}
+
+// CheckInstalled checks the tool used by the credential, when there is one, since nothing needs to be installed to
+// send requests.
+func (cli *azRestCli) CheckInstalled(ctx context.Context) (bool, error) {
+	if tool, ok := cli.credential.(ExternalTool); ok {
+		return tool.CheckInstalled(ctx)
+	}
+
+	return true, nil
+}
+
+func (cli *azRestCli) SetUserAgent(userAgent string) {
+	cli.userAgent = userAgent
+}
+
+func (cli *azRestCli) UserAgent() string {
+	return cli.userAgent
+}
+
+// Login delegates to the credential, when it supports logging in interactively.
+func (cli *azRestCli) Login(ctx context.Context, useDeviceCode bool, deviceCodeWriter io.Writer) error {
+	loginCredential, ok := cli.credential.(interface {
+		Login(ctx context.Context, useDeviceCode bool, deviceCodeWriter io.Writer) error
+	})
+	if !ok {
+		return errors.New("the configured credential does not support logging in interactively")
+	}
+
+	return loginCredential.Login(ctx, useDeviceCode, deviceCodeWriter)
+}
+
+// LoginAcr exchanges a Resource Manager token for a refresh token of the registry, which docker logs in with.
+func (cli *azRestCli) LoginAcr(ctx context.Context, subscriptionId string, loginServer string) error {
+	tenantId, err := cli.GetSubscriptionTenant(ctx, subscriptionId)
+	if err != nil {
+		return err
+	}
+
+	token, err := cli.getToken(ctx, ResourceManagerScope)
+	if err != nil {
+		return err
+	}
+
+	form := url.Values{
+		"grant_type":   {"access_token"},
+		"service":      {loginServer},
+		"tenant":       {tenantId},
+		"access_token": {token.AccessToken},
+	}
+
+	res, err := cli.send(ctx, http.MethodPost, fmt.Sprintf("https://%s/oauth2/exchange", loginServer), "", form.Encode(),
+		map[string]string{"Content-Type": "application/x-www-form-urlencoded"})
+	if err != nil {
+		return fmt.Errorf("exchanging token for registry %s: %w", loginServer, err)
+	}
+
+	var exchange struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.Unmarshal(res.Body, &exchange); err != nil {
+		return fmt.Errorf("could not unmarshal response %s as a token: %w", string(res.Body), err)
+	}
+
+	// Registries expect this well known user name when logging in with a refresh token.
+	return cli.docker.Login(ctx, loginServer, "00000000-0000-0000-0000-000000000000", exchange.RefreshToken)
+}
+
+func (cli *azRestCli) ListAccounts(ctx context.Context) ([]AzCliSubscriptionInfo, error) {
+	subscriptions, err := armList[struct {
+		SubscriptionId string `json:"subscriptionId"`
+		DisplayName    string `json:"displayName"`
+	}](ctx, cli, cli.armUrl("/subscriptions", subscriptionsApiVersion))
+	if err != nil {
+		return nil, fmt.Errorf("listing subscriptions: %w", err)
+	}
+
+	accounts := []AzCliSubscriptionInfo{}
+	for _, subscription := range subscriptions {
+		accounts = append(accounts, AzCliSubscriptionInfo{Name: subscription.DisplayName, Id: subscription.SubscriptionId})
+	}
+
+	return accounts, nil
+}
+
+// ListExtensions returns no extensions, since extensions are a feature of the Azure CLI.
+func (cli *azRestCli) ListExtensions(ctx context.Context) ([]AzCliExtensionInfo, error) {
+	return []AzCliExtensionInfo{}, nil
+}
+
+// GetCliConfigValue returns ErrNoConfigurationValue, since configuration is a feature of the Azure CLI.
+func (cli *azRestCli) GetCliConfigValue(ctx context.Context, name string) (AzCliConfigValue, error) {
+	return AzCliConfigValue{}, ErrNoConfigurationValue
+}
+
+func (cli *azRestCli) GetSubscriptionTenant(ctx context.Context, subscriptionId string) (string, error) {
+	var subscription struct {
+		TenantId string `json:"tenantId"`
+	}
+
+	if err := cli.armGet(ctx, azure.SubscriptionRID(subscriptionId), subscriptionsApiVersion, &subscription); err != nil {
+		return "", fmt.Errorf("getting subscription %s: %w", subscriptionId, err)
+	}
+
+	return subscription.TenantId, nil
+}
+
+func (cli *azRestCli) GetSubscriptionDeployment(ctx context.Context, subscriptionId string, deploymentName string) (AzCliDeployment, error) {
+	return cli.getDeployment(ctx, azure.SubscriptionDeploymentRID(subscriptionId, deploymentName))
+}
+
+func (cli *azRestCli) GetResourceGroupDeployment(ctx context.Context, subscriptionId string, resourceGroupName string, deploymentName string) (AzCliDeployment, error) {
+	return cli.getDeployment(ctx, azure.ResourceGroupDeploymentRID(subscriptionId, resourceGroupName, deploymentName))
+}
+
+func (cli *azRestCli) getDeployment(ctx context.Context, deploymentId string) (AzCliDeployment, error) {
+	var deployment AzCliDeployment
+	if err := cli.armGet(ctx, deploymentId, resourcesApiVersion, &deployment); isNotFoundError(err) {
+		return AzCliDeployment{}, ErrDeploymentNotFound
+	} else if err != nil {
+		return AzCliDeployment{}, fmt.Errorf("getting deployment: %w", err)
+	}
+
+	return deployment, nil
+}
+
+// GetResource gets a resource of any type, using the latest stable api version of the type.
+func (cli *azRestCli) GetResource(ctx context.Context, subscriptionId string, resourceId string) (AzCliResourceExtended, error) {
+	resourceType, _ := azure.ParseResourceTypeAndName(resourceId)
+
+	apiVersion, err := cli.getApiVersion(ctx, subscriptionId, resourceType)
+	if err != nil {
+		return AzCliResourceExtended{}, err
+	}
+
+	var resource AzCliResourceExtended
+	if err := cli.armGet(ctx, resourceId, apiVersion, &resource); err != nil {
+		return AzCliResourceExtended{}, fmt.Errorf("getting resource %s: %w", resourceId, err)
+	}
+
+	return resource, nil
+}
+
+func (cli *azRestCli) getApiVersion(ctx context.Context, subscriptionId string, resourceType string) (string, error) {
+	namespace, typeName, found := strings.Cut(resourceType, "/")
+	if !found {
+		return "", fmt.Errorf("resource type '%s' is not qualified by a namespace", resourceType)
+	}
+
+	if strings.EqualFold(resourceType, "Microsoft.Resources/resourceGroups") {
+		return resourcesApiVersion, nil
+	}
+
+	cli.mu.Lock()
+	apiVersion, has := cli.apiVersions[strings.ToLower(resourceType)]
+	cli.mu.Unlock()
+	if has {
+		return apiVersion, nil
+	}
+
+	var provider struct {
+		ResourceTypes []struct {
+			ResourceType string   `json:"resourceType"`
+			ApiVersions  []string `json:"apiVersions"`
+		} `json:"resourceTypes"`
+	}
+
+	providerId := fmt.Sprintf("%s/providers/%s", azure.SubscriptionRID(subscriptionId), namespace)
+	if err := cli.armGet(ctx, providerId, resourcesApiVersion, &provider); err != nil {
+		return "", fmt.Errorf("getting resource provider %s: %w", namespace, err)
+	}
+
+	for _, providerType := range provider.ResourceTypes {
+		if !strings.EqualFold(providerType.ResourceType, typeName) || len(providerType.ApiVersions) == 0 {
+			continue
+		}
+
+		// Api versions are listed from newest to oldest, prefer the newest which is not a preview.
+		apiVersion = providerType.ApiVersions[0]
+		for _, version := range providerType.ApiVersions {
+			if !strings.Contains(version, "preview") {
+				apiVersion = version
+				break
+			}
+		}
+
+		cli.mu.Lock()
+		cli.apiVersions[strings.ToLower(resourceType)] = apiVersion
+		cli.mu.Unlock()
+
+		return apiVersion, nil
+	}
+
+	return "", fmt.Errorf("resource type '%s' was not found", resourceType)
+}
+
+func (cli *azRestCli) GetKeyVault(ctx context.Context, subscriptionId string, vaultName string) (AzCliKeyVault, error) {
+	vaults, err := armList[AzCliKeyVault](ctx, cli, cli.armUrl(
+		fmt.Sprintf("%s/providers/Microsoft.KeyVault/vaults", azure.SubscriptionRID(subscriptionId)), keyVaultApiVersion))
+	if err != nil {
+		return AzCliKeyVault{}, fmt.Errorf("listing key vaults: %w", err)
+	}
+
+	for _, vault := range vaults {
+		if strings.EqualFold(vault.Name, vaultName) {
+			return vault, nil
+		}
+	}
+
+	return AzCliKeyVault{}, fmt.Errorf("key vault '%s' was not found", vaultName)
+}
+
+func (cli *azRestCli) PurgeKeyVault(ctx context.Context, subscriptionId string, vaultName string) error {
+	deletedVaults, err := armList[struct {
+		Name       string `json:"name"`
+		Properties struct {
+			Location string `json:"location"`
+		} `json:"properties"`
+	}](ctx, cli, cli.armUrl(
+		fmt.Sprintf("%s/providers/Microsoft.KeyVault/deletedVaults", azure.SubscriptionRID(subscriptionId)), keyVaultApiVersion))
+	if err != nil {
+		return fmt.Errorf("listing deleted key vaults: %w", err)
+	}
+
+	for _, vault := range deletedVaults {
+		if !strings.EqualFold(vault.Name, vaultName) {
+			continue
+		}
+
+		purgeId := fmt.Sprintf("%s/providers/Microsoft.KeyVault/locations/%s/deletedVaults/%s/purge",
+			azure.SubscriptionRID(subscriptionId), vault.Properties.Location, vault.Name)
+		if err := cli.armSend(ctx, http.MethodPost, purgeId, keyVaultApiVersion, nil, nil); err != nil {
+			return fmt.Errorf("purging key vault %s: %w", vaultName, err)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("deleted key vault '%s' was not found", vaultName)
+}
+
+func (cli *azRestCli) GetKeyVaultSecret(ctx context.Context, vaultName string, secretName string) (string, error) {
+	res, err := cli.send(ctx, http.MethodGet, keyVaultSecretUrl(vaultName, secretName), KeyVaultScope, nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("getting secret %s of key vault %s: %w", secretName, vaultName, err)
+	}
+
+	var secret keyVaultSecret
+	if err := json.Unmarshal(res.Body, &secret); err != nil {
+		return "", fmt.Errorf("could not unmarshal key vault secret: %w", err)
+	}
+
+	return secret.Value, nil
+}
+
+func (cli *azRestCli) SetKeyVaultSecret(ctx context.Context, vaultName string, secretName string, value string) error {
+	if _, err := cli.send(ctx, http.MethodPut, keyVaultSecretUrl(vaultName, secretName), KeyVaultScope, keyVaultSecret{Value: value}, nil); err != nil {
+		return fmt.Errorf("setting secret %s of key vault %s: %w", secretName, vaultName, err)
+	}
+
+	return nil
+}
+
+func (cli *azRestCli) ListBlobs(ctx context.Context, accountName string, containerName string, prefix string) ([]AzCliBlob, error) {
+	return listBlobs(accountName, containerName, prefix, func(listUrl string) ([]byte, error) {
+		res, err := cli.send(ctx, http.MethodGet, listUrl, StorageScope, nil, blobHeaders(nil))
+		if err != nil {
+			return nil, err
+		}
+
+		return res.Body, nil
+	})
+}
+
+func (cli *azRestCli) GetBlob(ctx context.Context, accountName string, containerName string, blobName string) ([]byte, string, error) {
+	res, err := cli.send(ctx, http.MethodGet, blobUrl(accountName, containerName, blobName), StorageScope, nil, blobHeaders(nil))
+	if err != nil {
+		return nil, "", fmt.Errorf("getting blob %s: %w", blobName, blobError(err))
+	}
+
+	return res.Body, res.Headers["Etag"], nil
+}
+
+func (cli *azRestCli) PutBlob(ctx context.Context, accountName string, containerName string, blobName string, contents []byte, etag string) (string, error) {
+	res, err := cli.send(ctx, http.MethodPut, blobUrl(accountName, containerName, blobName), StorageScope, string(contents), putBlobHeaders(etag))
+	if err != nil {
+		return "", fmt.Errorf("putting blob %s: %w", blobName, blobError(err))
+	}
+
+	return res.Headers["Etag"], nil
+}
+
+// DeployAppServiceZip deploys a zip file with the zip deploy API of the Kudu service of the app.
+func (cli *azRestCli) DeployAppServiceZip(ctx context.Context, subscriptionId string, resourceGroup string, appName string, deployZipPath string) (string, error) {
+	return cli.zipDeploy(ctx, subscriptionId, resourceGroup, appName, deployZipPath)
+}
+
+// DeployFunctionAppUsingZipFile deploys a zip file with the zip deploy API of the Kudu service of the app, after
+// enabling the remote build of the app.
+func (cli *azRestCli) DeployFunctionAppUsingZipFile(ctx context.Context, subscriptionID string, resourceGroup string, funcName string, deployZipPath string) (string, error) {
+	if err := cli.enableRemoteBuild(ctx, subscriptionID, resourceGroup, funcName); err != nil {
+		return "", err
+	}
+
+	return cli.zipDeploy(ctx, subscriptionID, resourceGroup, funcName, deployZipPath)
+}
+
+func (cli *azRestCli) enableRemoteBuild(ctx context.Context, subscriptionId string, resourceGroup string, appName string) error {
+	settingsId := fmt.Sprintf("%s/config/appsettings", azure.WebsiteRID(subscriptionId, resourceGroup, appName))
+
+	var settings struct {
+		Properties map[string]string `json:"properties"`
+	}
+	if err := cli.armPost(ctx, settingsId+"/list", webApiVersion, nil, &settings); err != nil {
+		return fmt.Errorf("listing app settings: %w", err)
+	}
+
+	if settings.Properties == nil {
+		settings.Properties = map[string]string{}
+	}
+
+	if settings.Properties["SCM_DO_BUILD_DURING_DEPLOYMENT"] == "true" && settings.Properties["ENABLE_ORYX_BUILD"] == "true" {
+		return nil
+	}
+
+	settings.Properties["SCM_DO_BUILD_DURING_DEPLOYMENT"] = "true"
+	settings.Properties["ENABLE_ORYX_BUILD"] = "true"
+
+	if err := cli.armSend(ctx, http.MethodPut, settingsId, webApiVersion, settings, nil); err != nil {
+		return fmt.Errorf("updating app settings: %w", err)
+	}
+
+	return nil
+}
+
+// scmHostName returns the host name of the Kudu service of an app.
+func (cli *azRestCli) scmHostName(ctx context.Context, subscriptionId string, resourceGroup string, appName string) (string, error) {
+	var site struct {
+		Properties struct {
+			HostNameSslStates []struct {
+				Name     string `json:"name"`
+				HostType string `json:"hostType"`
+			} `json:"hostNameSslStates"`
+		} `json:"properties"`
+	}
+	if err := cli.armGet(ctx, azure.WebsiteRID(subscriptionId, resourceGroup, appName), webApiVersion, &site); err != nil {
+		return "", fmt.Errorf("getting app %s: %w", appName, err)
+	}
+
+	for _, hostName := range site.Properties.HostNameSslStates {
+		if hostName.HostType == "Repository" {
+			return hostName.Name, nil
+		}
+	}
+
+	return fmt.Sprintf("%s.scm.azurewebsites.net", appName), nil
+}
+
+func (cli *azRestCli) zipDeploy(ctx context.Context, subscriptionId string, resourceGroup string, appName string, zipPath string) (string, error) {
+	scmHostName, err := cli.scmHostName(ctx, subscriptionId, resourceGroup, appName)
+	if err != nil {
+		return "", err
+	}
+
+	zip, err := os.ReadFile(zipPath)
+	if err != nil {
+		return "", fmt.Errorf("reading zip file: %w", err)
+	}
+
+	res, err := cli.send(ctx, http.MethodPost, fmt.Sprintf("https://%s/api/zipdeploy?isAsync=true", scmHostName),
+		ResourceManagerScope, string(zip), map[string]string{"Content-Type": "application/zip"})
+	if err != nil {
+		return "", fmt.Errorf("deploying zip file: %w", err)
+	}
+
+	res, err = cli.waitForCompletion(ctx, res)
+	if err != nil {
+		return "", fmt.Errorf("deploying zip file: %w", err)
+	}
+
+	// See https://github.com/projectkudu/kudu/wiki/REST-API#deployment
+	const kuduDeploymentFailed = 3
+
+	var status struct {
+		Status     int    `json:"status"`
+		StatusText string `json:"status_text"`
+	}
+	if err := json.Unmarshal(res.Body, &status); err == nil && status.Status == kuduDeploymentFailed {
+		return "", fmt.Errorf("deployment of app %s failed: %s", appName, status.StatusText)
+	}
+
+	return string(res.Body), nil
+}
+
+func (cli *azRestCli) GetFunctionAppProperties(ctx context.Context, subscriptionID string, resourceGroup string, funcName string) (AzCliFunctionAppProperties, error) {
+	var site struct {
+		Properties AzCliFunctionAppProperties `json:"properties"`
+	}
+	if err := cli.armGet(ctx, azure.WebsiteRID(subscriptionID, resourceGroup, funcName), webApiVersion, &site); err != nil {
+		return AzCliFunctionAppProperties{}, fmt.Errorf("getting function app %s: %w", funcName, err)
+	}
+
+	return site.Properties, nil
+}
+
+func (cli *azRestCli) GetAppServiceProperties(ctx context.Context, subscriptionId string, resourceGroupName string, applicationName string) (AzCliAppServiceProperties, error) {
+	var site struct {
+		Properties AzCliAppServiceProperties `json:"properties"`
+	}
+	if err := cli.armGet(ctx, azure.WebsiteRID(subscriptionId, resourceGroupName, applicationName), webApiVersion, &site); err != nil {
+		return AzCliAppServiceProperties{}, fmt.Errorf("getting app %s: %w", applicationName, err)
+	}
+
+	return site.Properties, nil
+}
+
+func (cli *azRestCli) GetContainerAppProperties(ctx context.Context, subscriptionId string, resourceGroupName string, applicationName string) (AzCliContainerAppProperties, error) {
+	var containerApp AzCliContainerAppProperties
+	if err := cli.armGet(ctx, azure.ContainerAppRID(subscriptionId, resourceGroupName, applicationName), containerAppsApiVersion, &containerApp); err != nil {
+		return AzCliContainerAppProperties{}, fmt.Errorf("getting container app %s: %w", applicationName, err)
+	}
+
+	return containerApp, nil
+}
+
+// StreamAppServiceLogs follows the log stream of the Kudu service of the app.
+func (cli *azRestCli) StreamAppServiceLogs(ctx context.Context, subscriptionId string, resourceGroupName string, applicationName string, writer io.Writer) error {
+	scmHostName, err := cli.scmHostName(ctx, subscriptionId, resourceGroupName, applicationName)
+	if err != nil {
+		return err
+	}
+
+	token, err := cli.getToken(ctx, ResourceManagerScope)
+	if err != nil {
+		return fmt.Errorf("getting access token: %w", err)
+	}
+
+	if err := cli.stream(ctx, fmt.Sprintf("https://%s/api/logstream", scmHostName), token.AccessToken, writer); err != nil {
+		return fmt.Errorf("streaming logs of app %s: %w", applicationName, err)
+	}
+
+	return nil
+}
+
+// StreamContainerAppLogs follows the log streams of the containers of each replica of the latest revision of the app,
+// which are served next to the event stream of the app.
+func (cli *azRestCli) StreamContainerAppLogs(ctx context.Context, subscriptionId string, resourceGroupName string, applicationName string, writer io.Writer) error {
+	containerAppId := azure.ContainerAppRID(subscriptionId, resourceGroupName, applicationName)
+
+	var containerApp struct {
+		Properties struct {
+			LatestRevisionName  string `json:"latestRevisionName"`
+			EventStreamEndpoint string `json:"eventStreamEndpoint"`
+		} `json:"properties"`
+	}
+	if err := cli.armGet(ctx, containerAppId, containerAppsApiVersion, &containerApp); err != nil {
+		return fmt.Errorf("getting container app %s: %w", applicationName, err)
+	}
+
+	endpoint := containerApp.Properties.EventStreamEndpoint
+	index := strings.Index(endpoint, "/subscriptions/")
+	if index < 0 {
+		return fmt.Errorf("container app %s does not have an event stream endpoint", applicationName)
+	}
+
+	var authToken struct {
+		Properties struct {
+			Token string `json:"token"`
+		} `json:"properties"`
+	}
+	if err := cli.armPost(ctx, containerAppId+"/getAuthToken", containerAppsApiVersion, nil, &authToken); err != nil {
+		return fmt.Errorf("getting log stream token of container app %s: %w", applicationName, err)
+	}
+
+	type replica struct {
+		Name       string `json:"name"`
+		Properties struct {
+			Containers []struct {
+				Name string `json:"name"`
+			} `json:"containers"`
+		} `json:"properties"`
+	}
+
+	revisionId := fmt.Sprintf("%s/revisions/%s", containerAppId, containerApp.Properties.LatestRevisionName)
+	replicas, err := armList[replica](ctx, cli, cli.armUrl(revisionId+"/replicas", containerAppsApiVersion))
+	if err != nil {
+		return fmt.Errorf("listing replicas of container app %s: %w", applicationName, err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 1)
+
+	for _, replica := range replicas {
+		for _, container := range replica.Properties.Containers {
+			streamUrl := fmt.Sprintf(
+				"%s/subscriptions/%s/resourceGroups/%s/containerApps/%s/revisions/%s/replicas/%s/containers/%s/logstream"+
+					"?follow=true&output=text",
+				endpoint[:index], subscriptionId, resourceGroupName, applicationName,
+				containerApp.Properties.LatestRevisionName, replica.Name, container.Name)
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				if err := cli.stream(ctx, streamUrl, authToken.Properties.Token, writer); err != nil {
+					select {
+					case errs <- fmt.Errorf("streaming logs of container app %s: %w", applicationName, err):
+					default:
+					}
+				}
+			}()
+		}
+	}
+
+	wg.Wait()
+	close(errs)
+
+	return <-errs
+}
+
+func (cli *azRestCli) GetStaticWebAppProperties(ctx context.Context, subscriptionID string, resourceGroup string, appName string) (AzCliStaticWebAppProperties, error) {
+	var staticSite struct {
+		Properties AzCliStaticWebAppProperties `json:"properties"`
+	}
+	if err := cli.armGet(ctx, azure.StaticWebAppRID(subscriptionID, resourceGroup, appName), webApiVersion, &staticSite); err != nil {
+		return AzCliStaticWebAppProperties{}, fmt.Errorf("getting static web app %s: %w", appName, err)
+	}
+
+	return staticSite.Properties, nil
+}
+
+func (cli *azRestCli) GetStaticWebAppApiKey(ctx context.Context, subscriptionID string, resourceGroup string, appName string) (string, error) {
+	var secrets struct {
+		Properties struct {
+			ApiKey string `json:"apiKey"`
+		} `json:"properties"`
+	}
+	if err := cli.armPost(ctx, azure.StaticWebAppRID(subscriptionID, resourceGroup, appName)+"/listSecrets", webApiVersion, nil, &secrets); err != nil {
+		return "", fmt.Errorf("listing secrets of static web app %s: %w", appName, err)
+	}
+
+	return secrets.Properties.ApiKey, nil
+}
+
+func (cli *azRestCli) GetStaticWebAppEnvironmentProperties(ctx context.Context, subscriptionID string, resourceGroup string, appName string, environmentName string) (AzCliStaticWebAppEnvironmentProperties, error) {
+	var build struct {
+		Properties AzCliStaticWebAppEnvironmentProperties `json:"properties"`
+	}
+	buildId := fmt.Sprintf("%s/builds/%s", azure.StaticWebAppRID(subscriptionID, resourceGroup, appName), environmentName)
+	if err := cli.armGet(ctx, buildId, webApiVersion, &build); err != nil {
+		return AzCliStaticWebAppEnvironmentProperties{}, fmt.Errorf("getting environment %s of static web app %s: %w", environmentName, appName, err)
+	}
+
+	return build.Properties, nil
+}
+
+func (cli *azRestCli) DeployToSubscription(ctx context.Context, subscriptionId string, deploymentName string, templatePath string, parametersPath string, location string) (AzCliDeploymentResult, error) {
+	return cli.deploy(ctx, azure.SubscriptionDeploymentRID(subscriptionId, deploymentName), templatePath, parametersPath, location)
+}
+
+func (cli *azRestCli) DeployToResourceGroup(ctx context.Context, subscriptionId string, resourceGroup string, deploymentName string, templatePath string, parametersPath string) (AzCliDeploymentResult, error) {
+	return cli.deploy(ctx, azure.ResourceGroupDeploymentRID(subscriptionId, resourceGroup, deploymentName), templatePath, parametersPath, "")
+}
+
+type armDeploymentRequest struct {
+	Location   string                         `json:"location,omitempty"`
+	Properties armDeploymentRequestProperties `json:"properties"`
+}
+
+type armDeploymentRequestProperties struct {
+	Mode       string          `json:"mode"`
+	Template   json.RawMessage `json:"template"`
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+}
+
+// newDeploymentRequest reads the template, compiling it when it is a bicep file, and the values of its parameters from
+// a parameters file.
+func (cli *azRestCli) newDeploymentRequest(ctx context.Context, templatePath string, parametersPath string, location string) (armDeploymentRequest, error) {
+	var template string
+	if filepath.Ext(templatePath) == ".bicep" {
+		compiled, err := cli.bicepCli.Build(ctx, templatePath)
+		if err != nil {
+			return armDeploymentRequest{}, err
+		}
+		template = compiled
+	} else {
+		contents, err := os.ReadFile(templatePath)
+		if err != nil {
+			return armDeploymentRequest{}, fmt.Errorf("reading template: %w", err)
+		}
+		template = string(contents)
+	}
+
+	parametersFile, err := os.ReadFile(parametersPath)
+	if err != nil {
+		return armDeploymentRequest{}, fmt.Errorf("reading parameters file: %w", err)
+	}
+
+	var parameters struct {
+		Parameters json.RawMessage `json:"parameters"`
+	}
+	if err := json.Unmarshal(parametersFile, &parameters); err != nil {
+		return armDeploymentRequest{}, fmt.Errorf("parsing parameters file %s: %w", parametersPath, err)
+	}
+
+	return armDeploymentRequest{
+		Location: location,
+		Properties: armDeploymentRequestProperties{
+			Mode:       "Incremental",
+			Template:   json.RawMessage(template),
+			Parameters: parameters.Parameters,
+		},
+	}, nil
+}
+
+func (cli *azRestCli) deploy(ctx context.Context, deploymentId string, templatePath string, parametersPath string, location string) (AzCliDeploymentResult, error) {
+	request, err := cli.newDeploymentRequest(ctx, templatePath, parametersPath, location)
+	if err != nil {
+		return AzCliDeploymentResult{}, err
+	}
+
+	if err := cli.armSend(ctx, http.MethodPut, deploymentId, resourcesApiVersion, request, nil); err != nil {
+		return AzCliDeploymentResult{}, fmt.Errorf("deploying template: \n%w", asDeploymentError(err))
+	}
+
+	var deploymentResult AzCliDeploymentResult
+	if err := cli.armGet(ctx, deploymentId, resourcesApiVersion, &deploymentResult); err != nil {
+		return AzCliDeploymentResult{}, fmt.Errorf("getting deployment result: %w", err)
+	}
+
+	return deploymentResult, nil
+}
+
+func (cli *azRestCli) WhatIfDeployToSubscription(ctx context.Context, subscriptionId string, deploymentName string, templatePath string, parametersPath string, location string) (AzCliWhatIfResult, error) {
+	return cli.whatIf(ctx, azure.SubscriptionDeploymentRID(subscriptionId, deploymentName), templatePath, parametersPath, location)
+}
+
+func (cli *azRestCli) WhatIfDeployToResourceGroup(ctx context.Context, subscriptionId string, resourceGroup string, deploymentName string, templatePath string, parametersPath string) (AzCliWhatIfResult, error) {
+	return cli.whatIf(ctx, azure.ResourceGroupDeploymentRID(subscriptionId, resourceGroup, deploymentName), templatePath, parametersPath, "")
+}
+
+func (cli *azRestCli) whatIf(ctx context.Context, deploymentId string, templatePath string, parametersPath string, location string) (AzCliWhatIfResult, error) {
+	request, err := cli.newDeploymentRequest(ctx, templatePath, parametersPath, location)
+	if err != nil {
+		return AzCliWhatIfResult{}, err
+	}
+
+	var wire struct {
+		Status     string                        `json:"status"`
+		Error      *AzCliDeploymentErrorResponse `json:"error"`
+		Properties struct {
+			Changes []AzCliWhatIfChange `json:"changes"`
+		} `json:"properties"`
+	}
+	if err := cli.armPost(ctx, deploymentId+"/whatIf", resourcesApiVersion, request, &wire); err != nil {
+		return AzCliWhatIfResult{}, fmt.Errorf("previewing deployment: \n%w", asDeploymentError(err))
+	}
+
+	if wire.Error != nil {
+		return AzCliWhatIfResult{}, fmt.Errorf("previewing deployment failed: %s: %s", wire.Error.Code, wire.Error.Message)
+	}
+
+	return AzCliWhatIfResult{Status: wire.Status, Changes: wire.Properties.Changes}, nil
+}
+
+func (cli *azRestCli) DeleteSubscriptionDeployment(ctx context.Context, subscriptionId string, deploymentName string) error {
+	if err := cli.armSend(ctx, http.MethodDelete, azure.SubscriptionDeploymentRID(subscriptionId, deploymentName), resourcesApiVersion, nil, nil); err != nil {
+		return fmt.Errorf("deleting deployment %s: %w", deploymentName, err)
+	}
+
+	return nil
+}
+
+func (cli *azRestCli) DeleteResourceGroup(ctx context.Context, subscriptionId string, resourceGroupName string) error {
+	if err := cli.armSend(ctx, http.MethodDelete, azure.ResourceGroupRID(subscriptionId, resourceGroupName), resourcesApiVersion, nil, nil); err != nil {
+		return fmt.Errorf("deleting resource group %s: %w", resourceGroupName, err)
+	}
+
+	return nil
+}
+
+func (cli *azRestCli) ListResourceGroupResources(ctx context.Context, subscriptionId string, resourceGroupName string) ([]AzCliResource, error) {
+	resources, err := armList[AzCliResource](ctx, cli, cli.armUrl(
+		azure.ResourceGroupRID(subscriptionId, resourceGroupName)+"/resources", resourcesApiVersion))
+	if err != nil {
+		return nil, fmt.Errorf("listing resources of resource group %s: %w", resourceGroupName, err)
+	}
+
+	return resources, nil
+}
+
+func (cli *azRestCli) ListSubscriptionDeploymentOperations(ctx context.Context, subscriptionId string, deploymentName string) ([]AzCliResourceOperation, error) {
+	return cli.listDeploymentOperations(ctx, azure.SubscriptionDeploymentRID(subscriptionId, deploymentName))
+}
+
+func (cli *azRestCli) ListResourceGroupDeploymentOperations(ctx context.Context, subscriptionId string, resourceGroupName string, deploymentName string) ([]AzCliResourceOperation, error) {
+	return cli.listDeploymentOperations(ctx, azure.ResourceGroupDeploymentRID(subscriptionId, resourceGroupName, deploymentName))
+}
+
+func (cli *azRestCli) listDeploymentOperations(ctx context.Context, deploymentId string) ([]AzCliResourceOperation, error) {
+	operations, err := armList[AzCliResourceOperation](ctx, cli, cli.armUrl(deploymentId+"/operations", resourcesApiVersion))
+	if isNotFoundError(err) {
+		return nil, ErrDeploymentNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("listing deployment operations: %w", err)
+	}
+
+	return operations, nil
+}
+
+// ListAccountLocations lists the physical locations available to the first subscription of the account, since the
+// REST API does not have a notion of a default subscription.
+func (cli *azRestCli) ListAccountLocations(ctx context.Context) ([]AzCliLocation, error) {
+	accounts, err := cli.ListAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(accounts) == 0 {
+		return nil, errors.New("no subscriptions were found for the account")
+	}
+
+	locations, err := armList[struct {
+		AzCliLocation
+		Metadata struct {
+			RegionType string `json:"regionType"`
+		} `json:"metadata"`
+	}](ctx, cli, cli.armUrl(azure.SubscriptionRID(accounts[0].Id)+"/locations", subscriptionsApiVersion))
+	if err != nil {
+		return nil, fmt.Errorf("listing locations: %w", err)
+	}
+
+	physicalLocations := []AzCliLocation{}
+	for _, location := range locations {
+		if location.Metadata.RegionType == "Physical" {
+			physicalLocations = append(physicalLocations, location.AzCliLocation)
+		}
+	}
+
+	return physicalLocations, nil
+}
+
+// CreateOrUpdateServicePrincipal creates the application and service principal with Microsoft Graph, replaces its
+// passwords with a new one and assigns it the role on the subscription.
+func (cli *azRestCli) CreateOrUpdateServicePrincipal(ctx context.Context, subscriptionId string, applicationName string, roleName string) (json.RawMessage, error) {
+	tenantId, err := cli.GetSubscriptionTenant(ctx, subscriptionId)
+	if err != nil {
+		return nil, err
+	}
+
+	application, servicePrincipalId, err := cli.createOrUpdateApplication(ctx, applicationName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Existing passwords are removed, so the credentials are reset as they are by `az ad sp create-for-rbac`.
+	for _, password := range application.PasswordCredentials {
+		removePath := fmt.Sprintf("/v1.0/applications/%s/removePassword", application.Id)
+		if err := cli.graphPost(ctx, removePath, map[string]string{"keyId": password.KeyId}, nil); err != nil {
+			return nil, fmt.Errorf("removing password of application: %w", err)
+		}
+	}
+
+	var password struct {
+		SecretText string `json:"secretText"`
+	}
+	addPath := fmt.Sprintf("/v1.0/applications/%s/addPassword", application.Id)
+	addRequest := map[string]interface{}{"passwordCredential": map[string]string{"displayName": "azd"}}
+	if err := cli.graphPost(ctx, addPath, addRequest, &password); err != nil {
+		return nil, fmt.Errorf("adding password to application: %w", err)
+	}
+
+	if err := cli.assignRole(ctx, azure.SubscriptionRID(subscriptionId), servicePrincipalId, roleName); err != nil {
+		return nil, err
+	}
+
+	credentials := AzureCredentials{
+		ClientId:                   application.AppId,
+		ClientSecret:               password.SecretText,
+		SubscriptionId:             subscriptionId,
+		TenantId:                   tenantId,
+		ResourceManagerEndpointUrl: "https://management.azure.com/",
+	}
+
+	credentialsJson, err := json.Marshal(credentials)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't build Azure Credential")
+	}
+
+	return json.RawMessage(credentialsJson), nil
+}
+
+// GetApplicationClientId lists the applications with the display name with Microsoft Graph.
+func (cli *azRestCli) GetApplicationClientId(ctx context.Context, applicationName string) (string, error) {
+	applications, err := graphList[graphApplication](ctx, cli,
+		cli.graphUrl("/v1.0/applications?$filter="+url.QueryEscape(fmt.Sprintf("displayName eq '%s'", applicationName))))
+	if err != nil {
+		return "", fmt.Errorf("listing applications: %w", err)
+	}
+
+	if len(applications) == 0 {
+		return "", ErrApplicationNotFound
+	}
+
+	return applications[0].AppId, nil
+}
+
+// CreateOrUpdateFederatedServicePrincipal creates the application and service principal with Microsoft Graph, assigns
+// it the role on the subscription and creates or updates its federated identity credentials. Its passwords are kept.
+func (cli *azRestCli) CreateOrUpdateFederatedServicePrincipal(ctx context.Context, subscriptionId string, applicationName string, roleName string, credentials []FederatedIdentityCredential) (AzCliServicePrincipal, error) {
+	tenantId, err := cli.GetSubscriptionTenant(ctx, subscriptionId)
+	if err != nil {
+		return AzCliServicePrincipal{}, err
+	}
+
+	application, servicePrincipalId, err := cli.createOrUpdateApplication(ctx, applicationName)
+	if err != nil {
+		return AzCliServicePrincipal{}, err
+	}
+
+	if err := cli.assignRole(ctx, azure.SubscriptionRID(subscriptionId), servicePrincipalId, roleName); err != nil {
+		return AzCliServicePrincipal{}, err
+	}
+
+	credentialsPath := fmt.Sprintf("/v1.0/applications/%s/federatedIdentityCredentials", application.Id)
+	existing, err := graphList[struct {
+		Id   string `json:"id"`
+		Name string `json:"name"`
+	}](ctx, cli, cli.graphUrl(credentialsPath))
+	if err != nil {
+		return AzCliServicePrincipal{}, fmt.Errorf("listing federated credentials: %w", err)
+	}
+
+	for _, credential := range credentials {
+		var existingId string
+		for _, e := range existing {
+			if e.Name == credential.Name {
+				existingId = e.Id
+			}
+		}
+
+		if existingId == "" {
+			err = cli.graphPost(ctx, credentialsPath, credential, nil)
+		} else {
+			_, err = cli.send(ctx, http.MethodPatch, cli.graphUrl(credentialsPath+"/"+existingId), GraphScope, credential, nil)
+		}
+		if err != nil {
+			return AzCliServicePrincipal{}, fmt.Errorf("saving federated credential %s: %w", credential.Name, err)
+		}
+	}
+
+	return AzCliServicePrincipal{ClientId: application.AppId, TenantId: tenantId}, nil
+}
+
+type graphApplication struct {
+	Id                  string `json:"id"`
+	AppId               string `json:"appId"`
+	PasswordCredentials []struct {
+		KeyId string `json:"keyId"`
+	} `json:"passwordCredentials"`
+}
+
+// createOrUpdateApplication returns the application with the given display name and the id of its service principal,
+// creating them when they do not exist.
+func (cli *azRestCli) createOrUpdateApplication(ctx context.Context, applicationName string) (graphApplication, string, error) {
+	applications, err := graphList[graphApplication](ctx, cli,
+		cli.graphUrl("/v1.0/applications?$filter="+url.QueryEscape(fmt.Sprintf("displayName eq '%s'", applicationName))))
+	if err != nil {
+		return graphApplication{}, "", fmt.Errorf("listing applications: %w", err)
+	}
+
+	var application graphApplication
+	if len(applications) > 0 {
+		application = applications[0]
+	} else if err := cli.graphPost(ctx, "/v1.0/applications", map[string]string{"displayName": applicationName}, &application); err != nil {
+		return graphApplication{}, "", fmt.Errorf("creating application: %w", err)
+	}
+
+	type graphServicePrincipal struct {
+		Id string `json:"id"`
+	}
+
+	servicePrincipals, err := graphList[graphServicePrincipal](ctx, cli,
+		cli.graphUrl("/v1.0/servicePrincipals?$filter="+url.QueryEscape(fmt.Sprintf("appId eq '%s'", application.AppId))))
+	if err != nil {
+		return graphApplication{}, "", fmt.Errorf("listing service principals: %w", err)
+	}
+
+	var servicePrincipal graphServicePrincipal
+	if len(servicePrincipals) > 0 {
+		servicePrincipal = servicePrincipals[0]
+	} else if err := cli.graphPost(ctx, "/v1.0/servicePrincipals", map[string]string{"appId": application.AppId}, &servicePrincipal); err != nil {
+		return graphApplication{}, "", fmt.Errorf("creating service principal: %w", err)
+	}
+
+	return application, servicePrincipal.Id, nil
+}
+
+func (cli *azRestCli) assignRole(ctx context.Context, scope string, principalId string, roleName string) error {
+	roleDefinitions, err := armList[struct {
+		Id string `json:"id"`
+	}](ctx, cli, cli.armUrl(scope+"/providers/Microsoft.Authorization/roleDefinitions", authorizationApiVersion)+
+		"&$filter="+url.QueryEscape(fmt.Sprintf("roleName eq '%s'", roleName)))
+	if err != nil {
+		return fmt.Errorf("listing role definitions: %w", err)
+	}
+
+	if len(roleDefinitions) == 0 {
+		return fmt.Errorf("role '%s' was not found", roleName)
+	}
+
+	assignmentName, err := newUuid()
+	if err != nil {
+		return err
+	}
+
+	assignment := map[string]interface{}{
+		"properties": map[string]string{
+			"roleDefinitionId": roleDefinitions[0].Id,
+			"principalId":      principalId,
+			"principalType":    "ServicePrincipal",
+		},
+	}
+	assignmentId := fmt.Sprintf("%s/providers/Microsoft.Authorization/roleAssignments/%s", scope, assignmentName)
+
+	// A new service principal takes a while to replicate, until then it can not be assigned a role.
+	err = retry.Do(ctx, retry.WithMaxRetries(10, retry.NewConstant(defaultPollInterval)), func(ctx context.Context) error {
+		err := cli.armSend(ctx, http.MethodPut, assignmentId, authorizationApiVersion, assignment, nil)
+
+		var restErr *AzRestError
+		switch {
+		case errors.As(err, &restErr) && restErr.Code == "RoleAssignmentExists":
+			return nil
+		case errors.As(err, &restErr) && restErr.Code == "PrincipalNotFound":
+			return retry.RetryableError(err)
+		default:
+			return err
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("assigning role '%s': %w", roleName, err)
+	}
+
+	return nil
+}
+
+func (cli *azRestCli) GetSignedInUserId(ctx context.Context) (string, error) {
+	res, err := cli.send(ctx, http.MethodGet, cli.graphUrl("/v1.0/me?$select=id"), GraphScope, nil, nil)
+
+	var restErr *AzRestError
+	if errors.As(err, &restErr) && isResourceSegmentMeNotFoundMessage(restErr.Body) {
+		return "", ErrCurrentPrincipalIsNotUser
+	} else if err != nil {
+		return "", fmt.Errorf("getting signed in user: %w", err)
+	}
+
+	var user struct {
+		Id string `json:"id"`
+	}
+	if err := json.Unmarshal(res.Body, &user); err != nil {
+		return "", fmt.Errorf("could not unmarshal response %s as a user: %w", string(res.Body), err)
+	}
+
+	return user.Id, nil
+}
+
+func (cli *azRestCli) GetAccessToken(ctx context.Context) (AzCliAccessToken, error) {
+	return cli.getToken(ctx, ResourceManagerScope)
+}
+
+func (cli *azRestCli) GraphQuery(ctx context.Context, query string, subscriptions []string) (*AzCliGraphQuery, error) {
+	request := GraphQueryRequest{
+		Subscriptions: subscriptions,
+		Query:         query,
+	}
+
+	var graphQueryResult AzCliGraphQuery
+	if err := cli.armPost(ctx, "/providers/Microsoft.ResourceGraph/resources", resourceGraphApiVersion, request, &graphQueryResult); err != nil {
+		return nil, fmt.Errorf("running graph query: %w", err)
+	}
+
+	return &graphQueryResult, nil
+}
+
+func (cli *azRestCli) QueryLogs(ctx context.Context, resourceId string, query string, timespan string) (AzCliLogsQueryResult, error) {
+	path, apiVersion := logsQueryPath(resourceId)
+
+	var result AzCliLogsQueryResult
+	if err := cli.armPost(ctx, path, apiVersion, logsQueryRequest{Query: query, Timespan: timespan}, &result); err != nil {
+		return AzCliLogsQueryResult{}, fmt.Errorf("querying logs: %w", err)
+	}
+
+	return result, nil
+}
+
+func (cli *azRestCli) getToken(ctx context.Context, scope string) (AzCliAccessToken, error) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	if token, has := cli.tokens[scope]; has && token.ExpiresOn != nil && time.Until(*token.ExpiresOn) > tokenRefreshWindow {
+		return token, nil
+	}
+
+	token, err := cli.credential.GetToken(ctx, scope)
+	if err != nil {
+		return AzCliAccessToken{}, err
+	}
+
+	cli.tokens[scope] = token
+	return token, nil
+}
+
+func (cli *azRestCli) armUrl(path string, apiVersion string) string {
+	return fmt.Sprintf("%s%s?api-version=%s", cli.resourceManagerEndpoint, path, apiVersion)
+}
+
+func (cli *azRestCli) graphUrl(path string) string {
+	return cli.graphEndpoint + path
+}
+
+// armGet gets the resource with the given id and unmarshals it into result.
+func (cli *azRestCli) armGet(ctx context.Context, id string, apiVersion string, result interface{}) error {
+	return cli.armSend(ctx, http.MethodGet, id, apiVersion, nil, result)
+}
+
+// armPost sends a POST request to a resource action and unmarshals the response into result.
+func (cli *azRestCli) armPost(ctx context.Context, id string, apiVersion string, body interface{}, result interface{}) error {
+	return cli.armSend(ctx, http.MethodPost, id, apiVersion, body, result)
+}
+
+// armSend sends a request to Resource Manager and waits for the operation to complete when it runs asynchronously.
+// When result is not nil, the final response is unmarshalled into it.
+func (cli *azRestCli) armSend(ctx context.Context, method string, id string, apiVersion string, body interface{}, result interface{}) error {
+	res, err := cli.send(ctx, method, cli.armUrl(id, apiVersion), ResourceManagerScope, body, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err = cli.waitForCompletion(ctx, res)
+	if err != nil {
+		return err
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(res.Body, result); err != nil {
+			return fmt.Errorf("could not unmarshal response %s: %w", string(res.Body), err)
+		}
+	}
+
+	return nil
+}
+
+func (cli *azRestCli) graphPost(ctx context.Context, path string, body interface{}, result interface{}) error {
+	res, err := cli.send(ctx, http.MethodPost, cli.graphUrl(path), GraphScope, body, nil)
+	if err != nil {
+		return err
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(res.Body, result); err != nil {
+			return fmt.Errorf("could not unmarshal response %s: %w", string(res.Body), err)
+		}
+	}
+
+	return nil
+}
+
+// armList gets each page of a list of resources, following the next links of the pages.
+func armList[T any](ctx context.Context, cli *azRestCli, pageUrl string) ([]T, error) {
+	return list[T](ctx, cli, pageUrl, ResourceManagerScope, "nextLink")
+}
+
+// graphList gets each page of a Microsoft Graph collection.
+func graphList[T any](ctx context.Context, cli *azRestCli, pageUrl string) ([]T, error) {
+	return list[T](ctx, cli, pageUrl, GraphScope, "@odata.nextLink")
+}
+
+func list[T any](ctx context.Context, cli *azRestCli, pageUrl string, scope string, nextLinkName string) ([]T, error) {
+	values := []T{}
+
+	for pageUrl != "" {
+		res, err := cli.send(ctx, http.MethodGet, pageUrl, scope, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			Value []T `json:"value"`
+		}
+		if err := json.Unmarshal(res.Body, &page); err != nil {
+			return nil, fmt.Errorf("could not unmarshal response %s: %w", string(res.Body), err)
+		}
+
+		var links map[string]json.RawMessage
+		if err := json.Unmarshal(res.Body, &links); err != nil {
+			return nil, fmt.Errorf("could not unmarshal response %s: %w", string(res.Body), err)
+		}
+
+		values = append(values, page.Value...)
+
+		pageUrl = ""
+		if nextLink, has := links[nextLinkName]; has {
+			if err := json.Unmarshal(nextLink, &pageUrl); err != nil {
+				return nil, fmt.Errorf("could not unmarshal next link %s: %w", string(nextLink), err)
+			}
+		}
+	}
+
+	return values, nil
+}
+
+// send sends a request, authenticated with a token for scope unless scope is empty. A body which is not a string is
+// sent as JSON. Responses with an error status are returned as an *AzRestError.
+func (cli *azRestCli) send(
+	ctx context.Context, method string, requestUrl string, scope string, body interface{}, headers map[string]string,
+) (*httpUtil.HttpResponseMessage, error) {
+	var content string
+	switch body := body.(type) {
+	case nil:
+	case string:
+		content = body
+	default:
+		bytes, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("marshalling JSON body: %w", err)
+		}
+		content = string(bytes)
+	}
+
+	requestHeaders := map[string]string{
+		"User-Agent": cli.userAgent,
+	}
+
+	if scope != "" {
+		token, err := cli.getToken(ctx, scope)
+		if err != nil {
+			return nil, fmt.Errorf("getting access token: %w", err)
+		}
+		requestHeaders["Authorization"] = fmt.Sprintf("Bearer %s", token.AccessToken)
+	}
+
+	for k, v := range headers {
+		requestHeaders[k] = v
+	}
+
+	res, err := httpUtil.GetHttpUtilFromContext(ctx).Send(&httpUtil.HttpRequestMessage{
+		Url:     requestUrl,
+		Method:  method,
+		Headers: requestHeaders,
+		Body:    content,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sending %s request to %s: %w", method, requestUrl, err)
+	}
+
+	if cli.enableDebug {
+		log.Printf("%s %s: %d", method, requestUrl, res.Status)
+	}
+
+	if res.Status >= http.StatusBadRequest {
+		return nil, newAzRestError(res.Status, res.Body)
+	}
+
+	return res, nil
+}
+
+// stream sends a GET request authenticated with token, and writes the lines of the response to writer as they are
+// received, until the response ends or ctx is cancelled. Unlike send, the response is not read whole, so the request
+// is not sent with the HttpUtil of the context.
+func (cli *azRestCli) stream(ctx context.Context, streamUrl string, token string, writer io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, streamUrl, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", cli.userAgent)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("sending GET request to %s: %w", streamUrl, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		body, _ := io.ReadAll(res.Body)
+		return newAzRestError(res.StatusCode, body)
+	}
+
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		if _, err := fmt.Fprintln(writer, scanner.Text()); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("reading stream: %w", err)
+	}
+
+	return nil
+}
+
+// waitForCompletion polls a long running operation until it completes, returning the final response. Responses of
+// requests which completed synchronously are returned as is.
+func (cli *azRestCli) waitForCompletion(ctx context.Context, res *httpUtil.HttpResponseMessage) (*httpUtil.HttpResponseMessage, error) {
+	// See https://github.com/Azure/azure-resource-manager-rpc/blob/master/v1.0/async-api-reference.md
+	if operationUrl := responseHeader(res, "Azure-AsyncOperation"); operationUrl != "" {
+		for {
+			if err := waitForRetry(ctx, res); err != nil {
+				return nil, err
+			}
+
+			var err error
+			res, err = cli.send(ctx, http.MethodGet, operationUrl, ResourceManagerScope, nil, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			var operation struct {
+				Status string `json:"status"`
+			}
+			if err := json.Unmarshal(res.Body, &operation); err != nil {
+				return nil, fmt.Errorf("could not unmarshal operation status %s: %w", string(res.Body), err)
+			}
+
+			switch strings.ToLower(operation.Status) {
+			case "succeeded":
+				return res, nil
+			case "failed", "canceled":
+				restErr := newAzRestError(res.Status, res.Body)
+				if restErr.Code == "" {
+					restErr.Code = operation.Status
+				}
+				return nil, restErr
+			}
+		}
+	}
+
+	if locationUrl := responseHeader(res, "Location"); locationUrl != "" && res.Status == http.StatusAccepted {
+		for res.Status == http.StatusAccepted {
+			if err := waitForRetry(ctx, res); err != nil {
+				return nil, err
+			}
+
+			var err error
+			res, err = cli.send(ctx, http.MethodGet, locationUrl, ResourceManagerScope, nil, nil)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return res, nil
+}
+
+func responseHeader(res *httpUtil.HttpResponseMessage, name string) string {
+	return res.Headers[http.CanonicalHeaderKey(name)]
+}
+
+// waitForRetry waits for the interval the response asks for before polling again.
+func waitForRetry(ctx context.Context, res *httpUtil.HttpResponseMessage) error {
+	delay := defaultPollInterval
+	if seconds, err := strconv.Atoi(responseHeader(res, "Retry-After")); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(delay):
+		return nil
+	}
+}
+
+// asDeploymentError returns the error of a failed deployment as an AzureDeploymentError, which formats the details of
+// the error.
+func asDeploymentError(err error) error {
+	var restErr *AzRestError
+	if errors.As(err, &restErr) && restErr.Code != "" {
+		return internal.NewAzureDeploymentError(restErr.Body)
+	}
+
+	return err
+}
+
+// newUuid returns a random (version 4) UUID.
+func newUuid() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating uuid: %w", err)
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,512 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeCredential struct {
+	calls int
+}
+
+func (cred *fakeCredential) GetToken(ctx context.Context, scope string) (AzCliAccessToken, error) {
+	cred.calls++
+	expiresOn := time.Now().Add(time.Hour)
+	return AzCliAccessToken{AccessToken: fmt.Sprintf("token-%d", cred.calls), ExpiresOn: &expiresOn}, nil
+}
+
+// newFakeArmServer starts a server which handles requests to Resource Manager with the handlers of mux, after checking
+// they are authenticated.
+func newFakeArmServer(t *testing.T, mux *http.ServeMux) (*httptest.Server, AzCli) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer token-1", r.Header.Get("Authorization"))
+		require.Equal(t, "test-user-agent", r.Header.Get("User-Agent"))
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	cli := NewAzRestCli(NewAzRestCliArgs{
+		Credential:              &fakeCredential{},
+		ResourceManagerEndpoint: server.URL,
+		GraphEndpoint:           server.URL,
+	})
+	cli.SetUserAgent("test-user-agent")
+
+	return server, cli
+}
+
+func writeJson(t *testing.T, w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	require.NoError(t, json.NewEncoder(w).Encode(value))
+}
+
+func writeDeploymentFiles(t *testing.T) (string, string) {
+	dir := t.TempDir()
+
+	templatePath := filepath.Join(dir, "main.json")
+	require.NoError(t, os.WriteFile(templatePath, []byte(`{"resources": []}`), 0600))
+
+	parametersPath := filepath.Join(dir, "main.parameters.json")
+	require.NoError(t, os.WriteFile(parametersPath, []byte(`{"parameters": {"name": {"value": "test"}}}`), 0600))
+
+	return templatePath, parametersPath
+}
+
+func TestAzRestCliDeployToSubscription(t *testing.T) {
+	const deploymentPath = "/subscriptions/SUBSCRIPTION_ID/providers/Microsoft.Resources/deployments/DEPLOYMENT_NAME"
+	templatePath, parametersPath := writeDeploymentFiles(t)
+
+	t.Run("Succeeded", func(t *testing.T) {
+		polls := 0
+		mux := http.NewServeMux()
+		var server *httptest.Server
+
+		mux.HandleFunc(deploymentPath, func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, resourcesApiVersion, r.URL.Query().Get("api-version"))
+
+			if r.Method == http.MethodPut {
+				var request armDeploymentRequest
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+				require.Equal(t, "westus2", request.Location)
+				require.Equal(t, "Incremental", request.Properties.Mode)
+				require.JSONEq(t, `{"resources": []}`, string(request.Properties.Template))
+				require.JSONEq(t, `{"name": {"value": "test"}}`, string(request.Properties.Parameters))
+
+				w.Header().Set("Azure-AsyncOperation", server.URL+"/operations/1")
+				w.Header().Set("Retry-After", "0")
+				writeJson(t, w, http.StatusCreated, map[string]interface{}{})
+				return
+			}
+
+			writeJson(t, w, http.StatusOK, map[string]interface{}{
+				"properties": map[string]interface{}{
+					"outputs": map[string]interface{}{
+						"WEBSITE_URL": map[string]interface{}{"type": "String", "value": "https://example.com"},
+					},
+				},
+			})
+		})
+
+		mux.HandleFunc("/operations/1", func(w http.ResponseWriter, r *http.Request) {
+			polls++
+			status := "Running"
+			if polls == 2 {
+				status = "Succeeded"
+			}
+
+			w.Header().Set("Retry-After", "0")
+			writeJson(t, w, http.StatusOK, map[string]interface{}{"status": status})
+		})
+
+		var cli AzCli
+		server, cli = newFakeArmServer(t, mux)
+
+		result, err := cli.DeployToSubscription(
+			context.Background(), "SUBSCRIPTION_ID", "DEPLOYMENT_NAME", templatePath, parametersPath, "westus2")
+		require.NoError(t, err)
+		require.Equal(t, 2, polls)
+		require.Equal(t, "https://example.com", result.Properties.Outputs["WEBSITE_URL"].Value)
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		mux := http.NewServeMux()
+		var server *httptest.Server
+
+		mux.HandleFunc(deploymentPath, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Azure-AsyncOperation", server.URL+"/operations/1")
+			w.Header().Set("Retry-After", "0")
+			writeJson(t, w, http.StatusCreated, map[string]interface{}{})
+		})
+
+		mux.HandleFunc("/operations/1", func(w http.ResponseWriter, r *http.Request) {
+			writeJson(t, w, http.StatusOK, map[string]interface{}{
+				"status": "Failed",
+				"error": map[string]interface{}{
+					"code":    "DeploymentFailed",
+					"message": "At least one resource deployment operation failed.",
+					"details": []interface{}{
+						map[string]interface{}{"code": "Conflict", "message": "Website with given name web already exists."},
+					},
+				},
+			})
+		})
+
+		var cli AzCli
+		server, cli = newFakeArmServer(t, mux)
+
+		_, err := cli.DeployToSubscription(
+			context.Background(), "SUBSCRIPTION_ID", "DEPLOYMENT_NAME", templatePath, parametersPath, "westus2")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "Deployment Error Details")
+		require.Contains(t, err.Error(), "Website with given name web already exists.")
+	})
+}
+
+func TestAzRestCliGetSubscriptionDeploymentNotFound(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/subscriptions/SUBSCRIPTION_ID/providers/Microsoft.Resources/deployments/DEPLOYMENT_NAME",
+		func(w http.ResponseWriter, r *http.Request) {
+			writeJson(t, w, http.StatusNotFound, map[string]interface{}{
+				"error": map[string]interface{}{"code": "DeploymentNotFound", "message": "Deployment could not be found."},
+			})
+		})
+
+	_, cli := newFakeArmServer(t, mux)
+
+	_, err := cli.GetSubscriptionDeployment(context.Background(), "SUBSCRIPTION_ID", "DEPLOYMENT_NAME")
+	require.ErrorIs(t, err, ErrDeploymentNotFound)
+}
+
+func TestAzRestCliDeleteResourceGroup(t *testing.T) {
+	polls := 0
+	mux := http.NewServeMux()
+	var server *httptest.Server
+
+	mux.HandleFunc("/subscriptions/SUBSCRIPTION_ID/resourceGroups/RESOURCE_GROUP", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodDelete, r.Method)
+		w.Header().Set("Location", server.URL+"/operations/1")
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	mux.HandleFunc("/operations/1", func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		if polls < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+
+	var cli AzCli
+	server, cli = newFakeArmServer(t, mux)
+
+	require.NoError(t, cli.DeleteResourceGroup(context.Background(), "SUBSCRIPTION_ID", "RESOURCE_GROUP"))
+	require.Equal(t, 3, polls)
+}
+
+func TestAzRestCliListResourceGroupResources(t *testing.T) {
+	mux := http.NewServeMux()
+	var server *httptest.Server
+
+	mux.HandleFunc("/subscriptions/SUBSCRIPTION_ID/resourceGroups/RESOURCE_GROUP/resources", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			writeJson(t, w, http.StatusOK, map[string]interface{}{
+				"value": []AzCliResource{{Id: "id-2", Name: "web", Type: "Microsoft.Web/sites", Location: "westus2"}},
+			})
+			return
+		}
+
+		writeJson(t, w, http.StatusOK, map[string]interface{}{
+			"value":    []AzCliResource{{Id: "id-1", Name: "plan", Type: "Microsoft.Web/serverFarms", Location: "westus2"}},
+			"nextLink": server.URL + r.URL.Path + "?api-version=" + resourcesApiVersion + "&page=2",
+		})
+	})
+
+	var cli AzCli
+	server, cli = newFakeArmServer(t, mux)
+
+	resources, err := cli.ListResourceGroupResources(context.Background(), "SUBSCRIPTION_ID", "RESOURCE_GROUP")
+	require.NoError(t, err)
+	require.Len(t, resources, 2)
+	require.Equal(t, "plan", resources[0].Name)
+	require.Equal(t, "web", resources[1].Name)
+}
+
+func TestAzRestCliGetResource(t *testing.T) {
+	const resourceId = "/subscriptions/SUBSCRIPTION_ID/resourceGroups/RESOURCE_GROUP/providers/Microsoft.Web/sites/web"
+	providerRequests := 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/subscriptions/SUBSCRIPTION_ID/providers/Microsoft.Web", func(w http.ResponseWriter, r *http.Request) {
+		providerRequests++
+		writeJson(t, w, http.StatusOK, map[string]interface{}{
+			"resourceTypes": []interface{}{
+				map[string]interface{}{"resourceType": "sites", "apiVersions": []string{"2022-09-01-preview", "2022-03-01"}},
+			},
+		})
+	})
+	mux.HandleFunc(resourceId, func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "2022-03-01", r.URL.Query().Get("api-version"))
+		writeJson(t, w, http.StatusOK, map[string]interface{}{
+			"id":   resourceId,
+			"name": "web",
+			"type": "Microsoft.Web/sites",
+			"kind": "app,linux",
+		})
+	})
+
+	_, cli := newFakeArmServer(t, mux)
+
+	for i := 0; i < 2; i++ {
+		resource, err := cli.GetResource(context.Background(), "SUBSCRIPTION_ID", resourceId)
+		require.NoError(t, err)
+		require.Equal(t, "web", resource.Name)
+		require.Equal(t, "app,linux", resource.Kind)
+	}
+
+	// The api version of the type is only looked up once.
+	require.Equal(t, 1, providerRequests)
+}
+
+func TestAzRestCliGetAppServiceProperties(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/subscriptions/SUBSCRIPTION_ID/resourceGroups/RESOURCE_GROUP/providers/Microsoft.Web/sites/web",
+		func(w http.ResponseWriter, r *http.Request) {
+			writeJson(t, w, http.StatusOK, map[string]interface{}{
+				"properties": map[string]interface{}{"hostNames": []string{"web.azurewebsites.net"}},
+			})
+		})
+
+	_, cli := newFakeArmServer(t, mux)
+
+	props, err := cli.GetAppServiceProperties(context.Background(), "SUBSCRIPTION_ID", "RESOURCE_GROUP", "web")
+	require.NoError(t, err)
+	require.Equal(t, []string{"web.azurewebsites.net"}, props.HostNames)
+}
+
+func TestAzRestCliGetSignedInUserId(t *testing.T) {
+	t.Run("User", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/v1.0/me", func(w http.ResponseWriter, r *http.Request) {
+			writeJson(t, w, http.StatusOK, map[string]interface{}{"id": "USER_ID"})
+		})
+
+		_, cli := newFakeArmServer(t, mux)
+
+		userId, err := cli.GetSignedInUserId(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "USER_ID", userId)
+	})
+
+	t.Run("ServicePrincipal", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/v1.0/me", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			_, err := io.WriteString(w, `{"error": {"code": "Request_ResourceNotFound", "message": "Resource not found for the segment 'me'."}}`)
+			require.NoError(t, err)
+		})
+
+		_, cli := newFakeArmServer(t, mux)
+
+		_, err := cli.GetSignedInUserId(context.Background())
+		require.ErrorIs(t, err, ErrCurrentPrincipalIsNotUser)
+	})
+}
+
+func TestAzRestCliCreateOrUpdateFederatedServicePrincipal(t *testing.T) {
+	var created []FederatedIdentityCredential
+	var updated []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/subscriptions/SUBSCRIPTION_ID", func(w http.ResponseWriter, r *http.Request) {
+		writeJson(t, w, http.StatusOK, map[string]interface{}{"tenantId": "TENANT_ID"})
+	})
+	mux.HandleFunc("/v1.0/applications", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "displayName eq 'az-dev-app'", r.URL.Query().Get("$filter"))
+		writeJson(t, w, http.StatusOK, map[string]interface{}{
+			"value": []interface{}{map[string]interface{}{"id": "APP_OBJECT_ID", "appId": "CLIENT_ID"}},
+		})
+	})
+	mux.HandleFunc("/v1.0/servicePrincipals", func(w http.ResponseWriter, r *http.Request) {
+		writeJson(t, w, http.StatusOK, map[string]interface{}{"value": []interface{}{map[string]interface{}{"id": "SP_ID"}}})
+	})
+	mux.HandleFunc("/subscriptions/SUBSCRIPTION_ID/providers/Microsoft.Authorization/roleDefinitions", func(w http.ResponseWriter, r *http.Request) {
+		writeJson(t, w, http.StatusOK, map[string]interface{}{"value": []interface{}{map[string]interface{}{"id": "ROLE_ID"}}})
+	})
+	mux.HandleFunc("/subscriptions/SUBSCRIPTION_ID/providers/Microsoft.Authorization/roleAssignments/", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPut, r.Method)
+		writeJson(t, w, http.StatusCreated, map[string]interface{}{})
+	})
+	mux.HandleFunc("/v1.0/applications/APP_OBJECT_ID/federatedIdentityCredentials", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJson(t, w, http.StatusOK, map[string]interface{}{
+				"value": []interface{}{map[string]interface{}{"id": "EXISTING_ID", "name": "main"}},
+			})
+		case http.MethodPost:
+			var credential FederatedIdentityCredential
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&credential))
+			created = append(created, credential)
+			writeJson(t, w, http.StatusCreated, credential)
+		default:
+			t.Fatalf("unexpected method %s", r.Method)
+		}
+	})
+	mux.HandleFunc("/v1.0/applications/APP_OBJECT_ID/federatedIdentityCredentials/EXISTING_ID", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPatch, r.Method)
+		updated = append(updated, "EXISTING_ID")
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// Resource Manager and Microsoft Graph are called with tokens of different scopes, so newFakeArmServer can not
+	// be used.
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	cli := NewAzRestCli(NewAzRestCliArgs{
+		Credential:              &fakeCredential{},
+		ResourceManagerEndpoint: server.URL,
+		GraphEndpoint:           server.URL,
+	})
+
+	credential := func(name string) FederatedIdentityCredential {
+		return FederatedIdentityCredential{
+			Name:      name,
+			Issuer:    "https://token.actions.githubusercontent.com",
+			Subject:   "repo:owner/repo:" + name,
+			Audiences: []string{"api://AzureADTokenExchange"},
+		}
+	}
+
+	principal, err := cli.CreateOrUpdateFederatedServicePrincipal(
+		context.Background(), "SUBSCRIPTION_ID", "az-dev-app", "Contributor", []FederatedIdentityCredential{credential("main"), credential("pull_request")})
+	require.NoError(t, err)
+	require.Equal(t, AzCliServicePrincipal{ClientId: "CLIENT_ID", TenantId: "TENANT_ID"}, principal)
+
+	require.Equal(t, []string{"EXISTING_ID"}, updated)
+	require.Equal(t, []FederatedIdentityCredential{credential("pull_request")}, created)
+}
+
+func TestAzRestCliGetApplicationClientId(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1.0/applications", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("$filter") == "displayName eq 'az-dev-app'" {
+			writeJson(t, w, http.StatusOK, map[string]interface{}{
+				"value": []interface{}{map[string]interface{}{"id": "APP_OBJECT_ID", "appId": "CLIENT_ID"}},
+			})
+			return
+		}
+
+		writeJson(t, w, http.StatusOK, map[string]interface{}{"value": []interface{}{}})
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	cli := NewAzRestCli(NewAzRestCliArgs{
+		Credential:    &fakeCredential{},
+		GraphEndpoint: server.URL,
+	})
+
+	clientId, err := cli.GetApplicationClientId(context.Background(), "az-dev-app")
+	require.NoError(t, err)
+	require.Equal(t, "CLIENT_ID", clientId)
+
+	_, err = cli.GetApplicationClientId(context.Background(), "az-dev-other")
+	require.ErrorIs(t, err, ErrApplicationNotFound)
+}
+
+func TestAzRestCliStreamContainerAppLogs(t *testing.T) {
+	const containerAppPath = "/subscriptions/SUBSCRIPTION_ID/resourceGroups/RESOURCE_GROUP/providers/Microsoft.App/containerApps/api"
+
+	logServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer LOG_TOKEN", r.Header.Get("Authorization"))
+		require.Equal(t,
+			"/subscriptions/SUBSCRIPTION_ID/resourceGroups/RESOURCE_GROUP/containerApps/api/revisions/api--rev1/replicas/REPLICA/containers/main/logstream",
+			r.URL.Path)
+		require.Equal(t, "true", r.URL.Query().Get("follow"))
+
+		_, _ = io.WriteString(w, "first\nsecond\n")
+	}))
+	t.Cleanup(logServer.Close)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(containerAppPath, func(w http.ResponseWriter, r *http.Request) {
+		writeJson(t, w, http.StatusOK, map[string]interface{}{
+			"properties": map[string]interface{}{
+				"latestRevisionName":  "api--rev1",
+				"eventStreamEndpoint": logServer.URL + "/subscriptions/SUBSCRIPTION_ID/resourceGroups/RESOURCE_GROUP/containerApps/api/eventstream",
+			},
+		})
+	})
+	mux.HandleFunc(containerAppPath+"/getAuthToken", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		writeJson(t, w, http.StatusOK, map[string]interface{}{
+			"properties": map[string]interface{}{"token": "LOG_TOKEN"},
+		})
+	})
+	mux.HandleFunc(containerAppPath+"/revisions/api--rev1/replicas", func(w http.ResponseWriter, r *http.Request) {
+		writeJson(t, w, http.StatusOK, map[string]interface{}{
+			"value": []interface{}{
+				map[string]interface{}{
+					"name": "REPLICA",
+					"properties": map[string]interface{}{
+						"containers": []interface{}{map[string]interface{}{"name": "main"}},
+					},
+				},
+			},
+		})
+	})
+
+	_, cli := newFakeArmServer(t, mux)
+
+	var out strings.Builder
+	err := cli.StreamContainerAppLogs(context.Background(), "SUBSCRIPTION_ID", "RESOURCE_GROUP", "api", &out)
+	require.NoError(t, err)
+	require.Equal(t, "first\nsecond\n", out.String())
+}
+
+func TestAzRestCliQueryLogs(t *testing.T) {
+	tests := map[string]string{
+		"/subscriptions/SUBSCRIPTION_ID/resourceGroups/RESOURCE_GROUP/providers/Microsoft.Insights/components/appi":           "/query",
+		"/subscriptions/SUBSCRIPTION_ID/resourceGroups/RESOURCE_GROUP/providers/Microsoft.OperationalInsights/workspaces/log": "/api/query",
+	}
+
+	for resourceId, querySuffix := range tests {
+		mux := http.NewServeMux()
+		mux.HandleFunc(resourceId+querySuffix, func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, http.MethodPost, r.Method)
+
+			var body map[string]string
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			require.Equal(t, map[string]string{"query": "exceptions | take 1", "timespan": "PT3600S"}, body)
+
+			writeJson(t, w, http.StatusOK, map[string]interface{}{
+				"tables": []interface{}{
+					map[string]interface{}{
+						"name":    "PrimaryResult",
+						"columns": []interface{}{map[string]interface{}{"name": "type", "type": "string"}},
+						"rows":    [][]interface{}{{"System.Exception"}},
+					},
+				},
+			})
+		})
+
+		_, cli := newFakeArmServer(t, mux)
+
+		result, err := cli.QueryLogs(context.Background(), resourceId, "exceptions | take 1", "PT3600S")
+		require.NoError(t, err)
+		require.Len(t, result.Tables, 1)
+		require.Equal(t, "type", result.Tables[0].Columns[0].Name)
+		require.Equal(t, [][]interface{}{{"System.Exception"}}, result.Tables[0].Rows)
+	}
+}
+
+func TestAzRestCliWithoutCredential(t *testing.T) {
+	cli := NewAzRestCli(NewAzRestCliArgs{})
+
+	_, err := cli.GetAccessToken(context.Background())
+	require.True(t, errors.Is(err, ErrNoCredential))
+
+	// Neither requests nor templates depend on the Azure CLI.
+	require.IsType(t, &standaloneBicepCli{}, NewBicepCli(cli))
+}
@@ -8,6 +8,8 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 	"regexp"
 	"testing"
 
@@ -138,3 +140,45 @@ func runAndCaptureUserAgent(t *testing.T, azcli *azCli, subscriptionID string) s
 
 	return matches[0][1]
 }
+
+func TestListBlobs(t *testing.T) {
+	pages := map[string]string{
+		"": `<?xml version="1.0" encoding="utf-8"?>
+<EnumerationResults><Blobs><Blob><Name>project/dev.json</Name><Properties><Etag>"0x1"</Etag></Properties></Blob></Blobs><NextMarker>next</NextMarker></EnumerationResults>`,
+		"next": `<?xml version="1.0" encoding="utf-8"?>
+<EnumerationResults><Blobs><Blob><Name>project/prod.json</Name><Properties><Etag>"0x2"</Etag></Properties></Blob></Blobs><NextMarker /></EnumerationResults>`,
+	}
+
+	blobs, err := listBlobs("account", "container", "project/", func(listUrl string) ([]byte, error) {
+		parsed, err := url.Parse(listUrl)
+		require.NoError(t, err)
+		require.Equal(t, "account.blob.core.windows.net", parsed.Host)
+		require.Equal(t, "/container", parsed.Path)
+		require.Equal(t, "project/", parsed.Query().Get("prefix"))
+
+		return []byte(pages[parsed.Query().Get("marker")]), nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []AzCliBlob{
+		{Name: "project/dev.json", ETag: `"0x1"`},
+		{Name: "project/prod.json", ETag: `"0x2"`},
+	}, blobs)
+}
+
+func TestBlobError(t *testing.T) {
+	require.Equal(t, ErrBlobNotFound, blobError(newAzRestError(http.StatusNotFound, nil)))
+	require.Equal(t, ErrBlobModified, blobError(newAzRestError(http.StatusPreconditionFailed, nil)))
+	require.Equal(t, ErrBlobModified, blobError(newAzRestError(http.StatusConflict, nil)))
+
+	err := newAzRestError(http.StatusForbidden, nil)
+	require.Equal(t, err, blobError(err))
+
+	require.Equal(t, map[string]string{
+		"x-ms-version":   blobApiVersion,
+		"Accept":         "application/xml",
+		"x-ms-blob-type": "BlockBlob",
+		"Content-Type":   "application/octet-stream",
+		"If-None-Match":  "*",
+	}, putBlobHeaders(""))
+	require.Equal(t, `"0x1"`, putBlobHeaders(`"0x1"`)["If-Match"])
+}
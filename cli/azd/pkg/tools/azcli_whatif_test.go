@@ -0,0 +1,72 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/executil"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WhatIfDeployToSubscription(t *testing.T) {
+	tempAZCLI := NewAzCli(NewAzCliArgs{
+		EnableDebug:     false,
+		EnableTelemetry: true,
+	})
+	azcli := tempAZCLI.(*azCli)
+
+	t.Run("NoErrors", func(t *testing.T) {
+		azcli.runWithResultFn = func(ctx context.Context, args executil.RunArgs) (executil.RunResult, error) {
+			require.Equal(t, []string{
+				"deployment", "sub", "what-if",
+				"--subscription", "subID",
+				"--name", "deploymentName",
+				"--location", "westus2",
+				"--template-file", "main.bicep",
+				"--parameters", "@main.parameters.json",
+				"--no-pretty-print",
+				"--output", "json",
+			}, args.Args)
+
+			return executil.RunResult{
+				Stdout: `{
+  "status": "Succeeded",
+  "changes": [
+    {
+      "resourceId": "/subscriptions/subID/resourceGroups/rg/providers/Microsoft.Web/sites/web",
+      "changeType": "Modify",
+      "delta": [
+        {"path": "properties.httpsOnly", "propertyChangeType": "Modify", "before": false, "after": true}
+      ]
+    }
+  ]
+}`,
+			}, nil
+		}
+
+		res, err := azcli.WhatIfDeployToSubscription(
+			context.Background(), "subID", "deploymentName", "main.bicep", "main.parameters.json", "westus2")
+		require.NoError(t, err)
+		require.Equal(t, "Succeeded", res.Status)
+		require.Len(t, res.Changes, 1)
+		require.Equal(t, "Modify", res.Changes[0].ChangeType)
+		require.Equal(t, []AzCliWhatIfPropertyChange{
+			{Path: "properties.httpsOnly", PropertyChangeType: "Modify", Before: false, After: true},
+		}, res.Changes[0].Delta)
+	})
+
+	t.Run("OperationError", func(t *testing.T) {
+		azcli.runWithResultFn = func(ctx context.Context, args executil.RunArgs) (executil.RunResult, error) {
+			return executil.RunResult{
+				Stdout: `{"status": "Failed", "error": {"code": "InvalidTemplate", "message": "the template is invalid"}}`,
+			}, nil
+		}
+
+		_, err := azcli.WhatIfDeployToSubscription(
+			context.Background(), "subID", "deploymentName", "main.bicep", "main.parameters.json", "westus2")
+		require.EqualError(t, err, "az deployment sub what-if failed: InvalidTemplate: the template is invalid")
+	})
+}
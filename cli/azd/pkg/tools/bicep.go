@@ -19,12 +19,24 @@ type BicepCli interface {
 	Build(ctx context.Context, file string) (string, error)
 }
 
+// NewBicepCli creates the BicepCli used along with cli. The bicep CLI of the Azure CLI is used, unless cli calls Azure
+// directly, in which case the standalone bicep CLI is used, so the Azure CLI is not required.
 func NewBicepCli(cli AzCli) BicepCli {
+	if restCli, ok := cli.(*azRestCli); ok {
+		return restCli.bicepCli
+	}
+
 	return &bicepCli{
 		cli: cli,
 	}
 }
 
+// NewStandaloneBicepCli creates a BicepCli which runs the standalone bicep CLI, instead of the bicep CLI of the Azure
+// CLI.
+func NewStandaloneBicepCli() BicepCli {
+	return &standaloneBicepCli{}
+}
+
 type bicepCli struct {
 	cli AzCli
 }
@@ -127,3 +139,57 @@ func (cli *bicepCli) Build(ctx context.Context, file string) (string, error) {
 	}
 	return buildRes.Stdout, nil
 }
+
+type standaloneBicepCli struct{}
+
+func (cli *standaloneBicepCli) Name() string {
+	return "Bicep CLI"
+}
+
+func (cli *standaloneBicepCli) InstallUrl() string {
+	return "https://aka.ms/azure-dev/bicep-install"
+}
+
+func (cli *standaloneBicepCli) versionInfo() VersionInfo {
+	return VersionInfo{
+		MinimumVersion: semver.Version{
+			Major: 0,
+			Minor: 8,
+			Patch: 9},
+		UpdateCommand: "Install the latest version from https://aka.ms/azure-dev/bicep-install to upgrade",
+	}
+}
+
+func (cli *standaloneBicepCli) CheckInstalled(ctx context.Context) (bool, error) {
+	found, err := toolInPath("bicep")
+	if !found {
+		return false, err
+	}
+
+	bicepRes, err := executeCommand(ctx, "bicep", "--version")
+	if err != nil {
+		return false, fmt.Errorf("checking %s version: %w", cli.Name(), err)
+	}
+	bicepSemver, err := extractSemver(bicepRes)
+	if err != nil {
+		return false, fmt.Errorf("converting to semver version fails: %w", err)
+	}
+	updateDetail := cli.versionInfo()
+	if bicepSemver.LT(updateDetail.MinimumVersion) {
+		return false, &ErrSemver{ToolName: cli.Name(), versionInfo: updateDetail}
+	}
+
+	return true, nil
+}
+
+func (cli *standaloneBicepCli) Build(ctx context.Context, file string) (string, error) {
+	buildRes, err := executil.RunCommandWithShell(ctx, "bicep", "build", file, "--stdout")
+	if err != nil {
+		return "", fmt.Errorf(
+			"failed running bicep build: %s (%w)",
+			buildRes.String(),
+			err,
+		)
+	}
+	return buildRes.Stdout, nil
+}
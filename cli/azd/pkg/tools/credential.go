@@ -0,0 +1,89 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// ResourceManagerScope is the scope of tokens for Azure Resource Manager.
+	ResourceManagerScope = "https://management.azure.com//.default"
+	// GraphScope is the scope of tokens for Microsoft Graph.
+	GraphScope = "https://graph.microsoft.com//.default"
+	// KeyVaultScope is the scope of tokens for the secrets of Key Vaults.
+	KeyVaultScope = "https://vault.azure.net/.default"
+	// StorageScope is the scope of tokens for the blobs of storage accounts.
+	StorageScope = "https://storage.azure.com/.default"
+)
+
+// TokenCredential provides access tokens used to authenticate requests to Azure.
+type TokenCredential interface {
+	// GetToken returns an access token for the given scope, for example ResourceManagerScope.
+	GetToken(ctx context.Context, scope string) (AzCliAccessToken, error)
+}
+
+// ErrNoCredential is returned when Azure is called directly, instead of with the Azure CLI, but no credential is
+// configured to authenticate the requests.
+var ErrNoCredential = errors.New(
+	"no credential is configured to call Azure without the Azure CLI. Log in with `azd login --client-id <id> " +
+		"--tenant-id <id>` or `azd login --managed-identity`, or set AZURE_CLIENT_ID, AZURE_TENANT_ID and one of " +
+		"AZURE_CLIENT_SECRET, AZURE_CLIENT_CERTIFICATE_PATH or AZURE_FEDERATED_TOKEN_FILE")
+
+// noCredential is the credential of requests to Azure when none is configured, which fails with ErrNoCredential.
+type noCredential struct{}
+
+func (noCredential) GetToken(_ context.Context, _ string) (AzCliAccessToken, error) {
+	return AzCliAccessToken{}, ErrNoCredential
+}
+
+// NewAzCliCredential creates a credential which gets tokens for the account the Azure CLI is logged in to.
+func NewAzCliCredential(args NewAzCliArgs) TokenCredential {
+	return &azCliCredential{
+		cli: NewAzCli(args).(*azCli),
+	}
+}
+
+// azCliCredential gets tokens from the Azure CLI. Since the Azure CLI is used, it is also an ExternalTool, and
+// supports interactive login.
+type azCliCredential struct {
+	cli *azCli
+}
+
+func (cred *azCliCredential) GetToken(ctx context.Context, scope string) (AzCliAccessToken, error) {
+	res, err := cred.cli.runAzCommand(ctx, "account", "get-access-token", "--scope", scope, "--output", "json")
+	if isNotLoggedInMessage(res.Stderr) {
+		return AzCliAccessToken{}, ErrAzCliNotLoggedIn
+	} else if isRefreshTokenExpiredMessage(res.Stderr) {
+		return AzCliAccessToken{}, ErrAzCliRefreshTokenExpired
+	} else if err != nil {
+		return AzCliAccessToken{}, fmt.Errorf("failed running az account get-access-token: %s: %w", res.String(), err)
+	}
+
+	var accessToken AzCliAccessToken
+	if err := json.Unmarshal([]byte(res.Stdout), &accessToken); err != nil {
+		return AzCliAccessToken{}, fmt.Errorf("could not unmarshal output %s as a AzCliAccessToken: %w", res.Stdout, err)
+	}
+	return accessToken, nil
+}
+
+func (cred *azCliCredential) Login(ctx context.Context, useDeviceCode bool, deviceCodeWriter io.Writer) error {
+	return cred.cli.Login(ctx, useDeviceCode, deviceCodeWriter)
+}
+
+func (cred *azCliCredential) CheckInstalled(ctx context.Context) (bool, error) {
+	return cred.cli.CheckInstalled(ctx)
+}
+
+func (cred *azCliCredential) InstallUrl() string {
+	return cred.cli.InstallUrl()
+}
+
+func (cred *azCliCredential) Name() string {
+	return cred.cli.Name()
+}
@@ -53,6 +53,22 @@ func (d *Docker) Tag(ctx context.Context, cwd string, imageName string, tag stri
 	return nil
 }
 
+// Login logs docker into a container registry. The password is passed on stdin, so it does not appear in the
+// arguments of the process.
+func (d *Docker) Login(ctx context.Context, loginServer string, username string, password string) error {
+	res, err := d.runWithResultFn(ctx, executil.RunArgs{
+		Cmd:         "docker",
+		Args:        []string{"login", loginServer, "--username", username, "--password-stdin"},
+		Stdin:       strings.NewReader(password),
+		EnrichError: true,
+	})
+	if err != nil {
+		return fmt.Errorf("logging into registry: %s: %w", res.String(), err)
+	}
+
+	return nil
+}
+
 func (d *Docker) Push(ctx context.Context, cwd string, tag string) error {
 	res, err := d.executeCommand(ctx, cwd, "push", tag)
 	if err != nil {
@@ -4,11 +4,14 @@
 package tools
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/azure/azure-dev/cli/azd/pkg/executil"
@@ -19,6 +22,15 @@ type GitHubCli interface {
 	ExternalTool
 	CheckAuth(ctx context.Context, hostname string) (bool, error)
 	SetSecret(ctx context.Context, repo string, name string, value string) error
+	// SetVariable sets a GitHub Actions variable of the repository, which unlike a secret is not encrypted.
+	SetVariable(ctx context.Context, repo string, name string, value string) error
+	// CreateOrUpdateEnvironment creates a GitHub environment of the repository. When reviewers are given, they replace
+	// the required reviewers of the environment. Reviewers are user names, or teams written as `organization/team`.
+	CreateOrUpdateEnvironment(ctx context.Context, repo string, environment string, reviewers []string) error
+	// SetEnvironmentSecret sets a secret which is only available to the jobs deploying to a GitHub environment.
+	SetEnvironmentSecret(ctx context.Context, repo string, environment string, name string, value string) error
+	// SetEnvironmentVariable sets a variable which is only available to the jobs deploying to a GitHub environment.
+	SetEnvironmentVariable(ctx context.Context, repo string, environment string, name string, value string) error
 	Login(ctx context.Context, hostname string) error
 	ListRepositories(ctx context.Context) ([]GhCliRepository, error)
 	ViewRepository(ctx context.Context, name string) (GhCliRepository, error)
@@ -113,6 +125,91 @@ func (cli *ghCli) SetSecret(ctx context.Context, repoSlug string, name string, v
 	return nil
 }
 
+func (cli *ghCli) SetVariable(ctx context.Context, repoSlug string, name string, value string) error {
+	return cli.setVariable(ctx, fmt.Sprintf("/repos/%s/actions/variables", repoSlug), name, value)
+}
+
+func (cli *ghCli) SetEnvironmentSecret(ctx context.Context, repoSlug string, environment string, name string, value string) error {
+	res, err := executil.RunCommand(ctx, "gh", "-R", repoSlug, "secret", "set", name, "--env", environment, "--body", value)
+	if isGhCliNotLoggedInMessageRegex.MatchString(res.Stderr) {
+		return ErrGitHubCliNotLoggedIn
+	} else if err != nil {
+		return fmt.Errorf("failed running gh secret set %s: %w", res.String(), err)
+	}
+	return nil
+}
+
+func (cli *ghCli) SetEnvironmentVariable(ctx context.Context, repoSlug string, environment string, name string, value string) error {
+	return cli.setVariable(ctx, fmt.Sprintf("/repos/%s/environments/%s/variables", repoSlug, url.PathEscape(environment)), name, value)
+}
+
+// setVariable sets a variable of the collection at path. `gh variable set` is not available in the minimum supported
+// version, so the REST API is called instead. The variable is updated, and created when it does not exist yet.
+func (cli *ghCli) setVariable(ctx context.Context, path string, name string, value string) error {
+	res, err := executil.RunCommand(ctx, "gh", "api", "--method", "PATCH", path+"/"+name, "-f", "name="+name, "-f", "value="+value)
+	if err != nil && ghApiNotFoundMessageRegex.MatchString(res.Stderr) {
+		res, err = executil.RunCommand(ctx, "gh", "api", "--method", "POST", path, "-f", "name="+name, "-f", "value="+value)
+	}
+
+	if isGhCliNotLoggedInMessageRegex.MatchString(res.Stderr) {
+		return ErrGitHubCliNotLoggedIn
+	} else if err != nil {
+		return fmt.Errorf("failed setting variable %s %s: %w", name, res.String(), err)
+	}
+	return nil
+}
+
+func (cli *ghCli) CreateOrUpdateEnvironment(ctx context.Context, repoSlug string, environment string, reviewers []string) error {
+	type reviewer struct {
+		Type string `json:"type"`
+		Id   int    `json:"id"`
+	}
+
+	body := map[string]interface{}{}
+	if len(reviewers) > 0 {
+		ids := make([]reviewer, len(reviewers))
+		for i, name := range reviewers {
+			// Teams are written as `organization/team`, users by their login.
+			reviewerType, path := "User", "/users/"+name
+			if org, team, isTeam := strings.Cut(name, "/"); isTeam {
+				reviewerType, path = "Team", fmt.Sprintf("/orgs/%s/teams/%s", org, team)
+			}
+
+			res, err := executil.RunCommand(ctx, "gh", "api", path, "--jq", ".id")
+			if isGhCliNotLoggedInMessageRegex.MatchString(res.Stderr) {
+				return ErrGitHubCliNotLoggedIn
+			} else if err != nil {
+				return fmt.Errorf("failed getting reviewer %s %s: %w", name, res.String(), err)
+			}
+
+			id, err := strconv.Atoi(strings.TrimSpace(res.Stdout))
+			if err != nil {
+				return fmt.Errorf("could not parse id %s of reviewer %s: %w", res.Stdout, name, err)
+			}
+
+			ids[i] = reviewer{Type: reviewerType, Id: id}
+		}
+		body["reviewers"] = ids
+	}
+
+	input, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshalling environment: %w", err)
+	}
+
+	res, err := executil.RunWithResult(ctx, executil.RunArgs{
+		Cmd:   "gh",
+		Args:  []string{"api", "--method", "PUT", fmt.Sprintf("/repos/%s/environments/%s", repoSlug, url.PathEscape(environment)), "--input", "-"},
+		Stdin: bytes.NewReader(input),
+	})
+	if isGhCliNotLoggedInMessageRegex.MatchString(res.Stderr) {
+		return ErrGitHubCliNotLoggedIn
+	} else if err != nil {
+		return fmt.Errorf("failed creating environment %s %s: %w", environment, res.String(), err)
+	}
+	return nil
+}
+
 type GhCliRepository struct {
 	// The slug for a repository (formatted as "<owner>/<name>")
 	NameWithOwner string
@@ -208,4 +305,5 @@ func (cli *ghCli) GitHubActionsExists(ctx context.Context, repoSlug string) (boo
 
 var isGhCliNotLoggedInMessageRegex = regexp.MustCompile("(To authenticate, please run `gh auth login`\\.)|(Try authenticating with:  gh auth login)|(To re-authenticate, run: gh auth login)")
 var repositoryNameInUseRegex = regexp.MustCompile("GraphQL: Name already exists on this account (createRepository)")
+var ghApiNotFoundMessageRegex = regexp.MustCompile(`\(HTTP 404\)`)
 var notLoggedIntoAnyGitHubHostsMessageRegex = regexp.MustCompile("You are not logged into any GitHub hosts. Run gh auth login to authenticate.")
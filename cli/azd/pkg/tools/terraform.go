@@ -0,0 +1,182 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/executil"
+	"github.com/blang/semver/v4"
+)
+
+type TerraformCli interface {
+	ExternalTool
+	// Init initializes the working directory containing the terraform configuration.
+	Init(ctx context.Context, modulePath string, additionalArgs ...string) (string, error)
+	// Plan creates an execution plan and saves it to planFilePath.
+	Plan(ctx context.Context, modulePath string, planFilePath string, additionalArgs ...string) (string, error)
+	// Apply applies the changes of a saved execution plan.
+	Apply(ctx context.Context, modulePath string, planFilePath string, additionalArgs ...string) (string, error)
+	// Destroy destroys all of the resources managed by the terraform configuration.
+	Destroy(ctx context.Context, modulePath string, additionalArgs ...string) (string, error)
+	// Output returns the values of the outputs from the state.
+	Output(ctx context.Context, modulePath string, additionalArgs ...string) (map[string]TerraformOutput, error)
+}
+
+// TerraformOutput is a single output value, as returned by `terraform output -json`.
+type TerraformOutput struct {
+	Sensitive bool            `json:"sensitive"`
+	Type      json.RawMessage `json:"type"`
+	Value     interface{}     `json:"value"`
+}
+
+type TerraformCliArgs struct {
+	// Env is a list of additional environment variables, in the form KEY=VALUE, to set when running terraform.
+	Env []string
+	// RunWithResultFn allows us to stub out the command execution for testing
+	RunWithResultFn func(ctx context.Context, args executil.RunArgs) (executil.RunResult, error)
+}
+
+func NewTerraformCli(args TerraformCliArgs) TerraformCli {
+	if args.RunWithResultFn == nil {
+		args.RunWithResultFn = executil.RunWithResult
+	}
+
+	return &terraformCli{
+		env:             args.Env,
+		runWithResultFn: args.RunWithResultFn,
+	}
+}
+
+type terraformCli struct {
+	env             []string
+	runWithResultFn func(ctx context.Context, args executil.RunArgs) (executil.RunResult, error)
+}
+
+func (cli *terraformCli) Name() string {
+	return "Terraform CLI"
+}
+
+func (cli *terraformCli) InstallUrl() string {
+	return "https://aka.ms/azure-dev/terraform-install"
+}
+
+func (cli *terraformCli) versionInfo() VersionInfo {
+	return VersionInfo{
+		MinimumVersion: semver.Version{
+			Major: 1,
+			Minor: 1,
+			Patch: 7},
+		UpdateCommand: "Visit https://www.terraform.io/downloads to upgrade",
+	}
+}
+
+func (cli *terraformCli) CheckInstalled(ctx context.Context) (bool, error) {
+	found, err := toolInPath("terraform")
+	if !found {
+		return false, err
+	}
+
+	res, err := cli.runCommand(ctx, "", "version", "-json")
+	if err != nil {
+		return false, fmt.Errorf("checking %s version: %w", cli.Name(), err)
+	}
+
+	var version struct {
+		TerraformVersion string `json:"terraform_version"`
+	}
+
+	if err := json.Unmarshal([]byte(res.Stdout), &version); err != nil {
+		return false, fmt.Errorf("parsing %s version: %w", cli.Name(), err)
+	}
+
+	terraformSemver, err := semver.Parse(version.TerraformVersion)
+	if err != nil {
+		return false, fmt.Errorf("converting to semver version fails: %w", err)
+	}
+
+	updateDetail := cli.versionInfo()
+	if terraformSemver.LT(updateDetail.MinimumVersion) {
+		return false, &ErrSemver{ToolName: cli.Name(), versionInfo: updateDetail}
+	}
+
+	return true, nil
+}
+
+func (cli *terraformCli) Init(ctx context.Context, modulePath string, additionalArgs ...string) (string, error) {
+	args := append([]string{"init", "-input=false", "-upgrade"}, additionalArgs...)
+	res, err := cli.runCommand(ctx, modulePath, args...)
+	if err != nil {
+		return "", fmt.Errorf("failed running terraform init: %s: %w", res.String(), err)
+	}
+
+	return res.Stdout, nil
+}
+
+func (cli *terraformCli) Plan(ctx context.Context, modulePath string, planFilePath string, additionalArgs ...string) (string, error) {
+	args := append([]string{"plan", "-input=false", "-out=" + planFilePath}, additionalArgs...)
+	res, err := cli.runCommand(ctx, modulePath, args...)
+	if err != nil {
+		return "", fmt.Errorf("failed running terraform plan: %s: %w", res.String(), err)
+	}
+
+	return res.Stdout, nil
+}
+
+func (cli *terraformCli) Apply(ctx context.Context, modulePath string, planFilePath string, additionalArgs ...string) (string, error) {
+	args := append([]string{"apply", "-input=false", "-auto-approve"}, additionalArgs...)
+	args = append(args, planFilePath)
+	res, err := cli.runCommand(ctx, modulePath, args...)
+	if err != nil {
+		return "", fmt.Errorf("failed running terraform apply: %s: %w", res.String(), err)
+	}
+
+	return res.Stdout, nil
+}
+
+func (cli *terraformCli) Destroy(ctx context.Context, modulePath string, additionalArgs ...string) (string, error) {
+	args := append([]string{"destroy", "-input=false", "-auto-approve"}, additionalArgs...)
+	res, err := cli.runCommand(ctx, modulePath, args...)
+	if err != nil {
+		return "", fmt.Errorf("failed running terraform destroy: %s: %w", res.String(), err)
+	}
+
+	return res.Stdout, nil
+}
+
+func (cli *terraformCli) Output(ctx context.Context, modulePath string, additionalArgs ...string) (map[string]TerraformOutput, error) {
+	args := append([]string{"output", "-json"}, additionalArgs...)
+	res, err := cli.runCommand(ctx, modulePath, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed running terraform output: %s: %w", res.String(), err)
+	}
+
+	outputs := map[string]TerraformOutput{}
+	if strings.TrimSpace(res.Stdout) == "" {
+		return outputs, nil
+	}
+
+	if err := json.Unmarshal([]byte(res.Stdout), &outputs); err != nil {
+		return nil, fmt.Errorf("parsing terraform output: %w", err)
+	}
+
+	return outputs, nil
+}
+
+func (cli *terraformCli) runCommand(ctx context.Context, modulePath string, args ...string) (executil.RunResult, error) {
+	// -chdir must come before the subcommand
+	if modulePath != "" {
+		args = append([]string{"-chdir=" + modulePath}, args...)
+	}
+
+	return cli.runWithResultFn(ctx, executil.RunArgs{
+		Cmd:         "terraform",
+		Args:        args,
+		Env:         append([]string{"TF_IN_AUTOMATION=1"}, cli.env...),
+		EnrichError: true,
+	})
+}
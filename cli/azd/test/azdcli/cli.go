@@ -11,6 +11,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
@@ -28,6 +29,9 @@ type CLI struct {
 	WorkingDirectory string
 	ConfigFilePath   string
 	Env              []string
+
+	// Recording, when set, records the interactions of the commands with Azure and tools, or plays them back.
+	Recording *Recording
 }
 
 func NewCLI(t *testing.T) *CLI {
@@ -49,6 +53,12 @@ func (cli *CLI) RunCommandWithStdIn(ctx context.Context, stdin string, args ...s
 	}
 
 	cmd.Env = cli.Env
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+
+	// Commands run live, unless they are recorded, even when the tests are run with AZURE_DEV_RECORD_MODE set.
+	cmd.Env = append(cmd.Env, cli.Recording.env(args)...)
 
 	// we run a background goroutine to report a heartbeat in the logs while the command
 	// is still running. This makes it easy to see what's still in progress if we hit a timeout.
@@ -0,0 +1,155 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package azdcli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/azure/azure-dev/cli/azd/pkg/osutil"
+	"github.com/azure/azure-dev/cli/azd/pkg/recording"
+)
+
+const (
+	// Recordings of a test are kept in this directory, in a folder named after the test
+	recordingsDirectory = "testdata/recordings"
+	variablesFileName   = "variables.json"
+)
+
+// Recording records the interactions of the commands run by a CLI to cassettes, one per command, or plays them back.
+//
+// The mode is set with AZURE_DEV_RECORD_MODE. When it is not set, recordings are played back if the test has any, and
+// the test is skipped otherwise, so it never runs against Azure unless live or record mode is requested.
+type Recording struct {
+	T    *testing.T
+	Mode recording.Mode
+	Dir  string
+
+	count     int
+	variables map[string]string
+}
+
+// NewRecording creates the Recording of the test. When the test has no recordings to play back, it is skipped, unless
+// live or record mode is requested.
+func NewRecording(t *testing.T) *Recording {
+	dir, err := filepath.Abs(filepath.Join(recordingsDirectory, t.Name()))
+	if err != nil {
+		t.Fatalf("resolving recordings directory: %v", err)
+	}
+
+	_, err = os.Stat(dir)
+	hasRecordings := err == nil
+
+	mode := recording.Mode(os.Getenv(recording.ModeEnvVarName))
+	switch {
+	case mode == "" && hasRecordings:
+		mode = recording.ModePlayback
+	case (mode == "" || mode == recording.ModePlayback) && !hasRecordings:
+		t.Skipf("%s has no recordings to play back, set %s to %s or %s to run it against Azure",
+			t.Name(), recording.ModeEnvVarName, recording.ModeLive, recording.ModeRecord)
+	case mode == recording.ModeRecord:
+		// Recordings are replaced, so they never mix interactions of different runs.
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("removing recordings: %v", err)
+		}
+	}
+
+	r := &Recording{T: t, Mode: mode, Dir: dir, variables: map[string]string{}}
+
+	if mode == recording.ModePlayback {
+		bytes, err := ioutil.ReadFile(filepath.Join(dir, variablesFileName))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("reading recorded variables: %v", err)
+		} else if err == nil {
+			if err := json.Unmarshal(bytes, &r.variables); err != nil {
+				t.Fatalf("parsing recorded variables: %v", err)
+			}
+		}
+	}
+
+	return r
+}
+
+// Variable returns a value generated by the test, such as a random environment name. The value is saved when
+// recording, and the saved value is returned in playback, so commands run with the same arguments.
+func (r *Recording) Variable(name string, generate func() string) string {
+	if r.Mode == recording.ModePlayback {
+		value, has := r.variables[name]
+		if !has {
+			r.T.Fatalf("variable %s was not recorded", name)
+		}
+
+		return value
+	}
+
+	value := generate()
+
+	if r.Mode == recording.ModeRecord {
+		r.variables[name] = value
+
+		bytes, err := json.MarshalIndent(r.variables, "", "  ")
+		if err != nil {
+			r.T.Fatalf("marshalling recorded variables: %v", err)
+		}
+
+		if err := os.MkdirAll(r.Dir, osutil.PermissionDirectory); err != nil {
+			r.T.Fatalf("creating recordings directory: %v", err)
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(r.Dir, variablesFileName), bytes, osutil.PermissionFile); err != nil {
+			r.T.Fatalf("writing recorded variables: %v", err)
+		}
+	}
+
+	return value
+}
+
+// WithContext returns a context whose commands and HTTP requests are recorded or played back, for the interactions of
+// the test itself, such as checking the resources created by azd. cwd is the working directory of the test.
+func (r *Recording) WithContext(ctx context.Context, cwd string) context.Context {
+	if r.Mode == recording.ModeLive {
+		return ctx
+	}
+
+	recorder, err := recording.NewRecorder(r.Mode, filepath.Join(r.Dir, "test.json"), cwd)
+	if err != nil {
+		r.T.Fatalf("creating recorder: %v", err)
+	}
+
+	r.T.Cleanup(func() {
+		if err := recorder.Save(); err != nil {
+			r.T.Errorf("saving recording: %v", err)
+		}
+	})
+
+	return recorder.WithContext(ctx)
+}
+
+// env returns the environment variables which record the next command, or play it back. Without a Recording,
+// commands run live.
+func (r *Recording) env(args []string) []string {
+	if r == nil || r.Mode == recording.ModeLive {
+		return []string{fmt.Sprintf("%s=%s", recording.ModeEnvVarName, recording.ModeLive)}
+	}
+
+	r.count++
+
+	name := fmt.Sprintf("%02d", r.count)
+	if len(args) > 0 {
+		name += "-" + args[0]
+	}
+
+	return []string{
+		fmt.Sprintf("%s=%s", recording.ModeEnvVarName, r.Mode),
+		fmt.Sprintf("%s=%s", recording.CassetteEnvVarName, filepath.Join(r.Dir, name+".json")),
+	}
+}
@@ -270,6 +270,47 @@ func Test_CLI_InfraCreateAndDelete(t *testing.T) {
 	require.NoError(t, err)
 }
 
+// Test_CLI_UpAndDown runs `azd up` and `azd down` end-to-end. It plays back the interactions with Azure recorded in
+// testdata/recordings, so it runs offline, and is skipped when there are none. Run it with AZURE_DEV_RECORD_MODE=record
+// to record them again, or with AZURE_DEV_RECORD_MODE=live to run it against Azure without recording.
+func Test_CLI_UpAndDown(t *testing.T) {
+	ctx, cancel := newTestContext(t)
+	defer cancel()
+
+	dir := t.TempDir()
+	t.Logf("DIR: %s", dir)
+
+	cli := azdcli.NewCLI(t)
+	cli.WorkingDirectory = dir
+	cli.Env = append(os.Environ(), "AZURE_LOCATION=eastus2")
+	cli.Recording = azdcli.NewRecording(t)
+
+	envName := cli.Recording.Variable("envName", randomEnvName)
+	t.Logf("AZURE_ENV_NAME: %s", envName)
+
+	ctx = cli.Recording.WithContext(ctx, dir)
+
+	err := copySample(dir, "storage")
+	require.NoError(t, err, "failed expanding sample")
+
+	_, err = cli.RunCommandWithStdIn(ctx, stdinForTests(envName), "up")
+	require.NoError(t, err)
+
+	env, err := environment.FromFile(getTestEnvPath(dir, envName))
+	require.NoError(t, err)
+
+	accountName, ok := env.Values["AZURE_STORAGE_ACCOUNT_NAME"]
+	require.True(t, ok)
+	require.Regexp(t, `st\S*`, accountName)
+
+	rgs, err := azureutil.GetResourceGroupsForEnvironment(ctx, &env)
+	require.NoError(t, err)
+	require.NotEmpty(t, rgs)
+
+	_, err = cli.RunCommand(ctx, "down", "--force", "--purge")
+	require.NoError(t, err)
+}
+
 func Test_CLI_InfraCreateAndDeleteWebApp(t *testing.T) {
 	ctx, cancel := newTestContext(t)
 	defer cancel()